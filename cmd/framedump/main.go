@@ -0,0 +1,426 @@
+// Command framedump prints a frame of a recording made with -record: the calls
+// made to the device, and for a draw the program, uniforms, textures and
+// buffers it was made with. Vertices, indices and the members of uniform
+// blocks are printed when buffer data was recorded. It only reads gfx types,
+// so it builds without cgo and runs without a display.
+//
+//	framedump -frame 2 -draw 5 frames.jsonl
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"physics/gfx"
+	"sort"
+)
+
+var (
+	frameNumber = flag.Int("frame", -1, "frame to print, -1 for the last one recorded")
+	drawIndex   = flag.Int("draw", -1, "draw of the frame whose state is printed, -1 to list the calls")
+	vertices    = flag.Int("vertices", 8, "vertices and indices of a draw printed from recorded buffer data")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: framedump [flags] recording.jsonl")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	frame, state, err := readFrame(json.NewDecoder(file), *frameNumber)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *drawIndex < 0 {
+		printCalls(frame)
+		return
+	}
+	if err := printDraw(frame, state, *drawIndex); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// replay is the device state rebuilt from the recorded calls
+type replay struct {
+	buffers map[uint32][]byte
+	// written holds the buffers whose data was recorded
+	written     map[uint32]bool
+	reflections map[uint32]gfx.ProgramReflection
+	// blockBindings maps each program's uniform block indices to the binding
+	// points they were bound to
+	blockBindings map[uint32]map[uint32]uint32
+}
+
+func newReplay() *replay {
+	return &replay{
+		buffers:       make(map[uint32][]byte),
+		written:       make(map[uint32]bool),
+		reflections:   make(map[uint32]gfx.ProgramReflection),
+		blockBindings: make(map[uint32]map[uint32]uint32),
+	}
+}
+
+// readFrame decodes frames up to the one numbered n, or the last, replaying
+// the calls of the frames before it. The state is that at the start of the
+// frame returned.
+func readFrame(decoder *json.Decoder, n int) (*gfx.RecordedFrame, *replay, error) {
+	state := newReplay()
+	var last *gfx.RecordedFrame
+	for {
+		var frame gfx.RecordedFrame
+		if err := decoder.Decode(&frame); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if last != nil {
+			for _, command := range last.Commands {
+				state.apply(command)
+			}
+		}
+		last = &frame
+		if frame.Frame == n {
+			return last, state, nil
+		}
+	}
+	if last == nil || n >= 0 {
+		return nil, nil, fmt.Errorf("frame %d wasn't recorded", n)
+	}
+	return last, state, nil
+}
+
+func (r *replay) apply(command gfx.RecordedCommand) {
+	switch command.Call {
+	case "CreateBuffer":
+		r.buffers[command.Result] = resized(command.Data, intArg(command, "size"))
+		r.written[command.Result] = command.Data != nil
+	case "ReallocateBuffer":
+		buffer := uint32(intArg(command, "buffer"))
+		r.buffers[buffer] = resized(command.Data, intArg(command, "size"))
+		r.written[buffer] = command.Data != nil
+	case "WriteBuffer":
+		buffer := uint32(intArg(command, "buffer"))
+		offset := intArg(command, "offset")
+		if data := r.buffers[buffer]; offset < len(data) {
+			copy(data[offset:], command.Data)
+		}
+		r.written[buffer] = r.written[buffer] || command.Data != nil
+	case "DeleteBuffer":
+		buffer := uint32(intArg(command, "buffer"))
+		delete(r.buffers, buffer)
+		delete(r.written, buffer)
+	case "ReflectProgram":
+		if command.Reflection != nil {
+			r.reflections[uint32(intArg(command, "program"))] = *command.Reflection
+		}
+	case "BindUniformBlock":
+		program := uint32(intArg(command, "program"))
+		if r.blockBindings[program] == nil {
+			r.blockBindings[program] = make(map[uint32]uint32)
+		}
+		r.blockBindings[program][uint32(intArg(command, "blockIndex"))] = uint32(intArg(command, "binding"))
+	case "DeleteProgram":
+		program := uint32(intArg(command, "program"))
+		delete(r.reflections, program)
+		delete(r.blockBindings, program)
+	}
+}
+
+// resized returns size bytes starting with data, zeroed where nothing was
+// recorded
+func resized(data []byte, size int) []byte {
+	buffer := make([]byte, size)
+	copy(buffer, data)
+	return buffer
+}
+
+func intArg(command gfx.RecordedCommand, name string) int {
+	value, _ := command.Args[name].(float64)
+	return int(value)
+}
+
+func printCalls(frame *gfx.RecordedFrame) {
+	fmt.Printf("frame %d: %d calls\n", frame.Frame, len(frame.Commands))
+	draws := 0
+	for i, command := range frame.Commands {
+		fmt.Printf("%5d  %s", i, command.Call)
+		if command.Call == "Draw" {
+			fmt.Printf(" #%d %s", draws, describeDraw(command))
+			draws++
+		} else {
+			printArgs(command.Args)
+		}
+		if command.Result != 0 {
+			fmt.Printf(" -> %d", command.Result)
+		}
+		if command.Error != "" {
+			fmt.Printf(" error: %s", command.Error)
+		}
+		fmt.Println()
+	}
+}
+
+func printArgs(args map[string]interface{}) {
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch value := args[name].(type) {
+		case string:
+			if len(value) > 40 {
+				value = fmt.Sprintf("%.40s... (%d bytes)", value, len(value))
+			}
+			fmt.Printf(" %s=%q", name, value)
+		case map[string]interface{}:
+			// Uniform values
+			if v, ok := value["value"]; ok {
+				fmt.Printf(" %s=%v", name, v)
+				continue
+			}
+			fmt.Printf(" %s=%v", name, value)
+		default:
+			fmt.Printf(" %s=%v", name, value)
+		}
+	}
+}
+
+func describeDraw(command gfx.RecordedCommand) string {
+	call, _ := command.Args["call"].(map[string]interface{})
+	mode, _ := call["Mode"].(float64)
+	s := fmt.Sprintf("%s count=%v vertexArray=%v", gfx.PrimitiveMode(mode), call["Count"], call["VertexArray"])
+	if call["Indexed"] == true {
+		s += " indexed"
+	}
+	if instances := call["Instances"].(float64); instances > 0 {
+		s += fmt.Sprintf(" instances=%v", instances)
+	}
+	if command.State != nil {
+		s += fmt.Sprintf(" program=%d framebuffer=%d", command.State.Program, command.State.Framebuffer)
+	}
+	return s
+}
+
+// printDraw prints the nth draw of frame with the buffers' contents at the
+// time it was made, replaying the frame's calls before it on state
+func printDraw(frame *gfx.RecordedFrame, state *replay, n int) error {
+	draws := 0
+	for i, command := range frame.Commands {
+		if command.Call != "Draw" {
+			state.apply(command)
+			continue
+		}
+		if draws != n {
+			draws++
+			continue
+		}
+		draw := command.State
+		fmt.Printf("frame %d draw #%d (call %d): %s\n", frame.Frame, n, i, describeDraw(command))
+		fmt.Printf("viewport %v\n", draw.Viewport)
+		fmt.Printf("pipeline %+v\n", draw.Pipeline)
+
+		fmt.Println("uniforms:")
+		names := make([]string, 0, len(draw.Uniforms))
+		for name := range draw.Uniforms {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			uniform := draw.Uniforms[name]
+			fmt.Printf("  %-24s %-14s %s\n", name, uniform.Type, uniform.Value)
+		}
+
+		fmt.Println("textures:")
+		units := make([]int, 0, len(draw.Textures))
+		for unit := range draw.Textures {
+			units = append(units, unit)
+		}
+		sort.Ints(units)
+		for _, unit := range units {
+			texture := draw.Textures[unit]
+			fmt.Printf("  unit %d: texture %d target %d\n", unit, texture.Texture, texture.Target)
+		}
+
+		fmt.Println("uniform buffers:")
+		bindings := make([]int, 0, len(draw.UniformBuffers))
+		for binding := range draw.UniformBuffers {
+			bindings = append(bindings, int(binding))
+		}
+		sort.Ints(bindings)
+		for _, binding := range bindings {
+			buffer := draw.UniformBuffers[uint32(binding)]
+			fmt.Printf("  binding %d: buffer %d (%d bytes)\n", binding, buffer, len(state.buffers[buffer]))
+		}
+
+		printUniformBlocks(draw, state)
+
+		printVertices(draw.VertexLayout, state.buffers)
+		return nil
+	}
+	return fmt.Errorf("frame %d has %d draws", frame.Frame, draws)
+}
+
+// printVertices prints each attribute of the first vertices, and the first
+// indices, from the buffers' recorded contents
+func printVertices(layout gfx.VertexLayout, buffers map[uint32][]byte) {
+	fmt.Println("vertex attributes:")
+	for _, attribute := range layout.Attributes {
+		data := buffers[attribute.Buffer]
+		fmt.Printf("  location %d: buffer %d (%d bytes), %d components, stride %d, offset %d, divisor %d\n",
+			attribute.Location, attribute.Buffer, len(data), attribute.Components,
+			attribute.Stride, attribute.Offset, attribute.Divisor)
+		stride := int(attribute.Stride)
+		if stride == 0 {
+			stride = int(attribute.Components) * 4
+		}
+		for i := 0; i < *vertices; i++ {
+			start := attribute.Offset + i*stride
+			if start+int(attribute.Components)*4 > len(data) {
+				break
+			}
+			values := make([]float32, attribute.Components)
+			for c := range values {
+				bits := binary.LittleEndian.Uint32(data[start+c*4:])
+				values[c] = math.Float32frombits(bits)
+			}
+			fmt.Printf("    [%d] %v\n", i, values)
+		}
+	}
+	if layout.IndexBuffer != 0 {
+		data := buffers[layout.IndexBuffer]
+		fmt.Printf("index buffer %d (%d bytes):", layout.IndexBuffer, len(data))
+		for i := 0; i < *vertices && (i+1)*4 <= len(data); i++ {
+			fmt.Printf(" %d", binary.LittleEndian.Uint32(data[i*4:]))
+		}
+		fmt.Println()
+	}
+}
+
+// printUniformBlocks prints the members of the draw program's uniform blocks
+// from the buffers bound to them, laid out by the program's reflection
+func printUniformBlocks(draw *gfx.DrawState, state *replay) {
+	reflection, ok := state.reflections[draw.Program]
+	if !ok {
+		return
+	}
+	fmt.Println("uniform blocks:")
+	for _, block := range reflection.UniformBlocks {
+		binding := uint32(block.Binding)
+		if bound, ok := state.blockBindings[draw.Program][block.Index]; ok {
+			binding = bound
+		}
+		buffer, ok := draw.UniformBuffers[binding]
+		fmt.Printf("  %s (binding %d, %d bytes): ", block.Name, binding, block.DataSize)
+		switch {
+		case !ok:
+			fmt.Println("no buffer bound")
+			continue
+		case !state.written[buffer]:
+			fmt.Printf("buffer %d, no data recorded\n", buffer)
+			continue
+		}
+		fmt.Printf("buffer %d\n", buffer)
+
+		var members []gfx.ShaderUniform
+		for _, uniform := range reflection.Uniforms {
+			if uniform.BlockIndex == int32(block.Index) {
+				members = append(members, uniform)
+			}
+		}
+		sort.Slice(members, func(i, j int) bool { return members[i].Offset < members[j].Offset })
+		data := state.buffers[buffer]
+		for _, member := range members {
+			fmt.Printf("    %-28s %-8s %s\n", member.Name, member.Type, std140Value(data, member))
+		}
+	}
+}
+
+// std140Shapes gives the columns and rows of the types a uniform block
+// holds, vectors having one column
+var std140Shapes = map[gfx.DataType][2]int{
+	gfx.TypeFloat: {1, 1}, gfx.TypeVec2: {1, 2}, gfx.TypeVec3: {1, 3}, gfx.TypeVec4: {1, 4},
+	gfx.TypeInt: {1, 1}, gfx.TypeIVec2: {1, 2}, gfx.TypeIVec3: {1, 3}, gfx.TypeIVec4: {1, 4},
+	gfx.TypeUint: {1, 1}, gfx.TypeUVec2: {1, 2}, gfx.TypeUVec3: {1, 3}, gfx.TypeUVec4: {1, 4},
+	gfx.TypeBool: {1, 1}, gfx.TypeBVec2: {1, 2}, gfx.TypeBVec3: {1, 3}, gfx.TypeBVec4: {1, 4},
+	gfx.TypeMat2: {2, 2}, gfx.TypeMat3: {3, 3}, gfx.TypeMat4: {4, 4},
+	gfx.TypeMat2x3: {2, 3}, gfx.TypeMat2x4: {2, 4}, gfx.TypeMat3x2: {3, 2},
+	gfx.TypeMat3x4: {3, 4}, gfx.TypeMat4x2: {4, 2}, gfx.TypeMat4x3: {4, 3},
+}
+
+// std140Value formats a block member read from data at the offsets its
+// reflection gives, matrices as their columns and arrays element by element
+func std140Value(data []byte, uniform gfx.ShaderUniform) string {
+	shape, ok := std140Shapes[uniform.Type]
+	if !ok {
+		return "(not decoded)"
+	}
+	columns, rows := shape[0], shape[1]
+	size := int(uniform.Size)
+	if size < 1 {
+		size = 1
+	}
+
+	s := ""
+	for element := 0; element < size; element++ {
+		offset := int(uniform.Offset) + element*int(uniform.ArrayStride)
+		if columns == 1 {
+			s += std140Scalars(data, offset, rows, uniform.Type)
+		} else {
+			s += "["
+			for column := 0; column < columns; column++ {
+				if column > 0 {
+					s += " "
+				}
+				s += std140Scalars(data, offset+column*int(uniform.MatrixStride), rows, uniform.Type)
+			}
+			s += "]"
+		}
+		if element+1 < size {
+			s += " "
+		}
+	}
+	return s
+}
+
+// std140Scalars formats n consecutive 4 byte components of type t at offset
+func std140Scalars(data []byte, offset, n int, t gfx.DataType) string {
+	if offset < 0 || offset+n*4 > len(data) {
+		return "(outside the buffer)"
+	}
+	s := "["
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			s += " "
+		}
+		bits := binary.LittleEndian.Uint32(data[offset+i*4:])
+		switch t {
+		case gfx.TypeInt, gfx.TypeIVec2, gfx.TypeIVec3, gfx.TypeIVec4:
+			s += fmt.Sprint(int32(bits))
+		case gfx.TypeUint, gfx.TypeUVec2, gfx.TypeUVec3, gfx.TypeUVec4:
+			s += fmt.Sprint(bits)
+		case gfx.TypeBool, gfx.TypeBVec2, gfx.TypeBVec3, gfx.TypeBVec4:
+			s += fmt.Sprint(bits != 0)
+		default:
+			s += fmt.Sprint(math.Float32frombits(bits))
+		}
+	}
+	return s + "]"
+}
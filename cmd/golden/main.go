@@ -0,0 +1,87 @@
+// Command golden renders the reference scenes headlessly and compares them
+// against their golden images, exiting with status 1 when any differ. Run it
+// from the repository root.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"log"
+	"os"
+	"physics/golden"
+	"regexp"
+	"runtime"
+)
+
+func init() {
+	// GLFW event handling must be run on the main OS thread
+	runtime.LockOSThread()
+}
+
+var contextAPIs = map[string]int{
+	"native": glfw.NativeContextAPI,
+	"egl":    glfw.EGLContextAPI,
+	"osmesa": glfw.OSMesaContextAPI,
+}
+
+func main() {
+	opts := golden.DefaultOptions()
+	flag.StringVar(&opts.GoldenDir, "golden", opts.GoldenDir, "directory of the golden images")
+	flag.StringVar(&opts.OutputDir, "out", opts.OutputDir, "directory the images of failing scenes are written to")
+	flag.BoolVar(&opts.Update, "update", false, "overwrite the golden images with the rendered ones")
+	flag.BoolVar(&opts.Deferred, "deferred", false, "render with the deferred renderer")
+	flag.Float64Var(&opts.Tolerance.Threshold, "threshold", opts.Tolerance.Threshold, "perceptual difference, 0 to 1, below which pixels are equal")
+	flag.Float64Var(&opts.Tolerance.MaxDiffPixels, "max-diff", opts.Tolerance.MaxDiffPixels, "fraction of pixels allowed to differ")
+	api := flag.String("api", "native", "context creation API: native, egl or osmesa")
+	run := flag.String("run", "", "only render the scenes matching this regular expression")
+	flag.Parse()
+
+	contextAPI, ok := contextAPIs[*api]
+	if !ok {
+		log.Fatalf("unknown context API %q", *api)
+	}
+	opts.ContextAPI = contextAPI
+
+	cases := golden.Cases
+	if *run != "" {
+		pattern, err := regexp.Compile(*run)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cases = nil
+		for _, c := range golden.Cases {
+			if pattern.MatchString(c.Name) {
+				cases = append(cases, c)
+			}
+		}
+	}
+
+	defer glfw.Terminate()
+	results, err := golden.Run(opts, cases)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	failed := false
+	for _, result := range results {
+		switch {
+		case result.Updated:
+			fmt.Printf("updated %s\n", result.Case)
+		case result.Passed(opts.Tolerance):
+			fmt.Printf("ok      %s\n", result.Case)
+		case result.Err != nil:
+			failed = true
+			fmt.Printf("FAIL    %s: %v\n", result.Case, result.Err)
+		default:
+			failed = true
+			c := result.Comparison
+			fmt.Printf("FAIL    %s: %.3f%% of pixels differ, max difference %.3f, see %s\n",
+				result.Case, c.DiffFraction()*100, c.MaxDelta, result.Diff)
+		}
+	}
+	if failed {
+		glfw.Terminate()
+		os.Exit(1)
+	}
+}
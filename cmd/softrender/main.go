@@ -0,0 +1,71 @@
+// Command softrender draws an OBJ model lit by one point light with the
+// software rasterizer and writes the image to a PNG, with no OpenGL. It builds
+// without cgo. Run it from the repository root.
+package main
+
+import (
+	"flag"
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"physics/scene"
+	"physics/soft/raster"
+)
+
+func main() {
+	model := flag.String("model", "meshes/sphere.obj", "OBJ file to draw")
+	out := flag.String("out", "soft.png", "PNG file the image is written to")
+	width := flag.Int("width", 640, "image width")
+	height := flag.Int("height", 480, "image height")
+	flag.Parse()
+
+	data, err := os.ReadFile(*model)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Material libraries are ignored, every object is drawn with the
+	// default material
+	objects, err := scene.ParseObj(data, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	renderer := raster.NewRenderer(*width, *height)
+	sky := raster.Sky{Sky: scene.GradientSky()}
+	renderer.BeginFrame(raster.Frame{
+		Lights: []scene.PointLight{{Position: mgl32.Vec3{15, 20, -15}, Color: mgl32.Vec3{1, 1, 1}, Range: 60}},
+		Sky:    &sky,
+	})
+
+	material := scene.DefaultPhong()
+	for _, object := range objects {
+		if len(object.Indices) == 0 {
+			continue
+		}
+		geometry := scene.NewGeometry(object.CombinedVertex, object.Indices)
+		renderer.Submit(raster.Draw{Geometry: &geometry, Model: mgl32.Ident4(), Material: &material})
+	}
+
+	eye := mgl32.Vec3{0, 15, -30}
+	view := mgl32.LookAtV(eye, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
+	projection := mgl32.Perspective(mgl32.DegToRad(45), float32(*width)/float32(*height), 0.1, 100)
+	renderer.Render(eye, view, projection)
+
+	if err := savePNG(*out, renderer.Image); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func savePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// AssetManager deduplicates GPU resources loaded from disk by canonical path
+// and reference counts them, freeing each one when its last user releases it.
+type AssetManager struct {
+	textures     map[string]*textureAsset
+	textureUsers map[*Texture]*textureAsset
+	models       map[string]*modelAsset
+	modelUsers   map[*Model]*modelAsset
+}
+
+type textureAsset struct {
+	key        string
+	texture    *Texture
+	references int
+}
+
+type modelAsset struct {
+	path       string
+	model      *Model
+	references int
+}
+
+// Model is an OBJ file uploaded to the GPU, one Mesh per material of each
+// object in the file
+type Model struct {
+	Path     string
+	Imported *ImportedModel
+	Meshes   []*Mesh
+	// MeshMaterials names the material of the library each of Meshes is
+	// drawn with, "" for faces the file assigns none
+	MeshMaterials []string
+}
+
+// NewMaterial returns a default material for Meshes[i] with the diffuse
+// texture of its material bound. Like NewDefaultMaterial, a material drawing
+// with the error shader is returned with a shader error.
+func (m *Model) NewMaterial(i int) (*Material, error) {
+	material, shaderErr := NewDefaultMaterial()
+	if material == nil {
+		return nil, shaderErr
+	}
+	if _, ok := m.Imported.materialLibrary[m.MeshMaterials[i]]; !ok {
+		return material, shaderErr
+	}
+	texture, err := m.Imported.MaterialTexture(m.MeshMaterials[i])
+	if err != nil {
+		material.Release()
+		return nil, err
+	}
+	if texture != nil {
+		material.SetTexture(texture)
+	}
+	return material, shaderErr
+}
+
+type AssetStats struct {
+	Textures int
+	Shaders  int
+	Models   int
+}
+
+// Assets is the asset manager used by the loaders in this package
+var Assets = NewAssetManager()
+
+func NewAssetManager() *AssetManager {
+	return &AssetManager{
+		textures:     make(map[string]*textureAsset),
+		textureUsers: make(map[*Texture]*textureAsset),
+		models:       make(map[string]*modelAsset),
+		modelUsers:   make(map[*Model]*modelAsset),
+	}
+}
+
+// CanonicalPath resolves path to an absolute path with symlinks evaluated, so
+// different spellings of the same file share one asset.
+func CanonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
+
+// Texture returns the texture at path, loading it on first use. The same file
+// loaded with different options is a separate texture. With
+// PreferCompressedTextures set, a .ktx2 or .dds file with the same base name as
+// path is loaded in its place when present.
+func (a *AssetManager) Texture(path string, opts TextureOptions) (*Texture, error) {
+	path = compressedVariant(path)
+	key := fmt.Sprintf("%s|%+v", CanonicalPath(path), opts)
+	if asset, ok := a.textures[key]; ok {
+		asset.references++
+		return asset.texture, nil
+	}
+
+	texture, err := LoadTexture(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	asset := &textureAsset{key: key, texture: texture, references: 1}
+	a.textures[key] = asset
+	a.textureUsers[texture] = asset
+	return texture, nil
+}
+
+// RetainTexture adds a reference to a texture obtained from Texture
+func (a *AssetManager) RetainTexture(texture *Texture) {
+	if asset, ok := a.textureUsers[texture]; ok {
+		asset.references++
+	}
+}
+
+// ReleaseTexture drops a reference, deleting the texture after the last one.
+// Textures not created by this manager are ignored.
+func (a *AssetManager) ReleaseTexture(texture *Texture) {
+	asset, ok := a.textureUsers[texture]
+	if !ok {
+		return
+	}
+	asset.references--
+	if asset.references > 0 {
+		return
+	}
+
+	delete(a.textures, asset.key)
+	delete(a.textureUsers, texture)
+	texture.Delete()
+}
+
+// Shader returns a shader program variant. Programs are already shared through
+// the shader cache, this only exists so every asset kind is acquired the same way.
+func (a *AssetManager) Shader(vertexShaderPath, fragmentShaderPath string, defines ShaderDefines) (*ShaderProgram, error) {
+	return NewShaderVariant(vertexShaderPath, fragmentShaderPath, defines)
+}
+
+func (a *AssetManager) ReleaseShader(shader *ShaderProgram) {
+	shader.Release()
+}
+
+// Model returns the OBJ file at path with its meshes uploaded, loading it on
+// first use. The textures of its material library are loaded lazily, by
+// NewMaterial.
+func (a *AssetManager) Model(path string) (*Model, error) {
+	canonical := CanonicalPath(path)
+	if asset, ok := a.models[canonical]; ok {
+		asset.references++
+		return asset.model, nil
+	}
+
+	imported, err := LdrParseObj(path)
+	if err != nil {
+		return nil, err
+	}
+
+	model := &Model{Path: canonical, Imported: imported}
+	for _, object := range imported.Objects {
+		if object == nil {
+			continue
+		}
+		for i, group := range object.Groups {
+			model.Meshes = append(model.Meshes, NewMesh(object.Group(i)))
+			model.MeshMaterials = append(model.MeshMaterials, group.Material)
+		}
+	}
+
+	asset := &modelAsset{path: canonical, model: model, references: 1}
+	a.models[canonical] = asset
+	a.modelUsers[model] = asset
+	return model, nil
+}
+
+// ReleaseModel drops a reference to a model, deleting its meshes and releasing
+// its material textures after the last one.
+func (a *AssetManager) ReleaseModel(model *Model) {
+	asset, ok := a.modelUsers[model]
+	if !ok {
+		return
+	}
+	asset.references--
+	if asset.references > 0 {
+		return
+	}
+
+	delete(a.models, asset.path)
+	delete(a.modelUsers, model)
+	for _, mesh := range model.Meshes {
+		mesh.Delete()
+	}
+	model.Imported.Release()
+}
+
+func (a *AssetManager) Stats() AssetStats {
+	return AssetStats{
+		Textures: len(a.textures),
+		Shaders:  len(loadedShaders),
+		Models:   len(a.models),
+	}
+}
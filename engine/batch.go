@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// staticBatches merges the geometry of static objects that share a material
+// into one world space mesh per material. Batches are rebuilt whenever the set
+// of static objects in the scene changes.
+type staticBatches struct {
+	objects []*GameObject
+	batches []*staticBatch
+	// singles are static objects with no material to share a batch with
+	singles []*GameObject
+}
+
+type staticBatch struct {
+	mesh     *Mesh
+	material *Material
+	objects  []*GameObject
+}
+
+// update rebuilds the batches if needed and returns the objects that have to
+// be drawn one by one
+func (b *staticBatches) update(objects []*GameObject) []*GameObject {
+	var static, dynamic []*GameObject
+	for _, obj := range objects {
+		if obj.Static && batchable(obj) {
+			static = append(static, obj)
+		} else {
+			dynamic = append(dynamic, obj)
+		}
+	}
+
+	if !sameObjects(static, b.objects) {
+		b.rebuild(static)
+	}
+	return append(dynamic, b.singles...)
+}
+
+// batchable reports whether obj's mesh has the full set of vertex attributes,
+// its material draws opaque and it has no renderer of its own, so its geometry
+// can be merged with others
+func batchable(obj *GameObject) bool {
+	mesh := obj.Mesh
+	return mesh != nil && len(mesh.Indices) > 0 && obj.Renderer == nil &&
+		len(mesh.Normals) == len(mesh.Vertices) &&
+		len(mesh.TexCoords)*3 == len(mesh.Vertices)*2 &&
+		!obj.Material.BlendMode.IsTransparent()
+}
+
+func sameObjects(a, b []*GameObject) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *staticBatches) rebuild(static []*GameObject) {
+	b.delete()
+	b.objects = append([]*GameObject(nil), static...)
+
+	// materials are compared by value; their uniform buffers are per instance
+	// and don't affect what is drawn
+	groups := make(map[Material][]*GameObject)
+	var order []Material
+	for _, obj := range static {
+		key := materialKey(&obj.Material)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], obj)
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		if len(group) == 1 {
+			b.singles = append(b.singles, group[0])
+			continue
+		}
+		b.batches = append(b.batches, &staticBatch{
+			mesh:     mergeMeshes(group),
+			material: &group[0].Material,
+			objects:  group,
+		})
+	}
+}
+
+func (b *staticBatches) delete() {
+	for _, batch := range b.batches {
+		batch.mesh.Delete()
+	}
+	b.objects = nil
+	b.batches = nil
+	b.singles = nil
+}
+
+// mergeMeshes bakes each object's transform into a copy of its mesh and
+// concatenates the copies into a single mesh
+func mergeMeshes(objects []*GameObject) *Mesh {
+	merged := &Mesh{}
+	for _, obj := range objects {
+		transform := NewObjectUniforms(obj.getModelMatrix())
+		src := obj.Mesh
+		base := uint32(len(merged.Vertices) / 3)
+
+		for i := 0; i < len(src.Vertices); i += 3 {
+			position := transform.Model.Mul4x1(mgl32.Vec4{src.Vertices[i], src.Vertices[i+1], src.Vertices[i+2], 1})
+			merged.Vertices = append(merged.Vertices, position.X(), position.Y(), position.Z())
+
+			normal := transform.NormalMatrix.Mul3x1(mgl32.Vec3{src.Normals[i], src.Normals[i+1], src.Normals[i+2]})
+			if normal.Len() > 0 {
+				normal = normal.Normalize()
+			}
+			merged.Normals = append(merged.Normals, normal.X(), normal.Y(), normal.Z())
+		}
+		merged.TexCoords = append(merged.TexCoords, src.TexCoords...)
+		for _, index := range src.Indices {
+			merged.Indices = append(merged.Indices, base+index)
+		}
+	}
+
+	// Uploaded by the first draw, renderers without OpenGL only read the data
+	merged.IndexCount = int32(len(merged.Indices))
+	return merged
+}
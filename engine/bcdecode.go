@@ -0,0 +1,665 @@
+package engine
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DecodeBlocks decompresses a width x height image of BCn blocks on the CPU.
+// BC1-BC3 and BC7 decode to RGBA8, BC4 and BC5 to R8 and RG8, and the signed
+// and HDR formats to float32 pixels in R16F, RG16F or RGB16F layout.
+func DecodeBlocks(format TextureFormat, width, height int32, data []byte) (TextureFormat, interface{}, error) {
+	info, ok := compressedFormats[format]
+	if !ok {
+		return 0, nil, fmt.Errorf("texture format %d is not block compressed", format)
+	}
+	if width < 1 || height < 1 {
+		return 0, nil, fmt.Errorf("invalid compressed image size %dx%d", width, height)
+	}
+	if size := compressedLevelSize(format, width, height); int64(len(data)) < size {
+		return 0, nil, fmt.Errorf("compressed image is %d bytes, %dx%d needs %d", len(data), width, height, size)
+	}
+
+	switch format {
+	case TextureFormatBC1:
+		return info.decoded, decodeBlocks8(width, height, 4, info.blockBytes, data, func(src []byte, dst *[16][4]uint8) {
+			decodeBC1(src, dst, false, false)
+		}), nil
+	case TextureFormatBC1A:
+		return info.decoded, decodeBlocks8(width, height, 4, info.blockBytes, data, func(src []byte, dst *[16][4]uint8) {
+			decodeBC1(src, dst, true, false)
+		}), nil
+	case TextureFormatBC2:
+		return info.decoded, decodeBlocks8(width, height, 4, info.blockBytes, data, decodeBC2), nil
+	case TextureFormatBC3:
+		return info.decoded, decodeBlocks8(width, height, 4, info.blockBytes, data, decodeBC3), nil
+	case TextureFormatBC4:
+		return info.decoded, decodeBlocks8(width, height, 1, info.blockBytes, data, func(src []byte, dst *[16][4]uint8) {
+			red := decodeAlphaBlock(src)
+			for i := range dst {
+				dst[i][0] = red[i]
+			}
+		}), nil
+	case TextureFormatBC5:
+		return info.decoded, decodeBlocks8(width, height, 2, info.blockBytes, data, func(src []byte, dst *[16][4]uint8) {
+			red, green := decodeAlphaBlock(src), decodeAlphaBlock(src[8:])
+			for i := range dst {
+				dst[i][0], dst[i][1] = red[i], green[i]
+			}
+		}), nil
+	case TextureFormatBC4S:
+		return info.decoded, decodeBlocksFloat(width, height, 1, info.blockBytes, data, func(src []byte, dst *[16][4]float32) {
+			red := decodeSignedBlock(src)
+			for i := range dst {
+				dst[i][0] = red[i]
+			}
+		}), nil
+	case TextureFormatBC5S:
+		return info.decoded, decodeBlocksFloat(width, height, 2, info.blockBytes, data, func(src []byte, dst *[16][4]float32) {
+			red, green := decodeSignedBlock(src), decodeSignedBlock(src[8:])
+			for i := range dst {
+				dst[i][0], dst[i][1] = red[i], green[i]
+			}
+		}), nil
+	case TextureFormatBC6H, TextureFormatBC6HS:
+		signed := format == TextureFormatBC6HS
+		return info.decoded, decodeBlocksFloat(width, height, 3, info.blockBytes, data, func(src []byte, dst *[16][4]float32) {
+			decodeBC6H(src, dst, signed)
+		}), nil
+	case TextureFormatBC7:
+		return info.decoded, decodeBlocks8(width, height, 4, info.blockBytes, data, decodeBC7), nil
+	}
+	return 0, nil, fmt.Errorf("no decoder for texture format %d", format)
+}
+
+// decodeBlocks8 runs decode over every block and copies the first channels of
+// each decoded texel into a tightly packed image, dropping texels past the edge.
+func decodeBlocks8(width, height int32, channels, blockBytes int, data []byte, decode func([]byte, *[16][4]uint8)) []uint8 {
+	w, h := int(width), int(height)
+	pixels := make([]uint8, w*h*channels)
+	var block [16][4]uint8
+	for by := 0; by < (h+3)/4; by++ {
+		for bx := 0; bx < (w+3)/4; bx++ {
+			decode(data[(by*((w+3)/4)+bx)*blockBytes:], &block)
+			for i, texel := range block {
+				x, y := bx*4+i%4, by*4+i/4
+				if x < w && y < h {
+					copy(pixels[(y*w+x)*channels:], texel[:channels])
+				}
+			}
+		}
+	}
+	return pixels
+}
+
+func decodeBlocksFloat(width, height int32, channels, blockBytes int, data []byte, decode func([]byte, *[16][4]float32)) []float32 {
+	w, h := int(width), int(height)
+	pixels := make([]float32, w*h*channels)
+	var block [16][4]float32
+	for by := 0; by < (h+3)/4; by++ {
+		for bx := 0; bx < (w+3)/4; bx++ {
+			decode(data[(by*((w+3)/4)+bx)*blockBytes:], &block)
+			for i, texel := range block {
+				x, y := bx*4+i%4, by*4+i/4
+				if x < w && y < h {
+					copy(pixels[(y*w+x)*channels:], texel[:channels])
+				}
+			}
+		}
+	}
+	return pixels
+}
+
+func rgb565(c uint16) [4]uint8 {
+	r, g, b := uint8(c>>11&31), uint8(c>>5&63), uint8(c&31)
+	return [4]uint8{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 255}
+}
+
+// decodeBC1 decodes a DXT1 color block. Blocks whose first endpoint is not the
+// larger one use three colors and black, which is transparent when alpha is
+// set. The color blocks of BC2 and BC3 always use four colors.
+func decodeBC1(src []byte, dst *[16][4]uint8, alpha, fourColor bool) {
+	c0, c1 := binary.LittleEndian.Uint16(src), binary.LittleEndian.Uint16(src[2:])
+	var palette [4][4]uint8
+	palette[0], palette[1] = rgb565(c0), rgb565(c1)
+	for ch := 0; ch < 3; ch++ {
+		a, b := int(palette[0][ch]), int(palette[1][ch])
+		if c0 > c1 || fourColor {
+			palette[2][ch] = uint8((2*a + b) / 3)
+			palette[3][ch] = uint8((a + 2*b) / 3)
+		} else {
+			palette[2][ch] = uint8((a + b) / 2)
+		}
+	}
+	palette[2][3], palette[3][3] = 255, 255
+	if c0 <= c1 && !fourColor && alpha {
+		palette[3][3] = 0
+	}
+
+	indices := binary.LittleEndian.Uint32(src[4:])
+	for i := range dst {
+		dst[i] = palette[indices>>(2*i)&3]
+	}
+}
+
+func decodeBC2(src []byte, dst *[16][4]uint8) {
+	decodeBC1(src[8:], dst, false, true)
+	alpha := binary.LittleEndian.Uint64(src)
+	for i := range dst {
+		dst[i][3] = uint8(alpha>>(4*i)&15) * 17
+	}
+}
+
+func decodeBC3(src []byte, dst *[16][4]uint8) {
+	decodeBC1(src[8:], dst, false, true)
+	alpha := decodeAlphaBlock(src)
+	for i := range dst {
+		dst[i][3] = alpha[i]
+	}
+}
+
+// decodeAlphaBlock decodes the 8 byte single channel block shared by the BC3
+// alpha channel and BC4/BC5
+func decodeAlphaBlock(src []byte) [16]uint8 {
+	a0, a1 := int(src[0]), int(src[1])
+	var palette [8]int
+	palette[0], palette[1] = a0, a1
+	if a0 > a1 {
+		for i := 1; i < 7; i++ {
+			palette[i+1] = ((7-i)*a0 + i*a1) / 7
+		}
+	} else {
+		for i := 1; i < 5; i++ {
+			palette[i+1] = ((5-i)*a0 + i*a1) / 5
+		}
+		palette[6], palette[7] = 0, 255
+	}
+
+	var out [16]uint8
+	indices := uint64(binary.LittleEndian.Uint16(src[2:])) | uint64(binary.LittleEndian.Uint32(src[4:]))<<16
+	for i := range out {
+		out[i] = uint8(palette[indices>>(3*i)&7])
+	}
+	return out
+}
+
+// decodeSignedBlock is decodeAlphaBlock for the SNORM variants of BC4 and BC5,
+// returning values in [-1, 1]
+func decodeSignedBlock(src []byte) [16]float32 {
+	a0, a1 := float32(int8(src[0])), float32(int8(src[1]))
+	greater := a0 > a1
+	a0, a1 = float32(math.Max(float64(a0), -127)), float32(math.Max(float64(a1), -127))
+	var palette [8]float32
+	palette[0], palette[1] = a0, a1
+	if greater {
+		for i := 1; i < 7; i++ {
+			palette[i+1] = (float32(7-i)*a0 + float32(i)*a1) / 7
+		}
+	} else {
+		for i := 1; i < 5; i++ {
+			palette[i+1] = (float32(5-i)*a0 + float32(i)*a1) / 5
+		}
+		palette[6], palette[7] = -127, 127
+	}
+
+	var out [16]float32
+	indices := uint64(binary.LittleEndian.Uint16(src[2:])) | uint64(binary.LittleEndian.Uint32(src[4:]))<<16
+	for i := range out {
+		out[i] = palette[indices>>(3*i)&7] / 127
+	}
+	return out
+}
+
+// blockBits reads a 128-bit BC6H/BC7 block least significant bit first
+type blockBits struct {
+	lo, hi uint64
+	pos    uint
+}
+
+func newBlockBits(src []byte) *blockBits {
+	return &blockBits{lo: binary.LittleEndian.Uint64(src), hi: binary.LittleEndian.Uint64(src[8:])}
+}
+
+func (b *blockBits) read(n uint) uint32 {
+	if n == 0 {
+		return 0
+	}
+	var v uint64
+	if b.pos >= 64 {
+		v = b.hi >> (b.pos - 64)
+	} else {
+		v = b.lo >> b.pos
+		if b.pos+n > 64 {
+			v |= b.hi << (64 - b.pos)
+		}
+	}
+	b.pos += n
+	return uint32(v & (1<<n - 1))
+}
+
+// Interpolation weights for 2, 3 and 4 bit indices, shared by BC6H and BC7
+var (
+	bptcWeights2 = []int32{0, 21, 43, 64}
+	bptcWeights3 = []int32{0, 9, 18, 27, 37, 46, 55, 64}
+	bptcWeights4 = []int32{0, 4, 9, 13, 17, 21, 26, 30, 34, 38, 43, 47, 51, 55, 60, 64}
+)
+
+func bptcWeights(bits uint) []int32 {
+	switch bits {
+	case 2:
+		return bptcWeights2
+	case 3:
+		return bptcWeights3
+	}
+	return bptcWeights4
+}
+
+func bptcInterpolate(a, b int32, weight int32) int32 {
+	return ((64-weight)*a + weight*b + 32) >> 6
+}
+
+// bptcPartitions2 holds the two subset partitions as one bit per texel,
+// set for texels in subset 1. BC6H uses the first 32.
+var bptcPartitions2 = [64]uint16{
+	0xcccc, 0x8888, 0xeeee, 0xecc8, 0xc880, 0xfeec, 0xfec8, 0xec80,
+	0xc800, 0xffec, 0xfe80, 0xe800, 0xffe8, 0xff00, 0xfff0, 0xf000,
+	0xf710, 0x008e, 0x7100, 0x08ce, 0x008c, 0x7310, 0x3100, 0x8cce,
+	0x088c, 0x3110, 0x6666, 0x366c, 0x17e8, 0x0ff0, 0x718e, 0x399c,
+	0xaaaa, 0xf0f0, 0x5a5a, 0x33cc, 0x3c3c, 0x55aa, 0x9696, 0xa55a,
+	0x73ce, 0x13c8, 0x324c, 0x3bdc, 0x6996, 0xc33c, 0x9966, 0x0660,
+	0x0272, 0x04e4, 0x4e40, 0x2720, 0xc936, 0x936c, 0x39c6, 0x639c,
+	0x9336, 0x9cc6, 0x817e, 0xe718, 0xccf0, 0x0fcc, 0x7744, 0xee22,
+}
+
+// bptcPartitions3 holds the three subset partitions with two bits per texel
+var bptcPartitions3 = [64]uint32{
+	0xaa685050, 0x6a5a5040, 0x5a5a4200, 0x5450a0a8, 0xa5a50000, 0xa0a05050, 0x5555a0a0, 0x5a5a5050,
+	0xaa550000, 0xaa555500, 0xaaaa5500, 0x90909090, 0x94949494, 0xa4a4a4a4, 0xa9a59450, 0x2a0a4250,
+	0xa5945040, 0x0a425054, 0xa5a5a500, 0x55a0a0a0, 0xa8a85454, 0x6a6a4040, 0xa4a45000, 0x1a1a0500,
+	0x0050a4a4, 0xaaa59090, 0x14696914, 0x69691400, 0xa08585a0, 0xaa821414, 0x50a4a450, 0x6a5a0200,
+	0xa9a58000, 0x5090a0a8, 0xa8a09050, 0x24242424, 0x00aa5500, 0x24924924, 0x24499224, 0x50a50a50,
+	0x500aa550, 0xaaaa4444, 0x66660000, 0xa5a0a5a0, 0x50a050a0, 0x69286928, 0x44aaaa44, 0x66666600,
+	0xaa444444, 0x54a854a8, 0x95809580, 0x96969600, 0xa85454a8, 0x80959580, 0xaa141414, 0x96960000,
+	0xaaaa1414, 0xa05050a0, 0xa0a5a5a0, 0x96000000, 0x40804080, 0xa9a8a9a8, 0xaaaaaa44, 0x2a4a5254,
+}
+
+// Anchor texels, whose index is stored with one bit less, of subset 1 in the
+// two subset partitions and of subsets 1 and 2 in the three subset partitions
+var (
+	bptcAnchors2 = [64]uint8{
+		15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+		15, 2, 8, 2, 2, 8, 8, 15, 2, 8, 2, 2, 8, 8, 2, 2,
+		15, 15, 6, 8, 2, 8, 15, 15, 2, 8, 2, 2, 2, 15, 15, 6,
+		6, 2, 6, 8, 15, 15, 2, 2, 15, 15, 15, 15, 15, 2, 2, 15,
+	}
+	bptcAnchors3a = [64]uint8{
+		3, 3, 15, 15, 8, 3, 15, 15, 8, 8, 6, 6, 6, 5, 3, 3,
+		3, 3, 8, 15, 3, 3, 6, 10, 5, 8, 8, 6, 8, 5, 15, 15,
+		8, 15, 3, 5, 6, 10, 8, 15, 15, 3, 15, 5, 15, 15, 15, 15,
+		3, 15, 5, 5, 5, 8, 5, 10, 5, 10, 8, 13, 15, 12, 3, 3,
+	}
+	bptcAnchors3b = [64]uint8{
+		15, 8, 8, 3, 15, 15, 3, 8, 15, 15, 15, 15, 15, 15, 15, 8,
+		15, 8, 15, 3, 15, 8, 15, 8, 3, 15, 6, 10, 15, 15, 10, 8,
+		15, 3, 15, 10, 10, 8, 9, 10, 6, 15, 8, 15, 3, 6, 6, 8,
+		15, 3, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 3, 15, 15, 8,
+	}
+)
+
+func bptcSubset(subsets int, partition uint32, texel int) int {
+	switch subsets {
+	case 2:
+		return int(bptcPartitions2[partition] >> texel & 1)
+	case 3:
+		return int(bptcPartitions3[partition] >> (2 * texel) & 3)
+	}
+	return 0
+}
+
+func bptcIsAnchor(subsets int, partition uint32, texel int) bool {
+	switch {
+	case texel == 0:
+		return true
+	case subsets == 2:
+		return texel == int(bptcAnchors2[partition])
+	case subsets == 3:
+		return texel == int(bptcAnchors3a[partition]) || texel == int(bptcAnchors3b[partition])
+	}
+	return false
+}
+
+type bc7Mode struct {
+	subsets       int
+	partitionBits uint
+	rotationBits  uint
+	selectorBits  uint
+	colorBits     uint
+	alphaBits     uint
+	endpointPBits bool
+	sharedPBits   bool
+	indexBits     uint
+	indexBits2    uint
+}
+
+var bc7Modes = [8]bc7Mode{
+	{3, 4, 0, 0, 4, 0, true, false, 3, 0},
+	{2, 6, 0, 0, 6, 0, false, true, 3, 0},
+	{3, 6, 0, 0, 5, 0, false, false, 2, 0},
+	{2, 6, 0, 0, 7, 0, true, false, 2, 0},
+	{1, 0, 2, 1, 5, 6, false, false, 2, 3},
+	{1, 0, 2, 0, 7, 8, false, false, 2, 2},
+	{1, 0, 0, 0, 7, 7, true, false, 4, 0},
+	{2, 6, 0, 0, 5, 5, true, false, 2, 0},
+}
+
+func decodeBC7(src []byte, dst *[16][4]uint8) {
+	bits := newBlockBits(src)
+	mode := 0
+	for mode < 8 && bits.read(1) == 0 {
+		mode++
+	}
+	if mode == 8 {
+		// reserved mode, decodes to transparent black
+		*dst = [16][4]uint8{}
+		return
+	}
+	m := bc7Modes[mode]
+	partition := bits.read(m.partitionBits)
+	rotation := bits.read(m.rotationBits)
+	selector := bits.read(m.selectorBits)
+
+	var endpoints [3][2][4]uint32
+	for ch := 0; ch < 3; ch++ {
+		for s := 0; s < m.subsets; s++ {
+			endpoints[s][0][ch] = bits.read(m.colorBits)
+			endpoints[s][1][ch] = bits.read(m.colorBits)
+		}
+	}
+	for s := 0; s < m.subsets; s++ {
+		endpoints[s][0][3] = bits.read(m.alphaBits)
+		endpoints[s][1][3] = bits.read(m.alphaBits)
+	}
+
+	colorBits, alphaBits := m.colorBits, m.alphaBits
+	if m.endpointPBits || m.sharedPBits {
+		for s := 0; s < m.subsets; s++ {
+			var p [2]uint32
+			p[0] = bits.read(1)
+			if m.endpointPBits {
+				p[1] = bits.read(1)
+			} else {
+				p[1] = p[0]
+			}
+			for e := 0; e < 2; e++ {
+				for ch := 0; ch < 4; ch++ {
+					endpoints[s][e][ch] = endpoints[s][e][ch]<<1 | p[e]
+				}
+			}
+		}
+		colorBits++
+		if alphaBits > 0 {
+			alphaBits++
+		}
+	}
+	for s := 0; s < m.subsets; s++ {
+		for e := 0; e < 2; e++ {
+			for ch := 0; ch < 3; ch++ {
+				endpoints[s][e][ch] = expandBits(endpoints[s][e][ch], colorBits)
+			}
+			if alphaBits > 0 {
+				endpoints[s][e][3] = expandBits(endpoints[s][e][3], alphaBits)
+			} else {
+				endpoints[s][e][3] = 255
+			}
+		}
+	}
+
+	var indices, indices2 [16]uint32
+	for i := range indices {
+		n := m.indexBits
+		if bptcIsAnchor(m.subsets, partition, i) {
+			n--
+		}
+		indices[i] = bits.read(n)
+	}
+	if m.indexBits2 > 0 {
+		for i := range indices2 {
+			n := m.indexBits2
+			if i == 0 {
+				n--
+			}
+			indices2[i] = bits.read(n)
+		}
+	}
+
+	colorIndexBits, alphaIndexBits := m.indexBits, m.indexBits
+	colorIndices, alphaIndices := &indices, &indices
+	if m.indexBits2 > 0 {
+		alphaIndexBits, alphaIndices = m.indexBits2, &indices2
+		if selector == 1 {
+			colorIndexBits, alphaIndexBits = alphaIndexBits, colorIndexBits
+			colorIndices, alphaIndices = alphaIndices, colorIndices
+		}
+	}
+	colorWeights, alphaWeights := bptcWeights(colorIndexBits), bptcWeights(alphaIndexBits)
+
+	for i := range dst {
+		e := endpoints[bptcSubset(m.subsets, partition, i)]
+		for ch := 0; ch < 3; ch++ {
+			dst[i][ch] = uint8(bptcInterpolate(int32(e[0][ch]), int32(e[1][ch]), colorWeights[colorIndices[i]]))
+		}
+		dst[i][3] = uint8(bptcInterpolate(int32(e[0][3]), int32(e[1][3]), alphaWeights[alphaIndices[i]]))
+		if rotation > 0 {
+			dst[i][rotation-1], dst[i][3] = dst[i][3], dst[i][rotation-1]
+		}
+	}
+}
+
+// expandBits widens a value of the given bit count to 8 bits by replicating
+// its high bits into the low ones
+func expandBits(v uint32, bits uint) uint32 {
+	v <<= 8 - bits
+	return v | v>>bits
+}
+
+type bc6hField struct {
+	endpoint int
+	channel  int
+	// first and last are bit positions in the order they appear in the block
+	first, last int
+}
+
+type bc6hMode struct {
+	regions      int
+	transformed  bool
+	endpointBits uint
+	deltaBits    [3]uint
+	fields       []bc6hField
+}
+
+// bc6hModes is keyed by the 2 or 5 bit mode number. The layouts list where each
+// bit of the four endpoints lives, written as in the format specification:
+// r0-r3 are the red components of endpoints w, x, y and z, and "r0:9-0" covers
+// bits 0 through 9 of w.r stored lowest bit first.
+var bc6hModes = map[uint32]*bc6hMode{
+	0x00: newBC6HMode(2, true, 10, 5, 5, 5, "g2:4 b2:4 b3:4 r0:9-0 g0:9-0 b0:9-0 r1:4-0 g3:4 g2:3-0 g1:4-0 b3:0 g3:3-0 b1:4-0 b3:1 b2:3-0 r2:4-0 b3:2 r3:4-0 b3:3"),
+	0x01: newBC6HMode(2, true, 7, 6, 6, 6, "g2:5 g3:4 g3:5 r0:6-0 b3:0 b3:1 b2:4 g0:6-0 b2:5 b3:2 g2:4 b0:6-0 b3:3 b3:5 b3:4 r1:5-0 g2:3-0 g1:5-0 g3:3-0 b1:5-0 b2:3-0 r2:5-0 r3:5-0"),
+	0x02: newBC6HMode(2, true, 11, 5, 4, 4, "r0:9-0 g0:9-0 b0:9-0 r1:4-0 r0:10 g2:3-0 g1:3-0 g0:10 b3:0 g3:3-0 b1:3-0 b0:10 b3:1 b2:3-0 r2:4-0 b3:2 r3:4-0 b3:3"),
+	0x06: newBC6HMode(2, true, 11, 4, 5, 4, "r0:9-0 g0:9-0 b0:9-0 r1:3-0 r0:10 g3:4 g2:3-0 g1:4-0 g0:10 g3:3-0 b1:3-0 b0:10 b3:1 b2:3-0 r2:3-0 b3:0 b3:2 r3:3-0 g2:4 b3:3"),
+	0x0a: newBC6HMode(2, true, 11, 4, 4, 5, "r0:9-0 g0:9-0 b0:9-0 r1:3-0 r0:10 b2:4 g2:3-0 g1:3-0 g0:10 b3:0 g3:3-0 b1:4-0 b0:10 b2:3-0 r2:3-0 b3:1 b3:2 r3:3-0 b3:4 b3:3"),
+	0x0e: newBC6HMode(2, true, 9, 5, 5, 5, "r0:8-0 b2:4 g0:8-0 g2:4 b0:8-0 b3:4 r1:4-0 g3:4 g2:3-0 g1:4-0 b3:0 g3:3-0 b1:4-0 b3:1 b2:3-0 r2:4-0 b3:2 r3:4-0 b3:3"),
+	0x12: newBC6HMode(2, true, 8, 6, 5, 5, "r0:7-0 g3:4 b2:4 g0:7-0 b3:2 g2:4 b0:7-0 b3:3 b3:4 r1:5-0 g2:3-0 g1:4-0 b3:0 g3:3-0 b1:4-0 b3:1 b2:3-0 r2:5-0 r3:5-0"),
+	0x16: newBC6HMode(2, true, 8, 5, 6, 5, "r0:7-0 b3:0 b2:4 g0:7-0 g2:5 g2:4 b0:7-0 g3:5 b3:4 r1:4-0 g3:4 g2:3-0 g1:5-0 g3:3-0 b1:4-0 b3:1 b2:3-0 r2:4-0 b3:2 r3:4-0 b3:3"),
+	0x1a: newBC6HMode(2, true, 8, 5, 5, 6, "r0:7-0 b3:1 b2:4 g0:7-0 b2:5 g2:4 b0:7-0 b3:5 b3:4 r1:4-0 g3:4 g2:3-0 g1:4-0 b3:0 g3:3-0 b1:5-0 b2:3-0 r2:4-0 b3:2 r3:4-0 b3:3"),
+	0x1e: newBC6HMode(2, false, 6, 6, 6, 6, "r0:5-0 g3:4 b3:0 b3:1 b2:4 g0:5-0 g2:5 b2:5 b3:2 g2:4 b0:5-0 g3:5 b3:3 b3:5 b3:4 r1:5-0 g2:3-0 g1:5-0 g3:3-0 b1:5-0 b2:3-0 r2:5-0 r3:5-0"),
+	0x03: newBC6HMode(1, false, 10, 10, 10, 10, "r0:9-0 g0:9-0 b0:9-0 r1:9-0 g1:9-0 b1:9-0"),
+	0x07: newBC6HMode(1, true, 11, 9, 9, 9, "r0:9-0 g0:9-0 b0:9-0 r1:8-0 r0:10 g1:8-0 g0:10 b1:8-0 b0:10"),
+	0x0b: newBC6HMode(1, true, 12, 8, 8, 8, "r0:9-0 g0:9-0 b0:9-0 r1:7-0 r0:10-11 g1:7-0 g0:10-11 b1:7-0 b0:10-11"),
+	0x0f: newBC6HMode(1, true, 16, 4, 4, 4, "r0:9-0 g0:9-0 b0:9-0 r1:3-0 r0:10-15 g1:3-0 g0:10-15 b1:3-0 b0:10-15"),
+}
+
+func newBC6HMode(regions int, transformed bool, endpointBits, deltaR, deltaG, deltaB uint, layout string) *bc6hMode {
+	mode := &bc6hMode{
+		regions:      regions,
+		transformed:  transformed,
+		endpointBits: endpointBits,
+		deltaBits:    [3]uint{deltaR, deltaG, deltaB},
+	}
+	for _, token := range strings.Fields(layout) {
+		field := bc6hField{channel: strings.IndexByte("rgb", token[0]), endpoint: int(token[1] - '0')}
+		bitRange := strings.SplitN(token[3:], "-", 2)
+		high, _ := strconv.Atoi(bitRange[0])
+		low := high
+		if len(bitRange) == 2 {
+			low, _ = strconv.Atoi(bitRange[1])
+		}
+		// a range is written high-low and stored starting from its right end
+		field.first, field.last = low, high
+		mode.fields = append(mode.fields, field)
+	}
+	return mode
+}
+
+func decodeBC6H(src []byte, dst *[16][4]float32, signed bool) {
+	bits := newBlockBits(src)
+	modeNumber := bits.read(2)
+	if modeNumber > 1 {
+		modeNumber |= bits.read(3) << 2
+	}
+	mode, ok := bc6hModes[modeNumber]
+	if !ok {
+		*dst = [16][4]float32{}
+		return
+	}
+
+	var endpoints [4][3]int32
+	for _, f := range mode.fields {
+		step := 1
+		if f.last < f.first {
+			step = -1
+		}
+		for bit := f.first; ; bit += step {
+			endpoints[f.endpoint][f.channel] |= int32(bits.read(1)) << bit
+			if bit == f.last {
+				break
+			}
+		}
+	}
+	var partition uint32
+	if mode.regions == 2 {
+		partition = bits.read(5)
+	}
+
+	epBits := mode.endpointBits
+	for ch := 0; ch < 3; ch++ {
+		if signed {
+			endpoints[0][ch] = signExtend(endpoints[0][ch], epBits)
+		}
+		for e := 1; e < mode.regions*2; e++ {
+			v := endpoints[e][ch]
+			if mode.transformed {
+				v = (endpoints[0][ch] + signExtend(v, mode.deltaBits[ch])) & (1<<epBits - 1)
+			}
+			if signed {
+				v = signExtend(v, epBits)
+			}
+			endpoints[e][ch] = v
+		}
+	}
+	for e := range endpoints {
+		for ch := range endpoints[e] {
+			endpoints[e][ch] = bc6hUnquantize(endpoints[e][ch], epBits, signed)
+		}
+	}
+
+	indexBits := uint(4)
+	if mode.regions == 2 {
+		indexBits = 3
+	}
+	weights := bptcWeights(indexBits)
+	for i := range dst {
+		n := indexBits
+		if bptcIsAnchor(mode.regions, partition, i) {
+			n--
+		}
+		weight := weights[bits.read(n)]
+		region := bptcSubset(mode.regions, partition, i)
+		for ch := 0; ch < 3; ch++ {
+			v := bptcInterpolate(endpoints[region*2][ch], endpoints[region*2+1][ch], weight)
+			dst[i][ch] = halfToFloat32(bc6hFinish(v, signed))
+		}
+		dst[i][3] = 1
+	}
+}
+
+func signExtend(v int32, bits uint) int32 {
+	shift := 32 - bits
+	return v << shift >> shift
+}
+
+// bc6hUnquantize scales an endpoint to the 16 bit range interpolation runs in
+func bc6hUnquantize(v int32, bits uint, signed bool) int32 {
+	if !signed {
+		switch {
+		case bits >= 15, v == 0:
+			return v
+		case v == 1<<bits-1:
+			return 0xffff
+		}
+		return (v<<16 + 0x8000) >> bits
+	}
+
+	if bits >= 16 {
+		return v
+	}
+	negative := v < 0
+	if negative {
+		v = -v
+	}
+	switch {
+	case v == 0:
+	case v >= 1<<(bits-1)-1:
+		v = 0x7fff
+	default:
+		v = (v<<15 + 0x4000) >> (bits - 1)
+	}
+	if negative {
+		v = -v
+	}
+	return v
+}
+
+// bc6hFinish scales an interpolated value to the bit pattern of a half float
+func bc6hFinish(v int32, signed bool) uint16 {
+	if !signed {
+		return uint16(v * 31 >> 6)
+	}
+	if v < 0 {
+		return uint16(-v*31>>5) | 0x8000
+	}
+	return uint16(v * 31 >> 5)
+}
+
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exponent := int32(h >> 10 & 31)
+	mantissa := uint32(h & 1023)
+	switch {
+	case exponent == 0 && mantissa == 0:
+		return math.Float32frombits(sign)
+	case exponent == 0:
+		// subnormal, normalise it for the wider exponent
+		for mantissa&1024 == 0 {
+			mantissa <<= 1
+			exponent--
+		}
+		exponent++
+		mantissa &= 1023
+	case exponent == 31:
+		return math.Float32frombits(sign | 0xff<<23 | mantissa<<13)
+	}
+	return math.Float32frombits(sign | uint32(exponent+112)<<23 | mantissa<<13)
+}
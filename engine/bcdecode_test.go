@@ -0,0 +1,372 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// packBits writes fields of {value, bit count} least significant bit first into
+// a 16 byte BC6H/BC7 block
+func packBits(fields ...[2]uint) []byte {
+	var lo, hi uint64
+	pos := uint(0)
+	for _, field := range fields {
+		for i := uint(0); i < field[1]; i++ {
+			bit := uint64(field[0] >> i & 1)
+			if pos < 64 {
+				lo |= bit << pos
+			} else {
+				hi |= bit << (pos - 64)
+			}
+			pos++
+		}
+	}
+	block := make([]byte, 16)
+	binary.LittleEndian.PutUint64(block, lo)
+	binary.LittleEndian.PutUint64(block[8:], hi)
+	return block
+}
+
+// bc1Block builds a BC1 color block from two RGB565 endpoints and 2 bit indices
+func bc1Block(c0, c1 uint16, indices [16]uint32) []byte {
+	block := make([]byte, 8)
+	binary.LittleEndian.PutUint16(block, c0)
+	binary.LittleEndian.PutUint16(block[2:], c1)
+	var bits uint32
+	for i, index := range indices {
+		bits |= index << (2 * i)
+	}
+	binary.LittleEndian.PutUint32(block[4:], bits)
+	return block
+}
+
+// alphaBlock builds a BC3 alpha or BC4 block from two endpoints and 3 bit indices
+func alphaBlock(a0, a1 uint8, indices [16]uint64) []byte {
+	var bits uint64
+	for i, index := range indices {
+		bits |= index << (3 * i)
+	}
+	block := make([]byte, 8)
+	binary.LittleEndian.PutUint64(block, bits<<16)
+	block[0], block[1] = a0, a1
+	return block
+}
+
+// fill returns 16 copies of v
+func fill(v uint32) (indices [16]uint32) {
+	for i := range indices {
+		indices[i] = v
+	}
+	return indices
+}
+
+// ramp returns indices 0, 1, ... n-1, 0, 1, ... for the 16 texels
+func ramp(n uint64) (indices [16]uint64) {
+	for i := range indices {
+		indices[i] = uint64(i) % n
+	}
+	return indices
+}
+
+func rgba(texels ...[4]uint8) []uint8 {
+	var pixels []uint8
+	for _, texel := range texels {
+		pixels = append(pixels, texel[:]...)
+	}
+	return pixels
+}
+
+func repeat(texel []uint8, n int) []uint8 {
+	return bytes.Repeat(texel, n)
+}
+
+func TestDecodeBlocks8(t *testing.T) {
+	red, blue := [4]uint8{255, 0, 0, 255}, [4]uint8{0, 0, 255, 255}
+	// the interpolated colors round down, 2/3 and 1/3 of the way
+	redBlue := [4]uint8{170, 0, 85, 255}
+	blueRed := [4]uint8{85, 0, 170, 255}
+	purple := [4]uint8{127, 0, 127, 255}
+	transparent := [4]uint8{0, 0, 0, 0}
+	palette := [16]uint32{0, 1, 2, 3, 0, 1, 2, 3, 0, 1, 2, 3, 0, 1, 2, 3}
+	row4 := func(texels ...[4]uint8) []uint8 {
+		var pixels []uint8
+		for i := 0; i < 4; i++ {
+			pixels = append(pixels, rgba(texels...)...)
+		}
+		return pixels
+	}
+
+	bc2Alpha := make([]byte, 8)
+	for i := range bc2Alpha {
+		// 4 bit alpha 0, 5, 10 and 15 per pair of texels
+		bc2Alpha[i] = byte(i%2*10<<4 | i%2*10)
+	}
+
+	tests := []struct {
+		name   string
+		format TextureFormat
+		width  int32
+		height int32
+		data   []byte
+		want   TextureFormat
+		pixels []uint8
+	}{
+		{
+			name:   "BC1 four colors",
+			format: TextureFormatBC1,
+			width:  4, height: 4,
+			data:   bc1Block(0xF800, 0x001F, palette),
+			want:   TextureFormatRGBA8,
+			pixels: row4(red, blue, redBlue, blueRed),
+		},
+		{
+			name:   "BC1 three colors and black",
+			format: TextureFormatBC1,
+			width:  4, height: 4,
+			data:   bc1Block(0x001F, 0xF800, palette),
+			want:   TextureFormatRGBA8,
+			pixels: row4(blue, red, purple, [4]uint8{0, 0, 0, 255}),
+		},
+		{
+			name:   "BC1A transparent black",
+			format: TextureFormatBC1A,
+			width:  4, height: 4,
+			data:   bc1Block(0x001F, 0xF800, palette),
+			want:   TextureFormatRGBA8,
+			pixels: row4(blue, red, purple, transparent),
+		},
+		{
+			name:   "BC2 explicit alpha",
+			format: TextureFormatBC2,
+			width:  4, height: 4,
+			data:   append(bc2Alpha, bc1Block(0x001F, 0xF800, fill(0))...),
+			want:   TextureFormatRGBA8,
+			pixels: row4([4]uint8{0, 0, 255, 0}, [4]uint8{0, 0, 255, 0}, [4]uint8{0, 0, 255, 170}, [4]uint8{0, 0, 255, 170}),
+		},
+		{
+			// BC3 color blocks always have four colors
+			name:   "BC3 interpolated alpha",
+			format: TextureFormatBC3,
+			width:  4, height: 4,
+			data:   append(alphaBlock(255, 0, ramp(4)), bc1Block(0x001F, 0xF800, palette)...),
+			want:   TextureFormatRGBA8,
+			pixels: row4([4]uint8{0, 0, 255, 255}, [4]uint8{255, 0, 0, 0}, [4]uint8{85, 0, 170, 218}, [4]uint8{170, 0, 85, 182}),
+		},
+		{
+			name:   "BC4 six values and the extremes",
+			format: TextureFormatBC4,
+			width:  4, height: 2,
+			data:   alphaBlock(50, 100, ramp(8)),
+			want:   TextureFormatR8,
+			pixels: []uint8{50, 100, 60, 70, 80, 90, 0, 255},
+		},
+		{
+			name:   "BC5",
+			format: TextureFormatBC5,
+			width:  2, height: 1,
+			data:   append(alphaBlock(200, 100, ramp(2)), alphaBlock(10, 20, fill64(1))...),
+			want:   TextureFormatRG8,
+			pixels: []uint8{200, 20, 100, 20},
+		},
+		{
+			// 6x5 pixels take 2x2 blocks; texels past the edge are dropped
+			name:   "partial blocks",
+			format: TextureFormatBC1,
+			width:  6, height: 5,
+			data: bytes.Join([][]byte{
+				bc1Block(0xF800, 0, fill(0)),
+				bc1Block(0x001F, 0, fill(0)),
+				bc1Block(0x07E0, 0, fill(0)),
+				bc1Block(0xFFFF, 0, fill(0)),
+			}, nil),
+			want: TextureFormatRGBA8,
+			pixels: bytes.Join([][]byte{
+				repeat(rgba(red), 4), repeat(rgba(blue), 2),
+				repeat(rgba(red), 4), repeat(rgba(blue), 2),
+				repeat(rgba(red), 4), repeat(rgba(blue), 2),
+				repeat(rgba(red), 4), repeat(rgba(blue), 2),
+				repeat([]uint8{0, 255, 0, 255}, 4), repeat([]uint8{255, 255, 255, 255}, 2),
+			}, nil),
+		},
+		{
+			name:   "BC7 mode 6 white",
+			format: TextureFormatBC7,
+			width:  1, height: 1,
+			// mode, 7 bit RGBA endpoints, both p-bits set, indices 0
+			data:   packBits([2]uint{1 << 6, 7}, [2]uint{1<<56 - 1, 56}, [2]uint{3, 2}),
+			want:   TextureFormatRGBA8,
+			pixels: []uint8{255, 255, 255, 255},
+		},
+		{
+			name:   "BC7 mode 6 endpoints",
+			format: TextureFormatBC7,
+			width:  2, height: 1,
+			// endpoint 0 is red with p-bit 0, endpoint 1 is green with p-bit 1.
+			// Texel 0 is the anchor with a 3 bit index, texel 1 uses index 15.
+			data: packBits([2]uint{1 << 6, 7},
+				[2]uint{127, 7}, [2]uint{0, 7}, [2]uint{0, 7}, [2]uint{127, 7},
+				[2]uint{0, 7}, [2]uint{0, 7}, [2]uint{127, 7}, [2]uint{127, 7},
+				[2]uint{0, 1}, [2]uint{1, 1}, [2]uint{0, 3}, [2]uint{15, 4}),
+			want:   TextureFormatRGBA8,
+			pixels: []uint8{254, 0, 0, 254, 1, 255, 1, 255},
+		},
+		{
+			name:   "BC7 reserved mode",
+			format: TextureFormatBC7,
+			width:  1, height: 1,
+			data:   make([]byte, 16),
+			want:   TextureFormatRGBA8,
+			pixels: []uint8{0, 0, 0, 0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			format, pixels, err := DecodeBlocks(test.format, test.width, test.height, test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != test.want {
+				t.Errorf("decoded to format %d, want %d", format, test.want)
+			}
+			if got := pixels.([]uint8); !bytes.Equal(got, test.pixels) {
+				t.Errorf("got pixels\n%v\nwant\n%v", got, test.pixels)
+			}
+		})
+	}
+}
+
+func fill64(v uint64) (indices [16]uint64) {
+	for i := range indices {
+		indices[i] = v
+	}
+	return indices
+}
+
+func TestDecodeBlocksFloat(t *testing.T) {
+	const maxHalf = 65504
+	tests := []struct {
+		name   string
+		format TextureFormat
+		width  int32
+		height int32
+		data   []byte
+		want   TextureFormat
+		pixels []float32
+	}{
+		{
+			name:   "BC4 signed",
+			format: TextureFormatBC4S,
+			width:  4, height: 1,
+			// -128 clamps to -127
+			data:   alphaBlock(127, 0x80, ramp(4)),
+			want:   TextureFormatR16F,
+			pixels: []float32{1, -1, 5.0 / 7, 3.0 / 7},
+		},
+		{
+			name:   "BC5 signed extremes",
+			format: TextureFormatBC5S,
+			width:  2, height: 1,
+			data:   append(alphaBlock(0, 10, fill64(6)), alphaBlock(0, 10, fill64(7))...),
+			want:   TextureFormatRG16F,
+			pixels: []float32{-1, 1, -1, 1},
+		},
+		{
+			name:   "BC6H mode 11 zero",
+			format: TextureFormatBC6H,
+			width:  1, height: 1,
+			data:   packBits([2]uint{3, 5}),
+			want:   TextureFormatRGB16F,
+			pixels: []float32{0, 0, 0},
+		},
+		{
+			// unsigned endpoints at their maximum unquantize to the largest half
+			name:   "BC6H mode 11 maximum",
+			format: TextureFormatBC6H,
+			width:  1, height: 1,
+			data:   packBits([2]uint{3, 5}, [2]uint{1<<60 - 1, 60}),
+			want:   TextureFormatRGB16F,
+			pixels: []float32{maxHalf, maxHalf, maxHalf},
+		},
+		{
+			name:   "BC6H reserved mode",
+			format: TextureFormatBC6H,
+			width:  1, height: 1,
+			data:   packBits([2]uint{0x13, 5}, [2]uint{1<<60 - 1, 60}),
+			want:   TextureFormatRGB16F,
+			pixels: []float32{0, 0, 0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			format, pixels, err := DecodeBlocks(test.format, test.width, test.height, test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != test.want {
+				t.Errorf("decoded to format %d, want %d", format, test.want)
+			}
+			got := pixels.([]float32)
+			if len(got) != len(test.pixels) {
+				t.Fatalf("got %d values, want %d", len(got), len(test.pixels))
+			}
+			for i := range got {
+				if math.Abs(float64(got[i]-test.pixels[i])) > 1e-6 {
+					t.Errorf("got pixels %v, want %v", got, test.pixels)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeBlocksErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format TextureFormat
+		width  int32
+		height int32
+		data   []byte
+	}{
+		{"uncompressed format", TextureFormatRGBA8, 4, 4, make([]byte, 64)},
+		{"truncated block", TextureFormatBC1, 4, 4, make([]byte, 7)},
+		{"missing blocks", TextureFormatBC7, 8, 4, make([]byte, 16)},
+		{"empty", TextureFormatBC3, 1, 1, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := DecodeBlocks(test.format, test.width, test.height, test.data); err == nil {
+				t.Error("decoded invalid data")
+			}
+		})
+	}
+}
+
+func TestHalfToFloat32(t *testing.T) {
+	tests := []struct {
+		half uint16
+		want float32
+	}{
+		{0x0000, 0},
+		{0x3C00, 1},
+		{0xC000, -2},
+		{0x3555, 0.33325195},
+		{0x7BFF, 65504},
+		{0x0001, 5.9604645e-08},
+		{0x03FF, 6.0975552e-05},
+		{0x7C00, float32(math.Inf(1))},
+		{0xFC00, float32(math.Inf(-1))},
+	}
+	for _, test := range tests {
+		if got := halfToFloat32(test.half); got != test.want {
+			t.Errorf("halfToFloat32(%#04x) = %v, want %v", test.half, got, test.want)
+		}
+	}
+	if got := halfToFloat32(0x7E00); !math.IsNaN(float64(got)) {
+		t.Errorf("halfToFloat32(0x7e00) = %v, want NaN", got)
+	}
+	if got := halfToFloat32(0x8000); got != 0 || !math.Signbit(float64(got)) {
+		t.Errorf("halfToFloat32(0x8000) = %v, want -0", got)
+	}
+}
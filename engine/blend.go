@@ -0,0 +1,15 @@
+package engine
+
+import "physics/scene"
+
+// BlendMode controls how a material's fragments combine with what is already
+// in the framebuffer, see scene.BlendMode
+type BlendMode = scene.BlendMode
+
+const (
+	BlendOpaque        = scene.BlendOpaque
+	BlendCutout        = scene.BlendCutout
+	BlendAlpha         = scene.BlendAlpha
+	BlendAdditive      = scene.BlendAdditive
+	BlendPremultiplied = scene.BlendPremultiplied
+)
@@ -38,11 +38,15 @@ func NewCamera(window *glfw.Window, position, target, up mgl32.Vec3) *Camera {
 	}
 }
 
+// EyePosition returns the world space position the camera is looking from
+func (c *Camera) EyePosition() mgl32.Vec3 {
+	// Apply rotation to camera position
+	return c.Rotation.Inverse().Rotate(c.Position)
+}
+
 // ViewMatrix returns the view matrix for the camera
 func (c *Camera) ViewMatrix() mgl32.Mat4 {
-	// Apply rotation to camera position
-	position := c.Rotation.Inverse().Rotate(c.Position)
-	return mgl32.LookAtV(position, c.Target, c.Up)
+	return mgl32.LookAtV(c.EyePosition(), c.Target, c.Up)
 }
 
 // ProjectionMatrix returns the projection matrix for the camera
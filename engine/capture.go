@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"fmt"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"image"
+	"image/png"
+	"os"
+)
+
+// CaptureFrame reads what has been drawn to the window's back buffer since the
+// last SwapBuffers. Call it before swapping. The pixels are sRGB encoded, as
+// they would be shown. The back buffer of a hidden window is unreliable, see
+// ContextOptions.Headless.
+func CaptureFrame(window *glfw.Window) (*image.RGBA, error) {
+	width, height := window.GetFramebufferSize()
+	img, err := readPixels(0, 0, int32(width), int32(height))
+	if err != nil {
+		return nil, err
+	}
+
+	// The window's alpha isn't meant to be seen, it may not even be stored
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img, nil
+}
+
+// CaptureFramebuffer reads color attachment n of f, resolving it first. Values
+// are read as stored, clamped to 8 bits, so HDR targets should be tone mapped
+// before capturing.
+func CaptureFramebuffer(f *Framebuffer, attachment int) (*image.RGBA, error) {
+	if attachment < 0 || attachment >= len(f.Color) {
+		return nil, fmt.Errorf("framebuffer has no color attachment %d", attachment)
+	}
+	f.Resolve()
+	return readPixels(f.Handle(), attachment, f.Width, f.Height)
+}
+
+// readPixels reads a color attachment of framebuffer, or the window's back
+// buffer, flipping it from OpenGL's bottom row first order to the image's top
+// row first
+func readPixels(framebuffer uint32, attachment int, width, height int32) (*image.RGBA, error) {
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("invalid capture size %dx%d", width, height)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	device.ReadPixels(framebuffer, attachment, width, height, img.Pix)
+
+	row := make([]byte, img.Stride)
+	for top, bottom := 0, int(height)-1; top < bottom; top, bottom = top+1, bottom-1 {
+		topRow := img.Pix[top*img.Stride : (top+1)*img.Stride]
+		bottomRow := img.Pix[bottom*img.Stride : (bottom+1)*img.Stride]
+		copy(row, topRow)
+		copy(topRow, bottomRow)
+		copy(bottomRow, row)
+	}
+	return img, nil
+}
+
+func SavePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,444 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"physics/gfx"
+	"strings"
+)
+
+type compressedFormatInfo struct {
+	blockBytes int
+	// decoded is the uncompressed format DecodeBlocks produces
+	decoded TextureFormat
+}
+
+var compressedFormats = map[TextureFormat]compressedFormatInfo{
+	TextureFormatBC1:   {8, TextureFormatRGBA8},
+	TextureFormatBC1A:  {8, TextureFormatRGBA8},
+	TextureFormatBC2:   {16, TextureFormatRGBA8},
+	TextureFormatBC3:   {16, TextureFormatRGBA8},
+	TextureFormatBC4:   {8, TextureFormatR8},
+	TextureFormatBC4S:  {8, TextureFormatR16F},
+	TextureFormatBC5:   {16, TextureFormatRG8},
+	TextureFormatBC5S:  {16, TextureFormatRG16F},
+	TextureFormatBC6H:  {16, TextureFormatRGB16F},
+	TextureFormatBC6HS: {16, TextureFormatRGB16F},
+	TextureFormatBC7:   {16, TextureFormatRGBA8},
+}
+
+// ForceCompressedTextureDecode decodes every compressed texture on the CPU,
+// e.g. to check the fallback path on a driver that supports all formats.
+var ForceCompressedTextureDecode = false
+
+// PreferCompressedTextures makes AssetManager.Texture load a .ktx2 or .dds file
+// with the same base name as the requested image in its place, so converted
+// textures replace the originals without editing the materials that use them.
+var PreferCompressedTextures = false
+
+// CompressedImage is a block compressed 2D texture or cube map read from a DDS
+// or KTX2 container. Levels holds the block data of each mip level, one entry
+// per face.
+type CompressedImage struct {
+	Format     TextureFormat
+	ColorSpace ColorSpace
+	Width      int32
+	Height     int32
+	Cubemap    bool
+	Levels     [][][]byte
+}
+
+// maxCompressedTextureSize is the largest width or height a container may
+// declare, the GL_MAX_TEXTURE_SIZE every GL 4.1 driver supports
+const maxCompressedTextureSize = 16384
+
+// compressedLevelSize is computed in int64 so sizes read from a file can't
+// overflow it
+func compressedLevelSize(format TextureFormat, width, height int32) int64 {
+	return (int64(width) + 3) / 4 * ((int64(height) + 3) / 4) * int64(compressedFormats[format].blockBytes)
+}
+
+func mipSize(size int32, level int) int32 {
+	if size >>= uint(level); size < 1 {
+		return 1
+	}
+	return size
+}
+
+// checkLevels rejects sizes and mip chains no texture can have, before their
+// level sizes are computed from them
+func (img *CompressedImage) checkLevels(container string, levels int) error {
+	if img.Width < 1 || img.Height < 1 || img.Width > maxCompressedTextureSize || img.Height > maxCompressedTextureSize {
+		return fmt.Errorf("%s texture has invalid size %dx%d", container, img.Width, img.Height)
+	}
+	if most := int(mipLevels(img.Width, img.Height)); levels > most {
+		return fmt.Errorf("%s file has %d mip levels, a %dx%d texture has at most %d", container, levels, img.Width, img.Height, most)
+	}
+	return nil
+}
+
+func (img *CompressedImage) faces() int {
+	if img.Cubemap {
+		return 6
+	}
+	return 1
+}
+
+// CompressedFormatSupported reports whether the driver can sample format
+// directly. Unsupported formats are decoded on the CPU when loaded.
+func CompressedFormatSupported(format TextureFormat) bool {
+	if _, ok := compressedFormats[format]; !ok {
+		return false
+	}
+	return device.FormatSupported(format)
+}
+
+var (
+	ddsMagic  = []byte("DDS ")
+	ktx2Magic = []byte{0xAB, 'K', 'T', 'X', ' ', '2', '0', 0xBB, '\r', '\n', 0x1A, '\n'}
+)
+
+func isCompressedContainer(header []byte) bool {
+	return bytes.HasPrefix(header, ddsMagic) || bytes.HasPrefix(header, ktx2Magic)
+}
+
+// ParseCompressedImage reads a DDS or KTX2 file, telling them apart by their magic
+func ParseCompressedImage(data []byte) (*CompressedImage, error) {
+	switch {
+	case bytes.HasPrefix(data, ddsMagic):
+		return ParseDDS(data)
+	case bytes.HasPrefix(data, ktx2Magic):
+		return ParseKTX2(data)
+	}
+	return nil, fmt.Errorf("not a DDS or KTX2 file")
+}
+
+const (
+	ddsHeaderSize      = 128
+	ddsDX10HeaderSize  = 20
+	ddsFlagMipMapCount = 0x20000
+	ddsPixelFourCC     = 0x4
+	ddsCaps2Cubemap    = 0x200
+	ddsCaps2AllFaces   = 0xFC00
+	ddsMiscTextureCube = 0x4
+)
+
+var ddsFourCCFormats = map[string]TextureFormat{
+	"DXT1": TextureFormatBC1A,
+	"DXT2": TextureFormatBC2,
+	"DXT3": TextureFormatBC2,
+	"DXT4": TextureFormatBC3,
+	"DXT5": TextureFormatBC3,
+	"ATI1": TextureFormatBC4,
+	"BC4U": TextureFormatBC4,
+	"BC4S": TextureFormatBC4S,
+	"ATI2": TextureFormatBC5,
+	"BC5U": TextureFormatBC5,
+	"BC5S": TextureFormatBC5S,
+}
+
+type containerFormat struct {
+	format     TextureFormat
+	colorSpace ColorSpace
+}
+
+var ddsDXGIFormats = map[uint32]containerFormat{
+	70: {TextureFormatBC1A, ColorSpaceLinear},
+	71: {TextureFormatBC1A, ColorSpaceLinear},
+	72: {TextureFormatBC1A, ColorSpaceSRGB},
+	73: {TextureFormatBC2, ColorSpaceLinear},
+	74: {TextureFormatBC2, ColorSpaceLinear},
+	75: {TextureFormatBC2, ColorSpaceSRGB},
+	76: {TextureFormatBC3, ColorSpaceLinear},
+	77: {TextureFormatBC3, ColorSpaceLinear},
+	78: {TextureFormatBC3, ColorSpaceSRGB},
+	79: {TextureFormatBC4, ColorSpaceLinear},
+	80: {TextureFormatBC4, ColorSpaceLinear},
+	81: {TextureFormatBC4S, ColorSpaceLinear},
+	82: {TextureFormatBC5, ColorSpaceLinear},
+	83: {TextureFormatBC5, ColorSpaceLinear},
+	84: {TextureFormatBC5S, ColorSpaceLinear},
+	94: {TextureFormatBC6H, ColorSpaceLinear},
+	95: {TextureFormatBC6H, ColorSpaceLinear},
+	96: {TextureFormatBC6HS, ColorSpaceLinear},
+	97: {TextureFormatBC7, ColorSpaceLinear},
+	98: {TextureFormatBC7, ColorSpaceLinear},
+	99: {TextureFormatBC7, ColorSpaceSRGB},
+}
+
+// ParseDDS reads a DDS file holding a BCn compressed 2D texture or cube map,
+// using either a legacy FourCC code or the DX10 extended header
+func ParseDDS(data []byte) (*CompressedImage, error) {
+	if len(data) < ddsHeaderSize || !bytes.HasPrefix(data, ddsMagic) {
+		return nil, fmt.Errorf("not a DDS file")
+	}
+	le := binary.LittleEndian
+	flags := le.Uint32(data[8:])
+	img := &CompressedImage{
+		Height: int32(le.Uint32(data[12:])),
+		Width:  int32(le.Uint32(data[16:])),
+	}
+	levels := 1
+	if flags&ddsFlagMipMapCount != 0 && le.Uint32(data[28:]) > 0 {
+		levels = int(le.Uint32(data[28:]))
+	}
+	if le.Uint32(data[80:])&ddsPixelFourCC == 0 {
+		return nil, fmt.Errorf("DDS file is not block compressed")
+	}
+
+	fourCC := string(data[84:88])
+	offset := ddsHeaderSize
+	caps2 := le.Uint32(data[112:])
+	img.Cubemap = caps2&ddsCaps2Cubemap != 0
+	if img.Cubemap && caps2&ddsCaps2AllFaces != ddsCaps2AllFaces {
+		return nil, fmt.Errorf("DDS cube map is missing faces")
+	}
+
+	if fourCC == "DX10" {
+		if len(data) < ddsHeaderSize+ddsDX10HeaderSize {
+			return nil, fmt.Errorf("DDS file is truncated")
+		}
+		dxgi, ok := ddsDXGIFormats[le.Uint32(data[128:])]
+		if !ok {
+			return nil, fmt.Errorf("unsupported DXGI format %d in DDS file", le.Uint32(data[128:]))
+		}
+		img.Format, img.ColorSpace = dxgi.format, dxgi.colorSpace
+		img.Cubemap = img.Cubemap || le.Uint32(data[136:])&ddsMiscTextureCube != 0
+		if arraySize := le.Uint32(data[140:]); arraySize > 1 {
+			return nil, fmt.Errorf("DDS texture arrays are not supported")
+		}
+		offset += ddsDX10HeaderSize
+	} else {
+		format, ok := ddsFourCCFormats[fourCC]
+		if !ok {
+			return nil, fmt.Errorf("unsupported DDS compression %q", fourCC)
+		}
+		img.Format = format
+	}
+	if err := img.checkLevels("DDS", levels); err != nil {
+		return nil, err
+	}
+
+	// faces are stored one after another, each with its whole mip chain
+	img.Levels = make([][][]byte, levels)
+	for level := range img.Levels {
+		img.Levels[level] = make([][]byte, img.faces())
+	}
+	for face := 0; face < img.faces(); face++ {
+		for level := 0; level < levels; level++ {
+			size := compressedLevelSize(img.Format, mipSize(img.Width, level), mipSize(img.Height, level))
+			if size > int64(len(data)-offset) {
+				return nil, fmt.Errorf("DDS file is truncated")
+			}
+			img.Levels[level][face] = data[offset : offset+int(size)]
+			offset += int(size)
+		}
+	}
+	return img, nil
+}
+
+var ktx2VkFormats = map[uint32]containerFormat{
+	131: {TextureFormatBC1, ColorSpaceLinear},
+	132: {TextureFormatBC1, ColorSpaceSRGB},
+	133: {TextureFormatBC1A, ColorSpaceLinear},
+	134: {TextureFormatBC1A, ColorSpaceSRGB},
+	135: {TextureFormatBC2, ColorSpaceLinear},
+	136: {TextureFormatBC2, ColorSpaceSRGB},
+	137: {TextureFormatBC3, ColorSpaceLinear},
+	138: {TextureFormatBC3, ColorSpaceSRGB},
+	139: {TextureFormatBC4, ColorSpaceLinear},
+	140: {TextureFormatBC4S, ColorSpaceLinear},
+	141: {TextureFormatBC5, ColorSpaceLinear},
+	142: {TextureFormatBC5S, ColorSpaceLinear},
+	143: {TextureFormatBC6H, ColorSpaceLinear},
+	144: {TextureFormatBC6HS, ColorSpaceLinear},
+	145: {TextureFormatBC7, ColorSpaceLinear},
+	146: {TextureFormatBC7, ColorSpaceSRGB},
+}
+
+const (
+	ktx2HeaderSize     = 80
+	ktx2LevelIndexSize = 24
+)
+
+// ParseKTX2 reads a KTX2 file holding a BCn compressed 2D texture or cube map.
+// Supercompressed (Basis, zstd) files are not supported.
+func ParseKTX2(data []byte) (*CompressedImage, error) {
+	if len(data) < ktx2HeaderSize || !bytes.HasPrefix(data, ktx2Magic) {
+		return nil, fmt.Errorf("not a KTX2 file")
+	}
+	le := binary.LittleEndian
+	vkFormat := le.Uint32(data[12:])
+	format, ok := ktx2VkFormats[vkFormat]
+	if !ok {
+		return nil, fmt.Errorf("unsupported VkFormat %d in KTX2 file", vkFormat)
+	}
+	img := &CompressedImage{
+		Format:     format.format,
+		ColorSpace: format.colorSpace,
+		Width:      int32(le.Uint32(data[20:])),
+		Height:     int32(le.Uint32(data[24:])),
+	}
+	depth, layers, faces := le.Uint32(data[28:]), le.Uint32(data[32:]), le.Uint32(data[36:])
+	levels := int(le.Uint32(data[40:]))
+	switch {
+	case depth > 0:
+		return nil, fmt.Errorf("3D KTX2 textures are not supported")
+	case layers > 1:
+		return nil, fmt.Errorf("KTX2 texture arrays are not supported")
+	case faces != 1 && faces != 6:
+		return nil, fmt.Errorf("KTX2 file has %d faces", faces)
+	case le.Uint32(data[44:]) != 0:
+		return nil, fmt.Errorf("supercompressed KTX2 files are not supported")
+	}
+	img.Cubemap = faces == 6
+	if levels == 0 {
+		levels = 1
+	}
+	if err := img.checkLevels("KTX2", levels); err != nil {
+		return nil, err
+	}
+	if len(data) < ktx2HeaderSize+levels*ktx2LevelIndexSize {
+		return nil, fmt.Errorf("KTX2 file is truncated")
+	}
+
+	// each level holds its faces one after another
+	img.Levels = make([][][]byte, levels)
+	for level := range img.Levels {
+		entry := data[ktx2HeaderSize+level*ktx2LevelIndexSize:]
+		offset, length := le.Uint64(entry), le.Uint64(entry[8:])
+		size := compressedLevelSize(img.Format, mipSize(img.Width, level), mipSize(img.Height, level))
+		if offset > uint64(len(data)) || length > uint64(len(data))-offset || length < uint64(size)*uint64(img.faces()) {
+			return nil, fmt.Errorf("KTX2 file is truncated")
+		}
+		img.Levels[level] = make([][]byte, img.faces())
+		for face := range img.Levels[level] {
+			start := int(offset) + face*int(size)
+			img.Levels[level][face] = data[start : start+int(size)]
+		}
+	}
+	return img, nil
+}
+
+// NewCompressedTexture uploads img with its precomputed mip levels. Formats the
+// driver can't sample are decoded on the CPU and uploaded uncompressed, with a
+// mip chain generated when the file has none and opts asks for one. FlipY is
+// ignored, block data can't be flipped without decoding it.
+func NewCompressedTexture(img *CompressedImage, opts TextureOptions) (*Texture, error) {
+	if _, ok := compressedFormats[img.Format]; !ok {
+		return nil, fmt.Errorf("texture format %d is not block compressed", img.Format)
+	}
+	if img.Width < 1 || img.Height < 1 || len(img.Levels) == 0 {
+		return nil, fmt.Errorf("invalid compressed texture size %dx%d", img.Width, img.Height)
+	}
+	colorSpace := opts.ColorSpace
+	if img.ColorSpace == ColorSpaceSRGB {
+		colorSpace = ColorSpaceSRGB
+	}
+	if colorSpace == ColorSpaceSRGB && !img.Format.HasSRGB() {
+		return nil, fmt.Errorf("texture format %d has no sRGB variant", img.Format)
+	}
+
+	target := gfx.Texture2D
+	if img.Cubemap {
+		target = gfx.TextureCubeMap
+	}
+
+	if ForceCompressedTextureDecode || !CompressedFormatSupported(img.Format) {
+		return decodeCompressedTexture(target, img, colorSpace, opts)
+	}
+
+	t := &Texture{
+		Target:     target,
+		Width:      img.Width,
+		Height:     img.Height,
+		Layers:     1,
+		Levels:     int32(len(img.Levels)),
+		Format:     img.Format,
+		ColorSpace: colorSpace,
+	}
+	t.allocate(opts.Sampler)
+	for level, faces := range img.Levels {
+		width, height := mipSize(img.Width, level), mipSize(img.Height, level)
+		for face, data := range faces {
+			faceTarget := target
+			if img.Cubemap {
+				faceTarget = gfx.TextureCubeMapPositiveX + gfx.TextureTarget(face)
+			}
+			device.CompressedTexImage2D(t.handle, faceTarget, int32(level), t.texelFormat(), width, height, data)
+		}
+	}
+	return t, nil
+}
+
+func decodeCompressedTexture(target gfx.TextureTarget, img *CompressedImage, colorSpace ColorSpace, opts TextureOptions) (*Texture, error) {
+	t := &Texture{
+		Target:     target,
+		Width:      img.Width,
+		Height:     img.Height,
+		Layers:     1,
+		Levels:     int32(len(img.Levels)),
+		Format:     compressedFormats[img.Format].decoded,
+		ColorSpace: colorSpace,
+	}
+	generateMipmaps := t.Levels == 1 && opts.Mipmaps
+	if generateMipmaps {
+		t.Levels = mipLevels(img.Width, img.Height)
+	}
+	t.allocate(opts.Sampler)
+
+	for level, faces := range img.Levels {
+		width, height := mipSize(img.Width, level), mipSize(img.Height, level)
+		for face, data := range faces {
+			_, pixels, err := DecodeBlocks(img.Format, width, height, data)
+			if err != nil {
+				t.Delete()
+				return nil, err
+			}
+			faceTarget := target
+			if img.Cubemap {
+				faceTarget = gfx.TextureCubeMapPositiveX + gfx.TextureTarget(face)
+			}
+			device.TexImage2D(t.handle, faceTarget, int32(level), t.texelFormat(), width, height, pixels)
+		}
+	}
+	t.finish(generateMipmaps)
+	return t, nil
+}
+
+// allocate creates the device texture for a texture whose levels are uploaded one
+// by one, limiting sampling to the levels the texture has
+func (t *Texture) allocate(sampler SamplerState) {
+	t.handle = device.CreateTexture(t.Target, t.Levels)
+	t.SetSampler(sampler)
+}
+
+func loadCompressedTexture(data []byte, opts TextureOptions) (*Texture, error) {
+	img, err := ParseCompressedImage(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode texture file: %w", err)
+	}
+	return NewCompressedTexture(img, opts)
+}
+
+// compressedVariant returns a .ktx2 or .dds file next to path with the same
+// base name when PreferCompressedTextures is set and one exists, logging the
+// swap.
+func compressedVariant(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if !PreferCompressedTextures || ext == ".ktx2" || ext == ".dds" {
+		return path
+	}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, candidate := range []string{".ktx2", ".KTX2", ".dds", ".DDS"} {
+		if _, err := os.Stat(base + candidate); err == nil {
+			log.Printf("loading %s in place of %s", base+candidate, path)
+			return base + candidate
+		}
+	}
+	return path
+}
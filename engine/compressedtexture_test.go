@@ -0,0 +1,314 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// ddsFile builds a DDS file with the given FourCC, followed by the DX10 header
+// when dxgi is set, and then data
+func ddsFile(width, height, levels uint32, fourCC string, caps2 uint32, dxgi, misc uint32, data []byte) []byte {
+	header := make([]byte, ddsHeaderSize)
+	le := binary.LittleEndian
+	copy(header, ddsMagic)
+	le.PutUint32(header[4:], 124)
+	flags := uint32(0x1007)
+	if levels > 0 {
+		flags |= ddsFlagMipMapCount
+	}
+	le.PutUint32(header[8:], flags)
+	le.PutUint32(header[12:], height)
+	le.PutUint32(header[16:], width)
+	le.PutUint32(header[28:], levels)
+	le.PutUint32(header[76:], 32)
+	le.PutUint32(header[80:], ddsPixelFourCC)
+	copy(header[84:], fourCC)
+	le.PutUint32(header[112:], caps2)
+	if fourCC == "DX10" {
+		dx10 := make([]byte, ddsDX10HeaderSize)
+		le.PutUint32(dx10, dxgi)
+		le.PutUint32(dx10[4:], 3)
+		le.PutUint32(dx10[8:], misc)
+		le.PutUint32(dx10[12:], 1)
+		header = append(header, dx10...)
+	}
+	return append(header, data...)
+}
+
+type ktx2Level struct {
+	offset, length uint64
+}
+
+// ktx2File builds a KTX2 file whose level index points at the given ranges of
+// the whole file, which ends with data
+func ktx2File(vkFormat, width, height, faces uint32, levels []ktx2Level, data []byte) []byte {
+	file := make([]byte, ktx2HeaderSize+len(levels)*ktx2LevelIndexSize)
+	le := binary.LittleEndian
+	copy(file, ktx2Magic)
+	le.PutUint32(file[12:], vkFormat)
+	le.PutUint32(file[16:], 1)
+	le.PutUint32(file[20:], width)
+	le.PutUint32(file[24:], height)
+	le.PutUint32(file[36:], faces)
+	le.PutUint32(file[40:], uint32(len(levels)))
+	for i, level := range levels {
+		entry := file[ktx2HeaderSize+i*ktx2LevelIndexSize:]
+		le.PutUint64(entry, level.offset)
+		le.PutUint64(entry[8:], level.length)
+		le.PutUint64(entry[16:], level.length)
+	}
+	return append(file, data...)
+}
+
+// blocks returns n blocks of size bytes, each filled with its index
+func blocks(n, size int) []byte {
+	data := make([]byte, 0, n*size)
+	for i := 0; i < n; i++ {
+		data = append(data, bytes.Repeat([]byte{byte(i)}, size)...)
+	}
+	return data
+}
+
+func TestParseDDS(t *testing.T) {
+	// an 8x8 BC1 image has 4 blocks at level 0, then 1 block at 4x4, 2x2 and 1x1
+	mips := blocks(7, 8)
+	cube := blocks(6*2, 16)
+
+	tests := []struct {
+		name       string
+		file       []byte
+		format     TextureFormat
+		colorSpace ColorSpace
+		width      int32
+		height     int32
+		cubemap    bool
+		// levelBytes is the size of each level of each face
+		levelBytes []int
+		err        string
+	}{
+		{
+			name:       "DXT1 mip chain",
+			file:       ddsFile(8, 8, 4, "DXT1", 0, 0, 0, mips),
+			format:     TextureFormatBC1A,
+			width:      8,
+			height:     8,
+			levelBytes: []int{32, 8, 8, 8},
+		},
+		{
+			name:       "DXT5 without mip count",
+			file:       ddsFile(4, 4, 0, "DXT5", 0, 0, 0, blocks(1, 16)),
+			format:     TextureFormatBC3,
+			width:      4,
+			height:     4,
+			levelBytes: []int{16},
+		},
+		{
+			name:       "non multiple of 4",
+			file:       ddsFile(5, 3, 1, "ATI2", 0, 0, 0, blocks(2, 16)),
+			format:     TextureFormatBC5,
+			width:      5,
+			height:     3,
+			levelBytes: []int{32},
+		},
+		{
+			name:       "DX10 sRGB BC7",
+			file:       ddsFile(4, 4, 1, "DX10", 0, 99, 0, blocks(1, 16)),
+			format:     TextureFormatBC7,
+			colorSpace: ColorSpaceSRGB,
+			width:      4,
+			height:     4,
+			levelBytes: []int{16},
+		},
+		{
+			name:       "cube map",
+			file:       ddsFile(4, 4, 2, "BC5U", ddsCaps2Cubemap|ddsCaps2AllFaces, 0, 0, cube),
+			format:     TextureFormatBC5,
+			width:      4,
+			height:     4,
+			cubemap:    true,
+			levelBytes: []int{16, 16},
+		},
+		{
+			name:       "DX10 cube map",
+			file:       ddsFile(4, 4, 2, "DX10", 0, 98, ddsMiscTextureCube, cube),
+			format:     TextureFormatBC7,
+			width:      4,
+			height:     4,
+			cubemap:    true,
+			levelBytes: []int{16, 16},
+		},
+		{name: "empty", file: nil, err: "not a DDS file"},
+		{name: "wrong magic", file: append([]byte("PNG "), ddsFile(4, 4, 1, "DXT1", 0, 0, 0, blocks(1, 8))[4:]...), err: "not a DDS file"},
+		{name: "truncated header", file: ddsFile(4, 4, 1, "DXT1", 0, 0, 0, nil)[:100], err: "not a DDS file"},
+		{name: "truncated DX10 header", file: ddsFile(4, 4, 1, "DX10", 0, 98, 0, nil)[:ddsHeaderSize+10], err: "truncated"},
+		{name: "truncated data", file: ddsFile(8, 8, 1, "DXT1", 0, 0, 0, blocks(3, 8)), err: "truncated"},
+		{name: "truncated mip chain", file: ddsFile(8, 8, 4, "DXT1", 0, 0, 0, blocks(6, 8)), err: "truncated"},
+		{name: "missing cube faces", file: ddsFile(4, 4, 1, "DXT1", ddsCaps2Cubemap|0x0C00, 0, 0, blocks(6, 8)), err: "missing faces"},
+		{name: "unknown FourCC", file: ddsFile(4, 4, 1, "ABCD", 0, 0, 0, blocks(1, 8)), err: "unsupported DDS compression"},
+		{name: "unknown DXGI format", file: ddsFile(4, 4, 1, "DX10", 0, 28, 0, blocks(1, 16)), err: "unsupported DXGI format"},
+		{name: "zero size", file: ddsFile(0, 4, 1, "DXT1", 0, 0, 0, blocks(1, 8)), err: "invalid size"},
+		{name: "negative size", file: ddsFile(0x80000000, 4, 1, "DXT1", 0, 0, 0, blocks(1, 8)), err: "invalid size"},
+		{name: "too many levels", file: ddsFile(4, 4, 0xFFFFFFFF, "DXT1", 0, 0, 0, blocks(3, 8)), err: "mip levels"},
+		{name: "overflowing size", file: ddsFile(0x7FFFFFFE, 0x7FFFFFFE, 31, "DXT1", 0, 0, 0, blocks(3, 8)), err: "invalid size"},
+		{name: "too large", file: ddsFile(maxCompressedTextureSize+4, 4, 1, "DXT1", 0, 0, 0, blocks(3, 8)), err: "invalid size"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img, err := ParseDDS(test.file)
+			checkCompressedImage(t, img, err, test.err, test.format, test.colorSpace, test.width, test.height, test.cubemap, test.levelBytes)
+		})
+	}
+}
+
+func TestParseKTX2(t *testing.T) {
+	header := func(levels int) uint64 {
+		return uint64(ktx2HeaderSize + levels*ktx2LevelIndexSize)
+	}
+
+	tests := []struct {
+		name       string
+		file       []byte
+		format     TextureFormat
+		colorSpace ColorSpace
+		width      int32
+		height     int32
+		cubemap    bool
+		levelBytes []int
+		err        string
+	}{
+		{
+			name:       "BC1 sRGB mip chain",
+			file:       ktx2File(132, 8, 8, 1, []ktx2Level{{header(2) + 8, 32}, {header(2), 8}}, blocks(5, 8)),
+			format:     TextureFormatBC1,
+			colorSpace: ColorSpaceSRGB,
+			width:      8,
+			height:     8,
+			levelBytes: []int{32, 8},
+		},
+		{
+			name:       "BC6H cube map",
+			file:       ktx2File(143, 4, 4, 6, []ktx2Level{{header(1), 96}}, blocks(6, 16)),
+			format:     TextureFormatBC6H,
+			width:      4,
+			height:     4,
+			cubemap:    true,
+			levelBytes: []int{16},
+		},
+		{name: "wrong magic", file: append([]byte("KTX 11"), ktx2File(145, 4, 4, 1, []ktx2Level{{header(1), 16}}, blocks(1, 16))[6:]...), err: "not a KTX2 file"},
+		{name: "truncated header", file: ktx2File(145, 4, 4, 1, nil, nil)[:40], err: "not a KTX2 file"},
+		{name: "truncated level index", file: ktx2File(145, 4, 4, 1, []ktx2Level{{header(2), 16}, {header(2), 16}}, nil)[:header(1)], err: "truncated"},
+		{name: "truncated data", file: ktx2File(145, 4, 4, 1, []ktx2Level{{header(1), 16}}, blocks(1, 8)), err: "truncated"},
+		{name: "level shorter than its blocks", file: ktx2File(145, 8, 8, 1, []ktx2Level{{header(1), 16}}, blocks(4, 16)), err: "truncated"},
+		{name: "offset past the end", file: ktx2File(145, 4, 4, 1, []ktx2Level{{1 << 40, 16}}, blocks(1, 16)), err: "truncated"},
+		{name: "offset overflows", file: ktx2File(145, 4, 4, 1, []ktx2Level{{^uint64(0), 2}}, blocks(1, 16)), err: "truncated"},
+		{name: "unknown VkFormat", file: ktx2File(37, 4, 4, 1, []ktx2Level{{header(1), 64}}, make([]byte, 64)), err: "unsupported VkFormat"},
+		{name: "three faces", file: ktx2File(145, 4, 4, 3, []ktx2Level{{header(1), 48}}, blocks(3, 16)), err: "3 faces"},
+		{name: "zero size", file: ktx2File(145, 4, 0, 1, []ktx2Level{{header(1), 16}}, blocks(1, 16)), err: "invalid size"},
+		{name: "too many levels", file: ktx2File(145, 4, 4, 1, make([]ktx2Level, 4), nil), err: "mip levels"},
+		{name: "too large", file: ktx2File(145, 0x7FFFFFFE, 4, 1, []ktx2Level{{header(1), 16}}, blocks(1, 16)), err: "invalid size"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img, err := ParseKTX2(test.file)
+			checkCompressedImage(t, img, err, test.err, test.format, test.colorSpace, test.width, test.height, test.cubemap, test.levelBytes)
+		})
+	}
+
+	t.Run("no level count", func(t *testing.T) {
+		// a level count of 0 asks for a generated mip chain, the file has one level
+		file := ktx2File(145, 4, 4, 1, []ktx2Level{{header(1), 16}}, blocks(1, 16))
+		binary.LittleEndian.PutUint32(file[40:], 0)
+		img, err := ParseKTX2(file)
+		checkCompressedImage(t, img, err, "", TextureFormatBC7, ColorSpaceLinear, 4, 4, false, []int{16})
+	})
+
+	t.Run("supercompressed", func(t *testing.T) {
+		file := ktx2File(145, 4, 4, 1, []ktx2Level{{header(1), 16}}, blocks(1, 16))
+		binary.LittleEndian.PutUint32(file[44:], 2)
+		if _, err := ParseKTX2(file); err == nil || !strings.Contains(err.Error(), "supercompressed") {
+			t.Errorf("got error %v, want supercompressed", err)
+		}
+	})
+}
+
+func checkCompressedImage(t *testing.T, img *CompressedImage, err error, wantErr string, format TextureFormat, colorSpace ColorSpace, width, height int32, cubemap bool, levelBytes []int) {
+	t.Helper()
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("got error %v, want one containing %q", err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Format != format || img.ColorSpace != colorSpace {
+		t.Errorf("format %d color space %d, want %d and %d", img.Format, img.ColorSpace, format, colorSpace)
+	}
+	if img.Width != width || img.Height != height || img.Cubemap != cubemap {
+		t.Errorf("%dx%d cube map %v, want %dx%d cube map %v", img.Width, img.Height, img.Cubemap, width, height, cubemap)
+	}
+	if len(img.Levels) != len(levelBytes) {
+		t.Fatalf("%d levels, want %d", len(img.Levels), len(levelBytes))
+	}
+	for level, faces := range img.Levels {
+		if len(faces) != img.faces() {
+			t.Fatalf("level %d has %d faces, want %d", level, len(faces), img.faces())
+		}
+		for face, data := range faces {
+			if len(data) != levelBytes[level] {
+				t.Errorf("level %d face %d is %d bytes, want %d", level, face, len(data), levelBytes[level])
+			}
+		}
+	}
+}
+
+func TestParseDDSFaceOrder(t *testing.T) {
+	// each face holds its whole mip chain before the next face
+	img, err := ParseDDS(ddsFile(4, 4, 2, "DXT1", ddsCaps2Cubemap|ddsCaps2AllFaces, 0, 0, blocks(12, 8)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for face := 0; face < 6; face++ {
+		for level := 0; level < 2; level++ {
+			if got, want := img.Levels[level][face][0], byte(face*2+level); got != want {
+				t.Errorf("level %d face %d starts with block %d, want %d", level, face, got, want)
+			}
+		}
+	}
+}
+
+func TestParseCompressedImage(t *testing.T) {
+	dds := ddsFile(4, 4, 1, "DXT1", 0, 0, 0, blocks(1, 8))
+	ktx2 := ktx2File(145, 4, 4, 1, []ktx2Level{{ktx2HeaderSize + ktx2LevelIndexSize, 16}}, blocks(1, 16))
+	tests := []struct {
+		name   string
+		file   []byte
+		format TextureFormat
+		err    bool
+	}{
+		{"DDS", dds, TextureFormatBC1A, false},
+		{"KTX2", ktx2, TextureFormatBC7, false},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n"), 0, true},
+		{"empty", nil, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img, err := ParseCompressedImage(test.file)
+			if test.err {
+				if err == nil {
+					t.Fatal("parsed a file that isn't DDS or KTX2")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Format != test.format {
+				t.Errorf("format %d, want %d", img.Format, test.format)
+			}
+		})
+	}
+}
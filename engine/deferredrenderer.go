@@ -0,0 +1,457 @@
+package engine
+
+import (
+	"fmt"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"physics/gfx"
+)
+
+// G-buffer attachments, in the order of the outputs in common/gbuffer.glsl
+const (
+	gBufferAlbedo = iota
+	gBufferNormal
+	gBufferMaterial
+	// gBufferLight accumulates lighting. The geometry pass writes emission to
+	// it and each light adds its contribution on top.
+	gBufferLight
+	gBufferAttachments
+)
+
+var gBufferFormats = [gBufferAttachments]TextureFormat{
+	gBufferAlbedo:   TextureFormatRGBA8,
+	gBufferNormal:   TextureFormatRGBA16F,
+	gBufferMaterial: TextureFormatRGBA8,
+	gBufferLight:    TextureFormatRGBA16F,
+}
+
+var gBufferSamplers = [...]string{
+	gBufferAlbedo:   "gAlbedo",
+	gBufferNormal:   "gNormal",
+	gBufferMaterial: "gMaterial",
+}
+
+// deferredDefines selects the variant of a material shader that writes the
+// G-buffer instead of shading
+var deferredDefines = ShaderDefines{"DEFERRED": "1"}
+
+// lightVolumeScale grows the light volume sphere, whose faces lie inside the
+// unit sphere, so it covers the light's whole range
+const lightVolumeScale = 1.1
+
+// DeferredRenderer draws opaque objects into a G-buffer and then lights them
+// one light volume at a time, so a light only costs the pixels it reaches and
+// the number of lights isn't limited to MaxLights. Transparent objects, and
+// opaque ones whose shader has no DEFERRED variant or whose ObjectRenderer
+// isn't a DeferredObjectRenderer, are drawn forward on top. The G-buffer keeps
+// each surface's shading model, so Phong and PBR materials light as they do
+// in the forward renderer.
+type DeferredRenderer struct {
+	drawer
+
+	// gbuffers holds one G-buffer per render target, nil being the window
+	gbuffers map[*Framebuffer]*gBuffer
+	deferred shaderVariants
+
+	pointLightShader  *ShaderProgram
+	directionalShader *ShaderProgram
+	fogShader         *ShaderProgram
+	lightVolume       *Mesh
+
+	lights  []*Light
+	forward []*DrawItem
+}
+
+func NewDeferredRenderer(window *glfw.Window) (*DeferredRenderer, error) {
+	pointLightShader, err := NewShaderProgram("shaders/deferred/light.vert", "shaders/deferred/light.frag")
+	if err != nil {
+		return nil, err
+	}
+	directionalShader, err := pointLightShader.Variant(ShaderDefines{"DIRECTIONAL": "1"})
+	if err != nil {
+		pointLightShader.Release()
+		return nil, err
+	}
+
+	fogShader, err := NewPostShader("shaders/deferred/fog.frag", nil)
+	if err != nil {
+		directionalShader.Release()
+		pointLightShader.Release()
+		return nil, err
+	}
+
+	drawer, err := newDrawer(window)
+	if err != nil {
+		fogShader.Release()
+		directionalShader.Release()
+		pointLightShader.Release()
+		return nil, err
+	}
+
+	r := &DeferredRenderer{
+		drawer:   drawer,
+		gbuffers: make(map[*Framebuffer]*gBuffer),
+		deferred: newShaderVariants(deferredDefines, func(variant *ShaderProgram) bool {
+			return device.FragDataLocation(variant.Handle(), "gAlbedo") >= 0
+		}),
+		pointLightShader:  pointLightShader,
+		directionalShader: directionalShader,
+		fogShader:         fogShader,
+		lightVolume:       newSphereMesh(16, 12),
+	}
+	return r, nil
+}
+
+func (r *DeferredRenderer) RenderPrimaryCamera(scene *Scene) error {
+	return RenderScene(r, scene)
+}
+
+func (r *DeferredRenderer) BeginFrame(scene *Scene) {
+	r.beginFrame(scene)
+	r.lights = scene.Lights
+}
+
+func (r *DeferredRenderer) Submit(item DrawItem) {
+	r.queue.Submit(item)
+}
+
+// RenderView runs the geometry, light and forward passes for the submitted
+// items and copies the result to the target. Multisampled targets receive it
+// already resolved.
+func (r *DeferredRenderer) RenderView(camera *Camera) error {
+	queue := &r.queue
+	width, height := r.targetSize()
+	if width < 1 || height < 1 {
+		queue.Reset()
+		return nil
+	}
+	r.setView(camera)
+
+	gbuffer := r.gbuffers[r.target]
+	if gbuffer == nil {
+		gbuffer = &gBuffer{}
+		r.gbuffers[r.target] = gbuffer
+	}
+	if err := gbuffer.resize(width, height); err != nil {
+		queue.Reset()
+		return err
+	}
+	device.Viewport(0, 0, width, height)
+
+	queue.Sort(camera.EyePosition())
+
+	r.renderGeometry(gbuffer, queue)
+	if r.SSAO != nil {
+		if err := r.applyAmbientOcclusion(gbuffer); err != nil {
+			queue.Reset()
+			return err
+		}
+	}
+	gbuffer.copyDepth()
+	r.renderLights(gbuffer)
+	r.renderFog(gbuffer)
+	r.renderForward(gbuffer, queue)
+
+	if r.target != nil {
+		gbuffer.present(r.target.Handle(), r.target.Depth != nil)
+	} else {
+		gbuffer.present(0, false)
+	}
+	queue.Reset()
+	return nil
+}
+
+func (r *DeferredRenderer) EndFrame() {}
+
+// renderGeometry fills the G-buffer with the opaque items whose shaders have a
+// deferred variant, or whose renderers can draw into it, and sets the others
+// aside for the forward pass
+func (r *DeferredRenderer) renderGeometry(gbuffer *gBuffer, queue *RenderQueue) {
+	device.BindFramebuffer(gbuffer.geometry)
+
+	// Depth writes have to be on for the clear to reach the depth buffer
+	r.state.apply(RenderState{}, BlendOpaque)
+	device.Clear(gfx.ColorBuffer | gfx.DepthBuffer)
+
+	r.forward = r.forward[:0]
+	r.bound = boundState{}
+	for i := range queue.Opaque {
+		item := &queue.Opaque[i]
+		if item.Renderer != nil {
+			if !r.drawDeferredCustom(item) {
+				r.forward = append(r.forward, item)
+			}
+			continue
+		}
+		shader := r.deferred.get(item.Material.GetShader())
+		if shader == nil {
+			r.forward = append(r.forward, item)
+			continue
+		}
+		r.draw(item, shader)
+	}
+	r.unbind()
+}
+
+// drawDeferredCustom hands item to its DeferredObjectRenderer, returning false
+// when it has none or it couldn't draw the item. Instanced items are left to
+// the forward pass.
+func (r *DeferredRenderer) drawDeferredCustom(item *DrawItem) bool {
+	renderer, ok := item.Renderer.(DeferredObjectRenderer)
+	if !ok || item.Instances != nil {
+		return false
+	}
+	r.unbind()
+	r.state.apply(item.Material.RenderState, BlendOpaque)
+	drawn := renderer.RenderDeferred(item.Mesh, *item.Material, item.Model)
+
+	r.state.invalidate()
+	r.frameBuffer.Bind()
+	r.objectBuffer.Bind()
+	if drawn {
+		r.stats.Objects += item.objects
+	}
+	return drawn
+}
+
+// applyAmbientOcclusion darkens the ambient light the geometry pass wrote to
+// the light buffer, before any light is added to it
+func (r *DeferredRenderer) applyAmbientOcclusion(gbuffer *gBuffer) error {
+	r.state.apply(RenderState{}, BlendOpaque)
+	occlusion, err := r.SSAO.compute(gbuffer.depth, gbuffer.textures[gBufferNormal], r.frame.Projection, r.frame.View)
+	if err != nil {
+		return err
+	}
+	device.BindFramebuffer(gbuffer.lighting)
+	device.Viewport(0, 0, gbuffer.width, gbuffer.height)
+	r.SSAO.modulate(occlusion)
+	device.BindFramebuffer(0)
+	return nil
+}
+
+// renderLights adds every light's contribution to the light buffer. Point
+// lights draw the back faces of a sphere around them, lighting the pixels
+// whose surface lies in front of it.
+func (r *DeferredRenderer) renderLights(gbuffer *gBuffer) {
+	device.BindFramebuffer(gbuffer.lighting)
+
+	// The light pass sets its state directly, the tracker picks up after it
+	r.state.invalidate()
+
+	inverseViewProjection := r.frame.Projection.Mul4(r.frame.View).Inv()
+
+	if len(r.lights) == 0 {
+		// No scene lights, light.frag matches each forward shader's constant
+		// directional light
+		shader := r.directionalShader
+		gbuffer.bind(shader, inverseViewProjection)
+		shader.SetVec3("lightColor", mgl32.Vec3{1, 1, 1})
+
+		device.SetPipeline(PipelineState{DepthFunc: gfx.CompareLess, Blend: AdditiveBlend()})
+		DrawFullscreen()
+		r.stats.DrawCalls++
+	} else {
+		shader := r.pointLightShader
+		gbuffer.bind(shader, inverseViewProjection)
+		r.PointShadows.Bind(shader)
+
+		// Back faces past the far plane are clamped onto it rather than
+		// clipped, so lights whose volume reaches beyond it still shade
+		device.SetPipeline(PipelineState{DepthTest: true, DepthFunc: gfx.CompareGreaterEqual, DepthClamp: true, Cull: CullFront, Blend: AdditiveBlend()})
+		count := int32(len(r.lightVolume.Indices))
+		for _, light := range r.lights {
+			lightRange := light.EffectiveRange()
+			shader.SetVec3("lightPosition", light.Position)
+			shader.SetVec3("lightColor", light.Color)
+			shader.SetFloat("lightRange", lightRange)
+			shader.SetInt("lightShadowLayer", int32(light.shadowLayer))
+			shader.SetFloat("volumeRadius", lightRange*lightVolumeScale)
+			device.Draw(DrawCall{VertexArray: r.lightVolume.Vao, Count: count, Indexed: true})
+			r.stats.DrawCalls++
+		}
+	}
+
+	device.UseProgram(0)
+	r.state.invalidate()
+}
+
+// renderFog blends the scene's fog over the lit G-buffer surfaces. What the
+// forward pass draws applies fog in its own shaders.
+func (r *DeferredRenderer) renderFog(gbuffer *gBuffer) {
+	if FogMode(r.frame.Fog.Mode) == FogNone {
+		return
+	}
+	device.BindFramebuffer(gbuffer.lighting)
+	r.state.apply(RenderState{DepthFunc: gfx.CompareAlways}, BlendAlpha)
+
+	shader := r.fogShader
+	shader.Use()
+	gbuffer.depth.Bind(0)
+	shader.SetSampler("gDepth", 0)
+	shader.SetMat4("inverseViewProjection", r.frame.Projection.Mul4(r.frame.View).Inv())
+	DrawFullscreen()
+	shader.Unuse()
+	r.stats.DrawCalls++
+}
+
+// bind makes the G-buffer textures available to a light shader
+func (g *gBuffer) bind(shader *ShaderProgram, inverseViewProjection mgl32.Mat4) {
+	shader.Use()
+	for i, name := range gBufferSamplers {
+		g.textures[i].Bind(i)
+		shader.SetSampler(name, i)
+	}
+	depthUnit := len(gBufferSamplers)
+	g.depth.Bind(depthUnit)
+	shader.SetSampler("gDepth", depthUnit)
+	shader.SetMat4("inverseViewProjection", inverseViewProjection)
+}
+
+// renderForward draws what the G-buffer can't hold and the sky into the light
+// buffer, depth tested against the opaque geometry
+func (r *DeferredRenderer) renderForward(gbuffer *gBuffer, queue *RenderQueue) {
+	device.BindFramebuffer(gbuffer.lighting)
+
+	r.bound = boundState{}
+	for _, item := range r.forward {
+		r.draw(item, item.Material.GetShader())
+	}
+	r.drawSky()
+	for i := range queue.Transparent {
+		r.draw(&queue.Transparent[i], queue.Transparent[i].Material.GetShader())
+	}
+	r.unbind()
+
+	// Leave the defaults behind for the shadow pass and anything drawn after
+	r.state.apply(RenderState{}, BlendOpaque)
+}
+
+// Delete frees everything NewDeferredRenderer created, and the G-buffers and
+// shader variants built since
+func (r *DeferredRenderer) Delete() {
+	for target, gbuffer := range r.gbuffers {
+		gbuffer.delete()
+		delete(r.gbuffers, target)
+	}
+	r.lightVolume.Delete()
+	r.deferred.delete()
+	r.fogShader.Release()
+	r.directionalShader.Release()
+	r.pointLightShader.Release()
+	r.delete()
+}
+
+// gBuffer is the set of screen sized textures the deferred renderer draws
+// into. The geometry framebuffer writes every attachment; the lighting one
+// writes only the light buffer and tests against a copy of the depth, so the
+// depth texture can be sampled while lights are drawn.
+type gBuffer struct {
+	width, height int32
+
+	geometry uint32
+	lighting uint32
+	textures [gBufferAttachments]*Texture
+	depth    *Texture
+	// depthCopy is the lighting framebuffer's depth renderbuffer
+	depthCopy uint32
+}
+
+// resize (re)creates the attachments whenever the window size changes
+func (g *gBuffer) resize(width, height int32) error {
+	if g.geometry != 0 && g.width == width && g.height == height {
+		return nil
+	}
+	g.delete()
+	g.width, g.height = width, height
+
+	opts := TextureOptions{Sampler: SamplerState{MinFilter: gfx.FilterNearest, MagFilter: gfx.FilterNearest}}
+	var err error
+	for i, format := range gBufferFormats {
+		if g.textures[i], err = NewTexture2D(width, height, format, nil, opts); err != nil {
+			return err
+		}
+	}
+	if g.depth, err = NewTexture2D(width, height, TextureFormatDepth24Stencil8, nil, opts); err != nil {
+		return err
+	}
+
+	geometry := make([]FramebufferAttachment, 0, gBufferAttachments+1)
+	for i, texture := range g.textures {
+		geometry = append(geometry, FramebufferAttachment{Attachment: gfx.ColorAttachment(i), Texture: texture.Handle()})
+	}
+	geometry = append(geometry, FramebufferAttachment{Attachment: gfx.AttachmentDepthStencil, Texture: g.depth.Handle()})
+	if g.geometry, err = device.CreateFramebuffer(geometry); err != nil {
+		return fmt.Errorf("G-buffer geometry %w", err)
+	}
+
+	g.depthCopy = device.CreateRenderbuffer(TexelFormat{Format: TextureFormatDepth24Stencil8}, width, height, 0)
+	g.lighting, err = device.CreateFramebuffer([]FramebufferAttachment{
+		{Attachment: gfx.AttachmentColor0, Texture: g.textures[gBufferLight].Handle()},
+		{Attachment: gfx.AttachmentDepthStencil, Renderbuffer: g.depthCopy},
+	})
+	if err != nil {
+		return fmt.Errorf("G-buffer lighting %w", err)
+	}
+	return nil
+}
+
+// copyDepth copies the geometry pass' depth to the lighting framebuffer
+func (g *gBuffer) copyDepth() {
+	device.BlitFramebuffer(g.geometry, g.lighting, g.width, g.height, gfx.DepthBuffer, -1)
+}
+
+// present copies the light buffer, and the depth if asked to, to framebuffer,
+// which must not be multisampled
+func (g *gBuffer) present(framebuffer uint32, depth bool) {
+	mask := gfx.ColorBuffer
+	if depth {
+		mask |= gfx.DepthBuffer
+	}
+	device.BlitFramebuffer(g.lighting, framebuffer, g.width, g.height, mask, -1)
+	device.BindFramebuffer(0)
+}
+
+func (g *gBuffer) delete() {
+	for i, texture := range g.textures {
+		if texture != nil {
+			texture.Delete()
+			g.textures[i] = nil
+		}
+	}
+	if g.depth != nil {
+		g.depth.Delete()
+		g.depth = nil
+	}
+	device.DeleteRenderbuffer(g.depthCopy)
+	device.DeleteFramebuffer(g.geometry)
+	device.DeleteFramebuffer(g.lighting)
+	g.depthCopy = 0
+	g.geometry = 0
+	g.lighting = 0
+}
+
+// newSphereMesh builds a unit UV sphere with outward facing triangles
+func newSphereMesh(segments, rings int) *Mesh {
+	mesh := &Mesh{}
+	for ring := 0; ring <= rings; ring++ {
+		phi := math.Pi * float64(ring) / float64(rings)
+		for segment := 0; segment <= segments; segment++ {
+			theta := 2 * math.Pi * float64(segment) / float64(segments)
+			mesh.Vertices = append(mesh.Vertices,
+				float32(math.Sin(phi)*math.Cos(theta)),
+				float32(math.Cos(phi)),
+				float32(math.Sin(phi)*math.Sin(theta)))
+		}
+	}
+	for ring := 0; ring < rings; ring++ {
+		for segment := 0; segment < segments; segment++ {
+			a := uint32(ring*(segments+1) + segment)
+			b := a + uint32(segments+1)
+			mesh.Indices = append(mesh.Indices, a, a+1, b, a+1, b+1, b)
+		}
+	}
+	mesh.IndexCount = int32(len(mesh.Indices))
+	mesh.Upload()
+	return mesh
+}
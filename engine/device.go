@@ -0,0 +1,99 @@
+package engine
+
+import "physics/gfx"
+
+// The device API lives in package gfx, which builds without cgo. These aliases
+// keep the names the engine has always used for it; enums the engine doesn't
+// re-export, such as gfx.CompareLess, are used from gfx directly.
+type (
+	// Device is the graphics API every GPU resource and draw of the engine
+	// goes through. GLDevice is the OpenGL 4.1 implementation; SetDevice
+	// substitutes another, such as a recorder wrapping it, before any
+	// resource is created.
+	Device                = gfx.Device
+	VertexLayout          = gfx.VertexLayout
+	VertexAttribute       = gfx.VertexAttribute
+	ProgramReflection     = gfx.ProgramReflection
+	ShaderUniform         = gfx.ShaderUniform
+	ShaderUniformBlock    = gfx.ShaderUniformBlock
+	ShaderAttribute       = gfx.ShaderAttribute
+	ShaderSourceLocation  = gfx.ShaderSourceLocation
+	ShaderErrorLine       = gfx.ShaderErrorLine
+	ShaderError           = gfx.ShaderError
+	TexelFormat           = gfx.TexelFormat
+	FramebufferAttachment = gfx.FramebufferAttachment
+	BlendState            = gfx.BlendState
+	PipelineState         = gfx.PipelineState
+	DrawCall              = gfx.DrawCall
+	TextureFormat         = gfx.TextureFormat
+	ColorSpace            = gfx.ColorSpace
+	SamplerState          = gfx.SamplerState
+	CullMode              = gfx.CullMode
+)
+
+const (
+	CullNone  = gfx.CullNone
+	CullBack  = gfx.CullBack
+	CullFront = gfx.CullFront
+)
+
+const (
+	ColorSpaceLinear = gfx.ColorSpaceLinear
+	ColorSpaceSRGB   = gfx.ColorSpaceSRGB
+)
+
+const (
+	TextureFormatRGBA8           = gfx.TextureFormatRGBA8
+	TextureFormatRGB8            = gfx.TextureFormatRGB8
+	TextureFormatRG8             = gfx.TextureFormatRG8
+	TextureFormatR8              = gfx.TextureFormatR8
+	TextureFormatRGBA16          = gfx.TextureFormatRGBA16
+	TextureFormatR16             = gfx.TextureFormatR16
+	TextureFormatRGBA16F         = gfx.TextureFormatRGBA16F
+	TextureFormatRGB16F          = gfx.TextureFormatRGB16F
+	TextureFormatRG16F           = gfx.TextureFormatRG16F
+	TextureFormatR16F            = gfx.TextureFormatR16F
+	TextureFormatRGBA32F         = gfx.TextureFormatRGBA32F
+	TextureFormatRGB32F          = gfx.TextureFormatRGB32F
+	TextureFormatR32F            = gfx.TextureFormatR32F
+	TextureFormatDepth24         = gfx.TextureFormatDepth24
+	TextureFormatDepth32F        = gfx.TextureFormatDepth32F
+	TextureFormatDepth24Stencil8 = gfx.TextureFormatDepth24Stencil8
+
+	// Block compressed formats, see compressedtexture.go
+	TextureFormatBC1   = gfx.TextureFormatBC1
+	TextureFormatBC1A  = gfx.TextureFormatBC1A
+	TextureFormatBC2   = gfx.TextureFormatBC2
+	TextureFormatBC3   = gfx.TextureFormatBC3
+	TextureFormatBC4   = gfx.TextureFormatBC4
+	TextureFormatBC4S  = gfx.TextureFormatBC4S
+	TextureFormatBC5   = gfx.TextureFormatBC5
+	TextureFormatBC5S  = gfx.TextureFormatBC5S
+	TextureFormatBC6H  = gfx.TextureFormatBC6H
+	TextureFormatBC6HS = gfx.TextureFormatBC6HS
+	TextureFormatBC7   = gfx.TextureFormatBC7
+)
+
+// DefaultPipelineState is the state renderers leave between passes: depth
+// tested with gfx.CompareLess and written, no blending or culling
+func DefaultPipelineState() PipelineState {
+	return gfx.DefaultPipelineState()
+}
+
+// AdditiveBlend adds colors to what is drawn, for accumulating light
+func AdditiveBlend() BlendState {
+	return gfx.AdditiveBlend()
+}
+
+var device Device = NewGLDevice()
+
+// CurrentDevice returns the device the engine draws with
+func CurrentDevice() Device {
+	return device
+}
+
+// SetDevice replaces the device the engine draws with. Resources created on
+// the previous device can't be used with the new one unless it wraps it.
+func SetDevice(d Device) {
+	device = d
+}
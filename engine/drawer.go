@@ -0,0 +1,327 @@
+package engine
+
+import (
+	"fmt"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// drawer holds the per-frame uniforms and the tracked pipeline state shared by the
+// renderers, and issues the draws of a RenderQueue
+type drawer struct {
+	PointShadows *PointShadowMaps
+	// SSAO, when set, darkens the ambient light of every view with screen
+	// space ambient occlusion
+	SSAO *SSAO
+
+	window *glfw.Window
+	// target is the framebuffer views are drawn into, the window when nil
+	target *Framebuffer
+
+	frame        FrameUniforms
+	frameBuffer  *UniformBuffer
+	objectBuffer *UniformBuffer
+
+	queue RenderQueue
+	state renderStateTracker
+	bound boundState
+	stats FrameStats
+
+	// instanced maps each material shader to its instanced variant
+	instanced shaderVariants
+
+	// sky is the current scene's background, drawn after the opaque items
+	sky *Skybox
+
+	// ambientOcclusion is the current view's SSAO result, nil outside of
+	// the passes it applies to
+	ambientOcclusion *Texture
+}
+
+// boundState is what the last draw left bound, so the next one can skip
+// binding it again
+type boundState struct {
+	shader   *ShaderProgram
+	material *Material
+	vao      uint32
+}
+
+func newDrawer(window *glfw.Window) (drawer, error) {
+	pointShadows, err := NewPointShadowMaps(DefaultPointShadowSettings())
+	if err != nil {
+		return drawer{}, fmt.Errorf("point shadows: %w", err)
+	}
+
+	frameBuffer, err := NewUniformBuffer(FrameUniformBinding, &FrameUniforms{})
+	if err != nil {
+		pointShadows.Delete()
+		return drawer{}, err
+	}
+	objectBuffer, err := NewUniformBuffer(ObjectUniformBinding, &ObjectUniforms{})
+	if err != nil {
+		pointShadows.Delete()
+		frameBuffer.Delete()
+		return drawer{}, err
+	}
+
+	return drawer{
+		PointShadows: pointShadows,
+		window:       window,
+		frameBuffer:  frameBuffer,
+		objectBuffer: objectBuffer,
+		instanced: newShaderVariants(instancedDefines, func(variant *ShaderProgram) bool {
+			_, ok := variant.reflection.attributes["aInstanceModel"]
+			return ok
+		}),
+	}, nil
+}
+
+// delete frees the shadow maps, uniform buffers and shader variants the
+// drawer created. SSAO belongs to whoever assigned it.
+func (d *drawer) delete() {
+	d.PointShadows.Delete()
+	d.frameBuffer.Delete()
+	d.objectBuffer.Delete()
+	d.instanced.delete()
+}
+
+// beginFrame resets the statistics, renders the shadow maps and uploads the
+// frame block for the scene's primary camera
+func (d *drawer) beginFrame(scene *Scene) {
+	camera := scene.Camera
+	d.stats = FrameStats{}
+	d.state.resetStats()
+	d.frame.ViewPos = camera.EyePosition()
+
+	d.PointShadows.Render(scene, d.frame.ViewPos)
+
+	// Per-frame data is uploaded once and shared by every program
+	d.frame.View = camera.ViewMatrix()
+	d.frame.Projection = camera.ProjectionMatrix()
+	d.frame.Time = float32(glfw.GetTime())
+	d.frame.SetLights(scene.Lights)
+	d.frame.Fog = fogUniforms(scene.Fog)
+	d.sky = scene.Sky
+	d.updateFrameUniforms()
+	d.frameBuffer.Bind()
+	d.objectBuffer.Bind()
+}
+
+// SetTarget directs the following views to target, or to the window when nil
+func (d *drawer) SetTarget(target *Framebuffer) {
+	d.target = target
+}
+
+func (d *drawer) targetSize() (int32, int32) {
+	if d.target != nil {
+		return d.target.Width, d.target.Height
+	}
+	width, height := d.window.GetFramebufferSize()
+	return int32(width), int32(height)
+}
+
+// bindTarget directs drawing to the current target and sets the viewport to
+// cover it
+func (d *drawer) bindTarget() {
+	if d.target != nil {
+		d.target.Bind()
+		return
+	}
+	width, height := d.targetSize()
+	device.BindFramebuffer(0)
+	device.Viewport(0, 0, width, height)
+}
+
+func (d *drawer) updateFrameUniforms() {
+	if err := d.frameBuffer.Update(&d.frame); err != nil {
+		panic(err)
+	}
+}
+
+// Stats returns the counters of the last frame
+func (d *drawer) Stats() FrameStats {
+	stats := d.stats
+	stats.StateChanges = d.state.changes
+	return stats
+}
+
+// setView points the frame block at camera
+func (d *drawer) setView(camera *Camera) {
+	eye, view, proj := camera.EyePosition(), camera.ViewMatrix(), camera.ProjectionMatrix()
+	if eye != d.frame.ViewPos || view != d.frame.View || proj != d.frame.Projection {
+		d.frame.ViewPos = eye
+		d.frame.View = view
+		d.frame.Projection = proj
+		d.updateFrameUniforms()
+	}
+}
+
+// setViewProjection re-uploads the frame block when drawing from a camera
+// other than the primary one
+func (d *drawer) setViewProjection(proj mgl32.Mat4, view mgl32.Mat4) {
+	if view != d.frame.View || proj != d.frame.Projection {
+		d.frame.View = view
+		d.frame.Projection = proj
+		d.updateFrameUniforms()
+	}
+}
+
+// draw issues item with shader, which is the material's shader or a variant
+// of it, only switching shader, material, mesh and render state when they
+// differ from the previous draw
+func (d *drawer) draw(item *DrawItem, shader *ShaderProgram) {
+	material := item.Material
+	if item.Renderer != nil {
+		d.drawCustom(item)
+		return
+	}
+	if item.Instances != nil {
+		instanced := d.instanced.get(shader)
+		if instanced == nil {
+			d.drawInstancesSeparately(item, shader)
+			return
+		}
+		shader = instanced
+	}
+	if shader != d.bound.shader {
+		shader.Use()
+		shader.checkUniformBlock("FrameData", &d.frame)
+		shader.checkUniformBlock("ObjectData", &ObjectUniforms{})
+		shader.checkAttributes(material.GetAttributeMap())
+		d.BindLighting(shader)
+		d.bound.shader = shader
+		d.bound.material = nil
+		d.stats.ShaderChanges++
+	}
+	if material != d.bound.material {
+		if err := material.BindShaderProperties(shader); err != nil {
+			panic(err)
+		}
+		d.bound.material = material
+		d.stats.MaterialChanges++
+	}
+	d.state.apply(material.RenderState, material.BlendMode)
+
+	if item.Instances != nil {
+		d.drawInstanced(item)
+		return
+	}
+
+	objectUniforms := NewObjectUniforms(item.Model)
+	if err := d.objectBuffer.Update(&objectUniforms); err != nil {
+		panic(err)
+	}
+
+	// The mesh's vertex array already holds its attribute bindings
+	uploadMesh(item.Mesh)
+	if item.Mesh.Vao != d.bound.vao {
+		d.bound.vao = item.Mesh.Vao
+		d.stats.MeshChanges++
+	}
+
+	count := int32(len(item.Mesh.Indices))
+	device.Draw(DrawCall{VertexArray: item.Mesh.Vao, Count: count, Indexed: true})
+
+	d.stats.DrawCalls++
+	d.stats.Triangles += int(count / 3)
+	d.stats.Objects += item.objects
+	if item.objects > 1 {
+		d.stats.Batches++
+	}
+}
+
+// BindLighting points shader's point shadow and ambient occlusion samplers at
+// the current view's, for ObjectRenderers drawing lit surfaces with shaders
+// of their own. shader has to be in use.
+func (d *drawer) BindLighting(shader *ShaderProgram) {
+	d.PointShadows.Bind(shader)
+	d.bindAmbientOcclusion(shader)
+}
+
+// drawInstanced uploads the instances of item and draws them all in one call
+func (d *drawer) drawInstanced(item *DrawItem) {
+	array := d.instanceArray(item)
+	array.upload(item.Instances)
+	if array.vao != d.bound.vao {
+		d.bound.vao = array.vao
+		d.stats.MeshChanges++
+	}
+
+	count := int32(len(item.Mesh.Indices))
+	instances := int32(len(item.Instances))
+	device.Draw(DrawCall{VertexArray: array.vao, Count: count, Indexed: true, Instances: instances})
+
+	d.stats.DrawCalls++
+	d.stats.InstancedDraws++
+	d.stats.Triangles += int(count/3) * int(instances)
+	d.stats.Objects += item.objects
+}
+
+// instanceArray returns the vertex array item's instances are drawn with:
+// its own for instanced meshes, the one of its mesh for merged items
+func (d *drawer) instanceArray(item *DrawItem) *instanceArray {
+	if item.instanced != nil {
+		return item.instanced.instanceArray()
+	}
+	if item.Mesh.instances == nil {
+		item.Mesh.instances = newInstanceArray(item.Mesh)
+	}
+	return item.Mesh.instances
+}
+
+// uploadMesh creates the buffers of meshes built without them, such as static
+// batches
+func uploadMesh(mesh *Mesh) {
+	if mesh.Vao == 0 {
+		mesh.Upload()
+	}
+}
+
+// drawInstancesSeparately is the fallback for shaders without an instanced
+// variant, drawing one object per instance
+func (d *drawer) drawInstancesSeparately(item *DrawItem, shader *ShaderProgram) {
+	for _, instance := range item.Instances {
+		d.draw(&DrawItem{Mesh: item.Mesh, Material: item.Material, Model: instance.Model, objects: 1}, shader)
+	}
+}
+
+// drawCustom hands item to its ObjectRenderer, then rebinds what that may have
+// changed
+func (d *drawer) drawCustom(item *DrawItem) {
+	d.unbind()
+	// Renderers start from the object's render state, not whatever the
+	// previous pass left behind
+	d.state.apply(item.Material.RenderState, item.Material.BlendMode)
+	d.bindAmbientOcclusionTexture()
+	switch renderer := item.Renderer.(type) {
+	case InstancedObjectRenderer:
+		if item.Instances == nil {
+			renderer.Render(item.Mesh, *item.Material, item.Model, d.frame.Projection, d.frame.View)
+			break
+		}
+		array := d.instanceArray(item)
+		array.upload(item.Instances)
+		renderer.RenderInstanced(item.Mesh, *item.Material, item.Instances, array.vao)
+		d.stats.InstancedDraws++
+	default:
+		if item.Instances == nil {
+			renderer.Render(item.Mesh, *item.Material, item.Model, d.frame.Projection, d.frame.View)
+			break
+		}
+		for _, instance := range item.Instances {
+			renderer.Render(item.Mesh, *item.Material, instance.Model, d.frame.Projection, d.frame.View)
+		}
+	}
+
+	d.state.invalidate()
+	d.frameBuffer.Bind()
+	d.objectBuffer.Bind()
+	d.stats.Objects += item.objects
+}
+
+func (d *drawer) unbind() {
+	if d.bound.shader != nil {
+		d.bound.shader.Unuse()
+	}
+	d.bound = boundState{}
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"physics/scene"
+)
+
+// Fog is a Scene's atmosphere, see scene.Fog. The zero value is no fog.
+type (
+	FogMode = scene.FogMode
+	Fog     = scene.Fog
+)
+
+const (
+	FogNone        = scene.FogNone
+	FogLinear      = scene.FogLinear
+	FogExponential = scene.FogExponential
+	FogHeight      = scene.FogHeight
+)
+
+func NewLinearFog(color mgl32.Vec3, start, end float32) Fog {
+	return scene.NewLinearFog(color, start, end)
+}
+
+func NewExponentialFog(color mgl32.Vec3, density float32) Fog {
+	return scene.NewExponentialFog(color, density)
+}
+
+func NewHeightFog(color mgl32.Vec3, density, height, falloff float32) Fog {
+	return scene.NewHeightFog(color, density, height, falloff)
+}
+
+// FogUniforms mirrors the Fog struct in shaders/common/frame.glsl
+type FogUniforms struct {
+	Color         mgl32.Vec3
+	Mode          int32
+	Start         float32
+	End           float32
+	Density       float32
+	Height        float32
+	HeightFalloff float32
+}
+
+func fogUniforms(f Fog) FogUniforms {
+	return FogUniforms{
+		Color:         f.Color,
+		Mode:          int32(f.Mode),
+		Start:         f.Start,
+		End:           f.End,
+		Density:       f.Density,
+		Height:        f.Height,
+		HeightFalloff: f.HeightFalloff,
+	}
+}
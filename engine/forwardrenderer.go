@@ -1,64 +1,97 @@
 package engine
 
 import (
-	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/go-gl/mathgl/mgl32"
+	"physics/gfx"
 )
 
 type ForwardRenderer struct {
-	window *glfw.Window
+	drawer
 }
 
-func NewForwardRenderer(window *glfw.Window) *ForwardRenderer {
-	return &ForwardRenderer{
-		window: window,
+func NewForwardRenderer(window *glfw.Window) (*ForwardRenderer, error) {
+	drawer, err := newDrawer(window)
+	if err != nil {
+		return nil, err
 	}
+	return &ForwardRenderer{drawer: drawer}, nil
 }
 
-func (r *ForwardRenderer) RenderPrimaryCamera(scene *Scene) {
-	scene.Render(r, scene.Camera)
+func (r *ForwardRenderer) RenderPrimaryCamera(scene *Scene) error {
+	return RenderScene(r, scene)
 }
 
-func (r *ForwardRenderer) RenderObject(mesh *Mesh, material Material, model mgl32.Mat4, proj mgl32.Mat4, view mgl32.Mat4) {
-	shader := material.GetShader()
-	shader.Use()
+func (r *ForwardRenderer) BeginFrame(scene *Scene) {
+	r.beginFrame(scene)
+}
 
-	// Set shader properties
-	err := material.BindShaderProperties(shader)
-	if err != nil {
-		panic(err)
+func (r *ForwardRenderer) Submit(item DrawItem) {
+	r.queue.Submit(item)
+}
+
+// RenderView clears the target and draws the submitted items sorted for camera
+func (r *ForwardRenderer) RenderView(camera *Camera) error {
+	if width, height := r.targetSize(); width < 1 || height < 1 {
+		r.queue.Reset()
+		return nil
 	}
+	r.setView(camera)
+	r.queue.Sort(camera.EyePosition())
+	if r.SSAO != nil {
+		occlusion, err := r.computeAmbientOcclusion(&r.queue)
+		if err != nil {
+			r.queue.Reset()
+			return err
+		}
+		r.ambientOcclusion = occlusion
+	}
+	r.bindTarget()
 
-	// Set matrices
-	shader.SetMat4UniformLocation("model", &model)
-	shader.SetMat4UniformLocation("view", &view)
-	shader.SetMat4UniformLocation("projection", &proj)
+	// Depth writes have to be on for the clear to reach the depth buffer
+	r.state.apply(RenderState{}, BlendOpaque)
+	device.Clear(gfx.ColorBuffer | gfx.DepthBuffer)
 
-	// Bind vertex array
-	gl.BindVertexArray(mesh.Vao)
+	r.RenderQueue(&r.queue, camera.ProjectionMatrix(), camera.ViewMatrix())
+	r.queue.Reset()
+	r.ambientOcclusion = nil
 
-	// Enable attribute pointers
-	attribMap := material.GetAttributeMap()
-	for attr, attrName := range attribMap {
-		attrLoc := shader.GetAttribLocation(attrName)
-		gl.EnableVertexAttribArray(attrLoc)
+	// Leave the defaults behind for the shadow pass and anything drawn after
+	r.state.apply(RenderState{}, BlendOpaque)
+	return nil
+}
 
-		gl.VertexAttribPointer(attrLoc, mesh.GetAttribSize(attr), gl.FLOAT, false, 0, gl.PtrOffset(0))
-	}
+func (r *ForwardRenderer) EndFrame() {}
 
-	// Render the mesh
-	gl.DrawElements(gl.TRIANGLES, int32(len(mesh.Indices)), gl.UNSIGNED_INT, gl.PtrOffset(0))
+func (r *ForwardRenderer) Delete() {
+	r.delete()
+}
 
-	// Disable attribute pointers
-	for _, attrName := range attribMap {
-		attrLoc := shader.GetAttribLocation(attrName)
-		gl.DisableVertexAttribArray(attrLoc)
+// RenderQueue draws the items of a sorted queue, only switching shader,
+// material, mesh and render state between items that differ
+func (r *ForwardRenderer) RenderQueue(queue *RenderQueue, proj mgl32.Mat4, view mgl32.Mat4) {
+	r.setViewProjection(proj, view)
+	r.bound = boundState{}
+	for i := range queue.Opaque {
+		r.draw(&queue.Opaque[i], queue.Opaque[i].Material.GetShader())
 	}
+	r.drawSky()
+	if r.ambientOcclusion != nil {
+		// Transparent surfaces aren't in the depth the occlusion came from,
+		// rebind white for them
+		r.ambientOcclusion = nil
+		r.bound.shader = nil
+	}
+	for i := range queue.Transparent {
+		r.draw(&queue.Transparent[i], queue.Transparent[i].Material.GetShader())
+	}
+	r.unbind()
+}
 
-	// Unbind vertex array
-	gl.BindVertexArray(0)
-
-	// Unuse the shader
-	shader.Unuse()
+// RenderObject draws a single mesh right away, outside of any queue
+func (r *ForwardRenderer) RenderObject(mesh *Mesh, material Material, model mgl32.Mat4, proj mgl32.Mat4, view mgl32.Mat4) {
+	r.setViewProjection(proj, view)
+	r.bound = boundState{}
+	r.draw(&DrawItem{Mesh: mesh, Material: &material, Model: model, objects: 1}, material.GetShader())
+	r.unbind()
 }
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"fmt"
+	"physics/gfx"
+)
+
+type FramebufferOptions struct {
+	// Color lists the format of each color attachment. Fragment output n is
+	// written to attachment n.
+	Color []TextureFormat
+	// Depth adds a depth-stencil renderbuffer, DepthTexture a depth-stencil
+	// texture that can be sampled afterwards
+	Depth        bool
+	DepthTexture bool
+	// Samples above 1 render into multisampled renderbuffers that Resolve
+	// averages into the textures
+	Samples int32
+	Sampler SamplerState
+	// ColorSpace of 8-bit color attachments. sRGB ones are written encoded,
+	// as the window is, so captures of them look as the window would.
+	ColorSpace ColorSpace
+}
+
+// Framebuffer is an offscreen render target whose attachments are textures,
+// for mirrors, screens, minimaps and post-processing. Renderers draw into it
+// with SetTarget or RenderSceneTo.
+type Framebuffer struct {
+	Width   int32
+	Height  int32
+	Samples int32
+	Color   []*Texture
+	// Depth is nil unless the framebuffer was created with DepthTexture
+	Depth *Texture
+
+	opts              FramebufferOptions
+	handle            uint32
+	depthRenderbuffer uint32
+
+	// multisampled is drawn to instead of handle when Samples > 1
+	multisampled      uint32
+	multisampledColor []uint32
+	multisampledDepth uint32
+	unresolved        bool
+}
+
+func NewFramebuffer(width, height int32, opts FramebufferOptions) (*Framebuffer, error) {
+	f := &Framebuffer{opts: opts}
+	if err := f.Resize(width, height); err != nil {
+		f.Delete()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Resize recreates the attachments at the new size. Their contents are lost.
+func (f *Framebuffer) Resize(width, height int32) error {
+	if f.handle != 0 && f.Width == width && f.Height == height {
+		return nil
+	}
+	f.Delete()
+	f.Width, f.Height = width, height
+
+	f.Samples = f.opts.Samples
+	if f.Samples > 1 {
+		if maxSamples := device.MaxSamples(); f.Samples > maxSamples {
+			f.Samples = maxSamples
+		}
+	}
+
+	textureOpts := TextureOptions{Sampler: f.opts.Sampler}
+	var attachments []FramebufferAttachment
+	for i, format := range f.opts.Color {
+		colorOpts := textureOpts
+		colorOpts.ColorSpace = f.opts.ColorSpace
+		texture, err := NewTexture2D(width, height, format, nil, colorOpts)
+		if err != nil {
+			return err
+		}
+		f.Color = append(f.Color, texture)
+		attachments = append(attachments, FramebufferAttachment{Attachment: gfx.ColorAttachment(i), Texture: texture.Handle()})
+	}
+	if f.opts.DepthTexture {
+		depth, err := NewTexture2D(width, height, TextureFormatDepth24Stencil8, nil, textureOpts)
+		if err != nil {
+			return err
+		}
+		f.Depth = depth
+		attachments = append(attachments, FramebufferAttachment{Attachment: gfx.AttachmentDepthStencil, Texture: depth.Handle()})
+	} else if f.opts.Depth && f.Samples <= 1 {
+		f.depthRenderbuffer = device.CreateRenderbuffer(TexelFormat{Format: TextureFormatDepth24Stencil8}, width, height, 0)
+		attachments = append(attachments, FramebufferAttachment{Attachment: gfx.AttachmentDepthStencil, Renderbuffer: f.depthRenderbuffer})
+	}
+	handle, err := device.CreateFramebuffer(attachments)
+	if err != nil {
+		return err
+	}
+	f.handle = handle
+
+	if f.Samples > 1 {
+		attachments = attachments[:0]
+		for i, texture := range f.Color {
+			renderbuffer := device.CreateRenderbuffer(texture.texelFormat(), width, height, f.Samples)
+			f.multisampledColor = append(f.multisampledColor, renderbuffer)
+			attachments = append(attachments, FramebufferAttachment{Attachment: gfx.ColorAttachment(i), Renderbuffer: renderbuffer})
+		}
+		if f.opts.Depth || f.opts.DepthTexture {
+			f.multisampledDepth = device.CreateRenderbuffer(TexelFormat{Format: TextureFormatDepth24Stencil8}, width, height, f.Samples)
+			attachments = append(attachments, FramebufferAttachment{Attachment: gfx.AttachmentDepthStencil, Renderbuffer: f.multisampledDepth})
+		}
+		multisampled, err := device.CreateFramebuffer(attachments)
+		if err != nil {
+			return fmt.Errorf("multisampled %w", err)
+		}
+		f.multisampled = multisampled
+	}
+	return nil
+}
+
+// Bind directs drawing to the framebuffer and sets the viewport to cover it
+func (f *Framebuffer) Bind() {
+	if f.multisampled != 0 {
+		device.BindFramebuffer(f.multisampled)
+		f.unresolved = true
+	} else {
+		device.BindFramebuffer(f.handle)
+	}
+	device.Viewport(0, 0, f.Width, f.Height)
+}
+
+// Resolve averages the samples drawn since the last Resolve into the
+// attachment textures. It does nothing for single sampled framebuffers.
+func (f *Framebuffer) Resolve() {
+	if f.multisampled == 0 || !f.unresolved {
+		return
+	}
+	f.unresolved = false
+
+	for i := range f.Color {
+		device.BlitFramebuffer(f.multisampled, f.handle, f.Width, f.Height, gfx.ColorBuffer, i)
+	}
+	if f.Depth != nil {
+		device.BlitFramebuffer(f.multisampled, f.handle, f.Width, f.Height, gfx.DepthBuffer, -1)
+	}
+	device.BindFramebuffer(0)
+}
+
+// Handle returns the framebuffer holding the resolved attachments
+func (f *Framebuffer) Handle() uint32 {
+	return f.handle
+}
+
+func (f *Framebuffer) Delete() {
+	for _, texture := range f.Color {
+		texture.Delete()
+	}
+	f.Color = nil
+	if f.Depth != nil {
+		f.Depth.Delete()
+		f.Depth = nil
+	}
+
+	for _, renderbuffer := range append([]uint32{f.depthRenderbuffer, f.multisampledDepth}, f.multisampledColor...) {
+		device.DeleteRenderbuffer(renderbuffer)
+	}
+	f.depthRenderbuffer = 0
+	f.multisampledDepth = 0
+	f.multisampledColor = nil
+
+	device.DeleteFramebuffer(f.handle)
+	device.DeleteFramebuffer(f.multisampled)
+	f.handle = 0
+	f.multisampled = 0
+	f.unresolved = false
+}
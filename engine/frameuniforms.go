@@ -0,0 +1,55 @@
+package engine
+
+import "github.com/go-gl/mathgl/mgl32"
+
+// FrameUniforms mirrors the FrameData block in shaders/common/frame.glsl. It is
+// uploaded once per view and shared by every shader program.
+type FrameUniforms struct {
+	View       mgl32.Mat4
+	Projection mgl32.Mat4
+	ViewPos    mgl32.Vec3
+	Time       float32
+	LightCount int32
+	Lights     [MaxLights]PointLightUniforms
+	Fog        FogUniforms
+}
+
+type PointLightUniforms struct {
+	Position    mgl32.Vec3
+	Color       mgl32.Vec3
+	Range       float32
+	ShadowLayer int32
+}
+
+// ObjectUniforms mirrors the ObjectData block, uploaded once per draw
+type ObjectUniforms struct {
+	Model        mgl32.Mat4
+	NormalMatrix mgl32.Mat3
+}
+
+func (f *FrameUniforms) SetLights(lights []*Light) {
+	count := len(lights)
+	if count > MaxLights {
+		count = MaxLights
+	}
+
+	f.LightCount = int32(count)
+	for i := 0; i < count; i++ {
+		f.Lights[i] = PointLightUniforms{
+			Position:    lights[i].Position,
+			Color:       lights[i].Color,
+			Range:       lights[i].EffectiveRange(),
+			ShadowLayer: int32(lights[i].shadowLayer),
+		}
+	}
+	for i := count; i < MaxLights; i++ {
+		f.Lights[i] = PointLightUniforms{}
+	}
+}
+
+func NewObjectUniforms(model mgl32.Mat4) ObjectUniforms {
+	return ObjectUniforms{
+		Model:        model,
+		NormalMatrix: model.Mat3().Inv().Transpose(),
+	}
+}
@@ -15,6 +15,9 @@ type GameObject struct {
 	Material Material
 	Mesh     *Mesh
 	Scene    *Scene
+	// Static objects never move once added to a scene, letting the renderer
+	// merge the ones that share a material into a single draw
+	Static bool
 
 	Renderer ObjectRenderer
 }
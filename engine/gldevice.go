@@ -0,0 +1,1009 @@
+package engine
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/go-gl/mathgl/mgl64"
+	"log"
+	"physics/gfx"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+// GLDevice draws with the OpenGL 4.1 context current on the calling thread.
+// It remembers the vertex array, framebuffer and pipeline state it last set,
+// so GL state changed behind its back, e.g. by an overlay library, has to be
+// put back before the engine draws again.
+type GLDevice struct {
+	vertexArray uint32
+	framebuffer uint32
+	// drawBuffers is the number of color attachments of each framebuffer,
+	// restored after blitting a single one
+	drawBuffers map[uint32]int
+
+	pipeline      PipelineState
+	pipelineValid bool
+
+	extensions map[string]bool
+	anisotropy float32
+}
+
+var _ Device = (*GLDevice)(nil)
+
+// NewGLDevice returns a device for the GL context, which doesn't have to be
+// created yet
+func NewGLDevice() *GLDevice {
+	return &GLDevice{
+		drawBuffers: make(map[uint32]int),
+		// GL's initial state, issued in full by the first SetPipeline
+		pipeline:   PipelineState{DepthFunc: gfx.CompareLess, DepthWrite: true},
+		anisotropy: -1,
+	}
+}
+
+// dataPointer returns a pointer to the first element of a slice, or nil for
+// nil and empty slices, which gl.Ptr can't take
+func dataPointer(data interface{}) unsafe.Pointer {
+	if data == nil {
+		return nil
+	}
+	if v := reflect.ValueOf(data); v.Kind() == reflect.Slice && v.Len() == 0 {
+		return nil
+	}
+	return gl.Ptr(data)
+}
+
+// bindBuffer binds buffer to the target of kind and returns the target. Index
+// buffers are bound with no vertex array bound, since the binding is recorded
+// in the vertex array.
+func (d *GLDevice) bindBuffer(kind gfx.BufferKind, buffer uint32) uint32 {
+	if kind == gfx.BufferIndices && d.vertexArray != 0 {
+		gl.BindVertexArray(0)
+		d.vertexArray = 0
+	}
+	target := glBufferTargets[kind]
+	gl.BindBuffer(target, buffer)
+	return target
+}
+
+func (d *GLDevice) CreateBuffer(kind gfx.BufferKind, size int, data interface{}, usage gfx.BufferUsage) uint32 {
+	var buffer uint32
+	gl.GenBuffers(1, &buffer)
+	target := d.bindBuffer(kind, buffer)
+	gl.BufferData(target, size, dataPointer(data), glBufferUsages[usage])
+	gl.BindBuffer(target, 0)
+	return buffer
+}
+
+func (d *GLDevice) WriteBuffer(kind gfx.BufferKind, buffer uint32, offset, size int, data interface{}) {
+	target := d.bindBuffer(kind, buffer)
+	gl.BufferSubData(target, offset, size, dataPointer(data))
+	gl.BindBuffer(target, 0)
+}
+
+func (d *GLDevice) ReallocateBuffer(kind gfx.BufferKind, buffer uint32, size int, data interface{}, usage gfx.BufferUsage) {
+	target := d.bindBuffer(kind, buffer)
+	gl.BufferData(target, size, dataPointer(data), glBufferUsages[usage])
+	gl.BindBuffer(target, 0)
+}
+
+func (d *GLDevice) DeleteBuffer(buffer uint32) {
+	if buffer != 0 {
+		gl.DeleteBuffers(1, &buffer)
+	}
+}
+
+func (d *GLDevice) BindUniformBuffer(binding, buffer uint32) {
+	gl.BindBufferBase(gl.UNIFORM_BUFFER, binding, buffer)
+}
+
+func (d *GLDevice) CreateVertexArray(layout VertexLayout) uint32 {
+	var vertexArray uint32
+	gl.GenVertexArrays(1, &vertexArray)
+	gl.BindVertexArray(vertexArray)
+	for _, attribute := range layout.Attributes {
+		gl.BindBuffer(gl.ARRAY_BUFFER, attribute.Buffer)
+		gl.VertexAttribPointer(attribute.Location, attribute.Components, gl.FLOAT, false, attribute.Stride, gl.PtrOffset(attribute.Offset))
+		gl.EnableVertexAttribArray(attribute.Location)
+		if attribute.Divisor != 0 {
+			gl.VertexAttribDivisor(attribute.Location, attribute.Divisor)
+		}
+	}
+	if layout.IndexBuffer != 0 {
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, layout.IndexBuffer)
+	}
+	gl.BindVertexArray(0)
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	d.vertexArray = 0
+	return vertexArray
+}
+
+func (d *GLDevice) DeleteVertexArray(vertexArray uint32) {
+	if vertexArray == 0 {
+		return
+	}
+	if d.vertexArray == vertexArray {
+		d.vertexArray = 0
+	}
+	gl.DeleteVertexArrays(1, &vertexArray)
+}
+
+type getGlParam func(uint32, uint32, *int32)
+type getInfoLog func(uint32, int32, *int32, *uint8)
+
+// checkGlError returns the complete info log of a shader or program when
+// errorParam reports failure.
+func checkGlError(glObject uint32, errorParam uint32, getParamFn getGlParam,
+	getInfoLogFn getInfoLog) (string, bool) {
+
+	var success int32
+	getParamFn(glObject, errorParam, &success)
+	if success == gl.TRUE {
+		return "", true
+	}
+
+	var logLength int32
+	getParamFn(glObject, gl.INFO_LOG_LENGTH, &logLength)
+	if logLength < 1 {
+		return "", false
+	}
+	infoLog := make([]byte, logLength)
+	getInfoLogFn(glObject, logLength, nil, (*uint8)(unsafe.Pointer(&infoLog[0])))
+	return strings.TrimRight(string(infoLog), "\x00\n"), false
+}
+
+func compileShaderStage(shaderType uint32, stage string, source string) (uint32, error) {
+	handle := gl.CreateShader(shaderType)
+	csource, free := gl.Strs(source + "\x00")
+	defer free()
+	gl.ShaderSource(handle, 1, csource, nil)
+	gl.CompileShader(handle)
+
+	if infoLog, ok := checkGlError(handle, gl.COMPILE_STATUS, gl.GetShaderiv, gl.GetShaderInfoLog); !ok {
+		gl.DeleteShader(handle)
+		return 0, &ShaderError{Stage: stage, Log: infoLog}
+	}
+	return handle, nil
+}
+
+// CreateProgram creates the shared GL context if there is none yet
+func (d *GLDevice) CreateProgram(vertexSource, fragmentSource string) (uint32, error) {
+	GLContext().check()
+
+	vertexHandle, err := compileShaderStage(gl.VERTEX_SHADER, "vertex", vertexSource)
+	if err != nil {
+		return 0, err
+	}
+	defer gl.DeleteShader(vertexHandle)
+
+	fragmentHandle, err := compileShaderStage(gl.FRAGMENT_SHADER, "fragment", fragmentSource)
+	if err != nil {
+		return 0, err
+	}
+	defer gl.DeleteShader(fragmentHandle)
+
+	// Link the shader program
+	program := gl.CreateProgram()
+	gl.AttachShader(program, vertexHandle)
+	gl.AttachShader(program, fragmentHandle)
+	gl.LinkProgram(program)
+	if infoLog, ok := checkGlError(program, gl.LINK_STATUS, gl.GetProgramiv, gl.GetProgramInfoLog); !ok {
+		gl.DeleteProgram(program)
+		return 0, &ShaderError{Stage: "link", Log: infoLog}
+	}
+
+	gl.DetachShader(program, vertexHandle)
+	gl.DetachShader(program, fragmentHandle)
+	return program, nil
+}
+
+func (d *GLDevice) DeleteProgram(program uint32) {
+	if program != 0 {
+		gl.DeleteProgram(program)
+	}
+}
+
+func (d *GLDevice) ReflectProgram(program uint32) ProgramReflection {
+	r := ProgramReflection{Locations: make(map[string]int32)}
+
+	var count, maxLength int32
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORMS, &count)
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORM_MAX_LENGTH, &maxLength)
+	name := make([]uint8, maxLength+1)
+	for i := uint32(0); i < uint32(count); i++ {
+		var length, size int32
+		var xtype uint32
+		gl.GetActiveUniform(program, i, int32(len(name)), &length, &size, &xtype, &name[0])
+
+		var blockIndex int32
+		gl.GetActiveUniformsiv(program, 1, &i, gl.UNIFORM_BLOCK_INDEX, &blockIndex)
+
+		uniform := ShaderUniform{
+			Name:       strings.TrimSuffix(string(name[:length]), "[0]"),
+			Type:       glDataTypes[xtype],
+			Size:       size,
+			Location:   -1,
+			BlockIndex: blockIndex,
+		}
+		if blockIndex >= 0 {
+			gl.GetActiveUniformsiv(program, 1, &i, gl.UNIFORM_OFFSET, &uniform.Offset)
+			gl.GetActiveUniformsiv(program, 1, &i, gl.UNIFORM_ARRAY_STRIDE, &uniform.ArrayStride)
+			gl.GetActiveUniformsiv(program, 1, &i, gl.UNIFORM_MATRIX_STRIDE, &uniform.MatrixStride)
+			r.Uniforms = append(r.Uniforms, uniform)
+			continue
+		}
+
+		uniform.Location = gl.GetUniformLocation(program, gl.Str(uniform.Name+"\x00"))
+		r.Locations[uniform.Name] = uniform.Location
+		if size > 1 {
+			// Array elements are looked up individually, their locations are
+			// not guaranteed to be sequential before GL 4.3
+			for element := int32(0); element < size; element++ {
+				elementName := fmt.Sprintf("%s[%d]", uniform.Name, element)
+				r.Locations[elementName] = gl.GetUniformLocation(program, gl.Str(elementName+"\x00"))
+			}
+		}
+		r.Uniforms = append(r.Uniforms, uniform)
+	}
+
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORM_BLOCKS, &count)
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORM_BLOCK_MAX_NAME_LENGTH, &maxLength)
+	name = make([]uint8, maxLength+1)
+	for i := uint32(0); i < uint32(count); i++ {
+		var length, dataSize, binding int32
+		gl.GetActiveUniformBlockName(program, i, int32(len(name)), &length, &name[0])
+		gl.GetActiveUniformBlockiv(program, i, gl.UNIFORM_BLOCK_DATA_SIZE, &dataSize)
+		gl.GetActiveUniformBlockiv(program, i, gl.UNIFORM_BLOCK_BINDING, &binding)
+
+		r.UniformBlocks = append(r.UniformBlocks, ShaderUniformBlock{
+			Name:     string(name[:length]),
+			Index:    i,
+			DataSize: dataSize,
+			Binding:  binding,
+		})
+	}
+
+	gl.GetProgramiv(program, gl.ACTIVE_ATTRIBUTES, &count)
+	gl.GetProgramiv(program, gl.ACTIVE_ATTRIBUTE_MAX_LENGTH, &maxLength)
+	name = make([]uint8, maxLength+1)
+	for i := uint32(0); i < uint32(count); i++ {
+		var length, size int32
+		var xtype uint32
+		gl.GetActiveAttrib(program, i, int32(len(name)), &length, &size, &xtype, &name[0])
+
+		attribute := ShaderAttribute{
+			Name: string(name[:length]),
+			Type: glDataTypes[xtype],
+			Size: size,
+		}
+		attribute.Location = gl.GetAttribLocation(program, gl.Str(attribute.Name+"\x00"))
+		r.Attributes = append(r.Attributes, attribute)
+	}
+
+	return r
+}
+
+func (d *GLDevice) BindUniformBlock(program, blockIndex, binding uint32) {
+	gl.UniformBlockBinding(program, blockIndex, binding)
+}
+
+func (d *GLDevice) FragDataLocation(program uint32, name string) int32 {
+	return gl.GetFragDataLocation(program, gl.Str(name+"\x00"))
+}
+
+func (d *GLDevice) UseProgram(program uint32) {
+	gl.UseProgram(program)
+}
+
+func (d *GLDevice) SetUniform(location int32, value interface{}) {
+	if location < 0 {
+		return
+	}
+	switch v := value.(type) {
+	case int32:
+		gl.Uniform1i(location, v)
+	case uint32:
+		gl.Uniform1ui(location, v)
+	case float32:
+		gl.Uniform1f(location, v)
+	case mgl32.Vec2:
+		gl.Uniform2fv(location, 1, &v[0])
+	case mgl32.Vec3:
+		gl.Uniform3fv(location, 1, &v[0])
+	case mgl32.Vec4:
+		gl.Uniform4fv(location, 1, &v[0])
+	case [2]int32:
+		gl.Uniform2iv(location, 1, &v[0])
+	case [3]int32:
+		gl.Uniform3iv(location, 1, &v[0])
+	case [4]int32:
+		gl.Uniform4iv(location, 1, &v[0])
+	case [2]uint32:
+		gl.Uniform2uiv(location, 1, &v[0])
+	case [3]uint32:
+		gl.Uniform3uiv(location, 1, &v[0])
+	case [4]uint32:
+		gl.Uniform4uiv(location, 1, &v[0])
+	case mgl32.Mat2:
+		gl.UniformMatrix2fv(location, 1, false, &v[0])
+	case mgl32.Mat3:
+		gl.UniformMatrix3fv(location, 1, false, &v[0])
+	case mgl32.Mat4:
+		gl.UniformMatrix4fv(location, 1, false, &v[0])
+	case mgl32.Mat2x3:
+		gl.UniformMatrix2x3fv(location, 1, false, &v[0])
+	case mgl32.Mat2x4:
+		gl.UniformMatrix2x4fv(location, 1, false, &v[0])
+	case mgl32.Mat3x2:
+		gl.UniformMatrix3x2fv(location, 1, false, &v[0])
+	case mgl32.Mat3x4:
+		gl.UniformMatrix3x4fv(location, 1, false, &v[0])
+	case mgl32.Mat4x2:
+		gl.UniformMatrix4x2fv(location, 1, false, &v[0])
+	case mgl32.Mat4x3:
+		gl.UniformMatrix4x3fv(location, 1, false, &v[0])
+	case float64:
+		gl.Uniform1d(location, v)
+	case mgl64.Vec2:
+		gl.Uniform2dv(location, 1, &v[0])
+	case mgl64.Vec3:
+		gl.Uniform3dv(location, 1, &v[0])
+	case mgl64.Vec4:
+		gl.Uniform4dv(location, 1, &v[0])
+	case mgl64.Mat2:
+		gl.UniformMatrix2dv(location, 1, false, &v[0])
+	case mgl64.Mat3:
+		gl.UniformMatrix3dv(location, 1, false, &v[0])
+	case mgl64.Mat4:
+		gl.UniformMatrix4dv(location, 1, false, &v[0])
+	case mgl64.Mat2x3:
+		gl.UniformMatrix2x3dv(location, 1, false, &v[0])
+	case mgl64.Mat2x4:
+		gl.UniformMatrix2x4dv(location, 1, false, &v[0])
+	case mgl64.Mat3x2:
+		gl.UniformMatrix3x2dv(location, 1, false, &v[0])
+	case mgl64.Mat3x4:
+		gl.UniformMatrix3x4dv(location, 1, false, &v[0])
+	case mgl64.Mat4x2:
+		gl.UniformMatrix4x2dv(location, 1, false, &v[0])
+	case mgl64.Mat4x3:
+		gl.UniformMatrix4x3dv(location, 1, false, &v[0])
+	case []int32:
+		gl.Uniform1iv(location, int32(len(v)), &v[0])
+	case []uint32:
+		gl.Uniform1uiv(location, int32(len(v)), &v[0])
+	case []float32:
+		gl.Uniform1fv(location, int32(len(v)), &v[0])
+	case []mgl32.Vec2:
+		gl.Uniform2fv(location, int32(len(v)), &v[0][0])
+	case []mgl32.Vec3:
+		gl.Uniform3fv(location, int32(len(v)), &v[0][0])
+	case []mgl32.Vec4:
+		gl.Uniform4fv(location, int32(len(v)), &v[0][0])
+	case [][2]int32:
+		gl.Uniform2iv(location, int32(len(v)), &v[0][0])
+	case [][3]int32:
+		gl.Uniform3iv(location, int32(len(v)), &v[0][0])
+	case [][4]int32:
+		gl.Uniform4iv(location, int32(len(v)), &v[0][0])
+	case [][2]uint32:
+		gl.Uniform2uiv(location, int32(len(v)), &v[0][0])
+	case [][3]uint32:
+		gl.Uniform3uiv(location, int32(len(v)), &v[0][0])
+	case [][4]uint32:
+		gl.Uniform4uiv(location, int32(len(v)), &v[0][0])
+	case []mgl32.Mat2:
+		gl.UniformMatrix2fv(location, int32(len(v)), false, &v[0][0])
+	case []mgl32.Mat3:
+		gl.UniformMatrix3fv(location, int32(len(v)), false, &v[0][0])
+	case []mgl32.Mat4:
+		gl.UniformMatrix4fv(location, int32(len(v)), false, &v[0][0])
+	case []mgl32.Mat2x3:
+		gl.UniformMatrix2x3fv(location, int32(len(v)), false, &v[0][0])
+	case []mgl32.Mat2x4:
+		gl.UniformMatrix2x4fv(location, int32(len(v)), false, &v[0][0])
+	case []mgl32.Mat3x2:
+		gl.UniformMatrix3x2fv(location, int32(len(v)), false, &v[0][0])
+	case []mgl32.Mat3x4:
+		gl.UniformMatrix3x4fv(location, int32(len(v)), false, &v[0][0])
+	case []mgl32.Mat4x2:
+		gl.UniformMatrix4x2fv(location, int32(len(v)), false, &v[0][0])
+	case []mgl32.Mat4x3:
+		gl.UniformMatrix4x3fv(location, int32(len(v)), false, &v[0][0])
+	case []float64:
+		gl.Uniform1dv(location, int32(len(v)), &v[0])
+	case []mgl64.Vec2:
+		gl.Uniform2dv(location, int32(len(v)), &v[0][0])
+	case []mgl64.Vec3:
+		gl.Uniform3dv(location, int32(len(v)), &v[0][0])
+	case []mgl64.Vec4:
+		gl.Uniform4dv(location, int32(len(v)), &v[0][0])
+	case []mgl64.Mat2:
+		gl.UniformMatrix2dv(location, int32(len(v)), false, &v[0][0])
+	case []mgl64.Mat3:
+		gl.UniformMatrix3dv(location, int32(len(v)), false, &v[0][0])
+	case []mgl64.Mat4:
+		gl.UniformMatrix4dv(location, int32(len(v)), false, &v[0][0])
+	case []mgl64.Mat2x3:
+		gl.UniformMatrix2x3dv(location, int32(len(v)), false, &v[0][0])
+	case []mgl64.Mat2x4:
+		gl.UniformMatrix2x4dv(location, int32(len(v)), false, &v[0][0])
+	case []mgl64.Mat3x2:
+		gl.UniformMatrix3x2dv(location, int32(len(v)), false, &v[0][0])
+	case []mgl64.Mat3x4:
+		gl.UniformMatrix3x4dv(location, int32(len(v)), false, &v[0][0])
+	case []mgl64.Mat4x2:
+		gl.UniformMatrix4x2dv(location, int32(len(v)), false, &v[0][0])
+	case []mgl64.Mat4x3:
+		gl.UniformMatrix4x3dv(location, int32(len(v)), false, &v[0][0])
+	default:
+		name := fmt.Sprintf("%T", value)
+		if !unsupportedUniforms[name] {
+			unsupportedUniforms[name] = true
+			log.Printf("SetUniform: ignoring value of unsupported type %s", name)
+		}
+	}
+}
+
+// unsupportedUniforms are the value types SetUniform has warned about
+var unsupportedUniforms = make(map[string]bool)
+
+// textureBindTarget is the target a texture is bound to for uploads to
+// target, which may be a single face of a cube map
+func textureBindTarget(target gfx.TextureTarget) uint32 {
+	if target.IsCubeMapFace() {
+		return gl.TEXTURE_CUBE_MAP
+	}
+	return glTextureTargets[target]
+}
+
+func (d *GLDevice) CreateTexture(textureTarget gfx.TextureTarget, levels int32) uint32 {
+	target := glTextureTargets[textureTarget]
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(target, texture)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexParameteri(target, gl.TEXTURE_BASE_LEVEL, 0)
+	gl.TexParameteri(target, gl.TEXTURE_MAX_LEVEL, levels-1)
+	gl.BindTexture(target, 0)
+	if target == gl.TEXTURE_CUBE_MAP {
+		gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
+	}
+	return texture
+}
+
+func (d *GLDevice) SetSampler(texture uint32, textureTarget gfx.TextureTarget, sampler SamplerState) {
+	target := glTextureTargets[textureTarget]
+	gl.BindTexture(target, texture)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_S, glWrapModes[sampler.WrapS])
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_T, glWrapModes[sampler.WrapT])
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_R, glWrapModes[sampler.WrapR])
+	gl.TexParameteri(target, gl.TEXTURE_MIN_FILTER, glFilterModes[sampler.MinFilter])
+	gl.TexParameteri(target, gl.TEXTURE_MAG_FILTER, glFilterModes[sampler.MagFilter])
+	if sampler.Anisotropy > 1 {
+		gl.TexParameterf(target, gl.TEXTURE_MAX_ANISOTROPY, sampler.Anisotropy)
+	}
+	gl.BindTexture(target, 0)
+}
+
+func (d *GLDevice) TexImage2D(texture uint32, target gfx.TextureTarget, level int32, format TexelFormat, width, height int32, pixels interface{}) {
+	bindTarget := textureBindTarget(target)
+	f := glTextureFormats[format.Format]
+	gl.BindTexture(bindTarget, texture)
+	gl.TexImage2D(glTextureTargets[target], level, f.internal(format.ColorSpace), width, height, 0, f.format, f.xtype, dataPointer(pixels))
+	gl.BindTexture(bindTarget, 0)
+}
+
+func (d *GLDevice) TexImage3D(texture uint32, textureTarget gfx.TextureTarget, level int32, format TexelFormat, width, height, depth int32, pixels interface{}) {
+	target := glTextureTargets[textureTarget]
+	f := glTextureFormats[format.Format]
+	gl.BindTexture(target, texture)
+	gl.TexImage3D(target, level, f.internal(format.ColorSpace), width, height, depth, 0, f.format, f.xtype, dataPointer(pixels))
+	gl.BindTexture(target, 0)
+}
+
+func (d *GLDevice) TexLayer(texture uint32, textureTarget gfx.TextureTarget, level, layer int32, format TexelFormat, width, height int32, pixels interface{}) {
+	target := glTextureTargets[textureTarget]
+	f := glTextureFormats[format.Format]
+	gl.BindTexture(target, texture)
+	gl.TexSubImage3D(target, level, 0, 0, layer, width, height, 1, f.format, f.xtype, dataPointer(pixels))
+	gl.BindTexture(target, 0)
+}
+
+func (d *GLDevice) CompressedTexImage2D(texture uint32, target gfx.TextureTarget, level int32, format TexelFormat, width, height int32, data []byte) {
+	bindTarget := textureBindTarget(target)
+	f := glTextureFormats[format.Format]
+	gl.BindTexture(bindTarget, texture)
+	gl.CompressedTexImage2D(glTextureTargets[target], level, uint32(f.internal(format.ColorSpace)), width, height, 0, int32(len(data)), dataPointer(data))
+	gl.BindTexture(bindTarget, 0)
+}
+
+func (d *GLDevice) GenerateMipmaps(texture uint32, textureTarget gfx.TextureTarget) {
+	target := glTextureTargets[textureTarget]
+	gl.BindTexture(target, texture)
+	gl.GenerateMipmap(target)
+	gl.BindTexture(target, 0)
+}
+
+// BindTexture leaves texture unit 0 active, the unit uploads bind to
+func (d *GLDevice) BindTexture(unit int, target gfx.TextureTarget, texture uint32) {
+	gl.ActiveTexture(gl.TEXTURE0 + uint32(unit))
+	gl.BindTexture(glTextureTargets[target], texture)
+	if unit != 0 {
+		gl.ActiveTexture(gl.TEXTURE0)
+	}
+}
+
+func (d *GLDevice) DeleteTexture(texture uint32) {
+	if texture != 0 {
+		gl.DeleteTextures(1, &texture)
+	}
+}
+
+// MaxAnisotropy needs GL 4.6 or the EXT/ARB_texture_filter_anisotropic
+// extension
+func (d *GLDevice) MaxAnisotropy() float32 {
+	if d.anisotropy >= 0 {
+		return d.anisotropy
+	}
+	d.anisotropy = 0
+	if d.extensionSupported("GL_EXT_texture_filter_anisotropic") || d.extensionSupported("GL_ARB_texture_filter_anisotropic") {
+		gl.GetFloatv(gl.MAX_TEXTURE_MAX_ANISOTROPY, &d.anisotropy)
+	}
+	return d.anisotropy
+}
+
+// FormatSupported checks the extension a compressed format needs
+func (d *GLDevice) FormatSupported(format gfx.TextureFormat) bool {
+	f, ok := glTextureFormats[format]
+	return ok && (f.extension == "" || d.extensionSupported(f.extension))
+}
+
+func (d *GLDevice) extensionSupported(name string) bool {
+	if d.extensions == nil {
+		d.extensions = make(map[string]bool)
+		var count int32
+		gl.GetIntegerv(gl.NUM_EXTENSIONS, &count)
+		for i := uint32(0); i < uint32(count); i++ {
+			d.extensions[gl.GoStr(gl.GetStringi(gl.EXTENSIONS, i))] = true
+		}
+	}
+	return d.extensions[name]
+}
+
+func (d *GLDevice) CreateRenderbuffer(format TexelFormat, width, height, samples int32) uint32 {
+	internalFormat := uint32(glTextureFormats[format.Format].internal(format.ColorSpace))
+	var renderbuffer uint32
+	gl.GenRenderbuffers(1, &renderbuffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, renderbuffer)
+	if samples > 1 {
+		gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, samples, internalFormat, width, height)
+	} else {
+		gl.RenderbufferStorage(gl.RENDERBUFFER, internalFormat, width, height)
+	}
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+	return renderbuffer
+}
+
+func (d *GLDevice) DeleteRenderbuffer(renderbuffer uint32) {
+	if renderbuffer != 0 {
+		gl.DeleteRenderbuffers(1, &renderbuffer)
+	}
+}
+
+func (d *GLDevice) MaxSamples() int32 {
+	var maxSamples int32
+	gl.GetIntegerv(gl.MAX_SAMPLES, &maxSamples)
+	return maxSamples
+}
+
+func (d *GLDevice) CreateFramebuffer(attachments []FramebufferAttachment) (uint32, error) {
+	var framebuffer uint32
+	gl.GenFramebuffers(1, &framebuffer)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, framebuffer)
+	defer gl.BindFramebuffer(gl.FRAMEBUFFER, d.framebuffer)
+
+	colors := 0
+	for _, a := range attachments {
+		if a.Attachment.IsColor() {
+			colors++
+		}
+		if a.Renderbuffer != 0 {
+			gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, glAttachment(a.Attachment), gl.RENDERBUFFER, a.Renderbuffer)
+		} else {
+			gl.FramebufferTexture2D(gl.FRAMEBUFFER, glAttachment(a.Attachment), gl.TEXTURE_2D, a.Texture, 0)
+		}
+	}
+	setDrawBuffers(colors)
+	d.drawBuffers[framebuffer] = colors
+
+	if len(attachments) > 0 {
+		if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+			d.DeleteFramebuffer(framebuffer)
+			return 0, fmt.Errorf("framebuffer incomplete: status 0x%x", status)
+		}
+	}
+	return framebuffer, nil
+}
+
+// setDrawBuffers routes fragment outputs 0 to count-1 to the color attachments
+// of the bound framebuffer
+func setDrawBuffers(count int) {
+	if count == 0 {
+		gl.DrawBuffer(gl.NONE)
+		gl.ReadBuffer(gl.NONE)
+		return
+	}
+	buffers := make([]uint32, count)
+	for i := range buffers {
+		buffers[i] = gl.COLOR_ATTACHMENT0 + uint32(i)
+	}
+	gl.DrawBuffers(int32(count), &buffers[0])
+}
+
+func (d *GLDevice) AttachLayer(framebuffer uint32, attachment gfx.Attachment, texture uint32, layer int32) {
+	if framebuffer != d.framebuffer {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, framebuffer)
+		defer gl.BindFramebuffer(gl.FRAMEBUFFER, d.framebuffer)
+	}
+	gl.FramebufferTextureLayer(gl.FRAMEBUFFER, glAttachment(attachment), texture, 0, layer)
+}
+
+func (d *GLDevice) BindFramebuffer(framebuffer uint32) {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, framebuffer)
+	d.framebuffer = framebuffer
+}
+
+func (d *GLDevice) BlitFramebuffer(from, to uint32, width, height int32, bufferMask gfx.BufferMask, attachment int) {
+	mask := glBufferMask(bufferMask)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, from)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, to)
+	if attachment < 0 {
+		gl.BlitFramebuffer(0, 0, width, height, 0, 0, width, height, mask, gl.NEAREST)
+	} else {
+		buffer := gl.COLOR_ATTACHMENT0 + uint32(attachment)
+		gl.ReadBuffer(buffer)
+		gl.DrawBuffer(buffer)
+		gl.BlitFramebuffer(0, 0, width, height, 0, 0, width, height, mask, gl.NEAREST)
+		gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+		setDrawBuffers(d.drawBuffers[to])
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, d.framebuffer)
+}
+
+func (d *GLDevice) DeleteFramebuffer(framebuffer uint32) {
+	if framebuffer == 0 {
+		return
+	}
+	if d.framebuffer == framebuffer {
+		d.framebuffer = 0
+	}
+	delete(d.drawBuffers, framebuffer)
+	gl.DeleteFramebuffers(1, &framebuffer)
+}
+
+func (d *GLDevice) ReadPixels(framebuffer uint32, attachment int, width, height int32, dst []byte) {
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, framebuffer)
+	if framebuffer == 0 {
+		gl.ReadBuffer(gl.BACK)
+	} else {
+		gl.ReadBuffer(gl.COLOR_ATTACHMENT0 + uint32(attachment))
+	}
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(0, 0, width, height, gl.RGBA, gl.UNSIGNED_BYTE, dataPointer(dst))
+	if framebuffer != 0 {
+		gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	}
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, d.framebuffer)
+}
+
+// SetFramebufferSRGB needs a window created sRGB capable to affect it
+func (d *GLDevice) SetFramebufferSRGB(enabled bool) {
+	setCapability(gl.FRAMEBUFFER_SRGB, enabled)
+}
+
+func (d *GLDevice) Viewport(x, y, width, height int32) {
+	gl.Viewport(x, y, width, height)
+}
+
+func (d *GLDevice) Clear(mask gfx.BufferMask) {
+	gl.Clear(glBufferMask(mask))
+}
+
+func (d *GLDevice) SetPipeline(state PipelineState) {
+	old := d.pipeline
+	all := !d.pipelineValid
+	d.pipeline = state
+	d.pipelineValid = true
+
+	if all || state.DepthTest != old.DepthTest {
+		setCapability(gl.DEPTH_TEST, state.DepthTest)
+	}
+	if all || state.DepthFunc != old.DepthFunc {
+		gl.DepthFunc(glCompareFuncs[state.DepthFunc])
+	}
+	if all || state.DepthWrite != old.DepthWrite {
+		gl.DepthMask(state.DepthWrite)
+	}
+	if all || state.DepthClamp != old.DepthClamp {
+		setCapability(gl.DEPTH_CLAMP, state.DepthClamp)
+	}
+	if all || state.Cull != old.Cull {
+		setCapability(gl.CULL_FACE, state.Cull != CullNone)
+		switch state.Cull {
+		case CullBack:
+			gl.CullFace(gl.BACK)
+		case CullFront:
+			gl.CullFace(gl.FRONT)
+		}
+	}
+	if all || state.Blend != old.Blend {
+		setCapability(gl.BLEND, state.Blend.Enabled)
+		if state.Blend.Enabled {
+			blend := state.Blend
+			gl.BlendFuncSeparate(glBlendFactors[blend.SrcColor], glBlendFactors[blend.DstColor], glBlendFactors[blend.SrcAlpha], glBlendFactors[blend.DstAlpha])
+		}
+	}
+	if all || state.Wireframe != old.Wireframe {
+		if state.Wireframe {
+			gl.PolygonMode(gl.FRONT_AND_BACK, gl.LINE)
+		} else {
+			gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
+		}
+	}
+	if all || state.PolygonOffsetFactor != old.PolygonOffsetFactor || state.PolygonOffsetUnits != old.PolygonOffsetUnits {
+		offset := state.PolygonOffsetFactor != 0 || state.PolygonOffsetUnits != 0
+		setCapability(gl.POLYGON_OFFSET_FILL, offset)
+		setCapability(gl.POLYGON_OFFSET_LINE, offset)
+		if offset {
+			gl.PolygonOffset(state.PolygonOffsetFactor, state.PolygonOffsetUnits)
+		}
+	}
+}
+
+func setCapability(capability uint32, enabled bool) {
+	if enabled {
+		gl.Enable(capability)
+	} else {
+		gl.Disable(capability)
+	}
+}
+
+func (d *GLDevice) Pipeline() PipelineState {
+	return d.pipeline
+}
+
+func (d *GLDevice) Draw(call DrawCall) {
+	if call.VertexArray != d.vertexArray {
+		gl.BindVertexArray(call.VertexArray)
+		d.vertexArray = call.VertexArray
+	}
+	mode := glPrimitiveModes[call.Mode]
+	switch {
+	case call.Indexed && call.Instances > 0:
+		gl.DrawElementsInstanced(mode, call.Count, gl.UNSIGNED_INT, gl.PtrOffset(0), call.Instances)
+	case call.Indexed:
+		gl.DrawElements(mode, call.Count, gl.UNSIGNED_INT, gl.PtrOffset(0))
+	case call.Instances > 0:
+		gl.DrawArraysInstanced(mode, 0, call.Count, call.Instances)
+	default:
+		gl.DrawArrays(mode, 0, call.Count)
+	}
+}
+
+// GL enums of the device's enum values
+
+var glBufferTargets = [...]uint32{
+	gfx.BufferVertices: gl.ARRAY_BUFFER,
+	gfx.BufferIndices:  gl.ELEMENT_ARRAY_BUFFER,
+	gfx.BufferUniforms: gl.UNIFORM_BUFFER,
+}
+
+var glBufferUsages = [...]uint32{
+	gfx.UsageStatic:  gl.STATIC_DRAW,
+	gfx.UsageDynamic: gl.DYNAMIC_DRAW,
+	gfx.UsageStream:  gl.STREAM_DRAW,
+}
+
+var glTextureTargets = [...]uint32{
+	gfx.Texture2D:               gl.TEXTURE_2D,
+	gfx.TextureCubeMap:          gl.TEXTURE_CUBE_MAP,
+	gfx.Texture2DArray:          gl.TEXTURE_2D_ARRAY,
+	gfx.TextureCubeMapArray:     gl.TEXTURE_CUBE_MAP_ARRAY,
+	gfx.TextureCubeMapPositiveX: gl.TEXTURE_CUBE_MAP_POSITIVE_X,
+	gfx.TextureCubeMapNegativeX: gl.TEXTURE_CUBE_MAP_NEGATIVE_X,
+	gfx.TextureCubeMapPositiveY: gl.TEXTURE_CUBE_MAP_POSITIVE_Y,
+	gfx.TextureCubeMapNegativeY: gl.TEXTURE_CUBE_MAP_NEGATIVE_Y,
+	gfx.TextureCubeMapPositiveZ: gl.TEXTURE_CUBE_MAP_POSITIVE_Z,
+	gfx.TextureCubeMapNegativeZ: gl.TEXTURE_CUBE_MAP_NEGATIVE_Z,
+}
+
+// Unset wrap modes and filters get SetSampler's defaults
+var glWrapModes = [...]int32{
+	gfx.WrapUnset:          gl.CLAMP_TO_EDGE,
+	gfx.WrapRepeat:         gl.REPEAT,
+	gfx.WrapClampToEdge:    gl.CLAMP_TO_EDGE,
+	gfx.WrapClampToBorder:  gl.CLAMP_TO_BORDER,
+	gfx.WrapMirroredRepeat: gl.MIRRORED_REPEAT,
+}
+
+var glFilterModes = [...]int32{
+	gfx.FilterUnset:                gl.LINEAR,
+	gfx.FilterNearest:              gl.NEAREST,
+	gfx.FilterLinear:               gl.LINEAR,
+	gfx.FilterNearestMipmapNearest: gl.NEAREST_MIPMAP_NEAREST,
+	gfx.FilterLinearMipmapNearest:  gl.LINEAR_MIPMAP_NEAREST,
+	gfx.FilterNearestMipmapLinear:  gl.NEAREST_MIPMAP_LINEAR,
+	gfx.FilterLinearMipmapLinear:   gl.LINEAR_MIPMAP_LINEAR,
+}
+
+var glCompareFuncs = [...]uint32{
+	gfx.CompareLess:         gl.LESS,
+	gfx.CompareNever:        gl.NEVER,
+	gfx.CompareEqual:        gl.EQUAL,
+	gfx.CompareLessEqual:    gl.LEQUAL,
+	gfx.CompareGreater:      gl.GREATER,
+	gfx.CompareNotEqual:     gl.NOTEQUAL,
+	gfx.CompareGreaterEqual: gl.GEQUAL,
+	gfx.CompareAlways:       gl.ALWAYS,
+}
+
+var glBlendFactors = [...]uint32{
+	gfx.FactorZero:             gl.ZERO,
+	gfx.FactorOne:              gl.ONE,
+	gfx.FactorSrcColor:         gl.SRC_COLOR,
+	gfx.FactorOneMinusSrcColor: gl.ONE_MINUS_SRC_COLOR,
+	gfx.FactorDstColor:         gl.DST_COLOR,
+	gfx.FactorOneMinusDstColor: gl.ONE_MINUS_DST_COLOR,
+	gfx.FactorSrcAlpha:         gl.SRC_ALPHA,
+	gfx.FactorOneMinusSrcAlpha: gl.ONE_MINUS_SRC_ALPHA,
+	gfx.FactorDstAlpha:         gl.DST_ALPHA,
+	gfx.FactorOneMinusDstAlpha: gl.ONE_MINUS_DST_ALPHA,
+}
+
+var glPrimitiveModes = [...]uint32{
+	gfx.PrimitiveTriangles:     gl.TRIANGLES,
+	gfx.PrimitivePoints:        gl.POINTS,
+	gfx.PrimitiveLines:         gl.LINES,
+	gfx.PrimitiveLineLoop:      gl.LINE_LOOP,
+	gfx.PrimitiveLineStrip:     gl.LINE_STRIP,
+	gfx.PrimitiveTriangleStrip: gl.TRIANGLE_STRIP,
+	gfx.PrimitiveTriangleFan:   gl.TRIANGLE_FAN,
+}
+
+func glAttachment(attachment gfx.Attachment) uint32 {
+	switch attachment {
+	case gfx.AttachmentDepth:
+		return gl.DEPTH_ATTACHMENT
+	case gfx.AttachmentDepthStencil:
+		return gl.DEPTH_STENCIL_ATTACHMENT
+	}
+	return gl.COLOR_ATTACHMENT0 + uint32(attachment-gfx.AttachmentColor0)
+}
+
+func glBufferMask(mask gfx.BufferMask) uint32 {
+	var bits uint32
+	if mask&gfx.ColorBuffer != 0 {
+		bits |= gl.COLOR_BUFFER_BIT
+	}
+	if mask&gfx.DepthBuffer != 0 {
+		bits |= gl.DEPTH_BUFFER_BIT
+	}
+	if mask&gfx.StencilBuffer != 0 {
+		bits |= gl.STENCIL_BUFFER_BIT
+	}
+	return bits
+}
+
+// sRGB S3TC formats from EXT_texture_sRGB, missing from the core bindings
+const (
+	compressedSRGBS3TCDXT1      = 0x8C4C
+	compressedSRGBAlphaS3TCDXT1 = 0x8C4D
+	compressedSRGBAlphaS3TCDXT3 = 0x8C4E
+	compressedSRGBAlphaS3TCDXT5 = 0x8C4F
+)
+
+// glTextureFormat is how GL stores a texture format. Compressed formats have
+// no upload format and type, and may need an extension to be sampled.
+type glTextureFormat struct {
+	internalFormat uint32
+	srgbFormat     uint32
+	format         uint32
+	xtype          uint32
+	extension      string
+}
+
+// internal returns the internal format at colorSpace
+func (f glTextureFormat) internal(colorSpace gfx.ColorSpace) int32 {
+	if colorSpace == gfx.ColorSpaceSRGB {
+		return int32(f.srgbFormat)
+	}
+	return int32(f.internalFormat)
+}
+
+var glTextureFormats = map[gfx.TextureFormat]glTextureFormat{
+	gfx.TextureFormatRGBA8:           {gl.RGBA8, gl.SRGB8_ALPHA8, gl.RGBA, gl.UNSIGNED_BYTE, ""},
+	gfx.TextureFormatRGB8:            {gl.RGB8, gl.SRGB8, gl.RGB, gl.UNSIGNED_BYTE, ""},
+	gfx.TextureFormatRG8:             {gl.RG8, 0, gl.RG, gl.UNSIGNED_BYTE, ""},
+	gfx.TextureFormatR8:              {gl.R8, 0, gl.RED, gl.UNSIGNED_BYTE, ""},
+	gfx.TextureFormatRGBA16:          {gl.RGBA16, 0, gl.RGBA, gl.UNSIGNED_SHORT, ""},
+	gfx.TextureFormatR16:             {gl.R16, 0, gl.RED, gl.UNSIGNED_SHORT, ""},
+	gfx.TextureFormatRGBA16F:         {gl.RGBA16F, 0, gl.RGBA, gl.FLOAT, ""},
+	gfx.TextureFormatRGB16F:          {gl.RGB16F, 0, gl.RGB, gl.FLOAT, ""},
+	gfx.TextureFormatRG16F:           {gl.RG16F, 0, gl.RG, gl.FLOAT, ""},
+	gfx.TextureFormatR16F:            {gl.R16F, 0, gl.RED, gl.FLOAT, ""},
+	gfx.TextureFormatRGBA32F:         {gl.RGBA32F, 0, gl.RGBA, gl.FLOAT, ""},
+	gfx.TextureFormatRGB32F:          {gl.RGB32F, 0, gl.RGB, gl.FLOAT, ""},
+	gfx.TextureFormatR32F:            {gl.R32F, 0, gl.RED, gl.FLOAT, ""},
+	gfx.TextureFormatDepth24:         {gl.DEPTH_COMPONENT24, 0, gl.DEPTH_COMPONENT, gl.FLOAT, ""},
+	gfx.TextureFormatDepth32F:        {gl.DEPTH_COMPONENT32F, 0, gl.DEPTH_COMPONENT, gl.FLOAT, ""},
+	gfx.TextureFormatDepth24Stencil8: {gl.DEPTH24_STENCIL8, 0, gl.DEPTH_STENCIL, gl.UNSIGNED_INT_24_8, ""},
+
+	gfx.TextureFormatBC1:   {gl.COMPRESSED_RGB_S3TC_DXT1_EXT, compressedSRGBS3TCDXT1, 0, 0, "GL_EXT_texture_compression_s3tc"},
+	gfx.TextureFormatBC1A:  {gl.COMPRESSED_RGBA_S3TC_DXT1_EXT, compressedSRGBAlphaS3TCDXT1, 0, 0, "GL_EXT_texture_compression_s3tc"},
+	gfx.TextureFormatBC2:   {gl.COMPRESSED_RGBA_S3TC_DXT3_EXT, compressedSRGBAlphaS3TCDXT3, 0, 0, "GL_EXT_texture_compression_s3tc"},
+	gfx.TextureFormatBC3:   {gl.COMPRESSED_RGBA_S3TC_DXT5_EXT, compressedSRGBAlphaS3TCDXT5, 0, 0, "GL_EXT_texture_compression_s3tc"},
+	gfx.TextureFormatBC4:   {gl.COMPRESSED_RED_RGTC1, 0, 0, 0, ""},
+	gfx.TextureFormatBC4S:  {gl.COMPRESSED_SIGNED_RED_RGTC1, 0, 0, 0, ""},
+	gfx.TextureFormatBC5:   {gl.COMPRESSED_RG_RGTC2, 0, 0, 0, ""},
+	gfx.TextureFormatBC5S:  {gl.COMPRESSED_SIGNED_RG_RGTC2, 0, 0, 0, ""},
+	gfx.TextureFormatBC6H:  {gl.COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT_ARB, 0, 0, 0, "GL_ARB_texture_compression_bptc"},
+	gfx.TextureFormatBC6HS: {gl.COMPRESSED_RGB_BPTC_SIGNED_FLOAT_ARB, 0, 0, 0, "GL_ARB_texture_compression_bptc"},
+	gfx.TextureFormatBC7:   {gl.COMPRESSED_RGBA_BPTC_UNORM_ARB, gl.COMPRESSED_SRGB_ALPHA_BPTC_UNORM_ARB, 0, 0, "GL_ARB_texture_compression_bptc"},
+}
+
+// glDataTypes maps the types reflection reports to the device's
+var glDataTypes = map[uint32]gfx.DataType{
+	gl.FLOAT:                         gfx.TypeFloat,
+	gl.FLOAT_VEC2:                    gfx.TypeVec2,
+	gl.FLOAT_VEC3:                    gfx.TypeVec3,
+	gl.FLOAT_VEC4:                    gfx.TypeVec4,
+	gl.DOUBLE:                        gfx.TypeDouble,
+	gl.DOUBLE_VEC2:                   gfx.TypeDVec2,
+	gl.DOUBLE_VEC3:                   gfx.TypeDVec3,
+	gl.DOUBLE_VEC4:                   gfx.TypeDVec4,
+	gl.INT:                           gfx.TypeInt,
+	gl.INT_VEC2:                      gfx.TypeIVec2,
+	gl.INT_VEC3:                      gfx.TypeIVec3,
+	gl.INT_VEC4:                      gfx.TypeIVec4,
+	gl.UNSIGNED_INT:                  gfx.TypeUint,
+	gl.UNSIGNED_INT_VEC2:             gfx.TypeUVec2,
+	gl.UNSIGNED_INT_VEC3:             gfx.TypeUVec3,
+	gl.UNSIGNED_INT_VEC4:             gfx.TypeUVec4,
+	gl.BOOL:                          gfx.TypeBool,
+	gl.BOOL_VEC2:                     gfx.TypeBVec2,
+	gl.BOOL_VEC3:                     gfx.TypeBVec3,
+	gl.BOOL_VEC4:                     gfx.TypeBVec4,
+	gl.FLOAT_MAT2:                    gfx.TypeMat2,
+	gl.FLOAT_MAT3:                    gfx.TypeMat3,
+	gl.FLOAT_MAT4:                    gfx.TypeMat4,
+	gl.FLOAT_MAT2x3:                  gfx.TypeMat2x3,
+	gl.FLOAT_MAT2x4:                  gfx.TypeMat2x4,
+	gl.FLOAT_MAT3x2:                  gfx.TypeMat3x2,
+	gl.FLOAT_MAT3x4:                  gfx.TypeMat3x4,
+	gl.FLOAT_MAT4x2:                  gfx.TypeMat4x2,
+	gl.FLOAT_MAT4x3:                  gfx.TypeMat4x3,
+	gl.DOUBLE_MAT2:                   gfx.TypeDMat2,
+	gl.DOUBLE_MAT3:                   gfx.TypeDMat3,
+	gl.DOUBLE_MAT4:                   gfx.TypeDMat4,
+	gl.DOUBLE_MAT2x3:                 gfx.TypeDMat2x3,
+	gl.DOUBLE_MAT2x4:                 gfx.TypeDMat2x4,
+	gl.DOUBLE_MAT3x2:                 gfx.TypeDMat3x2,
+	gl.DOUBLE_MAT3x4:                 gfx.TypeDMat3x4,
+	gl.DOUBLE_MAT4x2:                 gfx.TypeDMat4x2,
+	gl.DOUBLE_MAT4x3:                 gfx.TypeDMat4x3,
+	gl.SAMPLER_2D:                    gfx.TypeSampler2D,
+	gl.SAMPLER_3D:                    gfx.TypeSampler3D,
+	gl.SAMPLER_CUBE:                  gfx.TypeSamplerCube,
+	gl.SAMPLER_2D_SHADOW:             gfx.TypeSampler2DShadow,
+	gl.SAMPLER_2D_ARRAY:              gfx.TypeSampler2DArray,
+	gl.SAMPLER_2D_ARRAY_SHADOW:       gfx.TypeSampler2DArrayShadow,
+	gl.SAMPLER_CUBE_SHADOW:           gfx.TypeSamplerCubeShadow,
+	gl.SAMPLER_CUBE_MAP_ARRAY:        gfx.TypeSamplerCubeArray,
+	gl.SAMPLER_CUBE_MAP_ARRAY_SHADOW: gfx.TypeSamplerCubeArrayShadow,
+	gl.SAMPLER_2D_MULTISAMPLE:        gfx.TypeSampler2DMS,
+	gl.INT_SAMPLER_2D:                gfx.TypeISampler2D,
+	gl.UNSIGNED_INT_SAMPLER_2D:       gfx.TypeUSampler2D,
+}
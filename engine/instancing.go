@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"physics/gfx"
+	"unsafe"
+)
+
+// Instance is the per-copy data of an instanced draw. It is uploaded as is, so
+// its layout must match the instance attributes in the shaders.
+type Instance struct {
+	Model mgl32.Mat4
+	// Color multiplies the material's texture color
+	Color mgl32.Vec4
+}
+
+// InstancingThreshold is how many opaque draws of the same mesh with equal
+// materials it takes for a RenderQueue to merge them into one instanced draw
+var InstancingThreshold = 4
+
+// instancedDefines selects the instanced variant of a shader, which reads its
+// model matrix and tint from per-instance attributes
+var instancedDefines = ShaderDefines{"INSTANCED": "1"}
+
+const (
+	instanceModelLocation = 3
+	instanceColorLocation = 7
+)
+
+// InstancedMesh draws many copies of one mesh with a single draw call
+type InstancedMesh struct {
+	Mesh      *Mesh
+	Material  Material
+	Instances []Instance
+	// Renderer, when set, draws the instances instead of the renderer's queue,
+	// in one call when it is an InstancedObjectRenderer
+	Renderer ObjectRenderer
+
+	array *instanceArray
+}
+
+func NewInstancedMesh(mesh *Mesh, material Material) *InstancedMesh {
+	return &InstancedMesh{Mesh: mesh, Material: material}
+}
+
+// Add appends an instance and returns its index in Instances
+func (m *InstancedMesh) Add(model mgl32.Mat4, color mgl32.Vec4) int {
+	m.Instances = append(m.Instances, Instance{Model: model, Color: color})
+	return len(m.Instances) - 1
+}
+
+func (m *InstancedMesh) instanceArray() *instanceArray {
+	if m.array == nil {
+		m.array = newInstanceArray(m.Mesh)
+	}
+	return m.array
+}
+
+// Delete frees the instance buffer. The mesh is owned by the caller.
+func (m *InstancedMesh) Delete() {
+	if m.array != nil {
+		m.array.delete()
+		m.array = nil
+	}
+}
+
+// instanceArray is a vertex array reading a mesh's vertex buffers plus a
+// buffer of Instance values advanced once per instance
+type instanceArray struct {
+	vao    uint32
+	buffer uint32
+}
+
+func newInstanceArray(mesh *Mesh) *instanceArray {
+	a := &instanceArray{}
+	a.buffer = device.CreateBuffer(gfx.BufferVertices, 0, nil, gfx.UsageStream)
+
+	// a mat4 attribute takes four consecutive locations, one per column
+	attributes := mesh.vertexAttributes()
+	stride := int32(unsafe.Sizeof(Instance{}))
+	for column := uint32(0); column < 4; column++ {
+		attributes = append(attributes, VertexAttribute{
+			Location:   instanceModelLocation + column,
+			Buffer:     a.buffer,
+			Components: 4,
+			Stride:     stride,
+			Offset:     int(column) * 16,
+			Divisor:    1,
+		})
+	}
+	attributes = append(attributes, VertexAttribute{
+		Location:   instanceColorLocation,
+		Buffer:     a.buffer,
+		Components: 4,
+		Stride:     stride,
+		Offset:     int(unsafe.Offsetof(Instance{}.Color)),
+		Divisor:    1,
+	})
+
+	a.vao = device.CreateVertexArray(VertexLayout{Attributes: attributes, IndexBuffer: mesh.indexBuffer})
+	return a
+}
+
+// upload replaces the instance buffer's contents, orphaning the old storage so
+// the driver doesn't wait for draws still reading it
+func (a *instanceArray) upload(instances []Instance) {
+	device.ReallocateBuffer(gfx.BufferVertices, a.buffer, len(instances)*int(unsafe.Sizeof(Instance{})), instances, gfx.UsageStream)
+}
+
+func (a *instanceArray) delete() {
+	device.DeleteBuffer(a.buffer)
+	device.DeleteVertexArray(a.vao)
+}
+
+// materialKey is the part of a material that decides how it draws, so equal
+// keys can share a batch or an instanced draw
+func materialKey(m *Material) Material {
+	key := *m
+	key.uniforms = nil
+	key.ownedTexture = nil
+	return key
+}
+
+// mergeInstances replaces every group of at least InstancingThreshold opaque
+// items with the same mesh, equal materials and the same renderer by one
+// instanced item, drawn where the first item of the group was. Items with a
+// renderer that can't draw instances are left alone.
+func (q *RenderQueue) mergeInstances() {
+	type instanceKey struct {
+		mesh     *Mesh
+		material Material
+		renderer ObjectRenderer
+	}
+	mergeable := func(item *DrawItem) bool {
+		if item.Instances != nil || len(item.Mesh.Indices) == 0 {
+			return false
+		}
+		_, instanced := item.Renderer.(InstancedObjectRenderer)
+		return item.Renderer == nil || instanced
+	}
+	groups := make(map[instanceKey][]int)
+	for i := range q.Opaque {
+		item := &q.Opaque[i]
+		if !mergeable(item) {
+			continue
+		}
+		key := instanceKey{item.Mesh, materialKey(item.Material), item.Renderer}
+		groups[key] = append(groups[key], i)
+	}
+
+	merged := make([]DrawItem, 0, len(q.Opaque))
+	for i, item := range q.Opaque {
+		if !mergeable(&item) {
+			merged = append(merged, item)
+			continue
+		}
+		group := groups[instanceKey{item.Mesh, materialKey(item.Material), item.Renderer}]
+		switch {
+		case len(group) < InstancingThreshold:
+			merged = append(merged, item)
+		case group[0] == i:
+			item.Instances = make([]Instance, len(group))
+			for j, index := range group {
+				item.Instances[j] = Instance{Model: q.Opaque[index].Model, Color: mgl32.Vec4{1, 1, 1, 1}}
+			}
+			item.objects = len(group)
+			merged = append(merged, item)
+		}
+	}
+	q.Opaque = merged
+}
@@ -1,8 +1,33 @@
 package engine
 
-import "github.com/go-gl/mathgl/mgl32"
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"physics/scene"
+)
+
+// MaxLights is the number of point lights the lighting shaders accept per draw.
+const MaxLights = scene.MaxLights
 
 type Light struct {
-	Position mgl32.Vec3
-	Color    mgl32.Vec3
+	scene.PointLight
+
+	// CastShadows requests an omnidirectional shadow map for this light. Only
+	// the closest PointShadowSettings.MaxLights shadowed lights get one each frame.
+	CastShadows bool
+
+	shadowLayer int
+}
+
+// NewPointLight creates a point light at position with the given color and range
+func NewPointLight(position, color mgl32.Vec3, lightRange float32) *Light {
+	return &Light{
+		PointLight:  scene.PointLight{Position: position, Color: color, Range: lightRange},
+		shadowLayer: -1,
+	}
+}
+
+// ShadowLayer returns the cube map array layer holding this light's shadow map
+// for the current frame, or -1 when the light is unshadowed.
+func (l *Light) ShadowLayer() int {
+	return l.shadowLayer
 }
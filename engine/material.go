@@ -1,31 +1,60 @@
 package engine
 
 import (
-	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
+	"physics/scene"
 )
 
 type Material struct {
-	Ambient       mgl32.Vec3
-	Diffuse       mgl32.Vec3
-	Specular      mgl32.Vec3
-	Shininess     float32
-	TextureHandle uint32
+	scene.Phong
+	Texture *Texture
 
-	shader *ShaderProgram
+	shader   *ShaderProgram
+	uniforms *UniformBuffer
+	// ownedTexture is the texture the material holds a reference on, see SetTexture
+	ownedTexture *Texture
 }
 
-func NewDefaultMaterial() *Material {
-	// A default material using the default shader
-	return &Material{
-		Ambient:       mgl32.Vec3{0.1, 0.1, 0.1},
-		Diffuse:       mgl32.Vec3{0.5, 0.5, 0.5},
-		Specular:      mgl32.Vec3{0.5, 0.5, 0.5},
-		Shininess:     32.0,
-		TextureHandle: 0,
-		shader:        LoadShader("shaders/default.vert", "shaders/default.frag"),
+// PhongMaterialUniforms mirrors the PhongMaterial block in default.frag
+type PhongMaterialUniforms struct {
+	Ambient   mgl32.Vec3
+	Diffuse   mgl32.Vec3
+	Specular  mgl32.Vec3
+	Shininess float32
+
+	Opacity     float32
+	AlphaCutoff float32
+	// Premultiplied scales the lit color by Opacity as well as alpha
+	Premultiplied bool
+}
+
+// sharedMaterialBuffer is used by materials that were not created through
+// NewDefaultMaterial and so have no buffer of their own; it is re-uploaded on
+// every bind.
+var sharedMaterialBuffer *UniformBuffer
+
+// NewDefaultMaterial returns a material using the default shader. When the
+// shader fails to build the error is returned with a material drawing with the
+// error shader, see LoadShader.
+func NewDefaultMaterial() (*Material, error) {
+	shader, shaderErr := LoadShader("shaders/default.vert", "shaders/default.frag")
+	if shader == nil {
+		return nil, shaderErr
+	}
+	uniforms, err := NewUniformBuffer(MaterialUniformBinding, &PhongMaterialUniforms{})
+	if err != nil {
+		shader.Release()
+		return nil, err
 	}
 
+	materialBufferReferences[uniforms] = 1
+
+	return &Material{
+		Phong:    scene.DefaultPhong(),
+		Texture:  nil,
+		shader:   shader,
+		uniforms: uniforms,
+	}, shaderErr
 }
 
 func (m *Material) GetShader() *ShaderProgram {
@@ -36,27 +65,104 @@ func (m *Material) BindShaderProperties(shader *ShaderProgram) error {
 	shader.Use()
 
 	// Bind material properties to the shader
-	ambientUniform := shader.GetUniformLocation("material.ambient")
-	shader.SetUniform3f(ambientUniform, m.Ambient.X(), m.Ambient.Y(), m.Ambient.Z())
+	uniforms := PhongMaterialUniforms{
+		Ambient:       m.Ambient,
+		Diffuse:       m.Diffuse,
+		Specular:      m.Specular,
+		Shininess:     m.Shininess,
+		Opacity:       m.Opacity,
+		Premultiplied: m.BlendMode == BlendPremultiplied,
+	}
+	if m.BlendMode == BlendCutout {
+		uniforms.AlphaCutoff = m.AlphaCutoff
+	}
+	shader.checkUniformBlock("PhongMaterial", &uniforms)
+
+	buffer := m.uniforms
+	if buffer == nil {
+		if sharedMaterialBuffer == nil {
+			shared, err := NewUniformBuffer(MaterialUniformBinding, &uniforms)
+			if err != nil {
+				return err
+			}
+			sharedMaterialBuffer = shared
+		}
+		buffer = sharedMaterialBuffer
+	}
+	if err := buffer.Update(&uniforms); err != nil {
+		return err
+	}
+	buffer.Bind()
 
-	diffuseUniform := shader.GetUniformLocation("material.diffuse")
-	shader.SetUniform3f(diffuseUniform, m.Diffuse.X(), m.Diffuse.Y(), m.Diffuse.Z())
+	// Bind texture, untextured materials sample plain white
+	texture := m.Texture
+	if texture == nil {
+		texture = WhiteTexture()
+	}
+	texture.Bind(0)
+	shader.SetSampler("materialTexture", 0)
 
-	specularUniform := shader.GetUniformLocation("material.specular")
-	shader.SetUniform3f(specularUniform, m.Specular.X(), m.Specular.Y(), m.Specular.Z())
+	return nil
+}
 
-	shininessUniform := shader.GetUniformLocation("material.shininess")
-	shader.SetUniform1f(shininessUniform, m.Shininess)
+func (m *Material) textureHandle() uint32 {
+	if m.Texture == nil {
+		return 0
+	}
+	return m.Texture.Handle()
+}
 
-	// Bind texture if available
-	if m.TextureHandle != 0 {
-		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, m.TextureHandle)
-		textureUniform := shader.GetUniformLocation("material.texture")
-		shader.SetUniform1i(textureUniform, 0)
+// materialBufferReferences counts the materials holding each uniform buffer
+// created by NewDefaultMaterial
+var materialBufferReferences = make(map[*UniformBuffer]int)
+
+// SetTexture assigns texture and has the material hold a reference on it
+// through Assets, dropped by Release. Textures assigned to Texture directly
+// stay owned by whoever assigned them.
+func (m *Material) SetTexture(texture *Texture) {
+	Assets.RetainTexture(texture)
+	if m.ownedTexture != nil {
+		Assets.ReleaseTexture(m.ownedTexture)
 	}
+	m.Texture = texture
+	m.ownedTexture = texture
+}
 
-	return nil
+// Retain adds a reference on each handle the material holds. A copy of a
+// Material shares the handles of the original, so a copy that is released on
+// its own must be retained first.
+func (m *Material) Retain() {
+	if m.shader != nil {
+		m.shader.Retain()
+	}
+	if m.uniforms != nil {
+		materialBufferReferences[m.uniforms]++
+	}
+	if m.ownedTexture != nil {
+		Assets.RetainTexture(m.ownedTexture)
+	}
+}
+
+// Release drops the material's reference on each handle it holds: its shader,
+// its uniform buffer and a texture given to SetTexture. Each handle is freed
+// when its last holder releases it.
+func (m *Material) Release() {
+	if m.shader != nil {
+		m.shader.Release()
+		m.shader = nil
+	}
+	if m.uniforms != nil {
+		materialBufferReferences[m.uniforms]--
+		if materialBufferReferences[m.uniforms] <= 0 {
+			delete(materialBufferReferences, m.uniforms)
+			m.uniforms.Delete()
+		}
+		m.uniforms = nil
+	}
+	if m.ownedTexture != nil {
+		Assets.ReleaseTexture(m.ownedTexture)
+		m.ownedTexture = nil
+	}
 }
 
 func (m *Material) GetAttributeMap() map[uint32]string {
@@ -1,12 +1,12 @@
 package engine
 
-import "github.com/go-gl/gl/v4.1-core/gl"
+import (
+    "physics/gfx"
+    "physics/scene"
+)
 
 type Mesh struct {
-    Vertices   []float32
-    TexCoords  []float32
-    Normals    []float32
-    Indices    []uint32
+    scene.Geometry
     Depth      float32
     Vao        uint32
     IndexCount int32
@@ -16,6 +16,9 @@ type Mesh struct {
     texCoordBuffer uint32
     normalBuffer   uint32
     indexBuffer    uint32
+    // instances is the vertex array of the instanced draws render queues
+    // merge the mesh's items into, created on the first one
+    instances *instanceArray
 }
 
 func (m *Mesh) GetAttribSize(attr uint32) int32 {
@@ -23,36 +26,18 @@ func (m *Mesh) GetAttribSize(attr uint32) int32 {
 }
 
 func NewMesh(combinedVertices []CombinedVertex, indices []uint32) *Mesh {
-    vertexCount := len(combinedVertices)
-    mesh := &Mesh{
-        Vertices:  make([]float32, vertexCount*3),
-        TexCoords: make([]float32, vertexCount*2),
-        Normals:   make([]float32, vertexCount*3),
-        Indices:   indices,
-
-        Depth: 0.0,
+    mesh := NewMeshData(combinedVertices, indices)
+    mesh.Upload()
+    return mesh
+}
 
-        Vao:        0,
+// NewMeshData builds the mesh's vertex data without uploading it, for
+// renderers that don't draw with a Device
+func NewMeshData(combinedVertices []CombinedVertex, indices []uint32) *Mesh {
+    return &Mesh{
+        Geometry:   scene.NewGeometry(combinedVertices, indices),
         IndexCount: int32(len(indices)),
-
-        Material: Material{},
-    }
-
-    for i, cv := range combinedVertices {
-        mesh.Vertices[i*3] = cv.Position.X()
-        mesh.Vertices[i*3+1] = cv.Position.Y()
-        mesh.Vertices[i*3+2] = cv.Position.Z()
-
-        mesh.TexCoords[i*2] = cv.TexCoord.X()
-        mesh.TexCoords[i*2+1] = cv.TexCoord.Y()
-
-        mesh.Normals[i*3] = cv.Normal.X()
-        mesh.Normals[i*3+1] = cv.Normal.Y()
-        mesh.Normals[i*3+2] = cv.Normal.Z()
     }
-
-    mesh.SetupGLBuffers()
-    return mesh
 }
 
 func NewMeshNormalLines(mesh *Mesh, scale float32) *Mesh {
@@ -70,58 +55,68 @@ func NewMeshNormalLines(mesh *Mesh, scale float32) *Mesh {
     }
 
     normalLineMesh := &Mesh{
-        Vertices: normalLineVertices,
+        Geometry: scene.Geometry{Vertices: normalLineVertices},
         Vao:      0,
     }
-    normalLineMesh.SetupGLBuffers()
+    normalLineMesh.Upload()
 
     return normalLineMesh
 }
 
-func (mesh *Mesh) SetupGLBuffers() {
-    gl.GenVertexArrays(1, &mesh.Vao)
-    gl.BindVertexArray(mesh.Vao)
-
-    // Setup vertex buffer
-    gl.GenBuffers(1, &mesh.vertexBuffer)
-    gl.BindBuffer(gl.ARRAY_BUFFER, mesh.vertexBuffer)
-    gl.BufferData(gl.ARRAY_BUFFER, len(mesh.Vertices)*4, gl.Ptr(&mesh.Vertices[0]), gl.STATIC_DRAW)
-
-    // Set vertex attribute pointer for vertex positions
-    gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
-    gl.EnableVertexAttribArray(0)
-
-    // Setup texture coordinate buffer
-    if mesh.TexCoords != nil {
-        gl.GenBuffers(1, &mesh.texCoordBuffer)
-        gl.BindBuffer(gl.ARRAY_BUFFER, mesh.texCoordBuffer)
-        gl.BufferData(gl.ARRAY_BUFFER, len(mesh.TexCoords)*4, gl.Ptr(&mesh.TexCoords[0]), gl.STATIC_DRAW)
+// Upload creates the mesh's vertex array and buffers on the device
+func (mesh *Mesh) Upload() {
+    mesh.vertexBuffer = uploadFloats(mesh.Vertices)
+    mesh.texCoordBuffer = uploadFloats(mesh.TexCoords)
+    mesh.normalBuffer = uploadFloats(mesh.Normals)
+    if len(mesh.Indices) > 0 {
+        mesh.indexBuffer = device.CreateBuffer(gfx.BufferIndices, len(mesh.Indices)*4, mesh.Indices, gfx.UsageStatic)
     }
 
-    // Set vertex attribute pointer for texture coordinates
-    gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 0, gl.PtrOffset(0))
-    gl.EnableVertexAttribArray(1)
+    mesh.Vao = device.CreateVertexArray(VertexLayout{
+        Attributes:  mesh.vertexAttributes(),
+        IndexBuffer: mesh.indexBuffer,
+    })
+}
 
-    // Setup normal buffer
-    if mesh.Normals != nil {
-        gl.GenBuffers(1, &mesh.normalBuffer)
-        gl.BindBuffer(gl.ARRAY_BUFFER, mesh.normalBuffer)
-        gl.BufferData(gl.ARRAY_BUFFER, len(mesh.Normals)*4, gl.Ptr(&mesh.Normals[0]), gl.STATIC_DRAW)
+func uploadFloats(data []float32) uint32 {
+    if len(data) == 0 {
+        return 0
     }
+    return device.CreateBuffer(gfx.BufferVertices, len(data)*4, data, gfx.UsageStatic)
+}
 
-    // Set vertex attribute pointer for normals
-    gl.VertexAttribPointer(2, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
-    gl.EnableVertexAttribArray(2)
-
-    // Setup index buffer
+// vertexAttributes feeds positions, texture coordinates and normals to
+// locations 0, 1 and 2, leaving out the ones the mesh doesn't have
+func (mesh *Mesh) vertexAttributes() []VertexAttribute {
+    var attributes []VertexAttribute
+    for location, attribute := range []struct {
+        buffer     uint32
+        components int32
+    }{{mesh.vertexBuffer, 3}, {mesh.texCoordBuffer, 2}, {mesh.normalBuffer, 3}} {
+        if attribute.buffer != 0 {
+            attributes = append(attributes, VertexAttribute{Location: uint32(location), Buffer: attribute.buffer, Components: attribute.components})
+        }
+    }
+    return attributes
+}
 
-    if mesh.Indices != nil {
-        gl.GenBuffers(1, &mesh.indexBuffer)
-        gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, mesh.indexBuffer)
-        gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(mesh.Indices)*4, gl.Ptr(&mesh.Indices[0]), gl.STATIC_DRAW)
+// Delete frees the mesh's vertex array and buffers
+func (mesh *Mesh) Delete() {
+    if mesh.Vao == 0 {
+        return
+    }
+    for _, buffer := range []uint32{mesh.vertexBuffer, mesh.texCoordBuffer, mesh.normalBuffer, mesh.indexBuffer} {
+        device.DeleteBuffer(buffer)
+    }
+    device.DeleteVertexArray(mesh.Vao)
+    if mesh.instances != nil {
+        mesh.instances.delete()
+        mesh.instances = nil
     }
 
-    // Unbind buffers and VAO
-    gl.BindBuffer(gl.ARRAY_BUFFER, 0)
-    gl.BindVertexArray(0)
+    mesh.vertexBuffer = 0
+    mesh.texCoordBuffer = 0
+    mesh.normalBuffer = 0
+    mesh.indexBuffer = 0
+    mesh.Vao = 0
 }
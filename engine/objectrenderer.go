@@ -2,6 +2,27 @@ package engine
 
 import "github.com/go-gl/mathgl/mgl32"
 
+// ObjectRenderer draws a GameObject itself instead of the renderer's queue. It
+// is called in the object's place in the draw order and may change any GL
+// state; the renderer restores its own afterwards.
 type ObjectRenderer interface {
 	Render(mesh *Mesh, material Material, model mgl32.Mat4, proj mgl32.Mat4, view mgl32.Mat4)
 }
+
+// InstancedObjectRenderer is an ObjectRenderer that can draw every instance of
+// an instanced item in one call. vertexArray holds the mesh's attributes and
+// the instances, laid out as for the INSTANCED shader variants. Renderers
+// without it are called once per instance.
+type InstancedObjectRenderer interface {
+	ObjectRenderer
+	RenderInstanced(mesh *Mesh, material Material, instances []Instance, vertexArray uint32)
+}
+
+// DeferredObjectRenderer is an ObjectRenderer the DeferredRenderer can draw
+// into its G-buffer, with a shader writing the outputs of
+// shaders/common/gbuffer.glsl. RenderDeferred returns false when it can't, and
+// the object is drawn forward instead.
+type DeferredObjectRenderer interface {
+	ObjectRenderer
+	RenderDeferred(mesh *Mesh, material Material, model mgl32.Mat4) bool
+}
@@ -2,73 +2,31 @@ package engine
 
 import (
 	"bufio"
-	"bytes"
-	"errors"
 	"fmt"
-	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
-	"image"
 	"image/color"
-	"image/draw"
-	_ "image/jpeg"
-	_ "image/png"
 	"os"
 	"path/filepath"
+	"physics/scene"
 	"strconv"
 	"strings"
 )
 
-type Vertex struct {
-	X, Y, Z float32
-}
-
-func (v Vertex) ToVec3() mgl32.Vec3 {
-	return mgl32.Vec3{v.X, v.Y, v.Z}
-}
-
-type TexCoord struct {
-	Y, V float32
-}
-
-func (v TexCoord) ToVec2() mgl32.Vec2 {
-	return mgl32.Vec2{v.Y, v.V}
-}
-
-type Normal struct {
-	X, Y, Z float32
-}
-
-func (v Normal) ToVec3() mgl32.Vec3 {
-	return mgl32.Vec3{v.X, v.Y, v.Z}
-}
-
-type FaceVertex struct {
-	VertexIndex   int
-	TexCoordIndex int
-	NormalIndex   int
-}
-
-type CombinedVertex struct {
-	Position mgl32.Vec3
-	TexCoord mgl32.Vec2
-	Normal   mgl32.Vec3
-}
+// The OBJ geometry is parsed by package scene, which builds without cgo
+type (
+	Vertex          = scene.Vertex
+	TexCoord        = scene.TexCoord
+	Normal          = scene.Normal
+	FaceVertex      = scene.FaceVertex
+	CombinedVertex  = scene.CombinedVertex
+	ImportedMeshObj = scene.ImportedMeshObj
+)
 
 type ImportedModel struct {
 	Objects         []*ImportedMeshObj
 	materialLibrary map[string]material
 }
 
-type ImportedMeshObj struct {
-	Name           string
-	Vertices       []Vertex
-	Indices        []uint32
-	CombinedVertex []CombinedVertex
-	TexCoords      []TexCoord
-	Normals        []Normal
-	FaceIndices    []FaceVertex
-}
-
 type material struct {
 	name      string
 	ambient   color.RGBA
@@ -76,23 +34,9 @@ type material struct {
 	specular  color.RGBA
 	emissive  mgl32.Vec3
 	shininess float32
-	texture   uint32
-}
-
-func (o *ImportedMeshObj) AddVertex(v Vertex) {
-	o.Vertices = append(o.Vertices, v)
-}
-
-func (o *ImportedMeshObj) AddTexCoord(t TexCoord) {
-	o.TexCoords = append(o.TexCoords, t)
-}
-
-func (o *ImportedMeshObj) AddNormal(n Normal) {
-	o.Normals = append(o.Normals, n)
-}
-
-func (o *ImportedMeshObj) AddFaceVertex(v FaceVertex) {
-	o.FaceIndices = append(o.FaceIndices, v)
+	// texturePath is the map_Kd image, loaded by MaterialTexture
+	texturePath string
+	texture     *Texture
 }
 
 func LdrParseObj(filePath string) (*ImportedModel, error) {
@@ -105,140 +49,51 @@ func LdrParseObj(filePath string) (*ImportedModel, error) {
 	folderRootPathSplit := strings.Split(strings.ReplaceAll(filePath, "\\", "/"), "/")
 	folderRootPath := folderRootPathSplit[:len(folderRootPathSplit)-1][0]
 
-	model := &ImportedModel{
-		Objects: make([]*ImportedMeshObj, 0),
-	}
-	currentObject := &ImportedMeshObj{}
-	model.Objects = append(model.Objects, currentObject)
-
-	scanner := bufio.NewScanner(bytes.NewReader(fileContents))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if len(line) == 0 || strings.HasPrefix(line, "#") {
-			continue
-		}
-		fields := strings.Fields(line)
-		if len(fields) == 0 {
-			continue
-		}
-		switch fields[0] {
-		case "o":
-			if len(fields) < 2 {
-				return nil, errors.New("malformed obj file, missing object name")
-			}
-			currentObject = &ImportedMeshObj{
-				Name: fields[1],
-			}
-			model.Objects = append(model.Objects, currentObject)
-		case "v":
-			v, err := LdrParseVertex(line)
-			if err != nil {
-				return nil, err
-			}
-			currentObject.Vertices = append(currentObject.Vertices, v)
-		case "vt":
-			t, err := LdrParseTexCoord(line)
-			if err != nil {
-				return nil, err
-			}
-			currentObject.TexCoords = append(currentObject.TexCoords, t)
-		case "vn":
-			n, err := LdrParseNormal(line)
-			if err != nil {
-				return nil, err
-			}
-			currentObject.Normals = append(currentObject.Normals, n)
-		case "f":
-			faceVertices := make([]FaceVertex, 0, len(fields)-1)
-			for _, field := range fields[1:] {
-				f, err := LdrParseFaceVertex(field)
-				if err != nil {
-					return nil, fmt.Errorf("could not parse face vertex: %v", err)
-				}
-				faceVertices = append(faceVertices, f)
-			}
-
-			for _, faceVertex := range faceVertices {
-				combinedVertex := CombinedVertex{
-					Position: currentObject.Vertices[faceVertex.VertexIndex].ToVec3(),
-					Normal:   currentObject.Normals[faceVertex.NormalIndex].ToVec3(),
-				}
-				if len(currentObject.TexCoords) > faceVertex.TexCoordIndex {
-					combinedVertex.TexCoord = currentObject.TexCoords[faceVertex.TexCoordIndex].ToVec2()
-				}
-				index := findExistingCombinedVertexIndex(currentObject.CombinedVertex, combinedVertex)
-				if index == -1 {
-					currentObject.CombinedVertex = append(currentObject.CombinedVertex, combinedVertex)
-					index = len(currentObject.CombinedVertex) - 1
-				}
-				currentObject.Indices = append(currentObject.Indices, uint32(index))
-			}
-		case "s":
-			continue
-			/*if len(fields) > 1 && fields[1] == "1" {
-			  	smooth = true
-			  } else {
-			  	smooth = false
-			  }*/
-		/*case "usemtl":
-		  materialName, err := LdrParseusemtl(line)
-		  if err != nil {
-		  	return nil, err
-		  }
-		  currentObject.MaterialName = materialName
-
-		  // Add face material
-		  faceIndices := make([]int, 0)
-		  for i := 0; i < len(currentObject.FaceIndices); i++ {
-		  	faceIndices = append(faceIndices, i)
-		  }
-		  currentObject.AddFaceMaterial(materialName, faceIndices)*/
-		case "mtllib":
-			if len(fields) < 2 {
-				return nil, errors.New("malformed obj file, missing material library name")
-			}
-			materialMap, err := LdrParseMtlLib(filepath.Join(folderRootPath, fields[1]))
-			if err != nil {
-				return nil, err
-			}
-
-			model.materialLibrary = materialMap
+	model := &ImportedModel{}
+	objects, err := scene.ParseObj(fileContents, func(name string) error {
+		materialMap, err := LdrParseMtlLib(filepath.Join(folderRootPath, name))
+		if err != nil {
+			return err
 		}
-	}
-	if err := scanner.Err(); err != nil {
+		model.materialLibrary = materialMap
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
+	model.Objects = objects
+	return model, nil
+}
 
-	// Center the model
-	for _, mesh := range model.Objects {
-		if mesh == nil {
-			continue
-		}
-		var totalX, totalY, totalZ float32
-		for _, vertex := range mesh.Vertices {
-			totalX += vertex.X
-			totalY += vertex.Y
-			totalZ += vertex.Z
-		}
-		centerX := totalX / float32(len(mesh.Vertices))
-		centerY := totalY / float32(len(mesh.Vertices))
-		centerZ := totalZ / float32(len(mesh.Vertices))
-		for i := range mesh.Vertices {
-			mesh.Vertices[i].X -= centerX
-			mesh.Vertices[i].Y -= centerY
-			mesh.Vertices[i].Z -= centerZ
-		}
+// MaterialTexture returns the diffuse texture of a material of the model's
+// material library, loading it through Assets on first use. It is nil for
+// materials without one.
+func (m *ImportedModel) MaterialTexture(name string) (*Texture, error) {
+	mtl, ok := m.materialLibrary[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown material %q", name)
 	}
-	return model, nil
+	if mtl.texture != nil || mtl.texturePath == "" {
+		return mtl.texture, nil
+	}
+	texture, err := Assets.Texture(mtl.texturePath, ColorTextureOptions())
+	if err != nil {
+		return nil, fmt.Errorf("could not load texture image for material %q: %v", name, err)
+	}
+	mtl.texture = texture
+	m.materialLibrary[name] = mtl
+	return texture, nil
 }
 
-func findExistingCombinedVertexIndex(combinedVertices []CombinedVertex, target CombinedVertex) int {
-	for i, v := range combinedVertices {
-		if v.Position == target.Position && v.TexCoord == target.TexCoord && v.Normal == target.Normal {
-			return i
+// Release drops the references the model's material library holds on its textures
+func (m *ImportedModel) Release() {
+	for name, mtl := range m.materialLibrary {
+		if mtl.texture != nil {
+			Assets.ReleaseTexture(mtl.texture)
+			mtl.texture = nil
+			m.materialLibrary[name] = mtl
 		}
 	}
-	return -1
 }
 
 func LdrParseMtlLib(path string) (map[string]material, error) {
@@ -255,9 +110,11 @@ func LdrParseMtlLib(path string) (map[string]material, error) {
 	for scanner.Scan() {
 		line := scanner.Text()
 		if strings.HasPrefix(line, "newmtl ") {
+			if curMtl != nil {
+				mtls[curMtl.name] = *curMtl
+			}
 			name := strings.TrimSpace(line[7:])
-			curMtl = &material{}
-			mtls[name] = *curMtl
+			curMtl = &material{name: name}
 		} else if curMtl != nil {
 			fields := strings.Fields(line)
 			if len(fields) > 0 {
@@ -292,13 +149,7 @@ func LdrParseMtlLib(path string) (map[string]material, error) {
 					texturePathTemp = strings.Replace(texturePathTemp, "\\", "/", -1)
 					textureFileTemp := strings.Split(texturePathTemp, "/")
 
-					texturePath := filepath.Join(filepath.Dir(path), textureFileTemp[len(textureFileTemp)-1])
-
-					texture, err := loadImage(texturePath)
-					if err != nil {
-						return nil, fmt.Errorf("could not load texture image for material %q: %V", curMtl.name, err)
-					}
-					curMtl.texture = texture
+					curMtl.texturePath = filepath.Join(filepath.Dir(path), textureFileTemp[len(textureFileTemp)-1])
 				}
 			}
 		}
@@ -307,6 +158,9 @@ func LdrParseMtlLib(path string) (map[string]material, error) {
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
+	if curMtl != nil {
+		mtls[curMtl.name] = *curMtl
+	}
 
 	return mtls, nil
 }
@@ -331,46 +185,6 @@ func LdrParseColor(line string) (color.RGBA, error) {
 	return color.RGBA{uint8(r * 255), uint8(g * 255), uint8(b * 255), 255}, nil
 }
 
-func loadImage(texturePath string) (uint32, error) {
-	file, err := os.Open(texturePath)
-	if err != nil {
-		return 0, fmt.Errorf("failed to open texture file: %w", err)
-	}
-	defer file.Close()
-
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return 0, fmt.Errorf("failed to decode texture file: %w", err)
-	}
-
-	rgba := image.NewRGBA(img.Bounds())
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{}, draw.Src)
-
-	var textureID uint32
-	gl.GenTextures(1, &textureID)
-
-	gl.BindTexture(gl.TEXTURE_2D, textureID)
-
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-
-	gl.TexImage2D(
-		gl.TEXTURE_2D,
-		0,
-		gl.RGBA,
-		int32(rgba.Rect.Size().X),
-		int32(rgba.Rect.Size().Y),
-		0,
-		gl.RGBA,
-		gl.UNSIGNED_BYTE,
-		gl.Ptr(rgba.Pix),
-	)
-
-	return textureID, nil
-}
-
 func LdrParseusemtl(line string) (string, error) {
 	fields := strings.Fields(line)[1:]
 	if len(fields) != 1 {
@@ -378,112 +192,3 @@ func LdrParseusemtl(line string) (string, error) {
 	}
 	return fields[0], nil
 }
-
-func LdrParseVertex(line string) (Vertex, error) {
-	fields := strings.Fields(line)[1:]
-	if len(fields) != 3 {
-		return Vertex{}, fmt.Errorf("expected 3 fields in Vertex, found %d", len(fields))
-	}
-	x, err := strconv.ParseFloat(fields[0], 32)
-	if err != nil {
-		return Vertex{}, fmt.Errorf("could not parse Vertex X coordinate: %V", err)
-	}
-	y, err := strconv.ParseFloat(fields[1], 32)
-	if err != nil {
-		return Vertex{}, fmt.Errorf("could not parse Vertex Y coordinate: %V", err)
-	}
-	z, err := strconv.ParseFloat(fields[2], 32)
-	if err != nil {
-		return Vertex{}, fmt.Errorf("could not parse Vertex Z coordinate: %V", err)
-	}
-	return Vertex{float32(x), float32(y), float32(z)}, nil
-}
-
-func LdrParseTexCoord(line string) (TexCoord, error) {
-	fields := strings.Fields(line)[1:]
-	if len(fields) != 2 {
-		return TexCoord{}, fmt.Errorf("expected 2 fields in texture coordinate, found %d", len(fields))
-	}
-	u, err := strconv.ParseFloat(fields[0], 32)
-	if err != nil {
-		return TexCoord{}, fmt.Errorf("could not parse texture coordinate Y value: %V", err)
-	}
-	v, err := strconv.ParseFloat(fields[1], 32)
-	if err != nil {
-		return TexCoord{}, fmt.Errorf("could not parse texture coordinate V value: %V", err)
-	}
-	return TexCoord{float32(u), float32(v)}, nil
-}
-
-func LdrParseNormal(line string) (Normal, error) {
-	fields := strings.Fields(line)[1:]
-	if len(fields) != 3 {
-		return Normal{}, fmt.Errorf("expected 3 fields in Normal, found %d", len(fields))
-	}
-	x, err := strconv.ParseFloat(fields[0], 32)
-	if err != nil {
-		return Normal{}, fmt.Errorf("could not parse Normal X component: %V", err)
-	}
-	y, err := strconv.ParseFloat(fields[1], 32)
-	if err != nil {
-		return Normal{}, fmt.Errorf("could not parse Normal Y component: %V", err)
-	}
-	z, err := strconv.ParseFloat(fields[2], 32)
-	if err != nil {
-		return Normal{}, fmt.Errorf("could not parse Normal Z component: %V", err)
-	}
-	return Normal{float32(x), float32(y), float32(z)}, nil
-}
-
-func LdrParseFaceVertex(field string) (FaceVertex, error) {
-	faceVertex := FaceVertex{}
-
-	indices := strings.Split(field, "/")
-	switch len(indices) {
-	case 1:
-		// Vertex index
-		vertexIndex, err := strconv.Atoi(indices[0])
-		if err != nil {
-			return faceVertex, err
-		}
-		faceVertex.VertexIndex = vertexIndex - 1
-	case 2:
-		// Vertex index / texture coordinate index
-		vertexIndex, err := strconv.Atoi(indices[0])
-		if err != nil {
-			return faceVertex, err
-		}
-		faceVertex.VertexIndex = vertexIndex - 1
-
-		texCoordIndex, err := strconv.Atoi(indices[1])
-		if err != nil {
-			return faceVertex, err
-		}
-		faceVertex.TexCoordIndex = texCoordIndex - 1
-	case 3:
-		// Vertex index / texture coordinate index / Normal index
-		vertexIndex, err := strconv.Atoi(indices[0])
-		if err != nil {
-			return faceVertex, err
-		}
-		faceVertex.VertexIndex = vertexIndex - 1
-
-		if len(indices[1]) > 0 {
-			texCoordIndex, err := strconv.Atoi(indices[1])
-			if err != nil {
-				return faceVertex, err
-			}
-			faceVertex.TexCoordIndex = texCoordIndex - 1
-		}
-
-		normalIndex, err := strconv.Atoi(indices[2])
-		if err != nil {
-			return faceVertex, err
-		}
-		faceVertex.NormalIndex = normalIndex - 1
-	default:
-		return faceVertex, fmt.Errorf("invalid number of indices for face Vertex: %s", field)
-	}
-
-	return faceVertex, nil
-}
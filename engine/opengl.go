@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
@@ -34,11 +35,54 @@ func GLContext() *OpenGlContext {
 	return instance
 }
 
+// InitGLContext creates the shared context with opts instead of the defaults.
+// It has to be called before the first GLContext call.
+func InitGLContext(opts ContextOptions) (*OpenGlContext, error) {
+	var err error
+	created := false
+	once.Do(func() {
+		created = true
+		instance, err = NewGLContextWithOptions(opts)
+	})
+	if !created {
+		return nil, errors.New("GL context already created")
+	}
+	return instance, err
+}
+
+type ContextOptions struct {
+	Width  int
+	Height int
+	Title  string
+	// Headless hides the window. Hidden windows' back buffers may not be kept,
+	// so frames meant to be read are drawn into a Framebuffer, such as a
+	// PostProcessor's Output, and read with CaptureFramebuffer.
+	Headless bool
+	// ContextAPI picks how the context is created, glfw.NativeContextAPI when
+	// zero. glfw.EGLContextAPI and glfw.OSMesaContextAPI allow rendering on
+	// software Mesa without a display server.
+	ContextAPI int
+}
+
+func DefaultContextOptions() ContextOptions {
+	return ContextOptions{Width: 1280, Height: 800, Title: "Physics"}
+}
+
 func NewGLContext() (*OpenGlContext, error) {
+	return NewGLContextWithOptions(DefaultContextOptions())
+}
+
+func NewGLContextWithOptions(opts ContextOptions) (*OpenGlContext, error) {
 	if err := InitGlfw(); err != nil {
 		return nil, err
 	}
-	window, err := glfw.CreateWindow(1280, 800, "Physics", nil, nil)
+	if opts.Headless {
+		glfw.WindowHint(glfw.Visible, glfw.False)
+	}
+	if opts.ContextAPI != 0 {
+		glfw.WindowHint(glfw.ContextCreationAPI, opts.ContextAPI)
+	}
+	window, err := glfw.CreateWindow(opts.Width, opts.Height, opts.Title, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -52,6 +96,7 @@ func NewGLContext() (*OpenGlContext, error) {
 	if err := gl.Init(); err != nil {
 		panic(err)
 	}
+	device.SetFramebufferSRGB(true)
 
 	return &OpenGlContext{window}, nil
 }
@@ -65,5 +110,7 @@ func InitGlfw() error {
 	glfw.WindowHint(glfw.ContextVersionMinor, 1)
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+	// Shaders output linear color, the window encodes it to sRGB
+	glfw.WindowHint(glfw.SRGBCapable, glfw.True)
 	return nil
 }
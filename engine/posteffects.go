@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"physics/gfx"
+)
+
+type ToneMapOperator int32
+
+const (
+	ToneMapACES ToneMapOperator = iota
+	ToneMapReinhard
+	// ToneMapClamp only clips, for scenes that are already in range
+	ToneMapClamp
+)
+
+// ToneMapping scales HDR color by Exposure and maps it into [0, 1]
+type ToneMapping struct {
+	Exposure float32
+	Operator ToneMapOperator
+
+	shader *ShaderProgram
+}
+
+func NewToneMapping(operator ToneMapOperator) (*ToneMapping, error) {
+	shader, err := NewPostShader("shaders/post/tonemap.frag", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ToneMapping{Exposure: 1, Operator: operator, shader: shader}, nil
+}
+
+func (t *ToneMapping) Apply(ctx *PostContext, input *Texture) error {
+	t.shader.Use()
+	t.shader.SetFloat("exposure", t.Exposure)
+	t.shader.SetInt("toneMapper", int32(t.Operator))
+	DrawPass(t.shader, input)
+	return nil
+}
+
+func (t *ToneMapping) Delete() {
+	t.shader.Release()
+}
+
+// Bloom makes bright parts of the image bleed into their surroundings. The
+// bright parts are blurred over a chain of ever smaller images, each level
+// widening the glow.
+type Bloom struct {
+	// Threshold is the brightness bloom starts at, softened over Knee
+	Threshold float32
+	Knee      float32
+	Intensity float32
+	// Levels is the length of the chain, the first level being half size
+	Levels int
+
+	prefilter  *ShaderProgram
+	downsample *ShaderProgram
+	upsample   *ShaderProgram
+	combine    *ShaderProgram
+	chain      []*Framebuffer
+}
+
+func NewBloom() (*Bloom, error) {
+	b := &Bloom{Threshold: 1, Knee: 0.5, Intensity: 0.05, Levels: 6}
+	for _, pass := range []struct {
+		shader **ShaderProgram
+		define string
+	}{
+		{&b.prefilter, "PREFILTER"},
+		{&b.downsample, "DOWNSAMPLE"},
+		{&b.upsample, "UPSAMPLE"},
+		{&b.combine, "COMBINE"},
+	} {
+		shader, err := NewPostShader("shaders/post/bloom.frag", ShaderDefines{pass.define: "1"})
+		if err != nil {
+			b.Delete()
+			return nil, err
+		}
+		*pass.shader = shader
+	}
+	return b, nil
+}
+
+func (b *Bloom) Apply(ctx *PostContext, input *Texture) error {
+	if err := b.resize(ctx.Width, ctx.Height); err != nil {
+		return err
+	}
+
+	b.chain[0].Bind()
+	b.prefilter.Use()
+	b.prefilter.SetFloat("threshold", b.Threshold)
+	b.prefilter.SetFloat("knee", b.Knee)
+	DrawPass(b.prefilter, input)
+	for i := 1; i < len(b.chain); i++ {
+		b.chain[i].Bind()
+		DrawPass(b.downsample, b.chain[i-1].Color[0])
+	}
+
+	// Each level is blurred up into the next larger one, adding to its glow
+	pipeline := device.Pipeline()
+	additive := pipeline
+	additive.Blend = AdditiveBlend()
+	device.SetPipeline(additive)
+	for i := len(b.chain) - 1; i > 0; i-- {
+		b.chain[i-1].Bind()
+		DrawPass(b.upsample, b.chain[i].Color[0])
+	}
+	device.SetPipeline(pipeline)
+
+	ctx.BindOutput()
+	b.combine.Use()
+	b.chain[0].Color[0].Bind(1)
+	b.combine.SetSampler("bloomTexture", 1)
+	b.combine.SetFloat("intensity", b.Intensity)
+	DrawPass(b.combine, input)
+	return nil
+}
+
+// resize builds the chain of half sized framebuffers for a width x height input
+func (b *Bloom) resize(width, height int32) error {
+	levels := b.Levels
+	if levels < 1 {
+		levels = 1
+	}
+	if len(b.chain) == levels && b.chain[0].Width == max32(width/2, 1) && b.chain[0].Height == max32(height/2, 1) {
+		return nil
+	}
+	b.deleteChain()
+	for i := 0; i < levels; i++ {
+		width, height = max32(width/2, 1), max32(height/2, 1)
+		level, err := NewFramebuffer(width, height, FramebufferOptions{Color: []TextureFormat{TextureFormatRGBA16F}})
+		if err != nil {
+			return err
+		}
+		b.chain = append(b.chain, level)
+	}
+	return nil
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (b *Bloom) deleteChain() {
+	for _, level := range b.chain {
+		level.Delete()
+	}
+	b.chain = nil
+}
+
+func (b *Bloom) Delete() {
+	b.deleteChain()
+	for _, shader := range []*ShaderProgram{b.prefilter, b.downsample, b.upsample, b.combine} {
+		if shader != nil {
+			shader.Release()
+		}
+	}
+}
+
+// FXAA smooths jagged edges in the final image. It belongs after ToneMapping.
+type FXAA struct {
+	// SpanMax is the longest blur along an edge, in pixels
+	SpanMax float32
+
+	shader *ShaderProgram
+}
+
+func NewFXAA() (*FXAA, error) {
+	shader, err := NewPostShader("shaders/post/fxaa.frag", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &FXAA{SpanMax: 8, shader: shader}, nil
+}
+
+func (f *FXAA) Apply(ctx *PostContext, input *Texture) error {
+	f.shader.Use()
+	f.shader.SetFloat("spanMax", f.SpanMax)
+	DrawPass(f.shader, input)
+	return nil
+}
+
+func (f *FXAA) Delete() {
+	f.shader.Release()
+}
+
+// Vignette darkens the image towards its corners
+type Vignette struct {
+	Intensity float32
+	// Radius is where darkening ends, 1 being the corners, and Softness how far
+	// inwards it fades in
+	Radius   float32
+	Softness float32
+
+	shader *ShaderProgram
+}
+
+func NewVignette() (*Vignette, error) {
+	shader, err := NewPostShader("shaders/post/vignette.frag", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Vignette{Intensity: 0.5, Radius: 1, Softness: 0.6, shader: shader}, nil
+}
+
+func (v *Vignette) Apply(ctx *PostContext, input *Texture) error {
+	v.shader.Use()
+	v.shader.SetFloat("intensity", v.Intensity)
+	v.shader.SetFloat("radius", v.Radius)
+	v.shader.SetFloat("softness", v.Softness)
+	DrawPass(v.shader, input)
+	return nil
+}
+
+func (v *Vignette) Delete() {
+	v.shader.Release()
+}
+
+// ColorGrading remaps colors through a lookup table, a strip of N slices of
+// N x N texels as exported by most grading tools. It belongs after ToneMapping.
+type ColorGrading struct {
+	LUT *Texture
+	// Intensity blends between the original (0) and graded (1) colors
+	Intensity float32
+
+	shader *ShaderProgram
+}
+
+func NewColorGrading(lut *Texture) (*ColorGrading, error) {
+	shader, err := NewPostShader("shaders/post/colorgrading.frag", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ColorGrading{LUT: lut, Intensity: 1, shader: shader}, nil
+}
+
+// LoadColorGradingLUT loads a lookup table strip with the sampling it needs:
+// no sRGB decoding, no mipmaps, clamped
+func LoadColorGradingLUT(path string) (*Texture, error) {
+	return LoadTexture(path, TextureOptions{Sampler: SamplerState{MinFilter: gfx.FilterLinear, MagFilter: gfx.FilterLinear}})
+}
+
+func (g *ColorGrading) Apply(ctx *PostContext, input *Texture) error {
+	g.shader.Use()
+	g.LUT.Bind(1)
+	g.shader.SetSampler("lut", 1)
+	g.shader.SetFloat("lutSize", float32(g.LUT.Height))
+	g.shader.SetFloat("intensity", g.Intensity)
+	DrawPass(g.shader, input)
+	return nil
+}
+
+// Delete frees the effect's shader. The LUT is owned by the caller.
+func (g *ColorGrading) Delete() {
+	g.shader.Release()
+}
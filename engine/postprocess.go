@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// PostEffect is one stage of a PostProcessor. Effects before ToneMapping see
+// linear HDR color, effects after it linear color in [0, 1].
+type PostEffect interface {
+	// Apply draws input with the effect applied into the output framebuffer,
+	// which is bound when Apply is called
+	Apply(ctx *PostContext, input *Texture) error
+	Delete()
+}
+
+// PostContext is what effects get to work with besides their input
+type PostContext struct {
+	Width  int32
+	Height int32
+	// Depth is the scene's depth buffer, drawn with View and Projection
+	Depth      *Texture
+	View       mgl32.Mat4
+	Projection mgl32.Mat4
+
+	output *Framebuffer
+}
+
+// BindOutput binds the framebuffer the effect writes to again, for effects
+// that draw intermediate passes into framebuffers of their own
+func (c *PostContext) BindOutput() {
+	c.output.Bind()
+}
+
+// PostProcessor renders scenes into an HDR framebuffer and runs a chain of
+// effects over it on the way to the window
+type PostProcessor struct {
+	Effects []PostEffect
+	// Output, when set, receives the processed frame instead of the window,
+	// and the scene is drawn at its size. Headless renderers draw into one
+	// with ColorSpaceSRGB and read it with CaptureFramebuffer.
+	Output *Framebuffer
+
+	window  *glfw.Window
+	samples int32
+	scene   *Framebuffer
+	swap    [2]*Framebuffer
+	present *ShaderProgram
+}
+
+// NewPostProcessor creates a post-processor whose scene target has the given
+// number of MSAA samples, 0 or 1 for none
+func NewPostProcessor(window *glfw.Window, samples int32) (*PostProcessor, error) {
+	present, err := NewPostShader("shaders/post/present.frag", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &PostProcessor{window: window, samples: samples, present: present}, nil
+}
+
+// NewPostShader builds a full-screen pass from a fragment shader
+func NewPostShader(fragmentPath string, defines ShaderDefines) (*ShaderProgram, error) {
+	return NewShaderVariant("shaders/post/fullscreen.vert", fragmentPath, defines)
+}
+
+// size is the size frames are drawn at, the window's or Output's
+func (p *PostProcessor) size() (int32, int32) {
+	if p.Output != nil {
+		return p.Output.Width, p.Output.Height
+	}
+	width, height := p.window.GetFramebufferSize()
+	return int32(width), int32(height)
+}
+
+// Target returns the HDR framebuffer scenes are drawn into, sized to the
+// window or to Output. It fails while the window has no area, e.g. when it
+// is minimized.
+func (p *PostProcessor) Target() (*Framebuffer, error) {
+	if err := p.resize(p.size()); err != nil {
+		return nil, err
+	}
+	return p.scene, nil
+}
+
+func (p *PostProcessor) resize(width, height int32) error {
+	if p.scene != nil {
+		if err := p.scene.Resize(width, height); err != nil {
+			return err
+		}
+		for _, swap := range p.swap {
+			if err := swap.Resize(width, height); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var err error
+	p.scene, err = NewFramebuffer(width, height, FramebufferOptions{
+		Color:        []TextureFormat{TextureFormatRGBA16F},
+		DepthTexture: true,
+		Samples:      p.samples,
+	})
+	if err != nil {
+		return err
+	}
+	for i := range p.swap {
+		p.swap[i], err = NewFramebuffer(width, height, FramebufferOptions{Color: []TextureFormat{TextureFormatRGBA16F}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenderScene draws a frame of scene from its primary camera into the HDR
+// target and post-processes it to the window. Frames of a window with no
+// area are skipped.
+func (p *PostProcessor) RenderScene(renderer Renderer, scene *Scene) error {
+	if width, height := p.size(); width < 1 || height < 1 {
+		return nil
+	}
+	target, err := p.Target()
+	if err != nil {
+		return err
+	}
+	renderer.BeginFrame(scene)
+	err = RenderSceneTo(renderer, scene, scene.Camera, target)
+	renderer.EndFrame()
+	if err != nil {
+		return err
+	}
+	return p.Process(scene.Camera)
+}
+
+// Process runs the effects over the HDR target, drawn from camera, and writes
+// the result to the window or Output. It leaves the pipeline state as it
+// found it.
+func (p *PostProcessor) Process(camera *Camera) error {
+	ctx := &PostContext{
+		Width:      p.scene.Width,
+		Height:     p.scene.Height,
+		Depth:      p.scene.Depth,
+		View:       camera.ViewMatrix(),
+		Projection: camera.ProjectionMatrix(),
+	}
+
+	pipeline := device.Pipeline()
+	passes := pipeline
+	passes.DepthTest = false
+	device.SetPipeline(passes)
+	defer device.SetPipeline(pipeline)
+	input := p.scene.Color[0]
+	for i, effect := range p.Effects {
+		ctx.output = p.swap[i%2]
+		ctx.output.Bind()
+		if err := effect.Apply(ctx, input); err != nil {
+			device.BindFramebuffer(0)
+			return err
+		}
+		input = ctx.output.Color[0]
+	}
+
+	if p.Output != nil {
+		p.Output.Bind()
+	} else {
+		device.BindFramebuffer(0)
+		device.Viewport(0, 0, ctx.Width, ctx.Height)
+	}
+	DrawPass(p.present, input)
+	device.BindFramebuffer(0)
+	return nil
+}
+
+func (p *PostProcessor) Delete() {
+	for _, effect := range p.Effects {
+		effect.Delete()
+	}
+	if p.scene != nil {
+		p.scene.Delete()
+		for _, swap := range p.swap {
+			swap.Delete()
+		}
+	}
+	p.present.Release()
+}
+
+var fullscreenVao uint32
+
+// DrawFullscreen draws one triangle covering the viewport with the current
+// program, whose vertex stage is shaders/post/fullscreen.vert or generates its
+// positions from gl_VertexID the same way
+func DrawFullscreen() {
+	if fullscreenVao == 0 {
+		fullscreenVao = device.CreateVertexArray(VertexLayout{})
+	}
+	device.Draw(DrawCall{VertexArray: fullscreenVao, Count: 3})
+}
+
+// DrawPass runs shader over the bound framebuffer with source bound to its
+// sourceTexture sampler on unit 0, and texelSize set to source's texel size
+// when the shader has it. Other uniforms are set by the caller beforehand.
+func DrawPass(shader *ShaderProgram, source *Texture) {
+	shader.Use()
+	source.Bind(0)
+	shader.SetSampler("sourceTexture", 0)
+	if shader.HasUniform("texelSize") {
+		shader.SetVec2("texelSize", mgl32.Vec2{1 / float32(source.Width), 1 / float32(source.Height)})
+	}
+	DrawFullscreen()
+}
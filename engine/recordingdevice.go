@@ -0,0 +1,433 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"physics/gfx"
+	"reflect"
+	"unsafe"
+)
+
+// RecordingDevice passes every call on to the device it wraps and records the
+// ones that create resources, change state or draw. EndFrame writes the calls
+// of a frame as one line of JSON, which cmd/framedump prints. Each draw is
+// recorded with the program, uniforms, textures, buffers and pipeline state it
+// was made with.
+type RecordingDevice struct {
+	device Device
+	out    *json.Encoder
+	frame  RecordedFrame
+	// RecordBufferData stores the bytes written to buffers, so the vertices,
+	// indices and uniform blocks of a draw can be inspected
+	RecordBufferData bool
+
+	program        uint32
+	uniformNames   map[uint32]map[int32]string
+	uniforms       map[uint32]map[int32]RecordedUniform
+	textures       map[int]BoundTexture
+	uniformBuffers map[uint32]uint32
+	framebuffer    uint32
+	viewport       [4]int32
+	vertexArrays   map[uint32]VertexLayout
+}
+
+var _ Device = (*RecordingDevice)(nil)
+
+// The recorded calls are gfx types, so cmd/framedump reads them without cgo
+type (
+	RecordedFrame   = gfx.RecordedFrame
+	RecordedCommand = gfx.RecordedCommand
+	DrawState       = gfx.DrawState
+	RecordedUniform = gfx.RecordedUniform
+	BoundTexture    = gfx.BoundTexture
+)
+
+// NewRecordingDevice records the calls made to device, writing a line of JSON
+// to out at the end of each frame. Make it current with SetDevice before any
+// resource is created, so draws can be related to them.
+func NewRecordingDevice(device Device, out io.Writer) *RecordingDevice {
+	return &RecordingDevice{
+		device:         device,
+		out:            json.NewEncoder(out),
+		uniformNames:   make(map[uint32]map[int32]string),
+		uniforms:       make(map[uint32]map[int32]RecordedUniform),
+		textures:       make(map[int]BoundTexture),
+		uniformBuffers: make(map[uint32]uint32),
+		vertexArrays:   make(map[uint32]VertexLayout),
+	}
+}
+
+// EndFrame writes the calls recorded since the last EndFrame and starts the
+// next frame. Call it before swapping buffers.
+func (r *RecordingDevice) EndFrame() error {
+	err := r.out.Encode(r.frame)
+	r.frame = RecordedFrame{Frame: r.frame.Frame + 1}
+	return err
+}
+
+func (r *RecordingDevice) record(command RecordedCommand) {
+	r.frame.Commands = append(r.frame.Commands, command)
+}
+
+// bufferData copies the bytes of a slice written to a buffer, if they are
+// being recorded
+func (r *RecordingDevice) bufferData(data interface{}, size int) []byte {
+	if !r.RecordBufferData || data == nil {
+		return nil
+	}
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice || v.Len() == 0 {
+		return nil
+	}
+	if length := v.Len() * int(v.Type().Elem().Size()); size > length {
+		size = length
+	}
+	bytes := unsafe.Slice((*byte)(unsafe.Pointer(v.Pointer())), size)
+	return append([]byte(nil), bytes...)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func (r *RecordingDevice) CreateBuffer(kind gfx.BufferKind, size int, data interface{}, usage gfx.BufferUsage) uint32 {
+	buffer := r.device.CreateBuffer(kind, size, data, usage)
+	r.record(RecordedCommand{
+		Call:   "CreateBuffer",
+		Args:   map[string]interface{}{"kind": kind, "size": size, "usage": usage},
+		Result: buffer,
+		Data:   r.bufferData(data, size),
+	})
+	return buffer
+}
+
+func (r *RecordingDevice) WriteBuffer(kind gfx.BufferKind, buffer uint32, offset, size int, data interface{}) {
+	r.device.WriteBuffer(kind, buffer, offset, size, data)
+	r.record(RecordedCommand{
+		Call: "WriteBuffer",
+		Args: map[string]interface{}{"kind": kind, "buffer": buffer, "offset": offset, "size": size},
+		Data: r.bufferData(data, size),
+	})
+}
+
+func (r *RecordingDevice) ReallocateBuffer(kind gfx.BufferKind, buffer uint32, size int, data interface{}, usage gfx.BufferUsage) {
+	r.device.ReallocateBuffer(kind, buffer, size, data, usage)
+	r.record(RecordedCommand{
+		Call: "ReallocateBuffer",
+		Args: map[string]interface{}{"kind": kind, "buffer": buffer, "size": size, "usage": usage},
+		Data: r.bufferData(data, size),
+	})
+}
+
+func (r *RecordingDevice) DeleteBuffer(buffer uint32) {
+	r.device.DeleteBuffer(buffer)
+	r.record(RecordedCommand{Call: "DeleteBuffer", Args: map[string]interface{}{"buffer": buffer}})
+}
+
+func (r *RecordingDevice) BindUniformBuffer(binding, buffer uint32) {
+	r.device.BindUniformBuffer(binding, buffer)
+	r.uniformBuffers[binding] = buffer
+	r.record(RecordedCommand{Call: "BindUniformBuffer", Args: map[string]interface{}{"binding": binding, "buffer": buffer}})
+}
+
+func (r *RecordingDevice) CreateVertexArray(layout VertexLayout) uint32 {
+	vertexArray := r.device.CreateVertexArray(layout)
+	r.vertexArrays[vertexArray] = layout
+	r.record(RecordedCommand{Call: "CreateVertexArray", Args: map[string]interface{}{"layout": layout}, Result: vertexArray})
+	return vertexArray
+}
+
+func (r *RecordingDevice) DeleteVertexArray(vertexArray uint32) {
+	r.device.DeleteVertexArray(vertexArray)
+	delete(r.vertexArrays, vertexArray)
+	r.record(RecordedCommand{Call: "DeleteVertexArray", Args: map[string]interface{}{"vertexArray": vertexArray}})
+}
+
+func (r *RecordingDevice) CreateProgram(vertexSource, fragmentSource string) (uint32, error) {
+	program, err := r.device.CreateProgram(vertexSource, fragmentSource)
+	r.record(RecordedCommand{
+		Call:   "CreateProgram",
+		Args:   map[string]interface{}{"vertexSource": vertexSource, "fragmentSource": fragmentSource},
+		Result: program,
+		Error:  errorString(err),
+	})
+	return program, err
+}
+
+func (r *RecordingDevice) DeleteProgram(program uint32) {
+	r.device.DeleteProgram(program)
+	delete(r.uniformNames, program)
+	delete(r.uniforms, program)
+	r.record(RecordedCommand{Call: "DeleteProgram", Args: map[string]interface{}{"program": program}})
+}
+
+// ReflectProgram is recorded with its result, which cmd/framedump decodes
+// uniform blocks with. It also gives the names uniforms are recorded by.
+func (r *RecordingDevice) ReflectProgram(program uint32) ProgramReflection {
+	reflection := r.device.ReflectProgram(program)
+	names := make(map[int32]string, len(reflection.Locations))
+	for name, location := range reflection.Locations {
+		// An array's first element is also found by the array's name
+		if other, ok := names[location]; !ok || name < other {
+			names[location] = name
+		}
+	}
+	r.uniformNames[program] = names
+	r.record(RecordedCommand{
+		Call:       "ReflectProgram",
+		Args:       map[string]interface{}{"program": program},
+		Reflection: &reflection,
+	})
+	return reflection
+}
+
+func (r *RecordingDevice) BindUniformBlock(program, blockIndex, binding uint32) {
+	r.device.BindUniformBlock(program, blockIndex, binding)
+	r.record(RecordedCommand{
+		Call: "BindUniformBlock",
+		Args: map[string]interface{}{"program": program, "blockIndex": blockIndex, "binding": binding},
+	})
+}
+
+func (r *RecordingDevice) FragDataLocation(program uint32, name string) int32 {
+	return r.device.FragDataLocation(program, name)
+}
+
+func (r *RecordingDevice) UseProgram(program uint32) {
+	r.device.UseProgram(program)
+	r.program = program
+	r.record(RecordedCommand{Call: "UseProgram", Args: map[string]interface{}{"program": program}})
+}
+
+func (r *RecordingDevice) SetUniform(location int32, value interface{}) {
+	r.device.SetUniform(location, value)
+	if location < 0 {
+		return
+	}
+	uniform := RecordedUniform{Type: fmt.Sprintf("%T", value), Value: fmt.Sprint(value)}
+	if r.uniforms[r.program] == nil {
+		r.uniforms[r.program] = make(map[int32]RecordedUniform)
+	}
+	r.uniforms[r.program][location] = uniform
+	r.record(RecordedCommand{
+		Call: "SetUniform",
+		Args: map[string]interface{}{"name": r.uniformName(r.program, location), "location": location, "value": uniform},
+	})
+}
+
+func (r *RecordingDevice) uniformName(program uint32, location int32) string {
+	if name, ok := r.uniformNames[program][location]; ok {
+		return name
+	}
+	return fmt.Sprintf("location %d", location)
+}
+
+func (r *RecordingDevice) CreateTexture(target gfx.TextureTarget, levels int32) uint32 {
+	texture := r.device.CreateTexture(target, levels)
+	r.record(RecordedCommand{Call: "CreateTexture", Args: map[string]interface{}{"target": target, "levels": levels}, Result: texture})
+	return texture
+}
+
+func (r *RecordingDevice) SetSampler(texture uint32, target gfx.TextureTarget, sampler SamplerState) {
+	r.device.SetSampler(texture, target, sampler)
+	r.record(RecordedCommand{
+		Call: "SetSampler",
+		Args: map[string]interface{}{"texture": texture, "target": target, "sampler": sampler},
+	})
+}
+
+func (r *RecordingDevice) TexImage2D(texture uint32, target gfx.TextureTarget, level int32, format TexelFormat, width, height int32, pixels interface{}) {
+	r.device.TexImage2D(texture, target, level, format, width, height, pixels)
+	r.record(RecordedCommand{
+		Call: "TexImage2D",
+		Args: map[string]interface{}{
+			"texture": texture, "target": target, "level": level, "format": format,
+			"width": width, "height": height, "pixels": pixels != nil,
+		},
+	})
+}
+
+func (r *RecordingDevice) TexImage3D(texture uint32, target gfx.TextureTarget, level int32, format TexelFormat, width, height, depth int32, pixels interface{}) {
+	r.device.TexImage3D(texture, target, level, format, width, height, depth, pixels)
+	r.record(RecordedCommand{
+		Call: "TexImage3D",
+		Args: map[string]interface{}{
+			"texture": texture, "target": target, "level": level, "format": format,
+			"width": width, "height": height, "depth": depth, "pixels": pixels != nil,
+		},
+	})
+}
+
+func (r *RecordingDevice) TexLayer(texture uint32, target gfx.TextureTarget, level, layer int32, format TexelFormat, width, height int32, pixels interface{}) {
+	r.device.TexLayer(texture, target, level, layer, format, width, height, pixels)
+	r.record(RecordedCommand{
+		Call: "TexLayer",
+		Args: map[string]interface{}{
+			"texture": texture, "target": target, "level": level, "layer": layer, "format": format,
+			"width": width, "height": height, "pixels": pixels != nil,
+		},
+	})
+}
+
+func (r *RecordingDevice) CompressedTexImage2D(texture uint32, target gfx.TextureTarget, level int32, format TexelFormat, width, height int32, data []byte) {
+	r.device.CompressedTexImage2D(texture, target, level, format, width, height, data)
+	r.record(RecordedCommand{
+		Call: "CompressedTexImage2D",
+		Args: map[string]interface{}{
+			"texture": texture, "target": target, "level": level, "format": format,
+			"width": width, "height": height, "size": len(data),
+		},
+	})
+}
+
+func (r *RecordingDevice) GenerateMipmaps(texture uint32, target gfx.TextureTarget) {
+	r.device.GenerateMipmaps(texture, target)
+	r.record(RecordedCommand{Call: "GenerateMipmaps", Args: map[string]interface{}{"texture": texture, "target": target}})
+}
+
+func (r *RecordingDevice) BindTexture(unit int, target gfx.TextureTarget, texture uint32) {
+	r.device.BindTexture(unit, target, texture)
+	if texture == 0 {
+		delete(r.textures, unit)
+	} else {
+		r.textures[unit] = BoundTexture{Target: target, Texture: texture}
+	}
+	r.record(RecordedCommand{Call: "BindTexture", Args: map[string]interface{}{"unit": unit, "target": target, "texture": texture}})
+}
+
+func (r *RecordingDevice) DeleteTexture(texture uint32) {
+	r.device.DeleteTexture(texture)
+	r.record(RecordedCommand{Call: "DeleteTexture", Args: map[string]interface{}{"texture": texture}})
+}
+
+func (r *RecordingDevice) MaxAnisotropy() float32 {
+	return r.device.MaxAnisotropy()
+}
+
+func (r *RecordingDevice) FormatSupported(format gfx.TextureFormat) bool {
+	return r.device.FormatSupported(format)
+}
+
+func (r *RecordingDevice) CreateRenderbuffer(format TexelFormat, width, height, samples int32) uint32 {
+	renderbuffer := r.device.CreateRenderbuffer(format, width, height, samples)
+	r.record(RecordedCommand{
+		Call:   "CreateRenderbuffer",
+		Args:   map[string]interface{}{"format": format, "width": width, "height": height, "samples": samples},
+		Result: renderbuffer,
+	})
+	return renderbuffer
+}
+
+func (r *RecordingDevice) DeleteRenderbuffer(renderbuffer uint32) {
+	r.device.DeleteRenderbuffer(renderbuffer)
+	r.record(RecordedCommand{Call: "DeleteRenderbuffer", Args: map[string]interface{}{"renderbuffer": renderbuffer}})
+}
+
+func (r *RecordingDevice) MaxSamples() int32 {
+	return r.device.MaxSamples()
+}
+
+func (r *RecordingDevice) CreateFramebuffer(attachments []FramebufferAttachment) (uint32, error) {
+	framebuffer, err := r.device.CreateFramebuffer(attachments)
+	r.record(RecordedCommand{
+		Call:   "CreateFramebuffer",
+		Args:   map[string]interface{}{"attachments": attachments},
+		Result: framebuffer,
+		Error:  errorString(err),
+	})
+	return framebuffer, err
+}
+
+func (r *RecordingDevice) AttachLayer(framebuffer uint32, attachment gfx.Attachment, texture uint32, layer int32) {
+	r.device.AttachLayer(framebuffer, attachment, texture, layer)
+	r.record(RecordedCommand{
+		Call: "AttachLayer",
+		Args: map[string]interface{}{"framebuffer": framebuffer, "attachment": attachment, "texture": texture, "layer": layer},
+	})
+}
+
+func (r *RecordingDevice) BindFramebuffer(framebuffer uint32) {
+	r.device.BindFramebuffer(framebuffer)
+	r.framebuffer = framebuffer
+	r.record(RecordedCommand{Call: "BindFramebuffer", Args: map[string]interface{}{"framebuffer": framebuffer}})
+}
+
+func (r *RecordingDevice) BlitFramebuffer(from, to uint32, width, height int32, mask gfx.BufferMask, attachment int) {
+	r.device.BlitFramebuffer(from, to, width, height, mask, attachment)
+	r.record(RecordedCommand{
+		Call: "BlitFramebuffer",
+		Args: map[string]interface{}{
+			"from": from, "to": to, "width": width, "height": height, "mask": mask, "attachment": attachment,
+		},
+	})
+}
+
+func (r *RecordingDevice) DeleteFramebuffer(framebuffer uint32) {
+	r.device.DeleteFramebuffer(framebuffer)
+	r.record(RecordedCommand{Call: "DeleteFramebuffer", Args: map[string]interface{}{"framebuffer": framebuffer}})
+}
+
+func (r *RecordingDevice) ReadPixels(framebuffer uint32, attachment int, width, height int32, dst []byte) {
+	r.device.ReadPixels(framebuffer, attachment, width, height, dst)
+	r.record(RecordedCommand{
+		Call: "ReadPixels",
+		Args: map[string]interface{}{"framebuffer": framebuffer, "attachment": attachment, "width": width, "height": height},
+	})
+}
+
+func (r *RecordingDevice) SetFramebufferSRGB(enabled bool) {
+	r.device.SetFramebufferSRGB(enabled)
+	r.record(RecordedCommand{Call: "SetFramebufferSRGB", Args: map[string]interface{}{"enabled": enabled}})
+}
+
+func (r *RecordingDevice) Viewport(x, y, width, height int32) {
+	r.device.Viewport(x, y, width, height)
+	r.viewport = [4]int32{x, y, width, height}
+	r.record(RecordedCommand{Call: "Viewport", Args: map[string]interface{}{"x": x, "y": y, "width": width, "height": height}})
+}
+
+func (r *RecordingDevice) Clear(mask gfx.BufferMask) {
+	r.device.Clear(mask)
+	r.record(RecordedCommand{Call: "Clear", Args: map[string]interface{}{"mask": mask}})
+}
+
+func (r *RecordingDevice) SetPipeline(state PipelineState) {
+	r.device.SetPipeline(state)
+	r.record(RecordedCommand{Call: "SetPipeline", Args: map[string]interface{}{"state": state}})
+}
+
+func (r *RecordingDevice) Pipeline() PipelineState {
+	return r.device.Pipeline()
+}
+
+func (r *RecordingDevice) Draw(call DrawCall) {
+	r.device.Draw(call)
+	r.record(RecordedCommand{Call: "Draw", Args: map[string]interface{}{"call": call}, State: r.drawState(call)})
+}
+
+func (r *RecordingDevice) drawState(call DrawCall) *DrawState {
+	state := &DrawState{
+		Program:        r.program,
+		Uniforms:       make(map[string]RecordedUniform, len(r.uniforms[r.program])),
+		Textures:       make(map[int]BoundTexture, len(r.textures)),
+		UniformBuffers: make(map[uint32]uint32, len(r.uniformBuffers)),
+		Framebuffer:    r.framebuffer,
+		Viewport:       r.viewport,
+		Pipeline:       r.device.Pipeline(),
+		VertexLayout:   r.vertexArrays[call.VertexArray],
+	}
+	for location, uniform := range r.uniforms[r.program] {
+		state.Uniforms[r.uniformName(r.program, location)] = uniform
+	}
+	for unit, texture := range r.textures {
+		state.Textures[unit] = texture
+	}
+	for binding, buffer := range r.uniformBuffers {
+		state.UniformBuffers[binding] = buffer
+	}
+	return state
+}
@@ -0,0 +1,46 @@
+package engine
+
+// Renderer draws scenes. A frame is BeginFrame, then for every view the draws
+// of that view are submitted and RenderView issues them, then EndFrame.
+// ForwardRenderer and DeferredRenderer are interchangeable behind it.
+type Renderer interface {
+	// BeginFrame prepares what every view of the frame shares, such as the
+	// shadow maps and lights of scene
+	BeginFrame(scene *Scene)
+	Submit(item DrawItem)
+	// RenderView draws everything submitted since the last view from camera.
+	// Views of a target with no area, such as a minimized window, draw
+	// nothing.
+	RenderView(camera *Camera) error
+	EndFrame()
+	// SetTarget directs the following views to a framebuffer, or back to the
+	// window when target is nil
+	SetTarget(target *Framebuffer)
+}
+
+// Submitter accepts draws, e.g. a RenderQueue or a Renderer
+type Submitter interface {
+	Submit(item DrawItem)
+}
+
+// RenderSceneTo draws scene from camera into target as another view of the
+// current frame, between the renderer's BeginFrame and EndFrame. The renderer
+// draws to the window again afterwards.
+func RenderSceneTo(renderer Renderer, scene *Scene, camera *Camera, target *Framebuffer) error {
+	renderer.SetTarget(target)
+	err := scene.Render(renderer, camera)
+	renderer.SetTarget(nil)
+	if err != nil {
+		return err
+	}
+	target.Resolve()
+	return nil
+}
+
+// RenderScene draws one frame of scene from its primary camera
+func RenderScene(renderer Renderer, scene *Scene) error {
+	renderer.BeginFrame(scene)
+	err := scene.Render(renderer, scene.Camera)
+	renderer.EndFrame()
+	return err
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"sort"
+)
+
+// DrawItem is one mesh drawn with one material and model matrix
+type DrawItem struct {
+	Mesh     *Mesh
+	Material *Material
+	Model    mgl32.Mat4
+	// Instances, when set, draws the mesh once per instance with the instanced
+	// variant of the material's shader, ignoring Model
+	Instances []Instance
+	// Renderer, when set, draws the item instead of the renderer's queue
+	Renderer ObjectRenderer
+
+	// instanced is the InstancedMesh the item draws, nil for instanced items
+	// the queue merged itself
+	instanced *InstancedMesh
+	// objects is how many scene objects the item draws, more than one for
+	// static batches
+	objects  int
+	distance float32
+}
+
+// RenderQueue collects the draws of a view so they can be ordered before any
+// is issued. Opaque items are grouped by shader, render state, texture and mesh
+// to keep state changes down; transparent items are drawn back to front.
+type RenderQueue struct {
+	Opaque      []DrawItem
+	Transparent []DrawItem
+}
+
+// FrameStats counts the work done drawing the last frame, not including the
+// shadow pass
+type FrameStats struct {
+	Objects         int
+	DrawCalls       int
+	Batches         int
+	InstancedDraws  int
+	Triangles       int
+	ShaderChanges   int
+	MaterialChanges int
+	MeshChanges     int
+	// StateChanges counts blend, cull, depth and polygon state calls
+	StateChanges int
+}
+
+func (q *RenderQueue) Reset() {
+	q.Opaque = q.Opaque[:0]
+	q.Transparent = q.Transparent[:0]
+}
+
+func (q *RenderQueue) Submit(item DrawItem) {
+	if item.Mesh == nil || item.Material == nil {
+		return
+	}
+	// Instances that are set but empty draw nothing
+	if item.Instances != nil && len(item.Instances) == 0 {
+		return
+	}
+	if item.objects == 0 {
+		item.objects = 1
+		if item.Instances != nil {
+			item.objects = len(item.Instances)
+		}
+	}
+	if item.Material.BlendMode.IsTransparent() {
+		q.Transparent = append(q.Transparent, item)
+	} else {
+		q.Opaque = append(q.Opaque, item)
+	}
+}
+
+// Sort orders the queue for drawing from eye, first merging repeated opaque
+// draws into instanced ones. Items that compare equal keep the order they
+// were submitted in.
+func (q *RenderQueue) Sort(eye mgl32.Vec3) {
+	q.mergeInstances()
+	sort.SliceStable(q.Opaque, func(i, j int) bool {
+		return q.Opaque[i].less(&q.Opaque[j])
+	})
+
+	for i := range q.Transparent {
+		item := &q.Transparent[i]
+		item.distance = item.Model.Col(3).Vec3().Sub(eye).LenSqr()
+	}
+	sort.SliceStable(q.Transparent, func(i, j int) bool {
+		return q.Transparent[i].distance > q.Transparent[j].distance
+	})
+}
+
+func (a *DrawItem) less(b *DrawItem) bool {
+	if sa, sb := a.Material.GetShader().Handle(), b.Material.GetShader().Handle(); sa != sb {
+		return sa < sb
+	}
+	if ka, kb := sortKey(a.Material.RenderState, a.Material.BlendMode), sortKey(b.Material.RenderState, b.Material.BlendMode); ka != kb {
+		return ka < kb
+	}
+	if ta, tb := a.Material.textureHandle(), b.Material.textureHandle(); ta != tb {
+		return ta < tb
+	}
+	return a.Mesh.Vao < b.Mesh.Vao
+}
+
+// sortKey packs the state into an integer so draws sharing it sort together
+func sortKey(s RenderState, blend BlendMode) uint32 {
+	key := uint32(blend)<<8 | uint32(s.Cull)<<4 | uint32(s.DepthFunc)<<1
+	if s.DepthReadOnly {
+		key |= 1 << 12
+	}
+	if s.Wireframe {
+		key |= 1 << 13
+	}
+	if s.PolygonOffsetFactor != 0 || s.PolygonOffsetUnits != 0 {
+		key |= 1 << 14
+	}
+	return key
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"physics/gfx"
+	"physics/scene"
+)
+
+// RenderState is the fixed function state a material draws with, see
+// scene.RenderState
+type RenderState = scene.RenderState
+
+// renderStateTracker mirrors the pipeline state the renderer sets per draw so
+// the device is only asked for a new one when a draw's differs
+type renderStateTracker struct {
+	valid bool
+
+	blend        BlendMode
+	cull         CullMode
+	depthFunc    gfx.CompareFunc
+	depthWrite   bool
+	offsetFactor float32
+	offsetUnits  float32
+	wireframe    bool
+
+	// changes counts the parts of the state changed since the last resetStats
+	changes int
+}
+
+// invalidate forgets the tracked state, so the next apply sets all of it.
+// Call it after code outside the renderer set the device's pipeline.
+func (t *renderStateTracker) invalidate() {
+	t.valid = false
+}
+
+func (t *renderStateTracker) resetStats() {
+	t.changes = 0
+}
+
+// apply makes state and blend current, counting the parts that differ
+func (t *renderStateTracker) apply(state RenderState, blend BlendMode) {
+	depthFunc := state.DepthFunc
+	depthWrite := !state.DepthReadOnly && !blend.IsTransparent()
+
+	changes := t.changes
+	if !t.valid || blend != t.blend {
+		t.blend = blend
+		t.changes++
+	}
+	if !t.valid || state.Cull != t.cull {
+		t.cull = state.Cull
+		t.changes++
+	}
+	if !t.valid || depthFunc != t.depthFunc {
+		t.depthFunc = depthFunc
+		t.changes++
+	}
+	if !t.valid || depthWrite != t.depthWrite {
+		t.depthWrite = depthWrite
+		t.changes++
+	}
+	if !t.valid || state.PolygonOffsetFactor != t.offsetFactor || state.PolygonOffsetUnits != t.offsetUnits {
+		t.offsetFactor, t.offsetUnits = state.PolygonOffsetFactor, state.PolygonOffsetUnits
+		t.changes++
+	}
+	if !t.valid || state.Wireframe != t.wireframe {
+		t.wireframe = state.Wireframe
+		t.changes++
+	}
+	t.valid = true
+	if t.changes == changes {
+		return
+	}
+
+	device.SetPipeline(PipelineState{
+		// a disabled depth test also stops depth writes, ALWAYS keeps them working
+		DepthTest:           true,
+		DepthFunc:           t.depthFunc,
+		DepthWrite:          t.depthWrite,
+		Cull:                t.cull,
+		Blend:               blendState(t.blend),
+		Wireframe:           t.wireframe,
+		PolygonOffsetFactor: t.offsetFactor,
+		PolygonOffsetUnits:  t.offsetUnits,
+	})
+}
+
+// blendState is the blend equation of a blend mode
+func blendState(blend BlendMode) BlendState {
+	switch blend {
+	case BlendAlpha:
+		return BlendState{Enabled: true, SrcColor: gfx.FactorSrcAlpha, DstColor: gfx.FactorOneMinusSrcAlpha, SrcAlpha: gfx.FactorOne, DstAlpha: gfx.FactorOneMinusSrcAlpha}
+	case BlendAdditive:
+		return BlendState{Enabled: true, SrcColor: gfx.FactorSrcAlpha, DstColor: gfx.FactorOne, SrcAlpha: gfx.FactorSrcAlpha, DstAlpha: gfx.FactorOne}
+	case BlendPremultiplied:
+		return BlendState{Enabled: true, SrcColor: gfx.FactorOne, DstColor: gfx.FactorOneMinusSrcAlpha, SrcAlpha: gfx.FactorOne, DstAlpha: gfx.FactorOneMinusSrcAlpha}
+	}
+	return BlendState{}
+}
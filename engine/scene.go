@@ -1,7 +1,6 @@
 package engine
 
 import (
-	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/go-gl/mathgl/mgl32"
 )
@@ -12,12 +11,18 @@ type Scene struct {
 	Camera               *Camera
 	Objects              []*GameObject
 	Lights               []*Light
+	Instanced            []*InstancedMesh
+	// Sky is drawn behind everything, the clear color shows when it is nil
+	Sky *Skybox
+	Fog Fog
+
+	batches staticBatches
 }
 
 func NewScene(window *glfw.Window) (*Scene, error) {
 
 	width, height := window.GetFramebufferSize()
-	gl.Viewport(0, 0, int32(width), int32(height))
+	device.Viewport(0, 0, int32(width), int32(height))
 
 	shaderProgram, err := NewShaderProgram("shaders/default.vert", "shaders/default.frag")
 	if err != nil {
@@ -33,6 +38,16 @@ func NewScene(window *glfw.Window) (*Scene, error) {
 	return scene, nil
 }
 
+// Delete frees the static batches and the default shader program. Objects,
+// lights and the sky belong to whoever created them and are left alone.
+func (s *Scene) Delete() {
+	s.batches.delete()
+	if s.DefaultShaderProgram != nil {
+		s.DefaultShaderProgram.Release()
+		s.DefaultShaderProgram = nil
+	}
+}
+
 func (s *Scene) AddObject(obj *GameObject) {
 	obj.Scene = s
 	s.Objects = append(s.Objects, obj)
@@ -49,23 +64,60 @@ func (s *Scene) RemoveObject(obj *GameObject) {
 	}
 }
 
+func (s *Scene) AddLight(light *Light) {
+	s.Lights = append(s.Lights, light)
+}
+
+func (s *Scene) RemoveLight(light *Light) {
+	for i, l := range s.Lights {
+		if l == light {
+			s.Lights = append(s.Lights[:i], s.Lights[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *Scene) AddInstancedMesh(mesh *InstancedMesh) {
+	s.Instanced = append(s.Instanced, mesh)
+}
+
+func (s *Scene) RemoveInstancedMesh(mesh *InstancedMesh) {
+	for i, m := range s.Instanced {
+		if m == mesh {
+			s.Instanced = append(s.Instanced[:i], s.Instanced[i+1:]...)
+			break
+		}
+	}
+}
+
 func (s *Scene) Update(dt float32) {
 	for _, object := range s.Objects {
 		object.Update(dt)
 	}
 }
 
-func (s *Scene) Render(renderer *ForwardRenderer, camera *Camera) {
-	// Clear the screen
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-
-	proj := camera.ProjectionMatrix()
-	view := camera.ViewMatrix()
+// Render submits the scene to renderer and draws it from camera. It is one
+// view of a frame, between the renderer's BeginFrame and EndFrame.
+func (s *Scene) Render(renderer Renderer, camera *Camera) error {
+	s.Submit(renderer)
+	return renderer.RenderView(camera)
+}
 
-	// Loop over all objects and render them
-	for _, obj := range s.Objects {
-		// Render the object
-		renderer.RenderObject(obj.Mesh, obj.Material, obj.getModelMatrix(), proj, view)
+// Submit adds a draw for every object and instanced mesh to queue, merging
+// static objects that share a material into batches
+func (s *Scene) Submit(queue Submitter) {
+	unbatched := s.batches.update(s.Objects)
+	for _, batch := range s.batches.batches {
+		queue.Submit(DrawItem{Mesh: batch.mesh, Material: batch.material, Model: mgl32.Ident4(), objects: len(batch.objects)})
+	}
+	for _, obj := range unbatched {
+		queue.Submit(DrawItem{Mesh: obj.Mesh, Material: &obj.Material, Model: obj.getModelMatrix(), Renderer: obj.Renderer})
+	}
+	for _, im := range s.Instanced {
+		if len(im.Instances) == 0 || im.Mesh == nil {
+			continue
+		}
+		queue.Submit(DrawItem{Mesh: im.Mesh, Material: &im.Material, Instances: im.Instances, Renderer: im.Renderer, instanced: im})
 	}
 }
 
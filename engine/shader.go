@@ -1,23 +1,52 @@
 package engine
 
 import (
-	"github.com/go-gl/gl/v4.1-core/gl"
+	"crypto/sha1"
+	"fmt"
 	"github.com/go-gl/mathgl/mgl32"
 	"log"
-	"unsafe"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type ShaderProgram struct {
 	program                uint32
 	shaderReferenceTracker int64
+
+	vertexPath   string
+	fragmentPath string
+	defines      ShaderDefines
+	sources      []string
+	cacheKey     [sha1.Size]byte
+	reflection   *shaderReflection
+}
+
+// shaderCache holds every linked program keyed by its source files and
+// preprocessed sources, so materials asking for the same shader variant share
+// one GL program.
+var shaderCache = make(map[[sha1.Size]byte]*ShaderProgram)
+
+// shaderCacheKey hashes the files the stages were read from along with their
+// sources. Files with the same contents under different paths build separate
+// programs, each reloaded from its own paths.
+func shaderCacheKey(vertexShader, fragmentShader *preprocessedShader) [sha1.Size]byte {
+	return sha1.Sum([]byte(strings.Join(vertexShader.files, "\x00") + "\x01" +
+		strings.Join(fragmentShader.files, "\x00") + "\x01" +
+		vertexShader.source + "\x00" + fragmentShader.source))
 }
 
+// loadedShaders is every live program, including ones whose sources changed
+// after a reload and no longer match their cache key.
+var loadedShaders = make(map[*ShaderProgram]bool)
+
 func (s *ShaderProgram) Use() {
-	gl.UseProgram(s.program)
+	device.UseProgram(s.program)
 }
 
 func (s *ShaderProgram) Unuse() {
-	gl.UseProgram(0)
+	device.UseProgram(0)
 }
 
 func (s *ShaderProgram) Handle() uint32 {
@@ -25,102 +54,463 @@ func (s *ShaderProgram) Handle() uint32 {
 }
 
 func (s *ShaderProgram) SetMat4UniformLocation(key string, value *mgl32.Mat4) {
-	loc := s.GetUniformLocation(key)
-	gl.UniformMatrix4fv(loc, 1, false, &value[0])
+	s.SetMat4(key, *value)
 }
 
+// GetUniformLocation returns the cached location of an active uniform, or -1
+// with a one-time warning when the program has no such uniform.
 func (s *ShaderProgram) GetUniformLocation(key string) int32 {
-	return gl.GetUniformLocation(s.program, gl.Str(key+"\x00"))
+	if loc, ok := s.reflection.locations[key]; ok {
+		return loc
+	}
+	s.warnOnce(key, "unknown uniform %q", key)
+	return -1
 }
 
 func (s *ShaderProgram) GetAttribLocation(key string) uint32 {
-	return uint32(gl.GetAttribLocation(s.program, gl.Str(key+"\x00")))
+	if attribute, ok := s.reflection.attributes[key]; ok {
+		return uint32(attribute.Location)
+	}
+	s.warnOnce(key, "unknown attribute %q", key)
+	return ^uint32(0)
+}
+
+// HasUniform reports whether key is an active uniform, without warning
+func (s *ShaderProgram) HasUniform(key string) bool {
+	_, ok := s.reflection.locations[key]
+	return ok
+}
+
+func (s *ShaderProgram) warnOnce(key string, format string, args ...interface{}) {
+	if s.reflection.warned[key] {
+		return
+	}
+	s.reflection.warned[key] = true
+	log.Printf("shader %s + %s: "+format, append([]interface{}{s.vertexPath, s.fragmentPath}, args...)...)
 }
 
 func (s *ShaderProgram) SetUniform3f(uniform int32, x float32, y float32, z float32) {
-	gl.Uniform3f(uniform, x, y, z)
+	device.SetUniform(uniform, mgl32.Vec3{x, y, z})
 }
 
 func (s *ShaderProgram) SetUniform1f(uniform int32, value float32) {
-	gl.Uniform1f(uniform, value)
+	device.SetUniform(uniform, value)
 }
 
 func (s *ShaderProgram) SetUniform1i(uniform int32, value int) {
-	gl.Uniform1i(uniform, int32(value))
+	device.SetUniform(uniform, int32(value))
 }
 
-func LoadShader(vertShader string, fragShader string) *ShaderProgram {
+// LoadShader returns the program built from two stages. When they fail to
+// build it returns the error along with a program drawing everything magenta,
+// which a ShaderWatcher replaces in place once the files are fixed. The
+// program is only nil when even that can't be built.
+func LoadShader(vertShader string, fragShader string) (*ShaderProgram, error) {
 	shaderProgram, err := NewShaderProgram(vertShader, fragShader)
+	if err == nil {
+		return shaderProgram, nil
+	}
+	placeholder, placeholderErr := newErrorShader(vertShader, fragShader, nil)
+	if placeholderErr != nil {
+		return nil, fmt.Errorf("%w (error shader: %v)", err, placeholderErr)
+	}
+	return placeholder, err
+}
+
+// errorVertexSource and errorFragmentSource are the error shader. They read the
+// leading members of the shared blocks only and include nothing, so they build
+// however broken the files in ShaderDir are.
+const errorVertexSource = `#version 410 core
+layout (std140) uniform FrameData {
+    mat4 view;
+    mat4 projection;
+};
+layout (std140) uniform ObjectData {
+    mat4 model;
+};
+layout (location = 0) in vec3 aPos;
+void main()
+{
+    gl_Position = projection * view * model * vec4(aPos, 1.0);
+}
+`
+
+const errorFragmentSource = `#version 410 core
+out vec4 FragColor;
+void main()
+{
+    FragColor = vec4(1.0, 0.0, 1.0, 1.0);
+}
+`
+
+// newErrorShader builds the error shader standing in for the program of two
+// stages that don't build. It watches their files, and Reload builds them.
+func newErrorShader(vertexShaderPath, fragmentShaderPath string, defines ShaderDefines) (*ShaderProgram, error) {
+	program, err := device.CreateProgram(errorVertexSource, errorFragmentSource)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	defines = variantDefines(defines)
+	sources := []string{vertexShaderPath, fragmentShaderPath}
+	for _, path := range []string{vertexShaderPath, fragmentShaderPath} {
+		// Includes can only be watched if the stage still preprocesses
+		if stage, err := preprocessShader(path, defines); err == nil {
+			sources = append(sources, stage.files...)
+		}
+	}
+
+	shaderProgram := &ShaderProgram{
+		program:                program,
+		shaderReferenceTracker: 1,
+		vertexPath:             vertexShaderPath,
+		fragmentPath:           fragmentShaderPath,
+		defines:                defines,
+		sources:                sources,
+		reflection:             reflectShaderProgram(program),
 	}
-	return shaderProgram
+	shaderProgram.bindUniformBlocks()
+	loadedShaders[shaderProgram] = true
+	return shaderProgram, nil
 }
 
-type getGlParam func(uint32, uint32, *int32)
-type getInfoLog func(uint32, int32, *int32, *uint8)
+func NewShaderProgram(vertexShaderPath, fragmentShaderPath string) (*ShaderProgram, error) {
+	return NewShaderVariant(vertexShaderPath, fragmentShaderPath, nil)
+}
 
-func checkGlError(glObject uint32, errorParam uint32, getParamFn getGlParam,
-	getInfoLogFn getInfoLog, failMsg string) {
+// NewShaderVariant preprocesses both stages with defines and returns the cached
+// program for the result, compiling and linking it on first use.
+func NewShaderVariant(vertexShaderPath, fragmentShaderPath string, defines ShaderDefines) (*ShaderProgram, error) {
+	defines = variantDefines(defines)
 
-	var success int32
-	getParamFn(glObject, errorParam, &success)
-	if success != 1 {
-		var infoLog [512]byte
-		getInfoLogFn(glObject, 512, nil, (*uint8)(unsafe.Pointer(&infoLog)))
-		log.Fatalln(failMsg, "\n", string(infoLog[:512]))
+	vertexShader, err := preprocessShader(vertexShaderPath, defines)
+	if err != nil {
+		return nil, err
 	}
+
+	fragmentShader, err := preprocessShader(fragmentShaderPath, defines)
+	if err != nil {
+		return nil, err
+	}
+
+	key := shaderCacheKey(vertexShader, fragmentShader)
+	if cached, ok := shaderCache[key]; ok {
+		cached.shaderReferenceTracker++
+		return cached, nil
+	}
+
+	program, err := compileShaderProgram(vertexShader, fragmentShader)
+	if err != nil {
+		return nil, err
+	}
+	shaderProgram := &ShaderProgram{
+		program:                program,
+		shaderReferenceTracker: 1,
+		vertexPath:             vertexShaderPath,
+		fragmentPath:           fragmentShaderPath,
+		defines:                defines,
+		sources:                append(vertexShader.files, fragmentShader.files...),
+		cacheKey:               key,
+		reflection:             reflectShaderProgram(program),
+	}
+	shaderProgram.bindUniformBlocks()
+	shaderCache[key] = shaderProgram
+	loadedShaders[shaderProgram] = true
+	return shaderProgram, nil
 }
 
-func checkShaderCompileErrors(shader uint32) {
-	checkGlError(shader, gl.COMPILE_STATUS, gl.GetShaderiv, gl.GetShaderInfoLog,
-		"ERROR::SHADER::COMPILE_FAILURE")
+// variantDefines copies defines, adding the ones every program is built with
+func variantDefines(defines ShaderDefines) ShaderDefines {
+	defines = copyDefines(defines)
+	if _, ok := defines["MAX_LIGHTS"]; !ok {
+		defines["MAX_LIGHTS"] = strconv.Itoa(MaxLights)
+	}
+	return defines
 }
 
-func checkProgramLinkErrors(program uint32) {
-	checkGlError(program, gl.LINK_STATUS, gl.GetProgramiv, gl.GetProgramInfoLog,
-		"ERROR::PROGRAM::LINKING_FAILURE")
+// Variant returns the program built from the same source files with defines
+// added to this program's own
+func (s *ShaderProgram) Variant(defines ShaderDefines) (*ShaderProgram, error) {
+	merged := copyDefines(s.defines)
+	for name, value := range defines {
+		merged[name] = value
+	}
+	return NewShaderVariant(s.vertexPath, s.fragmentPath, merged)
 }
 
-func NewShaderProgram(vertexShaderPath, fragmentShaderPath string) (*ShaderProgram, error) {
-	GLContext().check()
+// shaderVariants builds one variant of each material shader the first time it
+// is drawn with. Shaders whose sources don't implement the variant, as told by
+// supported, or whose variant fails to build map to nil.
+type shaderVariants struct {
+	defines   ShaderDefines
+	supported func(variant *ShaderProgram) bool
+	programs  map[*ShaderProgram]*ShaderProgram
+}
+
+func newShaderVariants(defines ShaderDefines, supported func(*ShaderProgram) bool) shaderVariants {
+	return shaderVariants{
+		defines:   defines,
+		supported: supported,
+		programs:  make(map[*ShaderProgram]*ShaderProgram),
+	}
+}
 
-	vertexShaderSource, err := LoadFile(vertexShaderPath)
+func (v *shaderVariants) get(shader *ShaderProgram) *ShaderProgram {
+	if variant, ok := v.programs[shader]; ok {
+		return variant
+	}
+	variant, err := shader.Variant(v.defines)
 	if err != nil {
-		return nil, err
+		log.Printf("shader %s + %s variant %v: %v", shader.vertexPath, shader.fragmentPath, v.defines, err)
+		v.programs[shader] = nil
+		return nil
+	}
+	if !v.supported(variant) {
+		variant.Release()
+		variant = nil
+	}
+	v.programs[shader] = variant
+	return variant
+}
+
+// delete releases the variants built so far
+func (v *shaderVariants) delete() {
+	for shader, variant := range v.programs {
+		if variant != nil {
+			variant.Release()
+		}
+		delete(v.programs, shader)
+	}
+}
+
+// Reload preprocesses and recompiles the program from its source files. On
+// success the GL program is swapped in place, so every material holding this
+// ShaderProgram picks up the change. On failure the previous program is kept.
+func (s *ShaderProgram) Reload() error {
+	vertexShader, err := preprocessShader(s.vertexPath, s.defines)
+	if err != nil {
+		return err
 	}
 
-	fragmentShaderSource, err := LoadFile(fragmentShaderPath)
+	fragmentShader, err := preprocessShader(s.fragmentPath, s.defines)
 	if err != nil {
+		return err
+	}
+
+	program, err := compileShaderProgram(vertexShader, fragmentShader)
+	if err != nil {
+		return err
+	}
+
+	device.DeleteProgram(s.program)
+	s.program = program
+	s.reflection = reflectShaderProgram(program)
+	s.bindUniformBlocks()
+	s.sources = append(vertexShader.files, fragmentShader.files...)
+
+	if shaderCache[s.cacheKey] == s {
+		delete(shaderCache, s.cacheKey)
+	}
+	s.cacheKey = shaderCacheKey(vertexShader, fragmentShader)
+	if _, ok := shaderCache[s.cacheKey]; !ok {
+		shaderCache[s.cacheKey] = s
+	}
+	return nil
+}
+
+// Retain adds a reference to the program, to be matched by a call to Release
+func (s *ShaderProgram) Retain() {
+	s.shaderReferenceTracker++
+}
+
+// Release drops a reference taken by NewShaderVariant or Retain. The GL
+// program is deleted and evicted from the cache when the last one goes.
+func (s *ShaderProgram) Release() {
+	s.shaderReferenceTracker--
+	if s.shaderReferenceTracker > 0 {
+		return
+	}
+
+	if shaderCache[s.cacheKey] == s {
+		delete(shaderCache, s.cacheKey)
+	}
+	delete(loadedShaders, s)
+	device.DeleteProgram(s.program)
+	s.program = 0
+}
+
+// References returns the number of live references to the program
+func (s *ShaderProgram) References() int64 {
+	return s.shaderReferenceTracker
+}
+
+// Sources returns every file the program was built from, including includes
+func (s *ShaderProgram) Sources() []string {
+	return s.sources
+}
+
+func compileShaderProgram(vertexShader, fragmentShader *preprocessedShader) (uint32, error) {
+	program, err := device.CreateProgram(vertexShader.source, fragmentShader.source)
+	if shaderErr, ok := err.(*ShaderError); ok {
+		// Map the driver's line numbers back through includes and defines
+		switch shaderErr.Stage {
+		case "vertex":
+			return 0, newShaderError(shaderErr.Stage, shaderErr.Log, vertexShader)
+		case "fragment":
+			return 0, newShaderError(shaderErr.Stage, shaderErr.Log, fragmentShader)
+		}
+		return 0, newShaderError(shaderErr.Stage, shaderErr.Log, nil)
+	}
+	return program, err
+}
+
+// ShaderDir is the root directory #include paths are resolved against
+var ShaderDir = "shaders"
+
+// ShaderDefines are injected as #define lines after the #version directive of
+// every stage, selecting a compile-time variant of a shader.
+type ShaderDefines map[string]string
+
+func copyDefines(defines ShaderDefines) ShaderDefines {
+	c := make(ShaderDefines, len(defines))
+	for name, value := range defines {
+		c[name] = value
+	}
+	return c
+}
+
+type shaderPreprocessor struct {
+	defines  ShaderDefines
+	included map[string]bool
+	stack    []string
+	out      strings.Builder
+	lines    []ShaderSourceLocation
+	files    []string
+}
+
+// preprocessedShader is a shader stage ready for compilation, along with the
+// original file and line of every line in source.
+type preprocessedShader struct {
+	source string
+	lines  []ShaderSourceLocation
+	files  []string
+}
+
+// location maps a 1-based line of the preprocessed source back to its origin
+func (s *preprocessedShader) location(line int) ShaderSourceLocation {
+	if line < 1 || line > len(s.lines) {
+		return ShaderSourceLocation{Line: line}
+	}
+	return s.lines[line-1]
+}
+
+// PreprocessShader loads the shader at path, expands its #include directives and
+// injects defines. Each file is included at most once per stage.
+func PreprocessShader(path string, defines ShaderDefines) (string, error) {
+	shader, err := preprocessShader(path, defines)
+	if err != nil {
+		return "", err
+	}
+	return shader.source, nil
+}
+
+func preprocessShader(path string, defines ShaderDefines) (*preprocessedShader, error) {
+	p := &shaderPreprocessor{
+		defines:  defines,
+		included: make(map[string]bool),
+	}
+	if err := p.process(path, true); err != nil {
 		return nil, err
 	}
+	return &preprocessedShader{source: p.out.String(), lines: p.lines, files: p.files}, nil
+}
+
+func (p *shaderPreprocessor) process(path string, root bool) error {
+	path = filepath.Clean(path)
+	for _, parent := range p.stack {
+		if parent == path {
+			return fmt.Errorf("shader include cycle: %s -> %s", strings.Join(p.stack, " -> "), path)
+		}
+	}
+	if p.included[path] {
+		return nil
+	}
+	p.included[path] = true
+	p.files = append(p.files, path)
+
+	source, err := LoadFile(path)
+	if err != nil {
+		return err
+	}
+
+	p.stack = append(p.stack, path)
+	defer func() { p.stack = p.stack[:len(p.stack)-1] }()
 
-	// Compile the Vertex shader
-	vertexShader := gl.CreateShader(gl.VERTEX_SHADER)
-	cvertexShaderSource, freeVertexShader := gl.Strs(vertexShaderSource + "\x00")
-	defer freeVertexShader()
-	gl.ShaderSource(vertexShader, 1, cvertexShaderSource, nil)
-	gl.CompileShader(vertexShader)
-	checkShaderCompileErrors(vertexShader)
+	definesWritten := !root
+	for lineNumber, line := range strings.Split(source, "\n") {
+		line = strings.TrimRight(line, "\r")
+		directive := strings.TrimSpace(line)
+		location := ShaderSourceLocation{File: path, Line: lineNumber + 1}
 
-	// Compile the fragment shader
-	fragmentShader := gl.CreateShader(gl.FRAGMENT_SHADER)
-	cfragmentShaderSource, freeFragmentShader := gl.Strs(fragmentShaderSource + "\x00")
-	gl.ShaderSource(fragmentShader, 1, cfragmentShaderSource, nil)
-	defer freeFragmentShader()
-	gl.CompileShader(fragmentShader)
-	checkShaderCompileErrors(fragmentShader)
+		switch {
+		case strings.HasPrefix(directive, "#version"):
+			if !root {
+				return &ShaderError{Stage: "preprocess", Log: "#version is only allowed in the top level shader",
+					Lines: []ShaderErrorLine{{ShaderSourceLocation: location, Message: directive}}}
+			}
+			p.writeLine(line, location)
+			p.writeDefines()
+			definesWritten = true
+		case strings.HasPrefix(directive, "#include"):
+			includePath, err := parseIncludePath(directive)
+			if err != nil {
+				return &ShaderError{Stage: "preprocess", Log: err.Error(),
+					Lines: []ShaderErrorLine{{ShaderSourceLocation: location, Message: err.Error()}}}
+			}
+			if err := p.process(filepath.Join(ShaderDir, includePath), false); err != nil {
+				if _, ok := err.(*ShaderError); !ok {
+					err = &ShaderError{Stage: "preprocess", Log: err.Error(),
+						Lines: []ShaderErrorLine{{ShaderSourceLocation: location, Message: err.Error()}}}
+				}
+				return err
+			}
+		default:
+			if !definesWritten && directive != "" && !strings.HasPrefix(directive, "//") {
+				p.writeDefines()
+				definesWritten = true
+			}
+			p.writeLine(line, location)
+		}
+	}
+	return nil
+}
 
-	// Link the shader program
-	program := gl.CreateProgram()
-	gl.AttachShader(program, vertexShader)
-	gl.AttachShader(program, fragmentShader)
-	gl.LinkProgram(program)
-	checkProgramLinkErrors(program)
+func (p *shaderPreprocessor) writeLine(line string, location ShaderSourceLocation) {
+	p.out.WriteString(line)
+	p.out.WriteByte('\n')
+	p.lines = append(p.lines, location)
+}
 
-	gl.DeleteShader(vertexShader)
-	gl.DeleteShader(fragmentShader)
+func (p *shaderPreprocessor) writeDefines() {
+	names := make([]string, 0, len(p.defines))
+	for name := range p.defines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	return &ShaderProgram{program: program}, nil
+	for _, name := range names {
+		line := "#define " + name
+		if value := p.defines[name]; value != "" {
+			line += " " + value
+		}
+		p.writeLine(line, ShaderSourceLocation{File: "<defines>"})
+	}
+}
 
+func parseIncludePath(directive string) (string, error) {
+	arg := strings.TrimSpace(strings.TrimPrefix(directive, "#include"))
+	if len(arg) >= 2 && ((arg[0] == '"' && arg[len(arg)-1] == '"') || (arg[0] == '<' && arg[len(arg)-1] == '>')) {
+		return arg[1 : len(arg)-1], nil
+	}
+	return "", fmt.Errorf("malformed #include, expected a quoted path: %s", directive)
 }
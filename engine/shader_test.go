@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"physics/soft/raster"
+	"testing"
+)
+
+// TestShaderCacheKeepsPaths loads two copies of the same shader. They have to
+// build separate programs, or a reload through one path would change the other.
+func TestShaderCacheKeepsPaths(t *testing.T) {
+	useSoftDevice(t).RegisterProgram("test", &raster.Program{})
+	dir := t.TempDir()
+	source := []byte("#version 330 core\n// soft: test\nvoid main() {}\n")
+	var paths []string
+	for _, name := range []string{"a.vert", "a.frag", "b.vert", "b.frag"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, source, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	a, err := NewShaderProgram(paths[0], paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Release()
+	b, err := NewShaderProgram(paths[2], paths[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Release()
+	if a == b {
+		t.Fatal("copies of a shader at different paths share a program")
+	}
+	if b.vertexPath != paths[2] || b.fragmentPath != paths[3] {
+		t.Errorf("second copy loaded from %s and %s, want %s and %s", b.vertexPath, b.fragmentPath, paths[2], paths[3])
+	}
+
+	again, err := NewShaderProgram(paths[0], paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Release()
+	if again != a {
+		t.Error("loading the same paths twice built a second program")
+	}
+	if err := b.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cached := shaderCache[a.cacheKey]; cached != a {
+		t.Error("reloading the second copy evicted the first from the cache")
+	}
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Info log formats differ per vendor:
+//
+//	Mesa:        0:12(5): error: ...
+//	NVIDIA:      0(12) : error C0000: ...
+//	AMD / Apple: ERROR: 0:12: ...
+var (
+	mesaLogLine   = regexp.MustCompile(`^\d+:(\d+)\(\d+\):\s*(\w+)[^:]*:\s*(.*)$`)
+	nvidiaLogLine = regexp.MustCompile(`^\d+\((\d+)\)\s*:\s*(\w+)[^:]*:\s*(.*)$`)
+	appleLogLine  = regexp.MustCompile(`^(\w+):\s*\d+:(\d+):\s*(.*)$`)
+)
+
+func newShaderError(stage string, infoLog string, shader *preprocessedShader) *ShaderError {
+	err := &ShaderError{Stage: stage, Log: infoLog}
+
+	for _, raw := range strings.Split(infoLog, "\n") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		line := ShaderErrorLine{Message: raw}
+		lineNumber := 0
+		if m := mesaLogLine.FindStringSubmatch(raw); m != nil {
+			lineNumber, _ = strconv.Atoi(m[1])
+			line.Severity, line.Message = strings.ToLower(m[2]), m[3]
+		} else if m := nvidiaLogLine.FindStringSubmatch(raw); m != nil {
+			lineNumber, _ = strconv.Atoi(m[1])
+			line.Severity, line.Message = strings.ToLower(m[2]), m[3]
+		} else if m := appleLogLine.FindStringSubmatch(raw); m != nil {
+			lineNumber, _ = strconv.Atoi(m[2])
+			line.Severity, line.Message = strings.ToLower(m[1]), m[3]
+		}
+
+		if shader != nil && lineNumber > 0 {
+			line.ShaderSourceLocation = shader.location(lineNumber)
+		}
+		err.Lines = append(err.Lines, line)
+	}
+	return err
+}
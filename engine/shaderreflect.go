@@ -0,0 +1,103 @@
+package engine
+
+import "sort"
+
+// shaderReflection holds everything queried from a program at link time, so
+// that draws never have to go back to the device with a string to find a location.
+type shaderReflection struct {
+	uniforms      map[string]*ShaderUniform
+	locations     map[string]int32
+	uniformBlocks map[string]*ShaderUniformBlock
+	attributes    map[string]*ShaderAttribute
+	warned        map[string]bool
+}
+
+func reflectShaderProgram(program uint32) *shaderReflection {
+	r := &shaderReflection{
+		uniforms:      make(map[string]*ShaderUniform),
+		locations:     make(map[string]int32),
+		uniformBlocks: make(map[string]*ShaderUniformBlock),
+		attributes:    make(map[string]*ShaderAttribute),
+		warned:        make(map[string]bool),
+	}
+
+	reflection := device.ReflectProgram(program)
+	for i := range reflection.Uniforms {
+		r.uniforms[reflection.Uniforms[i].Name] = &reflection.Uniforms[i]
+	}
+	for name, location := range reflection.Locations {
+		r.locations[name] = location
+	}
+	for i := range reflection.UniformBlocks {
+		r.uniformBlocks[reflection.UniformBlocks[i].Name] = &reflection.UniformBlocks[i]
+	}
+	for i := range reflection.Attributes {
+		r.attributes[reflection.Attributes[i].Name] = &reflection.Attributes[i]
+	}
+	return r
+}
+
+// Uniforms returns the active uniforms of the program sorted by name
+func (s *ShaderProgram) Uniforms() []ShaderUniform {
+	uniforms := make([]ShaderUniform, 0, len(s.reflection.uniforms))
+	for _, uniform := range s.reflection.uniforms {
+		uniforms = append(uniforms, *uniform)
+	}
+	sort.Slice(uniforms, func(i, j int) bool { return uniforms[i].Name < uniforms[j].Name })
+	return uniforms
+}
+
+// UniformBlocks returns the active uniform blocks of the program sorted by index
+func (s *ShaderProgram) UniformBlocks() []ShaderUniformBlock {
+	blocks := make([]ShaderUniformBlock, 0, len(s.reflection.uniformBlocks))
+	for _, block := range s.reflection.uniformBlocks {
+		blocks = append(blocks, *block)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Index < blocks[j].Index })
+	return blocks
+}
+
+// Attributes returns the active vertex attributes of the program sorted by location
+func (s *ShaderProgram) Attributes() []ShaderAttribute {
+	attributes := make([]ShaderAttribute, 0, len(s.reflection.attributes))
+	for _, attribute := range s.reflection.attributes {
+		attributes = append(attributes, *attribute)
+	}
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Location < attributes[j].Location })
+	return attributes
+}
+
+func (s *ShaderProgram) Uniform(name string) (ShaderUniform, bool) {
+	uniform, ok := s.reflection.uniforms[name]
+	if !ok {
+		return ShaderUniform{}, false
+	}
+	return *uniform, true
+}
+
+func (s *ShaderProgram) UniformBlock(name string) (ShaderUniformBlock, bool) {
+	block, ok := s.reflection.uniformBlocks[name]
+	if !ok {
+		return ShaderUniformBlock{}, false
+	}
+	return *block, true
+}
+
+func (s *ShaderProgram) Attribute(name string) (ShaderAttribute, bool) {
+	attribute, ok := s.reflection.attributes[name]
+	if !ok {
+		return ShaderAttribute{}, false
+	}
+	return *attribute, true
+}
+
+// checkAttributes warns once about attributes the shader reads from a location
+// other than the one mesh vertex arrays feed them at
+func (s *ShaderProgram) checkAttributes(attributes map[uint32]string) {
+	for location, name := range attributes {
+		attribute, ok := s.reflection.attributes[name]
+		if ok && attribute.Location != int32(location) {
+			s.warnOnce("attribute "+name, "attribute %s is at location %d, meshes provide it at %d", name, attribute.Location, location)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// ShaderWatcher polls the source files of every loaded shader program and
+// recompiles programs whose files changed. It never touches GL outside of
+// Update, so it is safe to call once per frame from the render thread.
+type ShaderWatcher struct {
+	Interval time.Duration
+
+	// OnReload is called after every reload attempt. When nil, failures are logged.
+	OnReload func(program *ShaderProgram, err error)
+
+	modTimes map[string]time.Time
+	lastPoll time.Time
+}
+
+func NewShaderWatcher(interval time.Duration) *ShaderWatcher {
+	return &ShaderWatcher{
+		Interval: interval,
+		modTimes: make(map[string]time.Time),
+	}
+}
+
+// Update checks for modified shader files, at most once per Interval, and
+// reloads the affected programs in place.
+func (w *ShaderWatcher) Update() {
+	now := time.Now()
+	if now.Sub(w.lastPoll) < w.Interval {
+		return
+	}
+	w.lastPoll = now
+
+	changed := make(map[string]bool)
+	for program := range loadedShaders {
+		for _, path := range program.sources {
+			if _, seen := changed[path]; seen {
+				continue
+			}
+			changed[path] = w.poll(path)
+		}
+	}
+
+	for program := range loadedShaders {
+		for _, path := range program.sources {
+			if !changed[path] {
+				continue
+			}
+			err := program.Reload()
+			if w.OnReload != nil {
+				w.OnReload(program, err)
+			} else if err != nil {
+				log.Printf("shader reload failed, keeping previous program: %v", err)
+			} else {
+				log.Printf("reloaded shader %s + %s", program.vertexPath, program.fragmentPath)
+			}
+			break
+		}
+	}
+}
+
+// poll records the modification time of path and reports whether it moved
+// since the last poll. Files seen for the first time are not reported.
+func (w *ShaderWatcher) poll(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		// Keep the last known time, editors often replace files via rename
+		return false
+	}
+
+	modTime := info.ModTime()
+	last, known := w.modTimes[path]
+	w.modTimes[path] = modTime
+	return known && !modTime.Equal(last)
+}
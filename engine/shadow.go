@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"physics/gfx"
+	"sort"
+)
+
+// pointShadowTextureUnit is the texture unit the shadow cube map array is bound to
+// while lit geometry is drawn. Material textures use the low units.
+const pointShadowTextureUnit = 8
+
+const pointShadowNearPlane = 0.1
+
+type PointShadowSettings struct {
+	// MaxLights caps how many point lights are shadowed at once. All of them
+	// share one cube map array, so they are sampled without rebinding.
+	MaxLights int
+	// Resolution is the width and height of every cube face in texels.
+	Resolution int32
+	// Bias is subtracted from the stored distance to avoid shadow acne.
+	Bias float32
+	// FilterRadius scales the PCF sample disk; zero gives hard shadows.
+	FilterRadius float32
+	// FilterSamples is the number of PCF taps, between 1 and 20.
+	FilterSamples int
+}
+
+func DefaultPointShadowSettings() PointShadowSettings {
+	return PointShadowSettings{
+		MaxLights:     4,
+		Resolution:    1024,
+		Bias:          0.05,
+		FilterRadius:  0.02,
+		FilterSamples: 20,
+	}
+}
+
+// cubeFaceDirections holds the look and up vectors for each cube map face, in
+// GL_TEXTURE_CUBE_MAP_POSITIVE_X order.
+var cubeFaceDirections = [6][2]mgl32.Vec3{
+	{{1, 0, 0}, {0, -1, 0}},
+	{{-1, 0, 0}, {0, -1, 0}},
+	{{0, 1, 0}, {0, 0, 1}},
+	{{0, -1, 0}, {0, 0, -1}},
+	{{0, 0, 1}, {0, -1, 0}},
+	{{0, 0, -1}, {0, -1, 0}},
+}
+
+// PointShadowMaps renders linear-distance depth cube maps for the shadowed point
+// lights of a scene into a single GL_TEXTURE_CUBE_MAP_ARRAY.
+type PointShadowMaps struct {
+	Settings PointShadowSettings
+
+	framebuffer uint32
+	texture     uint32
+	layers      int
+	resolution  int32
+	shader      *ShaderProgram
+	// instancedShader draws the instances of InstancedMeshes
+	instancedShader *ShaderProgram
+	active          []*Light
+}
+
+func NewPointShadowMaps(settings PointShadowSettings) (*PointShadowMaps, error) {
+	shader, err := NewShaderProgram("shaders/shadow_point.vert", "shaders/shadow_point.frag")
+	if err != nil {
+		return nil, err
+	}
+
+	instancedShader, err := shader.Variant(instancedDefines)
+	if err != nil {
+		shader.Release()
+		return nil, err
+	}
+
+	s := &PointShadowMaps{
+		Settings:        settings,
+		shader:          shader,
+		instancedShader: instancedShader,
+	}
+	framebuffer, err := device.CreateFramebuffer(nil)
+	if err != nil {
+		instancedShader.Release()
+		shader.Release()
+		return nil, err
+	}
+	s.framebuffer = framebuffer
+	s.allocate()
+	return s, nil
+}
+
+// allocate (re)creates the cube map array whenever the settings change size
+func (s *PointShadowMaps) allocate() {
+	if s.Settings.MaxLights < 1 {
+		s.Settings.MaxLights = 1
+	}
+	if s.texture != 0 && s.layers == s.Settings.MaxLights && s.resolution == s.Settings.Resolution {
+		return
+	}
+	device.DeleteTexture(s.texture)
+
+	s.layers = s.Settings.MaxLights
+	s.resolution = s.Settings.Resolution
+
+	s.texture = device.CreateTexture(gfx.TextureCubeMapArray, 1)
+	device.TexImage3D(s.texture, gfx.TextureCubeMapArray, 0,
+		TexelFormat{Format: TextureFormatDepth24},
+		s.resolution, s.resolution, int32(s.layers*6), nil)
+	device.SetSampler(s.texture, gfx.TextureCubeMapArray, SamplerState{
+		WrapS:     gfx.WrapClampToEdge,
+		WrapT:     gfx.WrapClampToEdge,
+		WrapR:     gfx.WrapClampToEdge,
+		MinFilter: gfx.FilterLinear,
+		MagFilter: gfx.FilterLinear,
+	})
+}
+
+// selectLights picks the shadowed lights closest to the eye, up to MaxLights,
+// and assigns each its layer in the cube map array.
+func (s *PointShadowMaps) selectLights(lights []*Light, eye mgl32.Vec3) {
+	s.active = s.active[:0]
+	for _, light := range lights {
+		light.shadowLayer = -1
+		if light.CastShadows {
+			s.active = append(s.active, light)
+		}
+	}
+
+	sort.SliceStable(s.active, func(i, j int) bool {
+		return s.active[i].Position.Sub(eye).LenSqr() < s.active[j].Position.Sub(eye).LenSqr()
+	})
+	if len(s.active) > s.layers {
+		s.active = s.active[:s.layers]
+	}
+	for i, light := range s.active {
+		light.shadowLayer = i
+	}
+}
+
+// Render draws the depth of every object in the scene into the cube faces of
+// each selected light. It sets the pipeline state it needs and restores the
+// previous one; the caller is responsible for restoring the viewport.
+func (s *PointShadowMaps) Render(scene *Scene, eye mgl32.Vec3) {
+	s.allocate()
+	s.selectLights(scene.Lights, eye)
+	if len(s.active) == 0 {
+		return
+	}
+
+	// Whatever the last pass left, e.g. culling or no depth test before the
+	// first view, must not reach the shadow maps
+	saved := device.Pipeline()
+	device.SetPipeline(DefaultPipelineState())
+	device.BindFramebuffer(s.framebuffer)
+	device.Viewport(0, 0, s.resolution, s.resolution)
+
+	// instance data is uploaded once and drawn into every face
+	var instanced []*InstancedMesh
+	for _, im := range scene.Instanced {
+		if im.Mesh == nil || len(im.Mesh.Indices) == 0 || len(im.Instances) == 0 {
+			continue
+		}
+		im.instanceArray().upload(im.Instances)
+		instanced = append(instanced, im)
+	}
+
+	for _, light := range s.active {
+		farPlane := light.EffectiveRange()
+		proj := mgl32.Perspective(mgl32.DegToRad(90), 1.0, pointShadowNearPlane, farPlane)
+		s.shader.Use()
+		s.shader.SetVec3("lightPos", light.Position)
+		s.shader.SetFloat("farPlane", farPlane)
+		if len(instanced) > 0 {
+			s.instancedShader.Use()
+			s.instancedShader.SetVec3("lightPos", light.Position)
+			s.instancedShader.SetFloat("farPlane", farPlane)
+		}
+
+		for face, dir := range cubeFaceDirections {
+			layer := int32(light.shadowLayer*6 + face)
+			device.AttachLayer(s.framebuffer, gfx.AttachmentDepth, s.texture, layer)
+			device.Clear(gfx.DepthBuffer)
+
+			view := mgl32.LookAtV(light.Position, light.Position.Add(dir[0]), dir[1])
+			viewProj := proj.Mul4(view)
+			s.shader.Use()
+			s.shader.SetMat4("lightViewProjection", viewProj)
+
+			for _, obj := range scene.Objects {
+				if obj.Mesh == nil || len(obj.Mesh.Indices) == 0 {
+					continue
+				}
+				s.shader.SetMat4("model", obj.getModelMatrix())
+				device.Draw(DrawCall{VertexArray: obj.Mesh.Vao, Count: int32(len(obj.Mesh.Indices)), Indexed: true})
+			}
+
+			if len(instanced) > 0 {
+				s.instancedShader.Use()
+				s.instancedShader.SetMat4("lightViewProjection", viewProj)
+				for _, im := range instanced {
+					device.Draw(DrawCall{VertexArray: im.array.vao, Count: int32(len(im.Mesh.Indices)), Indexed: true, Instances: int32(len(im.Instances))})
+				}
+			}
+		}
+	}
+
+	s.shader.Unuse()
+	device.BindFramebuffer(0)
+	device.SetPipeline(saved)
+}
+
+// Bind makes the shadow cube map array available to shader and uploads the
+// filtering parameters. Every shader that declares pointShadowMaps must have it
+// pointed at its own unit, even when no light is shadowed, or the sampler
+// aliases unit 0 with a different sampler type. Shaders without it, such as
+// deferred geometry variants, are left alone.
+func (s *PointShadowMaps) Bind(shader *ShaderProgram) {
+	if !shader.HasUniform("pointShadowMaps") {
+		return
+	}
+	device.BindTexture(pointShadowTextureUnit, gfx.TextureCubeMapArray, s.texture)
+
+	shader.SetSampler("pointShadowMaps", pointShadowTextureUnit)
+	shader.SetFloat("shadowBias", s.Settings.Bias)
+	shader.SetFloat("shadowFilterRadius", s.Settings.FilterRadius)
+	shader.SetInt("shadowFilterSamples", int32(s.Settings.FilterSamples))
+}
+
+func (s *PointShadowMaps) Delete() {
+	device.DeleteTexture(s.texture)
+	device.DeleteFramebuffer(s.framebuffer)
+	s.texture = 0
+	s.framebuffer = 0
+	s.instancedShader.Release()
+	s.shader.Release()
+}
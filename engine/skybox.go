@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"log"
+	"physics/gfx"
+	"physics/scene"
+)
+
+type SkyKind = scene.SkyKind
+
+const (
+	SkyCubemap         = scene.SkyCubemap
+	SkyEquirectangular = scene.SkyEquirectangular
+	SkyGradient        = scene.SkyGradient
+)
+
+var skyDefines = map[SkyKind]ShaderDefines{
+	SkyCubemap:         {"CUBEMAP": "1"},
+	SkyEquirectangular: {"EQUIRECTANGULAR": "1"},
+	SkyGradient:        {"GRADIENT": "1"},
+}
+
+// Skybox is the background of a Scene. Renderers draw it after the opaque
+// objects at the far plane, so it is only shaded where nothing covers it.
+type Skybox struct {
+	scene.Sky
+	// Texture is the cube map or equirectangular image of textured skies. The
+	// skybox doesn't own it.
+	Texture *Texture
+
+	// shaders are the programs built for each Kind the sky has been drawn
+	// with, nil for those that failed to build
+	shaders map[SkyKind]*ShaderProgram
+}
+
+func NewCubemapSkybox(cubemap *Texture) *Skybox {
+	return &Skybox{Sky: scene.Sky{Kind: SkyCubemap, Intensity: 1}, Texture: cubemap}
+}
+
+// NewEquirectangularSkybox wraps texture around the view, such as the
+// mapdata/F_SKY1.PNG sky of the E1M1 map
+func NewEquirectangularSkybox(texture *Texture) *Skybox {
+	return &Skybox{Sky: scene.Sky{Kind: SkyEquirectangular, Intensity: 1}, Texture: texture}
+}
+
+// NewGradientSkybox creates a daylight sky with the sun high in the south-west
+func NewGradientSkybox() *Skybox {
+	return &Skybox{Sky: scene.GradientSky()}
+}
+
+// program returns the shader for the sky's current kind, building it the
+// first time that kind is drawn. It is nil when the shader fails to build,
+// which is logged once.
+func (s *Skybox) program() *ShaderProgram {
+	if shader, ok := s.shaders[s.Kind]; ok {
+		return shader
+	}
+	if s.shaders == nil {
+		s.shaders = make(map[SkyKind]*ShaderProgram)
+	}
+	shader, err := NewShaderVariant("shaders/sky.vert", "shaders/sky.frag", skyDefines[s.Kind])
+	if err != nil {
+		log.Printf("sky not drawn: %v", err)
+	}
+	s.shaders[s.Kind] = shader
+	return shader
+}
+
+// draw fills the pixels of the bound framebuffer that are still at the far
+// plane. The frame block has to be bound.
+func (s *Skybox) draw() {
+	shader := s.program()
+	if shader == nil {
+		return
+	}
+	shader.Use()
+	shader.SetFloat("intensity", s.Intensity)
+	switch s.Kind {
+	case SkyCubemap, SkyEquirectangular:
+		if s.Texture != nil {
+			s.Texture.Bind(0)
+		}
+		shader.SetSampler("skyTexture", 0)
+	case SkyGradient:
+		shader.SetVec3("zenithColor", s.ZenithColor)
+		shader.SetVec3("horizonColor", s.HorizonColor)
+		shader.SetVec3("groundColor", s.GroundColor)
+		shader.SetVec3("sunDirection", s.SunDirection.Normalize())
+		shader.SetVec3("sunColor", s.SunColor)
+		shader.SetFloat("sunSize", s.SunSize)
+	}
+	DrawFullscreen()
+	shader.Unuse()
+}
+
+// Delete frees the sky's shaders. Its texture is owned by the caller.
+func (s *Skybox) Delete() {
+	for _, shader := range s.shaders {
+		if shader != nil {
+			shader.Release()
+		}
+	}
+	s.shaders = nil
+}
+
+// drawSky draws the current scene's sky, depth tested against what has been
+// drawn so far
+func (d *drawer) drawSky() {
+	if d.sky == nil {
+		return
+	}
+	d.unbind()
+	d.state.apply(RenderState{DepthFunc: gfx.CompareLessEqual, DepthReadOnly: true}, BlendOpaque)
+	d.sky.draw()
+	d.stats.DrawCalls++
+}
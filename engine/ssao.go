@@ -0,0 +1,333 @@
+package engine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math/rand"
+	"physics/gfx"
+)
+
+// AmbientOcclusionTextureUnit is the texture unit the SSAO result is bound to
+// while lit geometry is drawn, next to the point shadow maps. It is bound for
+// ObjectRenderers too, which point their ambientOcclusionMap at it.
+const AmbientOcclusionTextureUnit = 9
+
+// ssaoMaxSamples matches MAX_SAMPLES in shaders/ssao/ssao.frag
+const ssaoMaxSamples = 64
+
+// ssaoNoiseSize is the width and height of the tiled kernel rotation texture,
+// which the blur pass averages over
+const ssaoNoiseSize = 4
+
+type SSAOSettings struct {
+	// Radius is how far around a surface occluders are looked for, in world units.
+	Radius float32
+	// Bias is how far in front of a sample a surface has to be to occlude it,
+	// against self-occlusion of flat surfaces.
+	Bias float32
+	// Samples is the number of depth taps per pixel, between 1 and 64.
+	Samples int
+	// Power darkens the occlusion curve; 1 leaves it linear.
+	Power float32
+	// Blur smooths the noise pattern out of the result.
+	Blur bool
+}
+
+func DefaultSSAOSettings() SSAOSettings {
+	return SSAOSettings{
+		Radius:  0.5,
+		Bias:    0.025,
+		Samples: 16,
+		Power:   1,
+		Blur:    true,
+	}
+}
+
+// SSAO computes screen space ambient occlusion for renderers that have it set,
+// once per view before shading. Materials darken their ambient light with the
+// result, see shaders/common/ssao.glsl.
+type SSAO struct {
+	Settings SSAOSettings
+
+	// depth is the forward renderer's depth prepass target
+	depth     *Framebuffer
+	occlusion *Framebuffer
+	blurred   *Framebuffer
+
+	depthShader *ShaderProgram
+	// cutoutShader is the depth shader variant discarding like BlendCutout
+	// materials
+	cutoutShader *ShaderProgram
+	// shader reconstructs normals from depth, normalShader reads a G-buffer's
+	shader       *ShaderProgram
+	normalShader *ShaderProgram
+	blurShader   *ShaderProgram
+	applyShader  *ShaderProgram
+
+	noise  *Texture
+	kernel []mgl32.Vec3
+	random *rand.Rand
+}
+
+func NewSSAO(settings SSAOSettings) (*SSAO, error) {
+	// A fixed seed keeps the pattern, and so the images, the same between runs
+	s := &SSAO{Settings: settings, random: rand.New(rand.NewSource(1))}
+
+	var err error
+	if s.depthShader, err = NewShaderProgram("shaders/depth.vert", "shaders/depth.frag"); err != nil {
+		s.Delete()
+		return nil, err
+	}
+	if s.cutoutShader, err = s.depthShader.Variant(ShaderDefines{"CUTOUT": "1"}); err != nil {
+		s.Delete()
+		return nil, err
+	}
+	for _, pass := range []struct {
+		shader   **ShaderProgram
+		fragment string
+		defines  ShaderDefines
+	}{
+		{&s.shader, "shaders/ssao/ssao.frag", nil},
+		{&s.normalShader, "shaders/ssao/ssao.frag", ShaderDefines{"NORMAL_TEXTURE": "1"}},
+		{&s.blurShader, "shaders/ssao/blur.frag", nil},
+		{&s.applyShader, "shaders/ssao/apply.frag", nil},
+	} {
+		if *pass.shader, err = NewPostShader(pass.fragment, pass.defines); err != nil {
+			s.Delete()
+			return nil, err
+		}
+	}
+
+	// Random rotations around the normal, tiled over the screen
+	noise := make([]float32, 0, ssaoNoiseSize*ssaoNoiseSize*3)
+	for i := 0; i < ssaoNoiseSize*ssaoNoiseSize; i++ {
+		noise = append(noise, s.random.Float32()*2-1, s.random.Float32()*2-1, 0)
+	}
+	sampler := SamplerState{WrapS: gfx.WrapRepeat, WrapT: gfx.WrapRepeat, MinFilter: gfx.FilterNearest, MagFilter: gfx.FilterNearest}
+	if s.noise, err = NewTexture2D(ssaoNoiseSize, ssaoNoiseSize, TextureFormatRGB16F, noise, TextureOptions{Sampler: sampler}); err != nil {
+		s.Delete()
+		return nil, err
+	}
+	return s, nil
+}
+
+// updateKernel generates the sample offsets when the sample count changes.
+// They fill the unit hemisphere around +Z, denser towards its center where
+// occluders matter most.
+func (s *SSAO) updateKernel() {
+	samples := s.Settings.Samples
+	if samples < 1 {
+		samples = 1
+	} else if samples > ssaoMaxSamples {
+		samples = ssaoMaxSamples
+	}
+	if len(s.kernel) == samples {
+		return
+	}
+	s.kernel = s.kernel[:0]
+	for i := 0; i < samples; i++ {
+		sample := mgl32.Vec3{
+			s.random.Float32()*2 - 1,
+			s.random.Float32()*2 - 1,
+			s.random.Float32(),
+		}.Normalize().Mul(s.random.Float32())
+		scale := float32(i) / float32(samples)
+		sample = sample.Mul(0.1 + 0.9*scale*scale)
+		s.kernel = append(s.kernel, sample)
+	}
+}
+
+func (s *SSAO) resize(width, height int32) error {
+	if s.occlusion != nil {
+		if err := s.occlusion.Resize(width, height); err != nil {
+			return err
+		}
+		return s.blurred.Resize(width, height)
+	}
+
+	opts := FramebufferOptions{Color: []TextureFormat{TextureFormatR8}}
+	var err error
+	if s.occlusion, err = NewFramebuffer(width, height, opts); err != nil {
+		return err
+	}
+	s.blurred, err = NewFramebuffer(width, height, opts)
+	return err
+}
+
+// depthTarget returns the framebuffer the depth prepass draws into
+func (s *SSAO) depthTarget(width, height int32) (*Framebuffer, error) {
+	if s.depth != nil {
+		return s.depth, s.depth.Resize(width, height)
+	}
+	var err error
+	s.depth, err = NewFramebuffer(width, height, FramebufferOptions{
+		DepthTexture: true,
+		Sampler:      SamplerState{MinFilter: gfx.FilterNearest, MagFilter: gfx.FilterNearest},
+	})
+	return s.depth, err
+}
+
+// compute returns the occlusion of the surfaces in depth, drawn with proj and
+// view. normals holds their world space normals, or is nil to reconstruct
+// them from depth. It expects the renderers' default state and leaves it.
+func (s *SSAO) compute(depth, normals *Texture, proj, view mgl32.Mat4) (*Texture, error) {
+	if err := s.resize(depth.Width, depth.Height); err != nil {
+		return nil, err
+	}
+	s.updateKernel()
+
+	shader := s.shader
+	if normals != nil {
+		shader = s.normalShader
+	}
+	pipeline := device.Pipeline()
+	passes := pipeline
+	passes.DepthTest = false
+	device.SetPipeline(passes)
+	s.occlusion.Bind()
+	shader.Use()
+	depth.Bind(1)
+	shader.SetSampler("depthTexture", 1)
+	s.noise.Bind(2)
+	shader.SetSampler("noiseTexture", 2)
+	if normals != nil {
+		normals.Bind(3)
+		shader.SetSampler("normalTexture", 3)
+		shader.SetMat4("viewMatrix", view)
+	}
+	shader.SetMat4("projection", proj)
+	shader.SetMat4("inverseProjection", proj.Inv())
+	shader.SetVec3Array("kernel", s.kernel)
+	shader.SetInt("sampleCount", int32(len(s.kernel)))
+	shader.SetFloat("radius", s.Settings.Radius)
+	shader.SetFloat("bias", s.Settings.Bias)
+	shader.SetFloat("power", s.Settings.Power)
+	DrawFullscreen()
+
+	result := s.occlusion.Color[0]
+	if s.Settings.Blur {
+		s.blurred.Bind()
+		DrawPass(s.blurShader, result)
+		result = s.blurred.Color[0]
+	}
+
+	s.blurShader.Unuse()
+	device.BindFramebuffer(0)
+	device.SetPipeline(pipeline)
+	return result, nil
+}
+
+// modulate multiplies the bound framebuffer's colors with occlusion
+func (s *SSAO) modulate(occlusion *Texture) {
+	pipeline := device.Pipeline()
+	multiply := pipeline
+	multiply.DepthTest = false
+	multiply.Blend = BlendState{Enabled: true, SrcColor: gfx.FactorZero, DstColor: gfx.FactorSrcColor, SrcAlpha: gfx.FactorZero, DstAlpha: gfx.FactorSrcColor}
+	device.SetPipeline(multiply)
+	DrawPass(s.applyShader, occlusion)
+	s.applyShader.Unuse()
+	device.SetPipeline(pipeline)
+}
+
+func (s *SSAO) Delete() {
+	for _, framebuffer := range []*Framebuffer{s.depth, s.occlusion, s.blurred} {
+		if framebuffer != nil {
+			framebuffer.Delete()
+		}
+	}
+	for _, shader := range []*ShaderProgram{s.depthShader, s.cutoutShader, s.shader, s.normalShader, s.blurShader, s.applyShader} {
+		if shader != nil {
+			shader.Release()
+		}
+	}
+	if s.noise != nil {
+		s.noise.Delete()
+	}
+}
+
+// bindAmbientOcclusion points shader's ambientOcclusionMap at the current
+// view's occlusion, or at white when there is none. Like pointShadowMaps, it
+// must never be left on unit 0.
+func (d *drawer) bindAmbientOcclusion(shader *ShaderProgram) {
+	if !shader.HasUniform("ambientOcclusionMap") {
+		return
+	}
+	d.bindAmbientOcclusionTexture()
+	shader.SetSampler("ambientOcclusionMap", AmbientOcclusionTextureUnit)
+}
+
+// bindAmbientOcclusionTexture binds the current view's occlusion, or white
+// when there is none, to AmbientOcclusionTextureUnit
+func (d *drawer) bindAmbientOcclusionTexture() {
+	occlusion := d.ambientOcclusion
+	if occlusion == nil {
+		occlusion = WhiteTexture()
+	}
+	occlusion.Bind(AmbientOcclusionTextureUnit)
+}
+
+// computeAmbientOcclusion draws the depth of the queue's opaque items and
+// computes the view's occlusion from it, for renderers without a G-buffer
+func (d *drawer) computeAmbientOcclusion(queue *RenderQueue) (*Texture, error) {
+	depth, err := d.SSAO.depthTarget(d.targetSize())
+	if err != nil {
+		return nil, err
+	}
+	depth.Bind()
+	d.state.apply(RenderState{}, BlendOpaque)
+	device.Clear(gfx.DepthBuffer)
+	d.drawDepth(queue)
+
+	d.state.apply(RenderState{}, BlendOpaque)
+	return d.SSAO.compute(depth.Depth, nil, d.frame.Projection, d.frame.View)
+}
+
+// drawDepth draws the opaque items of queue into the bound framebuffer's depth
+// with the SSAO depth shader, discarding the fragments of cutout materials.
+// Items with their own renderer are left out.
+func (d *drawer) drawDepth(queue *RenderQueue) {
+	d.bound = boundState{}
+	for i := range queue.Opaque {
+		item := &queue.Opaque[i]
+		if item.Renderer != nil {
+			continue
+		}
+		shader := d.SSAO.depthShader
+		cutout := item.Material.BlendMode == BlendCutout
+		if cutout {
+			shader = d.SSAO.cutoutShader
+		}
+		if item.Instances != nil {
+			shader = d.instanced.get(shader)
+			if shader == nil {
+				continue
+			}
+		}
+		if shader != d.bound.shader {
+			shader.Use()
+			d.bound.shader = shader
+			d.bound.material = nil
+		}
+		if cutout && item.Material != d.bound.material {
+			if err := item.Material.BindShaderProperties(shader); err != nil {
+				panic(err)
+			}
+			d.bound.material = item.Material
+		}
+		d.state.apply(item.Material.RenderState, BlendOpaque)
+
+		count := int32(len(item.Mesh.Indices))
+		if item.Instances != nil {
+			array := d.instanceArray(item)
+			array.upload(item.Instances)
+			device.Draw(DrawCall{VertexArray: array.vao, Count: count, Indexed: true, Instances: int32(len(item.Instances))})
+			continue
+		}
+		objectUniforms := NewObjectUniforms(item.Model)
+		if err := d.objectBuffer.Update(&objectUniforms); err != nil {
+			panic(err)
+		}
+		uploadMesh(item.Mesh)
+		device.Draw(DrawCall{VertexArray: item.Mesh.Vao, Count: count, Indexed: true})
+	}
+	d.unbind()
+}
@@ -0,0 +1,369 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"os"
+	"physics/gfx"
+)
+
+type TextureOptions struct {
+	Sampler    SamplerState
+	ColorSpace ColorSpace
+	Mipmaps    bool
+	// FlipY stores images bottom row first, matching OpenGL's texture origin
+	FlipY bool
+}
+
+func DefaultSamplerState() SamplerState {
+	return SamplerState{
+		WrapS:      gfx.WrapRepeat,
+		WrapT:      gfx.WrapRepeat,
+		WrapR:      gfx.WrapRepeat,
+		MinFilter:  gfx.FilterLinearMipmapLinear,
+		MagFilter:  gfx.FilterLinear,
+		Anisotropy: 8,
+	}
+}
+
+func DefaultTextureOptions() TextureOptions {
+	return TextureOptions{
+		Sampler: DefaultSamplerState(),
+		Mipmaps: true,
+	}
+}
+
+// ColorTextureOptions are the defaults for textures holding colors, such as
+// diffuse and albedo maps, which are authored in sRGB
+func ColorTextureOptions() TextureOptions {
+	opts := DefaultTextureOptions()
+	opts.ColorSpace = ColorSpaceSRGB
+	return opts
+}
+
+type Texture struct {
+	handle uint32
+
+	Target     gfx.TextureTarget
+	Width      int32
+	Height     int32
+	Layers     int32
+	Levels     int32
+	Format     TextureFormat
+	ColorSpace ColorSpace
+	Sampler    SamplerState
+}
+
+// newTexture creates the device texture and allocates every level of its
+// storage, leaving the contents to be uploaded by the caller.
+func newTexture(target gfx.TextureTarget, width, height, layers int32, format TextureFormat, opts TextureOptions) (*Texture, error) {
+	if format < TextureFormatRGBA8 || format > TextureFormatDepth24Stencil8 {
+		return nil, fmt.Errorf("unknown texture format %d", format)
+	}
+	if opts.ColorSpace == ColorSpaceSRGB && !format.HasSRGB() {
+		return nil, fmt.Errorf("texture format %d has no sRGB variant", format)
+	}
+	if width < 1 || height < 1 || layers < 1 {
+		return nil, fmt.Errorf("invalid texture size %dx%dx%d", width, height, layers)
+	}
+
+	t := &Texture{
+		Target:     target,
+		Width:      width,
+		Height:     height,
+		Layers:     layers,
+		Levels:     1,
+		Format:     format,
+		ColorSpace: opts.ColorSpace,
+	}
+	if opts.Mipmaps {
+		t.Levels = mipLevels(width, height)
+	}
+
+	t.allocate(opts.Sampler)
+	return t, nil
+}
+
+func mipLevels(width, height int32) int32 {
+	size := width
+	if height > size {
+		size = height
+	}
+	return int32(math.Floor(math.Log2(float64(size)))) + 1
+}
+
+// texelFormat is the texture's format at its color space
+func (t *Texture) texelFormat() TexelFormat {
+	return TexelFormat{Format: t.Format, ColorSpace: t.ColorSpace}
+}
+
+// NewTexture2D creates a 2D texture from raw pixels laid out as format
+// describes. pixels may be []uint8, []uint16, []float32, or nil to allocate
+// uninitialised storage, e.g. for render targets.
+func NewTexture2D(width, height int32, format TextureFormat, pixels interface{}, opts TextureOptions) (*Texture, error) {
+	t, err := newTexture(gfx.Texture2D, width, height, 1, format, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	device.TexImage2D(t.handle, gfx.Texture2D, 0, t.texelFormat(), width, height, pixels)
+	t.finish(pixels != nil)
+	return t, nil
+}
+
+// NewTextureFromImage uploads img, keeping 16 bits per channel when the image
+// has them and is linear. sRGB images are uploaded with 8, since no 16-bit
+// format has an sRGB variant.
+func NewTextureFromImage(img image.Image, opts TextureOptions) (*Texture, error) {
+	format, pixels, width, height := imagePixels(img, opts)
+	return NewTexture2D(width, height, format, pixels, opts)
+}
+
+func LoadTexture(path string, opts TextureOptions) (*Texture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open texture file: %w", err)
+	}
+	defer file.Close()
+
+	return LoadTextureFromReader(file, opts)
+}
+
+func LoadTextureFromBytes(data []byte, opts TextureOptions) (*Texture, error) {
+	return LoadTextureFromReader(bytes.NewReader(data), opts)
+}
+
+// LoadTextureFromReader decodes any format registered with the image package,
+// or a block compressed DDS or KTX2 file.
+func LoadTextureFromReader(r io.Reader, opts TextureOptions) (*Texture, error) {
+	buffered := bufio.NewReader(r)
+	if header, _ := buffered.Peek(len(ktx2Magic)); isCompressedContainer(header) {
+		data, err := io.ReadAll(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read texture file: %w", err)
+		}
+		return loadCompressedTexture(data, opts)
+	}
+
+	img, _, err := image.Decode(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode texture file: %w", err)
+	}
+	return NewTextureFromImage(img, opts)
+}
+
+// NewCubemap creates a cube map from six square faces in +X, -X, +Y, -Y, +Z, -Z order
+func NewCubemap(faces [6]image.Image, opts TextureOptions) (*Texture, error) {
+	format, _, width, height := imagePixels(faces[0], TextureOptions{ColorSpace: opts.ColorSpace})
+	t, err := newTexture(gfx.TextureCubeMap, width, height, 1, format, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, face := range faces {
+		faceFormat, pixels, w, h := imagePixels(face, opts)
+		if faceFormat != format || w != width || h != height {
+			t.Delete()
+			return nil, fmt.Errorf("cube map face %d does not match the size and format of face 0", i)
+		}
+		device.TexImage2D(t.handle, gfx.TextureCubeMapPositiveX+gfx.TextureTarget(i), 0, t.texelFormat(), width, height, pixels)
+	}
+	t.finish(true)
+	return t, nil
+}
+
+func LoadCubemap(paths [6]string, opts TextureOptions) (*Texture, error) {
+	var faces [6]image.Image
+	for i, path := range paths {
+		img, err := decodeImageFile(path)
+		if err != nil {
+			return nil, err
+		}
+		faces[i] = img
+	}
+	return NewCubemap(faces, opts)
+}
+
+// NewTextureArray creates a 2D texture array with one layer per image. All
+// images must share the same size and pixel depth.
+func NewTextureArray(layers []image.Image, opts TextureOptions) (*Texture, error) {
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("texture array needs at least one layer")
+	}
+	format, _, width, height := imagePixels(layers[0], TextureOptions{ColorSpace: opts.ColorSpace})
+	t, err := newTexture(gfx.Texture2DArray, width, height, int32(len(layers)), format, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	device.TexImage3D(t.handle, gfx.Texture2DArray, 0, t.texelFormat(), width, height, int32(len(layers)), nil)
+	for i, layer := range layers {
+		layerFormat, pixels, w, h := imagePixels(layer, opts)
+		if layerFormat != format || w != width || h != height {
+			t.Delete()
+			return nil, fmt.Errorf("texture array layer %d does not match the size and format of layer 0", i)
+		}
+		device.TexLayer(t.handle, gfx.Texture2DArray, 0, int32(i), t.texelFormat(), width, height, pixels)
+	}
+	t.finish(true)
+	return t, nil
+}
+
+func LoadTextureArray(paths []string, opts TextureOptions) (*Texture, error) {
+	layers := make([]image.Image, len(paths))
+	for i, path := range paths {
+		img, err := decodeImageFile(path)
+		if err != nil {
+			return nil, err
+		}
+		layers[i] = img
+	}
+	return NewTextureArray(layers, opts)
+}
+
+func decodeImageFile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open texture file: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode texture file %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// imagePixels converts img to tightly packed, non-premultiplied RGBA rows as
+// opts asks for them, flipped and with 16-bit images reduced to 8 bits when
+// they are sRGB
+func imagePixels(img image.Image, opts TextureOptions) (TextureFormat, interface{}, int32, int32) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	flipY := opts.FlipY
+
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		if opts.ColorSpace == ColorSpaceSRGB {
+			break
+		}
+		nrgba := image.NewNRGBA64(image.Rect(0, 0, width, height))
+		draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+		// Go stores 16-bit channels big endian, GL wants them in host order
+		pixels := make([]uint16, width*height*4)
+		for y := 0; y < height; y++ {
+			row := y
+			if flipY {
+				row = height - 1 - y
+			}
+			src := nrgba.Pix[y*nrgba.Stride:]
+			dst := pixels[row*width*4:]
+			for i := 0; i < width*4; i++ {
+				dst[i] = uint16(src[i*2])<<8 | uint16(src[i*2+1])
+			}
+		}
+		return TextureFormatRGBA16, pixels, int32(width), int32(height)
+	}
+
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok || nrgba.Stride != width*4 || bounds.Min != (image.Point{}) || flipY {
+		nrgba = image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+		if flipY {
+			flipRows(nrgba.Pix, nrgba.Stride, height)
+		}
+	}
+	return TextureFormatRGBA8, nrgba.Pix, int32(width), int32(height)
+}
+
+func flipRows(pix []uint8, stride, height int) {
+	tmp := make([]uint8, stride)
+	for y := 0; y < height/2; y++ {
+		top := pix[y*stride : (y+1)*stride]
+		bottom := pix[(height-1-y)*stride : (height-y)*stride]
+		copy(tmp, top)
+		copy(top, bottom)
+		copy(bottom, tmp)
+	}
+}
+
+// finish generates the mip chain once level 0 holds data
+func (t *Texture) finish(hasData bool) {
+	if hasData && t.Levels > 1 {
+		device.GenerateMipmaps(t.handle, t.Target)
+	}
+}
+
+// GenerateMipmaps rebuilds the mip chain from level 0, e.g. after rendering to it
+func (t *Texture) GenerateMipmaps() {
+	if t.Levels <= 1 {
+		return
+	}
+	device.GenerateMipmaps(t.handle, t.Target)
+}
+
+// SetSampler applies wrap, filter and anisotropy settings. Mipmapped minification
+// filters fall back to their base filter on textures without mipmaps.
+func (t *Texture) SetSampler(sampler SamplerState) {
+	if t.Levels <= 1 {
+		sampler.MinFilter = sampler.MinFilter.Base()
+	}
+	if sampler.MinFilter == gfx.FilterUnset {
+		sampler.MinFilter = gfx.FilterLinear
+	}
+	if sampler.MagFilter == gfx.FilterUnset {
+		sampler.MagFilter = gfx.FilterLinear
+	}
+	for _, wrap := range []*gfx.WrapMode{&sampler.WrapS, &sampler.WrapT, &sampler.WrapR} {
+		if *wrap == gfx.WrapUnset {
+			*wrap = gfx.WrapClampToEdge
+		}
+	}
+	t.Sampler = sampler
+
+	if sampler.Anisotropy > 1 {
+		sampler.Anisotropy = float32(math.Min(float64(sampler.Anisotropy), float64(device.MaxAnisotropy())))
+	}
+	device.SetSampler(t.handle, t.Target, sampler)
+}
+
+// Bind makes the texture current on the given texture unit
+func (t *Texture) Bind(unit int) {
+	device.BindTexture(unit, t.Target, t.handle)
+}
+
+func (t *Texture) Handle() uint32 {
+	return t.handle
+}
+
+func (t *Texture) Delete() {
+	device.DeleteTexture(t.handle)
+	t.handle = 0
+}
+
+var whiteTexture *Texture
+
+// WhiteTexture returns a shared 1x1 opaque white texture, bound in place of
+// missing material textures so sampling them leaves colors unchanged.
+func WhiteTexture() *Texture {
+	if whiteTexture == nil {
+		white := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		white.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		texture, err := NewTextureFromImage(white, TextureOptions{})
+		if err != nil {
+			panic(err)
+		}
+		whiteTexture = texture
+	}
+	return whiteTexture
+}
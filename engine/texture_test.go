@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"image"
+	"image/color"
+	"physics/gfx"
+	"physics/soft/raster"
+	"testing"
+)
+
+// useSoftDevice draws with the software device for the rest of the test
+func useSoftDevice(t *testing.T) *raster.Device {
+	previous := device
+	soft := raster.NewDevice(1, 1)
+	SetDevice(soft)
+	t.Cleanup(func() { SetDevice(previous) })
+	return soft
+}
+
+func TestNewTextureFromImage16Bit(t *testing.T) {
+	useSoftDevice(t)
+	img := image.NewNRGBA64(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA64(0, 0, color.NRGBA64{R: 0xffff, G: 0x8080, B: 0, A: 0xffff})
+	img.SetNRGBA64(1, 0, color.NRGBA64{R: 0x4040, G: 0, B: 0xffff, A: 0x8080})
+
+	linear, err := NewTextureFromImage(img, DefaultTextureOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer linear.Delete()
+	if linear.Format != TextureFormatRGBA16 {
+		t.Errorf("linear 16-bit image has format %d, want RGBA16", linear.Format)
+	}
+
+	srgb, err := NewTextureFromImage(img, ColorTextureOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srgb.Delete()
+	if srgb.Format != TextureFormatRGBA8 || srgb.ColorSpace != ColorSpaceSRGB {
+		t.Fatalf("sRGB 16-bit image has format %d in color space %d, want sRGB RGBA8", srgb.Format, srgb.ColorSpace)
+	}
+
+	framebuffer, err := device.CreateFramebuffer([]gfx.FramebufferAttachment{{Attachment: gfx.ColorAttachment(0), Texture: srgb.handle}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer device.DeleteFramebuffer(framebuffer)
+	pixels, err := readPixels(framebuffer, 0, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, want := range []color.RGBA{{0xff, 0x80, 0, 0xff}, {0x40, 0, 0xff, 0x80}} {
+		if got := pixels.RGBAAt(x, 0); got != want {
+			t.Errorf("pixel %d is %v, want %v", x, got, want)
+		}
+	}
+}
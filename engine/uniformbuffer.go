@@ -0,0 +1,406 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"physics/gfx"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Uniform block binding points shared by every shader program. Blocks with
+// these names are bound to their point when a program is linked, since GLSL
+// 4.10 has no layout(binding) qualifier for blocks.
+const (
+	FrameUniformBinding uint32 = iota
+	ObjectUniformBinding
+	MaterialUniformBinding
+)
+
+var UniformBlockBindings = map[string]uint32{
+	"FrameData":       FrameUniformBinding,
+	"ObjectData":      ObjectUniformBinding,
+	"PhongMaterial":   MaterialUniformBinding,
+	"PBRMaterialData": MaterialUniformBinding,
+}
+
+// std140Member is a leaf of a std140 layout, named the way GL reflection names
+// uniform block members, e.g. "lights[2].position" or "weights" for an array.
+type std140Member struct {
+	name         string
+	offset       int
+	dataType     gfx.DataType
+	arrayStride  int
+	matrixStride int
+}
+
+// std140Layout describes how a Go type is stored in a std140 uniform block
+type std140Layout struct {
+	align   int
+	size    int
+	members []std140Member
+	write   func(dst []byte, v reflect.Value)
+}
+
+var (
+	std140Layouts   = make(map[reflect.Type]*std140Layout)
+	std140LayoutsMu sync.Mutex
+
+	vec2Type = reflect.TypeOf(mgl32.Vec2{})
+	vec3Type = reflect.TypeOf(mgl32.Vec3{})
+	vec4Type = reflect.TypeOf(mgl32.Vec4{})
+	mat2Type = reflect.TypeOf(mgl32.Mat2{})
+	mat3Type = reflect.TypeOf(mgl32.Mat3{})
+	mat4Type = reflect.TypeOf(mgl32.Mat4{})
+)
+
+func roundUp(n, align int) int {
+	return (n + align - 1) / align * align
+}
+
+func putFloat32(dst []byte, offset int, value float32) {
+	binary.LittleEndian.PutUint32(dst[offset:], math.Float32bits(value))
+}
+
+// blockLayout returns the std140 layout of a uniform block whose members are
+// the fields of struct type t.
+func blockLayout(t reflect.Type) (*std140Layout, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("uniform block type must be a struct, got %s", t)
+	}
+
+	std140LayoutsMu.Lock()
+	defer std140LayoutsMu.Unlock()
+	if layout, ok := std140Layouts[t]; ok {
+		return layout, nil
+	}
+	layout, err := newStd140Layout(t)
+	if err != nil {
+		return nil, err
+	}
+	std140Layouts[t] = layout
+	return layout, nil
+}
+
+func newStd140Layout(t reflect.Type) (*std140Layout, error) {
+	vector := func(dataType gfx.DataType, components, align int) *std140Layout {
+		return &std140Layout{
+			align:   align,
+			size:    components * 4,
+			members: []std140Member{{dataType: dataType}},
+			write: func(dst []byte, v reflect.Value) {
+				for i := 0; i < components; i++ {
+					putFloat32(dst, i*4, float32(v.Index(i).Float()))
+				}
+			},
+		}
+	}
+	// Matrices are stored as arrays of column vectors, each padded to a vec4
+	matrix := func(dataType gfx.DataType, columns, rows int) *std140Layout {
+		return &std140Layout{
+			align:   16,
+			size:    columns * 16,
+			members: []std140Member{{dataType: dataType, matrixStride: 16}},
+			write: func(dst []byte, v reflect.Value) {
+				for c := 0; c < columns; c++ {
+					for r := 0; r < rows; r++ {
+						putFloat32(dst, c*16+r*4, float32(v.Index(c*rows+r).Float()))
+					}
+				}
+			},
+		}
+	}
+
+	switch t {
+	case vec2Type:
+		return vector(gfx.TypeVec2, 2, 8), nil
+	case vec3Type:
+		return vector(gfx.TypeVec3, 3, 16), nil
+	case vec4Type:
+		return vector(gfx.TypeVec4, 4, 16), nil
+	case mat2Type:
+		return matrix(gfx.TypeMat2, 2, 2), nil
+	case mat3Type:
+		return matrix(gfx.TypeMat3, 3, 3), nil
+	case mat4Type:
+		return matrix(gfx.TypeMat4, 4, 4), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Float32:
+		return &std140Layout{align: 4, size: 4, members: []std140Member{{dataType: gfx.TypeFloat}},
+			write: func(dst []byte, v reflect.Value) { putFloat32(dst, 0, float32(v.Float())) }}, nil
+	case reflect.Int32:
+		return &std140Layout{align: 4, size: 4, members: []std140Member{{dataType: gfx.TypeInt}},
+			write: func(dst []byte, v reflect.Value) { binary.LittleEndian.PutUint32(dst, uint32(int32(v.Int()))) }}, nil
+	case reflect.Uint32:
+		return &std140Layout{align: 4, size: 4, members: []std140Member{{dataType: gfx.TypeUint}},
+			write: func(dst []byte, v reflect.Value) { binary.LittleEndian.PutUint32(dst, uint32(v.Uint())) }}, nil
+	case reflect.Bool:
+		return &std140Layout{align: 4, size: 4, members: []std140Member{{dataType: gfx.TypeBool}},
+			write: func(dst []byte, v reflect.Value) {
+				var b uint32
+				if v.Bool() {
+					b = 1
+				}
+				binary.LittleEndian.PutUint32(dst, b)
+			}}, nil
+	case reflect.Array:
+		return newStd140ArrayLayout(t)
+	case reflect.Struct:
+		return newStd140StructLayout(t)
+	}
+	return nil, fmt.Errorf("type %s has no std140 representation", t)
+}
+
+// Array elements are aligned and strided to a multiple of a vec4
+func newStd140ArrayLayout(t reflect.Type) (*std140Layout, error) {
+	elem, err := newStd140Layout(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	stride := roundUp(elem.size, 16)
+	length := t.Len()
+
+	layout := &std140Layout{
+		align: roundUp(elem.align, 16),
+		size:  stride * length,
+		write: func(dst []byte, v reflect.Value) {
+			for i := 0; i < length; i++ {
+				elem.write(dst[i*stride:], v.Index(i))
+			}
+		},
+	}
+
+	if len(elem.members) != 1 || elem.members[0].name != "" {
+		// Arrays of structs are reported per element
+		for i := 0; i < length; i++ {
+			for _, m := range elem.members {
+				m.name = fmt.Sprintf("[%d].%s", i, m.name)
+				m.offset += i * stride
+				layout.members = append(layout.members, m)
+			}
+		}
+	} else {
+		m := elem.members[0]
+		m.arrayStride = stride
+		layout.members = append(layout.members, m)
+	}
+	return layout, nil
+}
+
+func newStd140StructLayout(t reflect.Type) (*std140Layout, error) {
+	type field struct {
+		index  int
+		offset int
+		layout *std140Layout
+	}
+
+	var fields []field
+	layout := &std140Layout{align: 16}
+	offset := 0
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("std140")
+		if name == "-" || f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = lowerFirst(f.Name)
+		}
+
+		fieldLayout, err := newStd140Layout(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+		offset = roundUp(offset, fieldLayout.align)
+		fields = append(fields, field{index: i, offset: offset, layout: fieldLayout})
+
+		for _, m := range fieldLayout.members {
+			if m.name == "" || strings.HasPrefix(m.name, "[") {
+				m.name = name + m.name
+			} else {
+				m.name = name + "." + m.name
+			}
+			m.offset += offset
+			layout.members = append(layout.members, m)
+		}
+		offset += fieldLayout.size
+		if fieldLayout.align > layout.align {
+			layout.align = fieldLayout.align
+		}
+	}
+
+	layout.size = roundUp(offset, layout.align)
+	layout.write = func(dst []byte, v reflect.Value) {
+		for _, f := range fields {
+			f.layout.write(dst[f.offset:], v.Field(f.index))
+		}
+	}
+	return layout, nil
+}
+
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
+
+// PackStd140 encodes the struct v into dst using the std140 layout rules,
+// growing dst as needed. Exported fields map to block members with the first
+// letter lower-cased, or to the name in a `std140:"name"` tag.
+func PackStd140(dst []byte, v interface{}) ([]byte, error) {
+	value := reflect.ValueOf(v)
+	layout, err := blockLayout(value.Type())
+	if err != nil {
+		return dst, err
+	}
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	if cap(dst) < layout.size {
+		dst = make([]byte, layout.size)
+	}
+	dst = dst[:layout.size]
+	for i := range dst {
+		dst[i] = 0
+	}
+	layout.write(dst, value)
+	return dst, nil
+}
+
+// ValidateUniformBlock checks that the std140 layout of the Go struct v matches
+// the block as the driver laid it out in this program.
+func (s *ShaderProgram) ValidateUniformBlock(blockName string, v interface{}) error {
+	block, ok := s.reflection.uniformBlocks[blockName]
+	if !ok {
+		return fmt.Errorf("shader has no uniform block %q", blockName)
+	}
+	layout, err := blockLayout(reflect.TypeOf(v))
+	if err != nil {
+		return err
+	}
+
+	members := make(map[string]std140Member, len(layout.members))
+	for _, m := range layout.members {
+		members[m.name] = m
+	}
+
+	var problems []string
+	if int(block.DataSize) > layout.size {
+		problems = append(problems, fmt.Sprintf("block is %d bytes, Go type packs to %d", block.DataSize, layout.size))
+	}
+	for _, uniform := range s.reflection.uniforms {
+		if uniform.BlockIndex != int32(block.Index) {
+			continue
+		}
+		// Members of blocks with an instance name are reported as Block.member
+		name := strings.TrimPrefix(uniform.Name, blockName+".")
+		m, ok := members[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: no matching Go field", name))
+		case m.dataType != uniform.Type && !uniformTypeCompatible(uniform.Type, m.dataType):
+			problems = append(problems, fmt.Sprintf("%s: declared %s, Go field is %s", name, uniform.Type, m.dataType))
+		case int32(m.offset) != uniform.Offset:
+			problems = append(problems, fmt.Sprintf("%s: offset %d in shader, %d in Go", name, uniform.Offset, m.offset))
+		case uniform.Size > 1 && int32(m.arrayStride) != uniform.ArrayStride:
+			problems = append(problems, fmt.Sprintf("%s: array stride %d in shader, %d in Go", name, uniform.ArrayStride, m.arrayStride))
+		case uniform.MatrixStride != 0 && int32(m.matrixStride) != uniform.MatrixStride:
+			problems = append(problems, fmt.Sprintf("%s: matrix stride %d in shader, %d in Go", name, uniform.MatrixStride, m.matrixStride))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("uniform block %q does not match %s:\n\t%s", blockName, reflect.TypeOf(v), strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// checkUniformBlock validates block against v the first time it is used with
+// this program, logging any mismatch once.
+func (s *ShaderProgram) checkUniformBlock(blockName string, v interface{}) {
+	if _, ok := s.reflection.uniformBlocks[blockName]; !ok {
+		return
+	}
+	key := "block " + blockName
+	if s.reflection.warned[key] {
+		return
+	}
+	if err := s.ValidateUniformBlock(blockName, v); err != nil {
+		s.warnOnce(key, "%v", err)
+	}
+	s.reflection.warned[key] = true
+}
+
+// bindUniformBlocks assigns the shared binding point to every known block
+func (s *ShaderProgram) bindUniformBlocks() {
+	for name, block := range s.reflection.uniformBlocks {
+		if binding, ok := UniformBlockBindings[name]; ok {
+			device.BindUniformBlock(s.program, block.Index, binding)
+			block.Binding = int32(binding)
+		}
+	}
+}
+
+// UniformBuffer is a std140 uniform buffer object holding one Go struct
+type UniformBuffer struct {
+	handle  uint32
+	binding uint32
+	data    []byte
+	scratch []byte
+}
+
+func NewUniformBuffer(binding uint32, v interface{}) (*UniformBuffer, error) {
+	data, err := PackStd140(nil, v)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &UniformBuffer{binding: binding, data: data}
+	b.handle = device.CreateBuffer(gfx.BufferUniforms, len(data), data, gfx.UsageDynamic)
+	return b, nil
+}
+
+// Update packs v and uploads it, skipping the upload when nothing changed
+func (b *UniformBuffer) Update(v interface{}) error {
+	var err error
+	b.scratch, err = PackStd140(b.scratch, v)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(b.scratch, b.data) {
+		return nil
+	}
+
+	if len(b.scratch) != len(b.data) {
+		device.ReallocateBuffer(gfx.BufferUniforms, b.handle, len(b.scratch), b.scratch, gfx.UsageDynamic)
+	} else {
+		device.WriteBuffer(gfx.BufferUniforms, b.handle, 0, len(b.scratch), b.scratch)
+	}
+	b.data, b.scratch = b.scratch, b.data
+	return nil
+}
+
+// Bind attaches the buffer to its binding point
+func (b *UniformBuffer) Bind() {
+	device.BindUniformBuffer(b.binding, b.handle)
+}
+
+func (b *UniformBuffer) Handle() uint32 {
+	return b.handle
+}
+
+func (b *UniformBuffer) Delete() {
+	device.DeleteBuffer(b.handle)
+	b.handle = 0
+}
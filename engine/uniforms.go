@@ -0,0 +1,548 @@
+package engine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/go-gl/mathgl/mgl64"
+	"physics/gfx"
+	"strings"
+)
+
+// Typed uniform setters. Like the glUniform calls they end up as, they act on the
+// program currently in use, so call Use first. Setting a uniform the program
+// does not have, or with a type that does not match its declaration, logs a
+// warning once per name and is otherwise ignored.
+
+// uniformLocation returns the location of key if it is declared with a type
+// compatible with dataType, and -1 otherwise.
+func (s *ShaderProgram) uniformLocation(key string, dataType gfx.DataType, count int) int32 {
+	loc, ok := s.reflection.locations[key]
+	if !ok {
+		s.warnOnce(key, "setting unknown uniform %q", key)
+		return -1
+	}
+
+	uniform := s.reflection.uniforms[key]
+	if uniform == nil {
+		// Array element, described by the uniform of the whole array
+		if i := strings.LastIndexByte(key, '['); i > 0 && strings.HasSuffix(key, "]") {
+			uniform = s.reflection.uniforms[key[:i]]
+		}
+	}
+	if uniform == nil {
+		return loc
+	}
+
+	if !uniformTypeCompatible(uniform.Type, dataType) {
+		s.warnOnce(key, "uniform %q is %s, set as %s", key, uniform.Type, dataType)
+		return -1
+	}
+	if count > int(uniform.Size) {
+		s.warnOnce(key, "uniform %q holds %d elements, set with %d", key, uniform.Size, count)
+	}
+	return loc
+}
+
+func uniformTypeCompatible(declared, set gfx.DataType) bool {
+	if declared == set {
+		return true
+	}
+	if declared.IsSampler() {
+		return set == gfx.TypeInt
+	}
+
+	// Booleans may be set through the int, uint or float variants
+	switch declared {
+	case gfx.TypeBool:
+		return set == gfx.TypeInt || set == gfx.TypeUint || set == gfx.TypeFloat
+	case gfx.TypeBVec2:
+		return set == gfx.TypeIVec2 || set == gfx.TypeUVec2 || set == gfx.TypeVec2
+	case gfx.TypeBVec3:
+		return set == gfx.TypeIVec3 || set == gfx.TypeUVec3 || set == gfx.TypeVec3
+	case gfx.TypeBVec4:
+		return set == gfx.TypeIVec4 || set == gfx.TypeUVec4 || set == gfx.TypeVec4
+	}
+	return false
+}
+
+func (s *ShaderProgram) SetBool(key string, value bool) {
+	var v int32
+	if value {
+		v = 1
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeBool, 1), v)
+}
+
+func (s *ShaderProgram) SetInt(key string, value int32) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeInt, 1), value)
+}
+
+func (s *ShaderProgram) SetUint(key string, value uint32) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeUint, 1), value)
+}
+
+func (s *ShaderProgram) SetFloat(key string, value float32) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeFloat, 1), value)
+}
+
+// SetSampler points a sampler uniform at a texture unit
+func (s *ShaderProgram) SetSampler(key string, unit int) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeInt, 1), int32(unit))
+}
+
+func (s *ShaderProgram) SetVec2(key string, value mgl32.Vec2) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeVec2, 1), value)
+}
+
+func (s *ShaderProgram) SetVec3(key string, value mgl32.Vec3) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeVec3, 1), value)
+}
+
+func (s *ShaderProgram) SetVec4(key string, value mgl32.Vec4) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeVec4, 1), value)
+}
+
+func (s *ShaderProgram) SetIVec2(key string, value [2]int32) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeIVec2, 1), value)
+}
+
+func (s *ShaderProgram) SetIVec3(key string, value [3]int32) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeIVec3, 1), value)
+}
+
+func (s *ShaderProgram) SetIVec4(key string, value [4]int32) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeIVec4, 1), value)
+}
+
+func (s *ShaderProgram) SetUVec2(key string, value [2]uint32) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeUVec2, 1), value)
+}
+
+func (s *ShaderProgram) SetUVec3(key string, value [3]uint32) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeUVec3, 1), value)
+}
+
+func (s *ShaderProgram) SetUVec4(key string, value [4]uint32) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeUVec4, 1), value)
+}
+
+func (s *ShaderProgram) SetBVec2(key string, value [2]bool) {
+	var v [2]int32
+	for i, b := range value {
+		if b {
+			v[i] = 1
+		}
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeBVec2, 1), v)
+}
+
+func (s *ShaderProgram) SetBVec3(key string, value [3]bool) {
+	var v [3]int32
+	for i, b := range value {
+		if b {
+			v[i] = 1
+		}
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeBVec3, 1), v)
+}
+
+func (s *ShaderProgram) SetBVec4(key string, value [4]bool) {
+	var v [4]int32
+	for i, b := range value {
+		if b {
+			v[i] = 1
+		}
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeBVec4, 1), v)
+}
+
+func (s *ShaderProgram) SetMat2(key string, value mgl32.Mat2) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat2, 1), value)
+}
+
+func (s *ShaderProgram) SetMat3(key string, value mgl32.Mat3) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat3, 1), value)
+}
+
+func (s *ShaderProgram) SetMat4(key string, value mgl32.Mat4) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat4, 1), value)
+}
+
+func (s *ShaderProgram) SetMat2x3(key string, value mgl32.Mat2x3) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat2x3, 1), value)
+}
+
+func (s *ShaderProgram) SetMat2x4(key string, value mgl32.Mat2x4) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat2x4, 1), value)
+}
+
+func (s *ShaderProgram) SetMat3x2(key string, value mgl32.Mat3x2) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat3x2, 1), value)
+}
+
+func (s *ShaderProgram) SetMat3x4(key string, value mgl32.Mat3x4) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat3x4, 1), value)
+}
+
+func (s *ShaderProgram) SetMat4x2(key string, value mgl32.Mat4x2) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat4x2, 1), value)
+}
+
+func (s *ShaderProgram) SetMat4x3(key string, value mgl32.Mat4x3) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat4x3, 1), value)
+}
+
+func (s *ShaderProgram) SetDouble(key string, value float64) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDouble, 1), value)
+}
+
+func (s *ShaderProgram) SetDVec2(key string, value mgl64.Vec2) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDVec2, 1), value)
+}
+
+func (s *ShaderProgram) SetDVec3(key string, value mgl64.Vec3) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDVec3, 1), value)
+}
+
+func (s *ShaderProgram) SetDVec4(key string, value mgl64.Vec4) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDVec4, 1), value)
+}
+
+func (s *ShaderProgram) SetDMat2(key string, value mgl64.Mat2) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat2, 1), value)
+}
+
+func (s *ShaderProgram) SetDMat3(key string, value mgl64.Mat3) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat3, 1), value)
+}
+
+func (s *ShaderProgram) SetDMat4(key string, value mgl64.Mat4) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat4, 1), value)
+}
+
+func (s *ShaderProgram) SetDMat2x3(key string, value mgl64.Mat2x3) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat2x3, 1), value)
+}
+
+func (s *ShaderProgram) SetDMat2x4(key string, value mgl64.Mat2x4) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat2x4, 1), value)
+}
+
+func (s *ShaderProgram) SetDMat3x2(key string, value mgl64.Mat3x2) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat3x2, 1), value)
+}
+
+func (s *ShaderProgram) SetDMat3x4(key string, value mgl64.Mat3x4) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat3x4, 1), value)
+}
+
+func (s *ShaderProgram) SetDMat4x2(key string, value mgl64.Mat4x2) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat4x2, 1), value)
+}
+
+func (s *ShaderProgram) SetDMat4x3(key string, value mgl64.Mat4x3) {
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat4x3, 1), value)
+}
+
+func (s *ShaderProgram) SetBoolArray(key string, values []bool) {
+	if len(values) == 0 {
+		return
+	}
+	ints := make([]int32, len(values))
+	for i, v := range values {
+		if v {
+			ints[i] = 1
+		}
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeBool, len(values)), ints)
+}
+
+func (s *ShaderProgram) SetIntArray(key string, values []int32) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeInt, len(values)), values)
+}
+
+func (s *ShaderProgram) SetUintArray(key string, values []uint32) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeUint, len(values)), values)
+}
+
+func (s *ShaderProgram) SetFloatArray(key string, values []float32) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeFloat, len(values)), values)
+}
+
+// SetSamplerArray points consecutive elements of a sampler array at units
+func (s *ShaderProgram) SetSamplerArray(key string, units []int32) {
+	if len(units) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeInt, len(units)), units)
+}
+
+func (s *ShaderProgram) SetVec2Array(key string, values []mgl32.Vec2) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeVec2, len(values)), values)
+}
+
+func (s *ShaderProgram) SetVec3Array(key string, values []mgl32.Vec3) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeVec3, len(values)), values)
+}
+
+func (s *ShaderProgram) SetVec4Array(key string, values []mgl32.Vec4) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeVec4, len(values)), values)
+}
+
+func (s *ShaderProgram) SetIVec2Array(key string, values [][2]int32) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeIVec2, len(values)), values)
+}
+
+func (s *ShaderProgram) SetIVec3Array(key string, values [][3]int32) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeIVec3, len(values)), values)
+}
+
+func (s *ShaderProgram) SetIVec4Array(key string, values [][4]int32) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeIVec4, len(values)), values)
+}
+
+func (s *ShaderProgram) SetUVec2Array(key string, values [][2]uint32) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeUVec2, len(values)), values)
+}
+
+func (s *ShaderProgram) SetUVec3Array(key string, values [][3]uint32) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeUVec3, len(values)), values)
+}
+
+func (s *ShaderProgram) SetUVec4Array(key string, values [][4]uint32) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeUVec4, len(values)), values)
+}
+
+func (s *ShaderProgram) SetBVec2Array(key string, values [][2]bool) {
+	if len(values) == 0 {
+		return
+	}
+	ints := make([][2]int32, len(values))
+	for i, value := range values {
+		for j, b := range value {
+			if b {
+				ints[i][j] = 1
+			}
+		}
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeBVec2, len(values)), ints)
+}
+
+func (s *ShaderProgram) SetBVec3Array(key string, values [][3]bool) {
+	if len(values) == 0 {
+		return
+	}
+	ints := make([][3]int32, len(values))
+	for i, value := range values {
+		for j, b := range value {
+			if b {
+				ints[i][j] = 1
+			}
+		}
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeBVec3, len(values)), ints)
+}
+
+func (s *ShaderProgram) SetBVec4Array(key string, values [][4]bool) {
+	if len(values) == 0 {
+		return
+	}
+	ints := make([][4]int32, len(values))
+	for i, value := range values {
+		for j, b := range value {
+			if b {
+				ints[i][j] = 1
+			}
+		}
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeBVec4, len(values)), ints)
+}
+
+func (s *ShaderProgram) SetMat2Array(key string, values []mgl32.Mat2) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat2, len(values)), values)
+}
+
+func (s *ShaderProgram) SetMat3Array(key string, values []mgl32.Mat3) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat3, len(values)), values)
+}
+
+func (s *ShaderProgram) SetMat4Array(key string, values []mgl32.Mat4) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat4, len(values)), values)
+}
+
+func (s *ShaderProgram) SetMat2x3Array(key string, values []mgl32.Mat2x3) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat2x3, len(values)), values)
+}
+
+func (s *ShaderProgram) SetMat2x4Array(key string, values []mgl32.Mat2x4) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat2x4, len(values)), values)
+}
+
+func (s *ShaderProgram) SetMat3x2Array(key string, values []mgl32.Mat3x2) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat3x2, len(values)), values)
+}
+
+func (s *ShaderProgram) SetMat3x4Array(key string, values []mgl32.Mat3x4) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat3x4, len(values)), values)
+}
+
+func (s *ShaderProgram) SetMat4x2Array(key string, values []mgl32.Mat4x2) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat4x2, len(values)), values)
+}
+
+func (s *ShaderProgram) SetMat4x3Array(key string, values []mgl32.Mat4x3) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeMat4x3, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDoubleArray(key string, values []float64) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDouble, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDVec2Array(key string, values []mgl64.Vec2) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDVec2, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDVec3Array(key string, values []mgl64.Vec3) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDVec3, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDVec4Array(key string, values []mgl64.Vec4) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDVec4, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDMat2Array(key string, values []mgl64.Mat2) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat2, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDMat3Array(key string, values []mgl64.Mat3) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat3, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDMat4Array(key string, values []mgl64.Mat4) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat4, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDMat2x3Array(key string, values []mgl64.Mat2x3) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat2x3, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDMat2x4Array(key string, values []mgl64.Mat2x4) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat2x4, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDMat3x2Array(key string, values []mgl64.Mat3x2) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat3x2, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDMat3x4Array(key string, values []mgl64.Mat3x4) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat3x4, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDMat4x2Array(key string, values []mgl64.Mat4x2) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat4x2, len(values)), values)
+}
+
+func (s *ShaderProgram) SetDMat4x3Array(key string, values []mgl64.Mat4x3) {
+	if len(values) == 0 {
+		return
+	}
+	device.SetUniform(s.uniformLocation(key, gfx.TypeDMat4x3, len(values)), values)
+}
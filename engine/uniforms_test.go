@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/go-gl/mathgl/mgl64"
+	"os"
+	"path/filepath"
+	"physics/gfx"
+	"physics/soft/raster"
+	"reflect"
+	"testing"
+)
+
+// uniformDevice is the software device keeping the last value set at each
+// location
+type uniformDevice struct {
+	*raster.Device
+	values map[int32]interface{}
+}
+
+func (d *uniformDevice) SetUniform(location int32, value interface{}) {
+	d.Device.SetUniform(location, value)
+	if location >= 0 {
+		d.values[location] = value
+	}
+}
+
+func TestUniformSetters(t *testing.T) {
+	d := &uniformDevice{Device: useSoftDevice(t), values: make(map[int32]interface{})}
+	SetDevice(d)
+	d.RegisterProgram("uniforms", &raster.Program{Uniforms: []gfx.ShaderUniform{
+		{Name: "offset", Type: gfx.TypeDVec3, Size: 1, Location: 0, BlockIndex: -1},
+		{Name: "cells", Type: gfx.TypeIVec2, Size: 2, Location: 1, BlockIndex: -1},
+		{Name: "masks", Type: gfx.TypeBVec3, Size: 2, Location: 3, BlockIndex: -1},
+		{Name: "rotations", Type: gfx.TypeMat2, Size: 2, Location: 5, BlockIndex: -1},
+		{Name: "projections", Type: gfx.TypeMat3x2, Size: 2, Location: 7, BlockIndex: -1},
+		{Name: "transform", Type: gfx.TypeDMat4x3, Size: 1, Location: 9, BlockIndex: -1},
+	}})
+	path := filepath.Join(t.TempDir(), "uniforms.glsl")
+	if err := os.WriteFile(path, []byte("#version 410 core\n// soft: uniforms\nvoid main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	shader, err := NewShaderProgram(path, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shader.Release()
+	shader.Use()
+
+	shader.SetDVec3("offset", mgl64.Vec3{1, 2, 3})
+	shader.SetIVec2Array("cells", [][2]int32{{1, 2}, {3, 4}})
+	shader.SetBVec3Array("masks", [][3]bool{{true, false, true}, {false, true, false}})
+	shader.SetMat2Array("rotations", []mgl32.Mat2{mgl32.Ident2(), mgl32.Ident2().Mul(2)})
+	shader.SetMat3x2Array("projections", []mgl32.Mat3x2{{1, 2, 3, 4, 5, 6}, {}})
+	shader.SetDMat4x3("transform", mgl64.Mat4x3{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12})
+	want := map[int32]interface{}{
+		0: mgl64.Vec3{1, 2, 3},
+		1: [][2]int32{{1, 2}, {3, 4}},
+		3: [][3]int32{{1, 0, 1}, {0, 1, 0}},
+		5: []mgl32.Mat2{mgl32.Ident2(), mgl32.Ident2().Mul(2)},
+		7: []mgl32.Mat3x2{{1, 2, 3, 4, 5, 6}, {}},
+		9: mgl64.Mat4x3{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+	}
+	if !reflect.DeepEqual(d.values, want) {
+		t.Errorf("set %v, want %v", d.values, want)
+	}
+
+	// A value of the wrong type is dropped with a warning
+	shader.SetDouble("cells", 1)
+	if _, ok := d.values[1].([][2]int32); !ok {
+		t.Errorf("double set on an ivec2 array uniform, now %v", d.values[1])
+	}
+}
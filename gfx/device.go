@@ -0,0 +1,221 @@
+// Package gfx is the graphics device API the engine draws through. Its types
+// don't depend on OpenGL or cgo, so devices and tools that only replay or
+// rasterize frames build without a GL driver.
+package gfx
+
+// Device is the graphics API every GPU resource and draw of the engine goes
+// through. Handles are object names the device chooses and buffer data is
+// passed as Go slices or pointers. engine.GLDevice is the OpenGL 4.1
+// implementation; engine.SetDevice substitutes another, such as a recorder
+// wrapping it or soft/raster's Device drawing on the CPU, before any resource
+// is created.
+type Device interface {
+	// CreateBuffer allocates size bytes of kind, filled from data when it
+	// isn't nil
+	CreateBuffer(kind BufferKind, size int, data interface{}, usage BufferUsage) uint32
+	// WriteBuffer replaces size bytes of the buffer's contents from offset
+	WriteBuffer(kind BufferKind, buffer uint32, offset, size int, data interface{})
+	// ReallocateBuffer replaces the buffer's storage with size bytes of data
+	ReallocateBuffer(kind BufferKind, buffer uint32, size int, data interface{}, usage BufferUsage)
+	DeleteBuffer(buffer uint32)
+	// BindUniformBuffer attaches buffer to a uniform block binding point
+	BindUniformBuffer(binding, buffer uint32)
+
+	CreateVertexArray(layout VertexLayout) uint32
+	DeleteVertexArray(vertexArray uint32)
+
+	// CreateProgram compiles and links a program. A stage that fails returns a
+	// *ShaderError with the driver's info log and no lines.
+	CreateProgram(vertexSource, fragmentSource string) (uint32, error)
+	DeleteProgram(program uint32)
+	// ReflectProgram lists the active uniforms, uniform blocks and attributes
+	// of a linked program
+	ReflectProgram(program uint32) ProgramReflection
+	BindUniformBlock(program, blockIndex, binding uint32)
+	// FragDataLocation returns the location of a fragment output, -1 when the
+	// program doesn't write it
+	FragDataLocation(program uint32, name string) int32
+	// UseProgram makes program current for draws and SetUniform, 0 for none
+	UseProgram(program uint32)
+	// SetUniform sets the uniform at location of the current program. value is
+	// one of the types the engine's ShaderProgram setters take; locations below
+	// 0 are ignored, as are values of other types.
+	SetUniform(location int32, value interface{})
+
+	// CreateTexture creates a texture sampling levels mip levels
+	CreateTexture(target TextureTarget, levels int32) uint32
+	SetSampler(texture uint32, target TextureTarget, sampler SamplerState)
+	// TexImage2D uploads level of a 2D texture or of the cube map face target.
+	// pixels may be nil to allocate it uninitialised.
+	TexImage2D(texture uint32, target TextureTarget, level int32, format TexelFormat, width, height int32, pixels interface{})
+	// TexImage3D allocates level of a texture array, filled from pixels when
+	// it isn't nil
+	TexImage3D(texture uint32, target TextureTarget, level int32, format TexelFormat, width, height, depth int32, pixels interface{})
+	// TexLayer uploads one layer of level of a texture array
+	TexLayer(texture uint32, target TextureTarget, level, layer int32, format TexelFormat, width, height int32, pixels interface{})
+	// CompressedTexImage2D uploads level of a block compressed 2D texture or
+	// cube map face
+	CompressedTexImage2D(texture uint32, target TextureTarget, level int32, format TexelFormat, width, height int32, data []byte)
+	GenerateMipmaps(texture uint32, target TextureTarget)
+	// BindTexture makes texture current on a texture unit
+	BindTexture(unit int, target TextureTarget, texture uint32)
+	DeleteTexture(texture uint32)
+	// MaxAnisotropy returns the anisotropic filtering limit, 0 when anisotropic
+	// filtering isn't available
+	MaxAnisotropy() float32
+	// FormatSupported reports whether textures can be created in format
+	FormatSupported(format TextureFormat) bool
+
+	CreateRenderbuffer(format TexelFormat, width, height, samples int32) uint32
+	DeleteRenderbuffer(renderbuffer uint32)
+	MaxSamples() int32
+	// CreateFramebuffer creates a framebuffer drawing fragment output n to its
+	// nth color attachment. It fails when the attachments are incomplete; a
+	// framebuffer without any has them attached later with AttachLayer.
+	CreateFramebuffer(attachments []FramebufferAttachment) (uint32, error)
+	// AttachLayer attaches one layer of a texture array to a framebuffer
+	AttachLayer(framebuffer uint32, attachment Attachment, texture uint32, layer int32)
+	// BindFramebuffer directs draws and reads to framebuffer, 0 for the window
+	BindFramebuffer(framebuffer uint32)
+	// BlitFramebuffer copies the buffers in mask from one framebuffer to
+	// another of the same size. attachment picks the color attachment copied
+	// on both, or -1 for their current ones.
+	BlitFramebuffer(from, to uint32, width, height int32, mask BufferMask, attachment int)
+	DeleteFramebuffer(framebuffer uint32)
+	// ReadPixels reads RGBA8 pixels from color attachment n of framebuffer,
+	// or from the window's back buffer when framebuffer is 0, bottom row first
+	ReadPixels(framebuffer uint32, attachment int, width, height int32, dst []byte)
+	// SetFramebufferSRGB makes draws to sRGB attachments and the window encode
+	// linear colors to sRGB
+	SetFramebufferSRGB(enabled bool)
+
+	Viewport(x, y, width, height int32)
+	Clear(mask BufferMask)
+	SetPipeline(state PipelineState)
+	// Pipeline returns the state last set
+	Pipeline() PipelineState
+	Draw(call DrawCall)
+}
+
+// VertexLayout says where a vertex array's attributes are read from
+type VertexLayout struct {
+	Attributes []VertexAttribute
+	// IndexBuffer holds the indices of indexed draws, 0 for none
+	IndexBuffer uint32
+}
+
+// VertexAttribute feeds a shader input location from floats in a buffer
+type VertexAttribute struct {
+	Location   uint32
+	Buffer     uint32
+	Components int32
+	// Stride is the distance between vertices in bytes, 0 when tightly packed
+	Stride int32
+	Offset int
+	// Divisor advances the attribute once per that many instances instead of
+	// once per vertex
+	Divisor uint32
+}
+
+// ProgramReflection is what a device reports about a linked program
+type ProgramReflection struct {
+	Uniforms      []ShaderUniform
+	UniformBlocks []ShaderUniformBlock
+	Attributes    []ShaderAttribute
+	// Locations holds the location of every uniform outside a block and of
+	// each element of uniform arrays, e.g. "lights[1]"
+	Locations map[string]int32
+}
+
+// ShaderUniform describes an active uniform found when the program was linked.
+// Uniforms declared inside a uniform block have Location -1, a BlockIndex and
+// their byte layout within the block.
+type ShaderUniform struct {
+	Name       string
+	Type       DataType
+	Size       int32
+	Location   int32
+	BlockIndex int32
+
+	Offset       int32
+	ArrayStride  int32
+	MatrixStride int32
+}
+
+type ShaderUniformBlock struct {
+	Name     string
+	Index    uint32
+	DataSize int32
+	Binding  int32
+}
+
+type ShaderAttribute struct {
+	Name     string
+	Type     DataType
+	Size     int32
+	Location int32
+}
+
+// TexelFormat is how a texture stores texels and how uploads lay them out.
+// Uploads of 8-bit formats are []uint8, of 16-bit ones []uint16 and of float
+// and depth ones []float32.
+type TexelFormat struct {
+	Format     TextureFormat
+	ColorSpace ColorSpace
+}
+
+// FramebufferAttachment attaches a texture or a renderbuffer at attachment
+type FramebufferAttachment struct {
+	Attachment   Attachment
+	Texture      uint32
+	Renderbuffer uint32
+}
+
+// BlendState is the blend equation's factors, used when Enabled
+type BlendState struct {
+	Enabled  bool
+	SrcColor BlendFactor
+	DstColor BlendFactor
+	SrcAlpha BlendFactor
+	DstAlpha BlendFactor
+}
+
+// PipelineState is the fixed function state draws are made with
+type PipelineState struct {
+	DepthTest  bool
+	DepthFunc  CompareFunc
+	DepthWrite bool
+	// DepthClamp clamps depth to the near and far planes instead of clipping
+	// what lies beyond them
+	DepthClamp bool
+	Cull       CullMode
+	Blend      BlendState
+	Wireframe  bool
+	// PolygonOffsetFactor and PolygonOffsetUnits push depth away from the
+	// camera, disabled when both are zero
+	PolygonOffsetFactor float32
+	PolygonOffsetUnits  float32
+}
+
+// DefaultPipelineState is the state renderers leave between passes: depth
+// tested with CompareLess and written, no blending or culling
+func DefaultPipelineState() PipelineState {
+	return PipelineState{DepthTest: true, DepthFunc: CompareLess, DepthWrite: true}
+}
+
+// AdditiveBlend adds colors to what is drawn, for accumulating light
+func AdditiveBlend() BlendState {
+	return BlendState{Enabled: true, SrcColor: FactorOne, DstColor: FactorOne, SrcAlpha: FactorOne, DstAlpha: FactorOne}
+}
+
+// DrawCall draws primitives from a vertex array with the current program
+type DrawCall struct {
+	VertexArray uint32
+	Mode        PrimitiveMode
+	Count       int32
+	// Indexed draws read Count unsigned int indices from the vertex array's
+	// index buffer
+	Indexed bool
+	// Instances above 0 draw that many instances
+	Instances int32
+}
@@ -0,0 +1,273 @@
+package gfx
+
+import "fmt"
+
+// BufferKind is what a buffer holds
+type BufferKind int
+
+const (
+	BufferVertices BufferKind = iota
+	BufferIndices
+	BufferUniforms
+)
+
+// BufferUsage hints how often a buffer's contents change
+type BufferUsage int
+
+const (
+	// UsageStatic buffers are written once and drawn many times
+	UsageStatic BufferUsage = iota
+	// UsageDynamic buffers are rewritten now and then
+	UsageDynamic
+	// UsageStream buffers are rewritten every frame
+	UsageStream
+)
+
+// TextureTarget is the kind of a texture, or a face of a cube map for uploads
+type TextureTarget int
+
+const (
+	Texture2D TextureTarget = iota
+	TextureCubeMap
+	Texture2DArray
+	TextureCubeMapArray
+
+	// Cube map faces in upload order, TextureCubeMapPositiveX+TextureTarget(i)
+	// is face i
+	TextureCubeMapPositiveX
+	TextureCubeMapNegativeX
+	TextureCubeMapPositiveY
+	TextureCubeMapNegativeY
+	TextureCubeMapPositiveZ
+	TextureCubeMapNegativeZ
+)
+
+// IsCubeMapFace reports whether t is a single face of a cube map
+func (t TextureTarget) IsCubeMapFace() bool {
+	return t >= TextureCubeMapPositiveX && t <= TextureCubeMapNegativeZ
+}
+
+// Attachment is a framebuffer attachment point
+type Attachment int
+
+const (
+	AttachmentNone Attachment = iota
+	AttachmentDepth
+	AttachmentDepthStencil
+	// AttachmentColor0 is the first color attachment, ColorAttachment(n) the nth
+	AttachmentColor0
+)
+
+func ColorAttachment(n int) Attachment {
+	return AttachmentColor0 + Attachment(n)
+}
+
+// IsColor reports whether a is a color attachment
+func (a Attachment) IsColor() bool {
+	return a >= AttachmentColor0
+}
+
+// BufferMask selects the buffers of a framebuffer to clear or copy
+type BufferMask int
+
+const (
+	ColorBuffer BufferMask = 1 << iota
+	DepthBuffer
+	StencilBuffer
+)
+
+// CompareFunc is the test a depth value must pass against the stored one
+type CompareFunc int
+
+const (
+	CompareLess CompareFunc = iota
+	CompareNever
+	CompareEqual
+	CompareLessEqual
+	CompareGreater
+	CompareNotEqual
+	CompareGreaterEqual
+	CompareAlways
+)
+
+// BlendFactor scales the source or destination color of the blend equation
+type BlendFactor int
+
+const (
+	FactorZero BlendFactor = iota
+	FactorOne
+	FactorSrcColor
+	FactorOneMinusSrcColor
+	FactorDstColor
+	FactorOneMinusDstColor
+	FactorSrcAlpha
+	FactorOneMinusSrcAlpha
+	FactorDstAlpha
+	FactorOneMinusDstAlpha
+)
+
+// CullMode selects which faces are discarded before rasterization
+type CullMode int
+
+const (
+	CullNone CullMode = iota
+	CullBack
+	CullFront
+)
+
+// PrimitiveMode is how a draw assembles vertices into primitives
+type PrimitiveMode int
+
+const (
+	PrimitiveTriangles PrimitiveMode = iota
+	PrimitivePoints
+	PrimitiveLines
+	PrimitiveLineLoop
+	PrimitiveLineStrip
+	PrimitiveTriangleStrip
+	PrimitiveTriangleFan
+)
+
+var primitiveNames = [...]string{
+	PrimitiveTriangles:     "triangles",
+	PrimitivePoints:        "points",
+	PrimitiveLines:         "lines",
+	PrimitiveLineLoop:      "line loop",
+	PrimitiveLineStrip:     "line strip",
+	PrimitiveTriangleStrip: "triangle strip",
+	PrimitiveTriangleFan:   "triangle fan",
+}
+
+func (m PrimitiveMode) String() string {
+	if m >= 0 && int(m) < len(primitiveNames) {
+		return primitiveNames[m]
+	}
+	return fmt.Sprintf("mode %d", int(m))
+}
+
+// DataType is the type of a shader uniform or attribute
+type DataType int
+
+const (
+	TypeUnknown DataType = iota
+	TypeFloat
+	TypeVec2
+	TypeVec3
+	TypeVec4
+	TypeDouble
+	TypeDVec2
+	TypeDVec3
+	TypeDVec4
+	TypeInt
+	TypeIVec2
+	TypeIVec3
+	TypeIVec4
+	TypeUint
+	TypeUVec2
+	TypeUVec3
+	TypeUVec4
+	TypeBool
+	TypeBVec2
+	TypeBVec3
+	TypeBVec4
+	TypeMat2
+	TypeMat3
+	TypeMat4
+	TypeMat2x3
+	TypeMat2x4
+	TypeMat3x2
+	TypeMat3x4
+	TypeMat4x2
+	TypeMat4x3
+	TypeDMat2
+	TypeDMat3
+	TypeDMat4
+	TypeDMat2x3
+	TypeDMat2x4
+	TypeDMat3x2
+	TypeDMat3x4
+	TypeDMat4x2
+	TypeDMat4x3
+
+	// Sampler types, see IsSampler
+	TypeSampler2D
+	TypeSampler3D
+	TypeSamplerCube
+	TypeSampler2DShadow
+	TypeSampler2DArray
+	TypeSampler2DArrayShadow
+	TypeSamplerCubeShadow
+	TypeSamplerCubeArray
+	TypeSamplerCubeArrayShadow
+	TypeSampler2DMS
+	TypeISampler2D
+	TypeUSampler2D
+)
+
+var dataTypeNames = [...]string{
+	TypeUnknown:                "unknown",
+	TypeFloat:                  "float",
+	TypeVec2:                   "vec2",
+	TypeVec3:                   "vec3",
+	TypeVec4:                   "vec4",
+	TypeDouble:                 "double",
+	TypeDVec2:                  "dvec2",
+	TypeDVec3:                  "dvec3",
+	TypeDVec4:                  "dvec4",
+	TypeInt:                    "int",
+	TypeIVec2:                  "ivec2",
+	TypeIVec3:                  "ivec3",
+	TypeIVec4:                  "ivec4",
+	TypeUint:                   "uint",
+	TypeUVec2:                  "uvec2",
+	TypeUVec3:                  "uvec3",
+	TypeUVec4:                  "uvec4",
+	TypeBool:                   "bool",
+	TypeBVec2:                  "bvec2",
+	TypeBVec3:                  "bvec3",
+	TypeBVec4:                  "bvec4",
+	TypeMat2:                   "mat2",
+	TypeMat3:                   "mat3",
+	TypeMat4:                   "mat4",
+	TypeMat2x3:                 "mat2x3",
+	TypeMat2x4:                 "mat2x4",
+	TypeMat3x2:                 "mat3x2",
+	TypeMat3x4:                 "mat3x4",
+	TypeMat4x2:                 "mat4x2",
+	TypeMat4x3:                 "mat4x3",
+	TypeDMat2:                  "dmat2",
+	TypeDMat3:                  "dmat3",
+	TypeDMat4:                  "dmat4",
+	TypeDMat2x3:                "dmat2x3",
+	TypeDMat2x4:                "dmat2x4",
+	TypeDMat3x2:                "dmat3x2",
+	TypeDMat3x4:                "dmat3x4",
+	TypeDMat4x2:                "dmat4x2",
+	TypeDMat4x3:                "dmat4x3",
+	TypeSampler2D:              "sampler2D",
+	TypeSampler3D:              "sampler3D",
+	TypeSamplerCube:            "samplerCube",
+	TypeSampler2DShadow:        "sampler2DShadow",
+	TypeSampler2DArray:         "sampler2DArray",
+	TypeSampler2DArrayShadow:   "sampler2DArrayShadow",
+	TypeSamplerCubeShadow:      "samplerCubeShadow",
+	TypeSamplerCubeArray:       "samplerCubeArray",
+	TypeSamplerCubeArrayShadow: "samplerCubeArrayShadow",
+	TypeSampler2DMS:            "sampler2DMS",
+	TypeISampler2D:             "isampler2D",
+	TypeUSampler2D:             "usampler2D",
+}
+
+// String returns the GLSL name of the type
+func (t DataType) String() string {
+	if t >= 0 && int(t) < len(dataTypeNames) {
+		return dataTypeNames[t]
+	}
+	return fmt.Sprintf("type %d", int(t))
+}
+
+// IsSampler reports whether t is one of the sampler types, set as an int
+// texture unit
+func (t DataType) IsSampler() bool {
+	return t >= TypeSampler2D && t <= TypeUSampler2D
+}
@@ -0,0 +1,51 @@
+package gfx
+
+// RecordedFrame is the calls a recording device saw between two frame ends.
+// Frame 0 also holds the resources created before the first frame. It is
+// written by engine.RecordingDevice and read by cmd/framedump.
+type RecordedFrame struct {
+	Frame    int               `json:"frame"`
+	Commands []RecordedCommand `json:"commands"`
+}
+
+// RecordedCommand is one call made to the device
+type RecordedCommand struct {
+	Call string                 `json:"call"`
+	Args map[string]interface{} `json:"args,omitempty"`
+	// Result is the handle of the resource a call created
+	Result uint32 `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Data is what was written to a buffer, when recording buffer data
+	Data []byte `json:"data,omitempty"`
+	// State is what a draw was made with
+	State *DrawState `json:"state,omitempty"`
+	// Reflection is what ReflectProgram returned, which lays out the
+	// program's uniform blocks
+	Reflection *ProgramReflection `json:"reflection,omitempty"`
+}
+
+// DrawState is the state bound when a draw was made
+type DrawState struct {
+	Program uint32 `json:"program"`
+	// Uniforms holds the values set on the program by uniform name
+	Uniforms       map[string]RecordedUniform `json:"uniforms"`
+	Textures       map[int]BoundTexture       `json:"textures"`
+	UniformBuffers map[uint32]uint32          `json:"uniformBuffers"`
+	Framebuffer    uint32                     `json:"framebuffer"`
+	Viewport       [4]int32                   `json:"viewport"`
+	Pipeline       PipelineState              `json:"pipeline"`
+	VertexLayout   VertexLayout               `json:"vertexLayout"`
+}
+
+// RecordedUniform is a uniform value printed with its Go type, which keeps
+// NaNs JSON can't encode
+type RecordedUniform struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// BoundTexture is the texture bound on a texture unit
+type BoundTexture struct {
+	Target  TextureTarget `json:"target"`
+	Texture uint32        `json:"texture"`
+}
@@ -0,0 +1,61 @@
+package gfx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ShaderSourceLocation is a line in one of the files a shader was assembled from
+type ShaderSourceLocation struct {
+	File string
+	Line int
+}
+
+func (l ShaderSourceLocation) String() string {
+	if l.File == "" {
+		return fmt.Sprintf("line %d", l.Line)
+	}
+	if l.Line == 0 {
+		return l.File
+	}
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+// ShaderErrorLine is a single diagnostic from the driver, with its line number
+// mapped back through includes to the original file.
+type ShaderErrorLine struct {
+	ShaderSourceLocation
+	Severity string
+	Message  string
+}
+
+// ShaderError is returned when a shader fails to preprocess, compile or link.
+// Log holds the complete, unmodified driver info log.
+type ShaderError struct {
+	Stage string
+	Log   string
+	Lines []ShaderErrorLine
+}
+
+func (e *ShaderError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s shader failed", e.Stage)
+	if len(e.Lines) == 0 {
+		b.WriteString(": ")
+		b.WriteString(e.Log)
+		return b.String()
+	}
+	for _, line := range e.Lines {
+		b.WriteString("\n\t")
+		if line.File != "" || line.Line != 0 {
+			b.WriteString(line.ShaderSourceLocation.String())
+			b.WriteString(": ")
+		}
+		if line.Severity != "" {
+			b.WriteString(line.Severity)
+			b.WriteString(": ")
+		}
+		b.WriteString(line.Message)
+	}
+	return b.String()
+}
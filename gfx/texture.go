@@ -0,0 +1,111 @@
+package gfx
+
+type TextureFormat int
+
+const (
+	TextureFormatRGBA8 TextureFormat = iota
+	TextureFormatRGB8
+	TextureFormatRG8
+	TextureFormatR8
+	TextureFormatRGBA16
+	TextureFormatR16
+	TextureFormatRGBA16F
+	TextureFormatRGB16F
+	TextureFormatRG16F
+	TextureFormatR16F
+	TextureFormatRGBA32F
+	TextureFormatRGB32F
+	TextureFormatR32F
+	TextureFormatDepth24
+	TextureFormatDepth32F
+	TextureFormatDepth24Stencil8
+
+	// Block compressed formats, see engine/compressedtexture.go
+	TextureFormatBC1
+	TextureFormatBC1A
+	TextureFormatBC2
+	TextureFormatBC3
+	TextureFormatBC4
+	TextureFormatBC4S
+	TextureFormatBC5
+	TextureFormatBC5S
+	TextureFormatBC6H
+	TextureFormatBC6HS
+	TextureFormatBC7
+)
+
+// IsCompressed reports whether f stores 4x4 blocks instead of texels
+func (f TextureFormat) IsCompressed() bool {
+	return f >= TextureFormatBC1 && f <= TextureFormatBC7
+}
+
+// IsDepth reports whether f holds depth, and perhaps stencil
+func (f TextureFormat) IsDepth() bool {
+	return f >= TextureFormatDepth24 && f <= TextureFormatDepth24Stencil8
+}
+
+// HasSRGB reports whether f can be stored in ColorSpaceSRGB
+func (f TextureFormat) HasSRGB() bool {
+	switch f {
+	case TextureFormatRGBA8, TextureFormatRGB8, TextureFormatBC1, TextureFormatBC1A,
+		TextureFormatBC2, TextureFormatBC3, TextureFormatBC7:
+		return true
+	}
+	return false
+}
+
+// ColorSpace says how 8-bit color data is encoded. sRGB textures are converted
+// to linear by the hardware when sampled.
+type ColorSpace int
+
+const (
+	ColorSpaceLinear ColorSpace = iota
+	ColorSpaceSRGB
+)
+
+// WrapMode is how texture coordinates outside [0, 1] are sampled
+type WrapMode int
+
+const (
+	// WrapUnset is replaced with WrapClampToEdge by engine.Texture.SetSampler
+	WrapUnset WrapMode = iota
+	WrapRepeat
+	WrapClampToEdge
+	WrapClampToBorder
+	WrapMirroredRepeat
+)
+
+// FilterMode is how texels are combined when a texture is magnified or
+// minified
+type FilterMode int
+
+const (
+	// FilterUnset is replaced with FilterLinear by engine.Texture.SetSampler
+	FilterUnset FilterMode = iota
+	FilterNearest
+	FilterLinear
+	FilterNearestMipmapNearest
+	FilterLinearMipmapNearest
+	FilterNearestMipmapLinear
+	FilterLinearMipmapLinear
+)
+
+// Base returns the filter without mip mapping
+func (f FilterMode) Base() FilterMode {
+	switch f {
+	case FilterNearestMipmapNearest, FilterNearestMipmapLinear:
+		return FilterNearest
+	case FilterLinearMipmapNearest, FilterLinearMipmapLinear:
+		return FilterLinear
+	}
+	return f
+}
+
+type SamplerState struct {
+	WrapS      WrapMode
+	WrapT      WrapMode
+	WrapR      WrapMode
+	MinFilter  FilterMode
+	MagFilter  FilterMode
+	Anisotropy float32
+}
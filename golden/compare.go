@@ -0,0 +1,109 @@
+package golden
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// maxYIQDelta is the largest squared YIQ distance between two colors, between
+// black and white
+const maxYIQDelta = 35215.0
+
+// Tolerance decides when a rendered image still matches its golden image
+type Tolerance struct {
+	// Threshold is the perceptual color difference, from 0 to 1, below which
+	// two pixels count as equal. It absorbs rounding and dithering between GL
+	// implementations.
+	Threshold float64
+	// MaxDiffPixels is the fraction of pixels, from 0 to 1, allowed to differ
+	// by more than Threshold, for edges rasterized slightly differently
+	MaxDiffPixels float64
+}
+
+func DefaultTolerance() Tolerance {
+	return Tolerance{Threshold: 0.1, MaxDiffPixels: 0.002}
+}
+
+type Comparison struct {
+	DiffPixels  int
+	TotalPixels int
+	// MaxDelta is the largest difference found, on the Threshold scale
+	MaxDelta float64
+	// Diff shows the golden image faded to gray with the differing pixels in
+	// red
+	Diff *image.RGBA
+}
+
+func (c Comparison) DiffFraction() float64 {
+	if c.TotalPixels == 0 {
+		return 0
+	}
+	return float64(c.DiffPixels) / float64(c.TotalPixels)
+}
+
+// Matches reports whether the comparison is within tolerance
+func (c Comparison) Matches(tolerance Tolerance) bool {
+	return c.DiffFraction() <= tolerance.MaxDiffPixels
+}
+
+// Compare measures how different got is from want. Colors are compared in the
+// YIQ space, which weighs brightness over hue the way the eye does.
+func Compare(got, want image.Image, tolerance Tolerance) (Comparison, error) {
+	bounds := want.Bounds()
+	if got.Bounds().Dx() != bounds.Dx() || got.Bounds().Dy() != bounds.Dy() {
+		return Comparison{}, fmt.Errorf("image is %dx%d, golden image %dx%d",
+			got.Bounds().Dx(), got.Bounds().Dy(), bounds.Dx(), bounds.Dy())
+	}
+
+	c := Comparison{
+		TotalPixels: bounds.Dx() * bounds.Dy(),
+		Diff:        image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy())),
+	}
+	limit := maxYIQDelta * tolerance.Threshold * tolerance.Threshold
+	offset := got.Bounds().Min.Sub(bounds.Min)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			wantColor := color.NRGBAModel.Convert(want.At(x, y)).(color.NRGBA)
+			gotColor := color.NRGBAModel.Convert(got.At(x+offset.X, y+offset.Y)).(color.NRGBA)
+			delta := yiqDelta(gotColor, wantColor)
+
+			diffX, diffY := x-bounds.Min.X, y-bounds.Min.Y
+			if delta > limit {
+				c.DiffPixels++
+				c.Diff.SetRGBA(diffX, diffY, color.RGBA{R: 255, A: 255})
+			} else {
+				gray := uint8(255 - (255-luma(wantColor))/10)
+				c.Diff.SetRGBA(diffX, diffY, color.RGBA{R: gray, G: gray, B: gray, A: 255})
+			}
+			if scaled := math.Sqrt(delta / maxYIQDelta); scaled > c.MaxDelta {
+				c.MaxDelta = scaled
+			}
+		}
+	}
+	return c, nil
+}
+
+// blend composites c over white, so transparent pixels compare by what they
+// would look like
+func blend(c color.NRGBA) (float64, float64, float64) {
+	a := float64(c.A) / 255
+	return 255 + (float64(c.R)-255)*a, 255 + (float64(c.G)-255)*a, 255 + (float64(c.B)-255)*a
+}
+
+func yiqDelta(a, b color.NRGBA) float64 {
+	r1, g1, b1 := blend(a)
+	r2, g2, b2 := blend(b)
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+
+	y := dr*0.29889531 + dg*0.58662247 + db*0.11448223
+	i := dr*0.59597799 - dg*0.27417610 - db*0.32180189
+	q := dr*0.21147017 - dg*0.52261711 + db*0.31114694
+	return 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+}
+
+func luma(c color.NRGBA) uint8 {
+	r, g, b := blend(c)
+	return uint8(r*0.29889531 + g*0.58662247 + b*0.11448223)
+}
@@ -0,0 +1,153 @@
+package golden
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// solid returns an image of bounds filled with c
+func solid(bounds image.Rectangle, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+var (
+	black = color.NRGBA{A: 255}
+	white = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	red   = color.RGBA{R: 255, A: 255}
+)
+
+func TestCompareIdentical(t *testing.T) {
+	img := solid(image.Rect(0, 0, 8, 8), color.NRGBA{R: 40, G: 90, B: 200, A: 255})
+	c, err := Compare(img, img, DefaultTolerance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DiffPixels != 0 || c.MaxDelta != 0 {
+		t.Errorf("identical images: %d pixels differ, max difference %v", c.DiffPixels, c.MaxDelta)
+	}
+	if c.TotalPixels != 64 {
+		t.Errorf("got %d pixels, want 64", c.TotalPixels)
+	}
+	if !c.Matches(DefaultTolerance()) {
+		t.Error("identical images don't match")
+	}
+}
+
+func TestCompareSizeMismatch(t *testing.T) {
+	got := solid(image.Rect(0, 0, 8, 8), black)
+	want := solid(image.Rect(0, 0, 8, 9), black)
+	if _, err := Compare(got, want, DefaultTolerance()); err == nil {
+		t.Error("images of different sizes compared without an error")
+	}
+}
+
+func TestCompareDifferentPixel(t *testing.T) {
+	want := solid(image.Rect(0, 0, 4, 4), black)
+	got := solid(image.Rect(0, 0, 4, 4), black)
+	got.SetNRGBA(1, 2, white)
+
+	c, err := Compare(got, want, DefaultTolerance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DiffPixels != 1 {
+		t.Errorf("got %d differing pixels, want 1", c.DiffPixels)
+	}
+	if diff := c.Diff.RGBAAt(1, 2); diff != red {
+		t.Errorf("differing pixel drawn %v in the diff, want red", diff)
+	}
+	if diff := c.Diff.RGBAAt(0, 0); diff == red {
+		t.Error("equal pixel drawn red in the diff")
+	}
+	// Black to white is close to the largest difference there is
+	if c.MaxDelta < 0.9 || c.MaxDelta > 1 {
+		t.Errorf("black against white: max difference %v, want close to 1", c.MaxDelta)
+	}
+	if c.Matches(Tolerance{Threshold: 0.1, MaxDiffPixels: 0}) {
+		t.Error("a differing pixel matches with no pixels allowed to differ")
+	}
+}
+
+func TestCompareBelowThreshold(t *testing.T) {
+	want := solid(image.Rect(0, 0, 4, 4), color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+	got := solid(image.Rect(0, 0, 4, 4), color.NRGBA{R: 102, G: 101, B: 100, A: 255})
+
+	c, err := Compare(got, want, DefaultTolerance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DiffPixels != 0 {
+		t.Errorf("rounding differences: %d pixels counted, want 0", c.DiffPixels)
+	}
+	if c.MaxDelta <= 0 || c.MaxDelta >= DefaultTolerance().Threshold {
+		t.Errorf("max difference %v, want between 0 and the threshold", c.MaxDelta)
+	}
+
+	// The same difference counts once the threshold is stricter than it
+	c, err = Compare(got, want, Tolerance{Threshold: c.MaxDelta / 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DiffPixels != 16 {
+		t.Errorf("strict threshold: %d pixels counted, want 16", c.DiffPixels)
+	}
+}
+
+func TestCompareTransparent(t *testing.T) {
+	// Transparent pixels are compared composited over white
+	got := solid(image.Rect(0, 0, 4, 4), color.NRGBA{R: 0, G: 0, B: 0, A: 0})
+	want := solid(image.Rect(0, 0, 4, 4), white)
+
+	c, err := Compare(got, want, DefaultTolerance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DiffPixels != 0 || c.MaxDelta != 0 {
+		t.Errorf("transparent against white: %d pixels differ, max difference %v", c.DiffPixels, c.MaxDelta)
+	}
+}
+
+func TestCompareOffsetBounds(t *testing.T) {
+	want := solid(image.Rect(0, 0, 4, 4), black)
+	want.SetNRGBA(3, 0, white)
+	got := solid(image.Rect(10, 20, 14, 24), black)
+	got.SetNRGBA(13, 20, white)
+
+	c, err := Compare(got, want, DefaultTolerance())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DiffPixels != 0 {
+		t.Errorf("images at different origins: %d pixels differ, want 0", c.DiffPixels)
+	}
+	if c.Diff.Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Errorf("diff bounds %v, want %v", c.Diff.Bounds(), image.Rect(0, 0, 4, 4))
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tolerance := Tolerance{Threshold: 0.1, MaxDiffPixels: 0.002}
+	for _, test := range []struct {
+		diff  int
+		match bool
+	}{
+		{0, true},
+		{2, true},
+		{3, false},
+	} {
+		c := Comparison{DiffPixels: test.diff, TotalPixels: 1000}
+		if got := c.Matches(tolerance); got != test.match {
+			t.Errorf("%d of 1000 pixels differ: match %v, want %v", test.diff, got, test.match)
+		}
+	}
+	if !(Comparison{}).Matches(Tolerance{}) {
+		t.Error("an empty comparison doesn't match")
+	}
+}
@@ -0,0 +1,92 @@
+package golden
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"os"
+	"physics/engine"
+	"physics/pbr"
+	"runtime"
+	"testing"
+)
+
+var (
+	update   = flag.Bool("update", false, "overwrite the golden images with the rendered ones")
+	deferred = flag.Bool("deferred", false, "render with the deferred renderer")
+)
+
+func init() {
+	// GLFW event handling must be run on the main OS thread
+	runtime.LockOSThread()
+}
+
+// results are rendered by TestMain, which runs on the main thread the GL
+// context needs, and checked by TestGolden
+var (
+	results []Result
+	runErr  error
+	// remaining is what Assets still holds after the run
+	remaining engine.AssetStats
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	// Cases load their assets relative to the repository root
+	if err := os.Chdir(".."); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts := DefaultOptions()
+	opts.Update = *update
+	opts.Deferred = *deferred
+	results, runErr = Run(opts, Cases)
+	// pbr keeps its default shader for the process, drop it before counting
+	if pbr.DefaultPbrShaderProgram != nil {
+		pbr.DefaultPbrShaderProgram.Release()
+		pbr.DefaultPbrShaderProgram = nil
+	}
+	remaining = engine.Assets.Stats()
+	glfw.Terminate()
+	os.Exit(m.Run())
+}
+
+func TestGolden(t *testing.T) {
+	if errors.Is(runErr, ErrNoContext) {
+		t.Skip(runErr)
+	}
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+	tolerance := DefaultOptions().Tolerance
+	for _, result := range results {
+		result := result
+		t.Run(result.Case, func(t *testing.T) {
+			switch {
+			case result.Updated:
+				t.Logf("updated %s", result.Case)
+			case result.Err != nil:
+				t.Fatal(result.Err)
+			case !result.Passed(tolerance):
+				c := result.Comparison
+				t.Errorf("%.3f%% of pixels differ, max difference %.3f, see %s",
+					c.DiffFraction()*100, c.MaxDelta, result.Diff)
+			}
+		})
+	}
+}
+
+// TestGoldenReleasesAssets checks that the run frees everything the cases,
+// renderer and post-processor loaded
+func TestGoldenReleasesAssets(t *testing.T) {
+	if errors.Is(runErr, ErrNoContext) {
+		t.Skip(runErr)
+	}
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+	if remaining != (engine.AssetStats{}) {
+		t.Errorf("%+v still loaded after the run", remaining)
+	}
+}
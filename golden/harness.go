@@ -0,0 +1,202 @@
+// Package golden renders reference scenes through the headless path and
+// compares them against stored golden images, as a rendering regression test.
+// Run it with cmd/golden or go test, which skips when no GL context can be
+// created; on a machine without a GPU use software Mesa, e.g.
+// LIBGL_ALWAYS_SOFTWARE=1 under xvfb-run, or -api osmesa. Golden images are
+// written by running with -update on a reference machine and reviewing them;
+// the committed ones were rendered by Mesa 22.3's llvmpipe, and other drivers
+// may differ from them by more than the tolerance.
+package golden
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	. "physics/engine"
+)
+
+// ErrNoContext is returned by Run when no GL context could be created, as on
+// machines without a display or software Mesa
+var ErrNoContext = errors.New("no GL context")
+
+type Options struct {
+	// GoldenDir holds the golden images, OutputDir receives the rendered and
+	// diff images of failing cases
+	GoldenDir string
+	OutputDir string
+	// Update overwrites the golden images with the rendered ones
+	Update    bool
+	Deferred  bool
+	Tolerance Tolerance
+
+	Width  int
+	Height int
+	// ContextAPI is passed on to ContextOptions
+	ContextAPI int
+}
+
+func DefaultOptions() Options {
+	return Options{
+		GoldenDir: "golden/testdata",
+		OutputDir: "golden/out",
+		Tolerance: DefaultTolerance(),
+		Width:     320,
+		Height:    240,
+	}
+}
+
+type Result struct {
+	Case       string
+	Comparison Comparison
+	// Err is set when the case couldn't be rendered or compared, including
+	// when it has no golden image yet
+	Err     error
+	Updated bool
+	// Output and Diff are the paths of the images written for a failure
+	Output string
+	Diff   string
+}
+
+func (r Result) Passed(tolerance Tolerance) bool {
+	return r.Err == nil && (r.Updated || r.Comparison.Matches(tolerance))
+}
+
+// Run renders every case in a hidden window and compares, or with Update
+// stores, the result. It creates the shared GL context, so it runs once per
+// process, on the main thread.
+func Run(opts Options, cases []Case) ([]Result, error) {
+	contextOpts := DefaultContextOptions()
+	contextOpts.Width, contextOpts.Height = opts.Width, opts.Height
+	contextOpts.Title = "golden"
+	contextOpts.Headless = true
+	contextOpts.ContextAPI = opts.ContextAPI
+	ctx, err := InitGLContext(contextOpts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoContext, err)
+	}
+	window := ctx.Window
+
+	var renderer Renderer
+	if opts.Deferred {
+		deferred, err := NewDeferredRenderer(window)
+		if err != nil {
+			return nil, err
+		}
+		defer deferred.Delete()
+		renderer = deferred
+	} else {
+		forward, err := NewForwardRenderer(window)
+		if err != nil {
+			return nil, err
+		}
+		defer forward.Delete()
+		renderer = forward
+	}
+
+	// Only tone mapping, the other effects add nothing the scenes test but
+	// more room for differences between GL implementations
+	post, err := NewPostProcessor(window, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer post.Delete()
+	toneMapping, err := NewToneMapping(ToneMapACES)
+	if err != nil {
+		return nil, err
+	}
+	post.Effects = []PostEffect{toneMapping}
+
+	// The hidden window's back buffer can't be relied on, frames are drawn
+	// into a framebuffer encoded like the window and read from there
+	output, err := NewFramebuffer(int32(opts.Width), int32(opts.Height), FramebufferOptions{
+		Color:      []TextureFormat{TextureFormatRGBA8},
+		ColorSpace: ColorSpaceSRGB,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Delete()
+	post.Output = output
+
+	var results []Result
+	for _, c := range cases {
+		result := Result{Case: c.Name}
+		img, err := render(window, renderer, post, c)
+		if err == nil {
+			err = check(&result, img, opts)
+		}
+		result.Err = err
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func render(window *glfw.Window, renderer Renderer, post *PostProcessor, c Case) (*image.RGBA, error) {
+	scene, err := NewScene(window)
+	if err != nil {
+		return nil, err
+	}
+	defer scene.Delete()
+	cleanup, err := c.Setup(scene, renderer)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if err := post.RenderScene(renderer, scene); err != nil {
+		return nil, err
+	}
+	return CaptureFramebuffer(post.Output, 0)
+}
+
+// check compares img against the case's golden image, writing the images of
+// a failure, or replaces the golden image when updating
+func check(result *Result, img *image.RGBA, opts Options) error {
+	goldenPath := filepath.Join(opts.GoldenDir, result.Case+".png")
+	if opts.Update {
+		if err := os.MkdirAll(opts.GoldenDir, 0755); err != nil {
+			return err
+		}
+		result.Updated = true
+		return SavePNG(goldenPath, img)
+	}
+
+	want, err := loadPNG(goldenPath)
+	if errors.Is(err, os.ErrNotExist) {
+		err = fmt.Errorf("no golden image %s, run with -update to create it", goldenPath)
+	}
+	if err == nil {
+		result.Comparison, err = Compare(img, want, opts.Tolerance)
+		if err == nil && result.Comparison.Matches(opts.Tolerance) {
+			return nil
+		}
+	}
+
+	if mkdirErr := os.MkdirAll(opts.OutputDir, 0755); mkdirErr != nil {
+		return mkdirErr
+	}
+	result.Output = filepath.Join(opts.OutputDir, result.Case+".png")
+	if saveErr := SavePNG(result.Output, img); saveErr != nil {
+		return saveErr
+	}
+	if result.Comparison.Diff != nil {
+		result.Diff = filepath.Join(opts.OutputDir, result.Case+".diff.png")
+		if saveErr := SavePNG(result.Diff, result.Comparison.Diff); saveErr != nil {
+			return saveErr
+		}
+	}
+	return err
+}
+
+func loadPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return png.Decode(file)
+}
@@ -0,0 +1,188 @@
+package golden
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	. "physics/engine"
+	"physics/pbr"
+)
+
+// Case is a reference scene, compared against the golden image <Name>.png
+type Case struct {
+	Name string
+	// Setup fills scene with objects, lights and a camera. It returns a
+	// function releasing what it loaded and created, run before the scene's
+	// Delete.
+	Setup func(scene *Scene, renderer Renderer) (func(), error)
+}
+
+// Cases are the reference scenes the harness renders by default
+var Cases = []Case{
+	{Name: "sphere", Setup: setupSphere},
+	{Name: "e1m1", Setup: setupE1M1},
+	{Name: "pbr_spheres", Setup: setupPBRSpheres},
+}
+
+// setupSphere is the scene main.go starts with: the sphere model lit by one
+// shadowed point light under a gradient sky
+func setupSphere(scene *Scene, renderer Renderer) (func(), error) {
+	model, err := Assets.Model("meshes/sphere.obj")
+	if err != nil {
+		return nil, err
+	}
+	material, err := NewDefaultMaterial()
+	if err != nil {
+		Assets.ReleaseModel(model)
+		return nil, err
+	}
+	for _, mesh := range model.Meshes {
+		scene.AddObject(&GameObject{Rotation: QuatIdent, Scale: 1, Mesh: mesh, Material: *material})
+	}
+
+	light := NewPointLight(mgl32.Vec3{15, 20, -15}, mgl32.Vec3{1, 1, 1}, 60)
+	light.CastShadows = true
+	scene.AddLight(light)
+	scene.Sky = NewGradientSkybox()
+	scene.Camera = scene.CreateCamera(mgl32.Vec3{0, 15, -30}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
+
+	return func() {
+		scene.Sky.Delete()
+		material.Release()
+		Assets.ReleaseModel(model)
+	}, nil
+}
+
+// e1m1Scale brings the map from Doom units to a size within the camera's
+// far plane
+const e1m1Scale = 1.0 / 64
+
+// setupE1M1 looks down on the whole first Doom map, textured from its
+// material library, under its own sky
+func setupE1M1(scene *Scene, renderer Renderer) (func(), error) {
+	model, err := Assets.Model("mapdata/doom_E1M1.obj")
+	if err != nil {
+		return nil, err
+	}
+	sky, err := LoadTexture("mapdata/F_SKY1.PNG", ColorTextureOptions())
+	if err != nil {
+		Assets.ReleaseModel(model)
+		return nil, err
+	}
+
+	var materials []*Material
+	release := func() {
+		for _, material := range materials {
+			material.Release()
+		}
+		sky.Delete()
+		Assets.ReleaseModel(model)
+	}
+	for i, mesh := range model.Meshes {
+		material, err := model.NewMaterial(i)
+		if err != nil {
+			if material != nil {
+				material.Release()
+			}
+			release()
+			return nil, err
+		}
+		materials = append(materials, material)
+		scene.AddObject(&GameObject{Rotation: QuatIdent, Scale: e1m1Scale, Mesh: mesh, Material: *material, Static: true})
+	}
+	min, max := bounds(model.Meshes)
+	center := min.Add(max).Mul(0.5 * e1m1Scale)
+
+	scene.AddLight(NewPointLight(center.Add(mgl32.Vec3{0, 20, 0}), mgl32.Vec3{1, 1, 1}, 90))
+	scene.Sky = NewEquirectangularSkybox(sky)
+	scene.Camera = scene.CreateCamera(center.Add(mgl32.Vec3{0, 40, 25}), center, mgl32.Vec3{0, 1, 0})
+
+	return func() {
+		scene.Sky.Delete()
+		release()
+	}, nil
+}
+
+// bounds returns the corners of the box around the vertices of meshes
+func bounds(meshes []*Mesh) (mgl32.Vec3, mgl32.Vec3) {
+	var min, max mgl32.Vec3
+	first := true
+	for _, mesh := range meshes {
+		for i := 0; i+2 < len(mesh.Vertices); i += 3 {
+			for axis := 0; axis < 3; axis++ {
+				value := mesh.Vertices[i+axis]
+				if first || value < min[axis] {
+					min[axis] = value
+				}
+				if first || value > max[axis] {
+					max[axis] = value
+				}
+			}
+			first = false
+		}
+	}
+	return min, max
+}
+
+// pbrGridSize is the number of spheres along each side of the PBR grid
+const pbrGridSize = 5
+
+// setupPBRSpheres lays out PBR spheres with metallic increasing upwards and
+// roughness to the right
+func setupPBRSpheres(scene *Scene, renderer Renderer) (func(), error) {
+	model, err := Assets.Model("meshes/sphere.obj")
+	if err != nil {
+		return nil, err
+	}
+	// The Phong material is drawn only by renderers that can't draw PBR
+	fallback, err := NewDefaultMaterial()
+	if err != nil {
+		Assets.ReleaseModel(model)
+		return nil, err
+	}
+	lighting, _ := renderer.(pbr.LightingBinder)
+
+	var renderers []*pbr.MaterialRenderer
+	release := func() {
+		for _, materialRenderer := range renderers {
+			materialRenderer.Delete()
+		}
+		fallback.Release()
+		Assets.ReleaseModel(model)
+	}
+	for row := 0; row < pbrGridSize; row++ {
+		for column := 0; column < pbrGridSize; column++ {
+			material, err := pbr.NewPBRMaterial()
+			if err != nil {
+				release()
+				return nil, err
+			}
+			material.AlbedoColor = mgl32.Vec3{0.8, 0.1, 0.1}
+			material.Metallic = float32(row) / (pbrGridSize - 1)
+			material.Roughness = mgl32.Clamp(float32(column)/(pbrGridSize-1), 0.05, 1)
+			materialRenderer := pbr.NewMaterialRenderer(material, lighting)
+			renderers = append(renderers, materialRenderer)
+
+			// The camera looks down +Z, so +X is on the left of the image
+			position := mgl32.Vec3{
+				(float32(pbrGridSize-1)/2 - float32(column)) * 2.5,
+				(float32(row) - float32(pbrGridSize-1)/2) * 2.5,
+				0,
+			}
+			for _, mesh := range model.Meshes {
+				scene.AddObject(&GameObject{
+					Position: position,
+					Rotation: QuatIdent,
+					Scale:    0.1,
+					Mesh:     mesh,
+					Material: *fallback,
+					Renderer: materialRenderer,
+				})
+			}
+		}
+	}
+
+	scene.AddLight(NewPointLight(mgl32.Vec3{-6, 6, -10}, mgl32.Vec3{3, 3, 3}, 40))
+	scene.AddLight(NewPointLight(mgl32.Vec3{6, -6, -10}, mgl32.Vec3{3, 3, 3}, 40))
+	scene.Camera = scene.CreateCamera(mgl32.Vec3{0, 0, -16}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
+
+	return release, nil
+}
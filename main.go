@@ -1,11 +1,13 @@
 package main
 
 import (
-	"github.com/go-gl/gl/v4.1-core/gl"
+	"flag"
 	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/go-gl/mathgl/mgl32"
+	"image"
 	"log"
 	"math/rand"
+	"os"
 	. "physics/engine"
 	"runtime"
 	"time"
@@ -21,20 +23,39 @@ func init() {
 	runtime.LockOSThread()
 }
 
+var (
+	deferred = flag.Bool("deferred", false, "render with the deferred renderer")
+	headless = flag.Bool("headless", false, "render to a hidden window, e.g. on CI")
+	capture  = flag.String("capture", "", "write frame -frames to this PNG file and exit")
+	frames   = flag.Int("frames", 1, "number of frames rendered before -capture")
+	record   = flag.String("record", "", "write the device calls of each frame to this JSON lines file, see cmd/framedump")
+)
+
 func main() {
+	flag.Parse()
 	rand.Seed(time.Now().UnixNano())
 
 	defer glfw.Terminate()
 
-	window := GLContext().Window
-
-	// Enable depth testing
-	gl.Enable(gl.DEPTH_TEST)
-	gl.DepthFunc(gl.LESS)
+	opts := DefaultContextOptions()
+	opts.Headless = *headless
+	ctx, err := InitGLContext(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	window := ctx.Window
 
-	// Enable blending for rendering transparency
-	gl.Enable(gl.BLEND)
-	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	var recorder *RecordingDevice
+	if *record != "" {
+		file, err := os.Create(*record)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		recorder = NewRecordingDevice(CurrentDevice(), file)
+		recorder.RecordBufferData = true
+		SetDevice(recorder)
+	}
 
 	scene, err := NewScene(window)
 	if err != nil {
@@ -48,24 +69,86 @@ func main() {
 		mgl32.Vec3{0, 1, 0},
 	)
 	scene.Camera = camera
+	scene.Sky = NewGradientSkybox()
+	defer scene.Sky.Delete()
 
-	objFileMeshes, err := LdrParseObj("meshes/sphere.obj")
+	light := NewPointLight(mgl32.Vec3{5, 8, -5}, mgl32.Vec3{1, 1, 1}, 40)
+	light.CastShadows = true
+	scene.AddLight(light)
+
+	sphereModel, err := Assets.Model("meshes/sphere.obj")
 	if err != nil {
 		print("Failed loading obj file")
 		log.Fatal(err)
 	}
+	defer Assets.ReleaseModel(sphereModel)
 
-	if objFileMeshes != nil {
-		print("hello")
+	ssao, err := NewSSAO(DefaultSSAOSettings())
+	if err != nil {
+		log.Fatal(err)
 	}
+	defer ssao.Delete()
 
-	forwardRenderer := NewForwardRenderer(window)
+	var renderer Renderer
+	if *deferred {
+		deferredRenderer, err := NewDeferredRenderer(window)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer deferredRenderer.Delete()
+		deferredRenderer.SSAO = ssao
+		renderer = deferredRenderer
+	} else {
+		forwardRenderer, err := NewForwardRenderer(window)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer forwardRenderer.Delete()
+		forwardRenderer.SSAO = ssao
+		renderer = forwardRenderer
+	}
 
-	for _, mesh := range objFileMeshes.Objects {
-		if mesh == nil {
-			continue
+	// Scenes are drawn in HDR and tone mapped on the way to the window
+	post, err := NewPostProcessor(window, 4)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer post.Delete()
+	bloom, err := NewBloom()
+	if err != nil {
+		log.Fatal(err)
+	}
+	toneMapping, err := NewToneMapping(ToneMapACES)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fxaa, err := NewFXAA()
+	if err != nil {
+		log.Fatal(err)
+	}
+	post.Effects = []PostEffect{bloom, toneMapping, fxaa}
+	if *headless {
+		// A hidden window's back buffer may not be kept, draw into a
+		// framebuffer encoded like the window instead
+		width, height := window.GetFramebufferSize()
+		output, err := NewFramebuffer(int32(width), int32(height), FramebufferOptions{
+			Color:      []TextureFormat{TextureFormatRGBA8},
+			ColorSpace: ColorSpaceSRGB,
+		})
+		if err != nil {
+			log.Fatal(err)
 		}
-		basicMesh := NewMesh(mesh.CombinedVertex, mesh.Indices)
+		defer output.Delete()
+		post.Output = output
+	}
+
+	material, err := NewDefaultMaterial()
+	if material == nil {
+		log.Fatal(err)
+	} else if err != nil {
+		log.Print(err)
+	}
+	for _, basicMesh := range sphereModel.Meshes {
 		normalLinesMesh := NewMeshNormalLines(basicMesh, 0.5)
 		scene.AddObject(&GameObject{
 			Position: mgl32.Vec3{0.0, 0.0, 0.0},
@@ -74,7 +157,7 @@ func main() {
 			Scale:    1.0,
 			Mass:     3,
 			Mesh:     basicMesh,
-			Material: *NewDefaultMaterial(),
+			Material: *material,
 		})
 
 		scene.AddObject(&GameObject{
@@ -84,7 +167,7 @@ func main() {
 			Scale:    5.0,
 			Mass:     3,
 			Mesh:     normalLinesMesh,
-			Material: *NewDefaultMaterial(),
+			Material: *material,
 		})
 	}
 
@@ -129,9 +212,11 @@ func main() {
 	  sphereMaterial.Roughness = 0.2
 	  sphereMaterial.Metallic = 0.8*/
 
+	shaderWatcher := NewShaderWatcher(500 * time.Millisecond)
+
 	// Initialize the last frame time
 	var lastFrameTime float64 = 0.0
-	for !window.ShouldClose() {
+	for frame := 1; !window.ShouldClose(); frame++ {
 		currentFrameTime := glfw.GetTime()
 		dt := currentFrameTime - lastFrameTime
 		lastFrameTime = currentFrameTime
@@ -139,6 +224,9 @@ func main() {
 		// Poll events
 		glfw.PollEvents()
 
+		// Recompile any shaders edited since the last frame
+		shaderWatcher.Update()
+
 		// update camera position based on user input
 		camera.Update(window, float32(dt))
 
@@ -146,7 +234,31 @@ func main() {
 		scene.Update(float32(dt))
 
 		// Render the objects in the scene
-		forwardRenderer.RenderPrimaryCamera(scene)
+		if err := post.RenderScene(renderer, scene); err != nil {
+			log.Fatal(err)
+		}
+
+		if recorder != nil {
+			if err := recorder.EndFrame(); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if *capture != "" && frame >= *frames {
+			var img *image.RGBA
+			if post.Output != nil {
+				img, err = CaptureFramebuffer(post.Output, 0)
+			} else {
+				img, err = CaptureFrame(window)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := SavePNG(*capture, img); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 
 		// Swap buffers
 		window.SwapBuffers()
@@ -2,36 +2,71 @@ package pbr
 
 import (
 	"github.com/go-gl/mathgl/mgl32"
+	"log"
 	. "physics/engine"
+	"physics/scene"
 )
 
 type PBRMaterial struct {
 	Shader *ShaderProgram
 	//texture       *gl.Texture
-	AlbedoColor mgl32.Vec3
-	Metallic    float32
-	Roughness   float32
+	scene.PBR
+
+	uniforms *UniformBuffer
+}
+
+// PBRMaterialUniforms mirrors the PBRMaterialData block in pbr.frag
+type PBRMaterialUniforms struct {
+	Albedo    mgl32.Vec3
+	Metallic  float32
+	Roughness float32
 }
 
-var DefaultPbrShaderProgram = LoadShader("shaders/pbr.vert", "shaders/pbr.frag")
+// DefaultPbrShaderProgram is loaded by the first material that needs it, once
+// a GL context exists
+var DefaultPbrShaderProgram *ShaderProgram
+
+// defaultShader returns DefaultPbrShaderProgram, loading it on first use. The
+// call that loads it returns its build error, see LoadShader.
+func defaultShader() (*ShaderProgram, error) {
+	if DefaultPbrShaderProgram != nil {
+		return DefaultPbrShaderProgram, nil
+	}
+	shader, err := LoadShader("shaders/pbr.vert", "shaders/pbr.frag")
+	DefaultPbrShaderProgram = shader
+	return shader, err
+}
 
 var DefaultAlbedoColor = mgl32.Vec3{0.5, 0.0, 0.0}
 var DefaultMetallic = float32(0.0)
 var DefaultRoughness = float32(0.5)
 
-func NewPBRMaterial() *PBRMaterial {
+// NewPBRMaterial returns a material using the default PBR shader. When the
+// shader fails to build the error is returned with a material drawing with the
+// error shader, see LoadShader.
+func NewPBRMaterial() (*PBRMaterial, error) {
+	shader, err := defaultShader()
+	if shader == nil {
+		return nil, err
+	}
 	return &PBRMaterial{
 		//texture:       texture,
-		Shader:      DefaultPbrShaderProgram,
-		AlbedoColor: DefaultAlbedoColor,
-		Metallic:    DefaultMetallic,
-		Roughness:   DefaultRoughness,
-	}
+		Shader: shader,
+		PBR: scene.PBR{
+			AlbedoColor: DefaultAlbedoColor,
+			Metallic:    DefaultMetallic,
+			Roughness:   DefaultRoughness,
+		},
+	}, err
 }
 
 func (m *PBRMaterial) GetShader() *ShaderProgram {
 	if m.Shader == nil {
-		return DefaultPbrShaderProgram
+		shader, err := defaultShader()
+		if err != nil {
+			log.Print(err)
+		}
+		return shader
 	}
 	return m.Shader
 }
@@ -45,8 +80,21 @@ func (m *PBRMaterial) GetAttributeMap() map[string]string {
 }
 
 func (m *PBRMaterial) BindShaderProperties(shader *ShaderProgram) error {
-	shader.SetFloat("albedoColor", m.AlbedoColor[0])
-	shader.SetFloat("metallic", m.Metallic)
-	shader.SetFloat("roughness", m.Roughness)
+	uniforms := PBRMaterialUniforms{
+		Albedo:    m.AlbedoColor,
+		Metallic:  m.Metallic,
+		Roughness: m.Roughness,
+	}
+
+	if m.uniforms == nil {
+		buffer, err := NewUniformBuffer(MaterialUniformBinding, &uniforms)
+		if err != nil {
+			return err
+		}
+		m.uniforms = buffer
+	} else if err := m.uniforms.Update(&uniforms); err != nil {
+		return err
+	}
+	m.uniforms.Bind()
 	return nil
 }
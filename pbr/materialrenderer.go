@@ -0,0 +1,174 @@
+package pbr
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"log"
+	. "physics/engine"
+)
+
+// LightingBinder is implemented by the engine's renderers, which point a
+// shader's shadow and ambient occlusion samplers at the current view's
+type LightingBinder interface {
+	BindLighting(shader *ShaderProgram)
+}
+
+// MaterialRenderer draws a GameObject with a PBRMaterial when set as its
+// Renderer, in place of its Phong Material
+type MaterialRenderer struct {
+	Material *PBRMaterial
+	Lighting LightingBinder
+
+	objects   *UniformBuffer
+	instanced shaderVariant
+	deferred  shaderVariant
+}
+
+var (
+	instancedVariant = variantKind{
+		defines: ShaderDefines{"INSTANCED": "1"},
+		missing: "PBR instances drawn one at a time",
+	}
+	deferredVariant = variantKind{
+		defines: ShaderDefines{"DEFERRED": "1"},
+		missing: "PBR drawn forward by the deferred renderer",
+		supported: func(variant *ShaderProgram) bool {
+			return CurrentDevice().FragDataLocation(variant.Handle(), "gAlbedo") >= 0
+		},
+	}
+)
+
+func NewMaterialRenderer(material *PBRMaterial, lighting LightingBinder) *MaterialRenderer {
+	return &MaterialRenderer{Material: material, Lighting: lighting}
+}
+
+// Render draws mesh at model. The view and projection come from the frame
+// block the renderer has bound.
+func (r *MaterialRenderer) Render(mesh *Mesh, _ Material, model mgl32.Mat4, proj mgl32.Mat4, view mgl32.Mat4) {
+	shader := r.Material.GetShader()
+	r.bind(shader)
+	r.bindObject(model)
+
+	CurrentDevice().Draw(DrawCall{VertexArray: mesh.Vao, Count: int32(len(mesh.Indices)), Indexed: true})
+	shader.Unuse()
+}
+
+// RenderInstanced draws every instance of mesh with the INSTANCED variant of
+// the material's shader, tinting the albedo by each instance's color. When the
+// variant fails to build the instances are drawn one at a time, untinted.
+func (r *MaterialRenderer) RenderInstanced(mesh *Mesh, material Material, instances []Instance, vertexArray uint32) {
+	shader := r.instanced.get(r.Material.GetShader(), &instancedVariant)
+	if shader == nil {
+		for _, instance := range instances {
+			r.Render(mesh, material, instance.Model, mgl32.Ident4(), mgl32.Ident4())
+		}
+		return
+	}
+	r.bind(shader)
+
+	CurrentDevice().Draw(DrawCall{VertexArray: vertexArray, Count: int32(len(mesh.Indices)), Indexed: true, Instances: int32(len(instances))})
+	shader.Unuse()
+}
+
+// RenderDeferred writes mesh at model to the bound G-buffer with the DEFERRED
+// variant of the material's shader. It returns false, drawing nothing, when
+// the shader has no such variant.
+func (r *MaterialRenderer) RenderDeferred(mesh *Mesh, _ Material, model mgl32.Mat4) bool {
+	shader := r.deferred.get(r.Material.GetShader(), &deferredVariant)
+	if shader == nil {
+		return false
+	}
+	shader.Use()
+	if err := r.Material.BindShaderProperties(shader); err != nil {
+		panic(err)
+	}
+	r.bindObject(model)
+
+	CurrentDevice().Draw(DrawCall{VertexArray: mesh.Vao, Count: int32(len(mesh.Indices)), Indexed: true})
+	shader.Unuse()
+	return true
+}
+
+// bind uses shader with the material's properties and the view's lighting.
+// The renderer keeps the view's ambient occlusion bound for object renderers,
+// so only the shadows need Lighting.
+func (r *MaterialRenderer) bind(shader *ShaderProgram) {
+	shader.Use()
+	if err := r.Material.BindShaderProperties(shader); err != nil {
+		panic(err)
+	}
+	if shader.HasUniform("ambientOcclusionMap") {
+		shader.SetSampler("ambientOcclusionMap", AmbientOcclusionTextureUnit)
+	}
+	if r.Lighting != nil {
+		r.Lighting.BindLighting(shader)
+	}
+}
+
+// bindObject uploads the object block for model
+func (r *MaterialRenderer) bindObject(model mgl32.Mat4) {
+	uniforms := NewObjectUniforms(model)
+	if r.objects == nil {
+		buffer, err := NewUniformBuffer(ObjectUniformBinding, &uniforms)
+		if err != nil {
+			panic(err)
+		}
+		r.objects = buffer
+	} else if err := r.objects.Update(&uniforms); err != nil {
+		panic(err)
+	}
+	r.objects.Bind()
+}
+
+func (r *MaterialRenderer) Delete() {
+	if r.objects != nil {
+		r.objects.Delete()
+		r.objects = nil
+	}
+	r.instanced.release()
+	r.deferred.release()
+}
+
+// variantKind is a variant of material shaders. supported, when set, tells
+// whether a built variant implements it; missing is logged when it doesn't.
+type variantKind struct {
+	defines   ShaderDefines
+	missing   string
+	supported func(*ShaderProgram) bool
+}
+
+// shaderVariant is a variant of the material's shader, built again when the
+// material's shader changes. It is nil when the variant fails to build or
+// isn't supported, which is logged once.
+type shaderVariant struct {
+	from    *ShaderProgram
+	program *ShaderProgram
+}
+
+func (v *shaderVariant) get(shader *ShaderProgram, kind *variantKind) *ShaderProgram {
+	if v.from == shader {
+		return v.program
+	}
+	v.release()
+	v.from = shader
+
+	variant, err := shader.Variant(kind.defines)
+	if err != nil {
+		log.Printf("%s: %v", kind.missing, err)
+		return nil
+	}
+	if kind.supported != nil && !kind.supported(variant) {
+		log.Printf("%s: shader has no %v variant", kind.missing, kind.defines)
+		variant.Release()
+		return nil
+	}
+	v.program = variant
+	return variant
+}
+
+func (v *shaderVariant) release() {
+	if v.program != nil {
+		v.program.Release()
+	}
+	v.from = nil
+	v.program = nil
+}
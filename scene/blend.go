@@ -0,0 +1,28 @@
+// Package scene holds the description of what is drawn that renderers share:
+// geometry, materials, lights, fog and sky. It builds without cgo, so renderers
+// that don't use OpenGL, such as package soft/raster, can draw it headlessly.
+package scene
+
+// BlendMode controls how a material's fragments combine with what is already
+// in the framebuffer
+type BlendMode int
+
+const (
+	// BlendOpaque ignores alpha
+	BlendOpaque BlendMode = iota
+	// BlendCutout discards fragments whose alpha is below the material's
+	// AlphaCutoff and draws the rest opaque
+	BlendCutout
+	// BlendAlpha is classic "over" blending of non-premultiplied colors
+	BlendAlpha
+	// BlendAdditive adds the fragment color scaled by its alpha, for glows and particles
+	BlendAdditive
+	// BlendPremultiplied blends colors that are already multiplied by their alpha
+	BlendPremultiplied
+)
+
+// IsTransparent reports whether objects using the mode blend with what is
+// behind them and so have to be drawn after opaque objects, back to front
+func (b BlendMode) IsTransparent() bool {
+	return b == BlendAlpha || b == BlendAdditive || b == BlendPremultiplied
+}
@@ -0,0 +1,42 @@
+package scene
+
+import "github.com/go-gl/mathgl/mgl32"
+
+type FogMode int32
+
+const (
+	FogNone FogMode = iota
+	// FogLinear fades in from Start to End distance
+	FogLinear
+	// FogExponential thickens with distance at Density per unit
+	FogExponential
+	// FogHeight is exponential fog of Density at Height, thinning above it by
+	// HeightFalloff per unit and thickening below it, for valleys and mist
+	FogHeight
+)
+
+// Fog is a Scene's atmosphere, blended over every lit surface and the sky by
+// the distance from the camera. The zero value is no fog.
+type Fog struct {
+	Mode  FogMode
+	Color mgl32.Vec3
+
+	Start float32
+	End   float32
+
+	Density       float32
+	Height        float32
+	HeightFalloff float32
+}
+
+func NewLinearFog(color mgl32.Vec3, start, end float32) Fog {
+	return Fog{Mode: FogLinear, Color: color, Start: start, End: end}
+}
+
+func NewExponentialFog(color mgl32.Vec3, density float32) Fog {
+	return Fog{Mode: FogExponential, Color: color, Density: density}
+}
+
+func NewHeightFog(color mgl32.Vec3, density, height, falloff float32) Fog {
+	return Fog{Mode: FogHeight, Color: color, Density: density, Height: height, HeightFalloff: falloff}
+}
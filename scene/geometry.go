@@ -0,0 +1,36 @@
+package scene
+
+// Geometry is a mesh's vertex data: three floats of position and of normal and
+// two of texture coordinates per vertex, and triangles as indices into them
+type Geometry struct {
+	Vertices  []float32
+	TexCoords []float32
+	Normals   []float32
+	Indices   []uint32
+}
+
+// NewGeometry flattens combined vertices, such as those of ParseObj
+func NewGeometry(combinedVertices []CombinedVertex, indices []uint32) Geometry {
+	vertexCount := len(combinedVertices)
+	geometry := Geometry{
+		Vertices:  make([]float32, vertexCount*3),
+		TexCoords: make([]float32, vertexCount*2),
+		Normals:   make([]float32, vertexCount*3),
+		Indices:   indices,
+	}
+
+	for i, cv := range combinedVertices {
+		geometry.Vertices[i*3] = cv.Position.X()
+		geometry.Vertices[i*3+1] = cv.Position.Y()
+		geometry.Vertices[i*3+2] = cv.Position.Z()
+
+		geometry.TexCoords[i*2] = cv.TexCoord.X()
+		geometry.TexCoords[i*2+1] = cv.TexCoord.Y()
+
+		geometry.Normals[i*3] = cv.Normal.X()
+		geometry.Normals[i*3+1] = cv.Normal.Y()
+		geometry.Normals[i*3+2] = cv.Normal.Z()
+	}
+
+	return geometry
+}
@@ -0,0 +1,25 @@
+package scene
+
+import "github.com/go-gl/mathgl/mgl32"
+
+// MaxLights is the number of point lights the lighting shaders accept per draw.
+const MaxLights = 8
+
+const defaultLightRange = 25.0
+
+type PointLight struct {
+	Position mgl32.Vec3
+	Color    mgl32.Vec3
+
+	// Range is the distance at which the light's contribution reaches zero. It
+	// is also the far plane of the light's shadow cube map.
+	Range float32
+}
+
+// EffectiveRange returns the light's Range, or defaultLightRange when unset
+func (l *PointLight) EffectiveRange() float32 {
+	if l.Range <= 0 {
+		return defaultLightRange
+	}
+	return l.Range
+}
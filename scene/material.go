@@ -0,0 +1,39 @@
+package scene
+
+import "github.com/go-gl/mathgl/mgl32"
+
+// Phong is the surface of the default material, shaded by default.frag
+type Phong struct {
+	Ambient   mgl32.Vec3
+	Diffuse   mgl32.Vec3
+	Specular  mgl32.Vec3
+	Shininess float32
+
+	BlendMode BlendMode
+	// Opacity scales the texture's alpha, 0 being fully transparent.
+	// DefaultPhong starts it at 1.
+	Opacity float32
+	// AlphaCutoff is the alpha below which BlendCutout discards fragments
+	AlphaCutoff float32
+	RenderState RenderState
+}
+
+// DefaultPhong is the surface of engine.NewDefaultMaterial
+func DefaultPhong() Phong {
+	return Phong{
+		Ambient:     mgl32.Vec3{0.1, 0.1, 0.1},
+		Diffuse:     mgl32.Vec3{0.5, 0.5, 0.5},
+		Specular:    mgl32.Vec3{0.5, 0.5, 0.5},
+		Shininess:   32.0,
+		BlendMode:   BlendOpaque,
+		Opacity:     1.0,
+		AlphaCutoff: 0.5,
+	}
+}
+
+// PBR is the metallic-roughness surface shaded by pbr.frag
+type PBR struct {
+	AlbedoColor mgl32.Vec3
+	Metallic    float32
+	Roughness   float32
+}
@@ -0,0 +1,375 @@
+package scene
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"strconv"
+	"strings"
+)
+
+type Vertex struct {
+	X, Y, Z float32
+}
+
+func (v Vertex) ToVec3() mgl32.Vec3 {
+	return mgl32.Vec3{v.X, v.Y, v.Z}
+}
+
+type TexCoord struct {
+	Y, V float32
+}
+
+func (v TexCoord) ToVec2() mgl32.Vec2 {
+	return mgl32.Vec2{v.Y, v.V}
+}
+
+type Normal struct {
+	X, Y, Z float32
+}
+
+func (v Normal) ToVec3() mgl32.Vec3 {
+	return mgl32.Vec3{v.X, v.Y, v.Z}
+}
+
+type FaceVertex struct {
+	VertexIndex   int
+	TexCoordIndex int
+	NormalIndex   int
+}
+
+type CombinedVertex struct {
+	Position mgl32.Vec3
+	TexCoord mgl32.Vec2
+	Normal   mgl32.Vec3
+}
+
+type ImportedMeshObj struct {
+	Name           string
+	Vertices       []Vertex
+	Indices        []uint32
+	CombinedVertex []CombinedVertex
+	TexCoords      []TexCoord
+	Normals        []Normal
+	FaceIndices    []FaceVertex
+	// Groups splits Indices into the runs of faces each usemtl selects a
+	// material for, in file order. Faces before the first usemtl have no
+	// material.
+	Groups []MaterialGroup
+}
+
+// MaterialGroup is a run of an object's faces drawn with one material
+type MaterialGroup struct {
+	Material string
+	// Start and Count select the group's indices in Indices
+	Start, Count int
+}
+
+// useMaterial starts a group of the faces that follow
+func (o *ImportedMeshObj) useMaterial(name string) {
+	o.closeGroup()
+	o.Groups = append(o.Groups, MaterialGroup{Material: name, Start: len(o.Indices)})
+}
+
+// closeGroup ends the last group at the faces read so far, putting the
+// faces before it in a group without a material and dropping it when empty
+func (o *ImportedMeshObj) closeGroup() {
+	if len(o.Groups) == 0 {
+		if len(o.Indices) > 0 {
+			o.Groups = append(o.Groups, MaterialGroup{Count: len(o.Indices)})
+		}
+		return
+	}
+	last := &o.Groups[len(o.Groups)-1]
+	last.Count = len(o.Indices) - last.Start
+	if last.Count == 0 {
+		o.Groups = o.Groups[:len(o.Groups)-1]
+	}
+}
+
+// Group returns the vertices and indices of group i alone, renumbered from 0
+func (o *ImportedMeshObj) Group(i int) ([]CombinedVertex, []uint32) {
+	group := o.Groups[i]
+	if len(o.Groups) == 1 {
+		return o.CombinedVertex, o.Indices
+	}
+	renumbered := make(map[uint32]uint32)
+	var vertices []CombinedVertex
+	indices := make([]uint32, group.Count)
+	for j, index := range o.Indices[group.Start : group.Start+group.Count] {
+		n, ok := renumbered[index]
+		if !ok {
+			n = uint32(len(vertices))
+			renumbered[index] = n
+			vertices = append(vertices, o.CombinedVertex[index])
+		}
+		indices[j] = n
+	}
+	return vertices, indices
+}
+
+func (o *ImportedMeshObj) AddVertex(v Vertex) {
+	o.Vertices = append(o.Vertices, v)
+}
+
+func (o *ImportedMeshObj) AddTexCoord(t TexCoord) {
+	o.TexCoords = append(o.TexCoords, t)
+}
+
+func (o *ImportedMeshObj) AddNormal(n Normal) {
+	o.Normals = append(o.Normals, n)
+}
+
+func (o *ImportedMeshObj) AddFaceVertex(v FaceVertex) {
+	o.FaceIndices = append(o.FaceIndices, v)
+}
+
+// ParseObj reads the objects of an OBJ file's contents and centers each on the
+// origin. mtllib, when not nil, is called with the file name of every material
+// library the file references.
+func ParseObj(data []byte, mtllib func(name string) error) ([]*ImportedMeshObj, error) {
+	currentObject := &ImportedMeshObj{}
+	objects := []*ImportedMeshObj{currentObject}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "o":
+			if len(fields) < 2 {
+				return nil, errors.New("malformed obj file, missing object name")
+			}
+			currentObject.closeGroup()
+			currentObject = &ImportedMeshObj{
+				Name: fields[1],
+			}
+			objects = append(objects, currentObject)
+		case "v":
+			v, err := LdrParseVertex(line)
+			if err != nil {
+				return nil, err
+			}
+			currentObject.Vertices = append(currentObject.Vertices, v)
+		case "vt":
+			t, err := LdrParseTexCoord(line)
+			if err != nil {
+				return nil, err
+			}
+			currentObject.TexCoords = append(currentObject.TexCoords, t)
+		case "vn":
+			n, err := LdrParseNormal(line)
+			if err != nil {
+				return nil, err
+			}
+			currentObject.Normals = append(currentObject.Normals, n)
+		case "f":
+			faceVertices := make([]FaceVertex, 0, len(fields)-1)
+			for _, field := range fields[1:] {
+				f, err := LdrParseFaceVertex(field)
+				if err != nil {
+					return nil, fmt.Errorf("could not parse face vertex: %v", err)
+				}
+				faceVertices = append(faceVertices, f)
+			}
+
+			if len(faceVertices) < 3 {
+				return nil, errors.New("malformed obj file, face with fewer than 3 vertices")
+			}
+
+			faceIndices := make([]uint32, 0, len(faceVertices))
+			for _, faceVertex := range faceVertices {
+				combinedVertex := CombinedVertex{
+					Position: currentObject.Vertices[faceVertex.VertexIndex].ToVec3(),
+					Normal:   currentObject.Normals[faceVertex.NormalIndex].ToVec3(),
+				}
+				if len(currentObject.TexCoords) > faceVertex.TexCoordIndex {
+					combinedVertex.TexCoord = currentObject.TexCoords[faceVertex.TexCoordIndex].ToVec2()
+				}
+				index := findExistingCombinedVertexIndex(currentObject.CombinedVertex, combinedVertex)
+				if index == -1 {
+					currentObject.CombinedVertex = append(currentObject.CombinedVertex, combinedVertex)
+					index = len(currentObject.CombinedVertex) - 1
+				}
+				faceIndices = append(faceIndices, uint32(index))
+			}
+			// Polygons are split into a fan of triangles around their first vertex
+			for i := 2; i < len(faceIndices); i++ {
+				currentObject.Indices = append(currentObject.Indices, faceIndices[0], faceIndices[i-1], faceIndices[i])
+			}
+		case "s":
+			continue
+			/*if len(fields) > 1 && fields[1] == "1" {
+			  	smooth = true
+			  } else {
+			  	smooth = false
+			  }*/
+		case "usemtl":
+			if len(fields) < 2 {
+				return nil, errors.New("malformed obj file, missing material name")
+			}
+			currentObject.useMaterial(fields[1])
+		case "mtllib":
+			if len(fields) < 2 {
+				return nil, errors.New("malformed obj file, missing material library name")
+			}
+			if mtllib != nil {
+				if err := mtllib(fields[1]); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	currentObject.closeGroup()
+
+	// Center the model
+	for _, mesh := range objects {
+		if mesh == nil {
+			continue
+		}
+		var totalX, totalY, totalZ float32
+		for _, vertex := range mesh.Vertices {
+			totalX += vertex.X
+			totalY += vertex.Y
+			totalZ += vertex.Z
+		}
+		centerX := totalX / float32(len(mesh.Vertices))
+		centerY := totalY / float32(len(mesh.Vertices))
+		centerZ := totalZ / float32(len(mesh.Vertices))
+		for i := range mesh.Vertices {
+			mesh.Vertices[i].X -= centerX
+			mesh.Vertices[i].Y -= centerY
+			mesh.Vertices[i].Z -= centerZ
+		}
+	}
+	return objects, nil
+}
+
+func findExistingCombinedVertexIndex(combinedVertices []CombinedVertex, target CombinedVertex) int {
+	for i, v := range combinedVertices {
+		if v.Position == target.Position && v.TexCoord == target.TexCoord && v.Normal == target.Normal {
+			return i
+		}
+	}
+	return -1
+}
+
+func LdrParseVertex(line string) (Vertex, error) {
+	fields := strings.Fields(line)[1:]
+	if len(fields) != 3 {
+		return Vertex{}, fmt.Errorf("expected 3 fields in Vertex, found %d", len(fields))
+	}
+	x, err := strconv.ParseFloat(fields[0], 32)
+	if err != nil {
+		return Vertex{}, fmt.Errorf("could not parse Vertex X coordinate: %V", err)
+	}
+	y, err := strconv.ParseFloat(fields[1], 32)
+	if err != nil {
+		return Vertex{}, fmt.Errorf("could not parse Vertex Y coordinate: %V", err)
+	}
+	z, err := strconv.ParseFloat(fields[2], 32)
+	if err != nil {
+		return Vertex{}, fmt.Errorf("could not parse Vertex Z coordinate: %V", err)
+	}
+	return Vertex{float32(x), float32(y), float32(z)}, nil
+}
+
+func LdrParseTexCoord(line string) (TexCoord, error) {
+	fields := strings.Fields(line)[1:]
+	if len(fields) != 2 {
+		return TexCoord{}, fmt.Errorf("expected 2 fields in texture coordinate, found %d", len(fields))
+	}
+	u, err := strconv.ParseFloat(fields[0], 32)
+	if err != nil {
+		return TexCoord{}, fmt.Errorf("could not parse texture coordinate Y value: %V", err)
+	}
+	v, err := strconv.ParseFloat(fields[1], 32)
+	if err != nil {
+		return TexCoord{}, fmt.Errorf("could not parse texture coordinate V value: %V", err)
+	}
+	return TexCoord{float32(u), float32(v)}, nil
+}
+
+func LdrParseNormal(line string) (Normal, error) {
+	fields := strings.Fields(line)[1:]
+	if len(fields) != 3 {
+		return Normal{}, fmt.Errorf("expected 3 fields in Normal, found %d", len(fields))
+	}
+	x, err := strconv.ParseFloat(fields[0], 32)
+	if err != nil {
+		return Normal{}, fmt.Errorf("could not parse Normal X component: %V", err)
+	}
+	y, err := strconv.ParseFloat(fields[1], 32)
+	if err != nil {
+		return Normal{}, fmt.Errorf("could not parse Normal Y component: %V", err)
+	}
+	z, err := strconv.ParseFloat(fields[2], 32)
+	if err != nil {
+		return Normal{}, fmt.Errorf("could not parse Normal Z component: %V", err)
+	}
+	return Normal{float32(x), float32(y), float32(z)}, nil
+}
+
+func LdrParseFaceVertex(field string) (FaceVertex, error) {
+	faceVertex := FaceVertex{}
+
+	indices := strings.Split(field, "/")
+	switch len(indices) {
+	case 1:
+		// Vertex index
+		vertexIndex, err := strconv.Atoi(indices[0])
+		if err != nil {
+			return faceVertex, err
+		}
+		faceVertex.VertexIndex = vertexIndex - 1
+	case 2:
+		// Vertex index / texture coordinate index
+		vertexIndex, err := strconv.Atoi(indices[0])
+		if err != nil {
+			return faceVertex, err
+		}
+		faceVertex.VertexIndex = vertexIndex - 1
+
+		texCoordIndex, err := strconv.Atoi(indices[1])
+		if err != nil {
+			return faceVertex, err
+		}
+		faceVertex.TexCoordIndex = texCoordIndex - 1
+	case 3:
+		// Vertex index / texture coordinate index / Normal index
+		vertexIndex, err := strconv.Atoi(indices[0])
+		if err != nil {
+			return faceVertex, err
+		}
+		faceVertex.VertexIndex = vertexIndex - 1
+
+		if len(indices[1]) > 0 {
+			texCoordIndex, err := strconv.Atoi(indices[1])
+			if err != nil {
+				return faceVertex, err
+			}
+			faceVertex.TexCoordIndex = texCoordIndex - 1
+		}
+
+		normalIndex, err := strconv.Atoi(indices[2])
+		if err != nil {
+			return faceVertex, err
+		}
+		faceVertex.NormalIndex = normalIndex - 1
+	default:
+		return faceVertex, fmt.Errorf("invalid number of indices for face Vertex: %s", field)
+	}
+
+	return faceVertex, nil
+}
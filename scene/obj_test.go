@@ -0,0 +1,72 @@
+package scene
+
+import (
+	"reflect"
+	"testing"
+)
+
+const groupedObj = `v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vn 0 0 1
+f 1//1 2//1 3//1
+usemtl red
+f 1//1 3//1 4//1
+usemtl empty
+usemtl blue
+f 3//1 4//1 1//1
+f 2//1 3//1 4//1
+`
+
+func TestParseObjMaterialGroups(t *testing.T) {
+	objects, err := ParseObj([]byte(groupedObj), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := objects[0]
+	want := []MaterialGroup{{"", 0, 3}, {"red", 3, 3}, {"blue", 6, 6}}
+	if !reflect.DeepEqual(object.Groups, want) {
+		t.Fatalf("groups %v, want %v", object.Groups, want)
+	}
+
+	// Each group's vertices are its own, renumbered in order of use
+	vertices, indices := object.Group(1)
+	if want := []uint32{0, 1, 2}; !reflect.DeepEqual(indices, want) {
+		t.Errorf("red indices %v, want %v", indices, want)
+	}
+	for i, position := range []Vertex{{0, 0, 0}, {1, 1, 0}, {0, 1, 0}} {
+		if vertices[i].Position != position.ToVec3() {
+			t.Errorf("red vertex %d at %v, want %v", i, vertices[i].Position, position)
+		}
+	}
+	vertices, indices = object.Group(2)
+	if len(vertices) != 4 || len(indices) != 6 {
+		t.Errorf("blue has %d vertices and %d indices, want 4 and 6", len(vertices), len(indices))
+	}
+}
+
+func TestParseObjWithoutMaterials(t *testing.T) {
+	objects, err := ParseObj([]byte("v 0 0 0\nv 1 0 0\nv 0 1 0\nvn 0 0 1\nf 1//1 2//1 3//1\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := objects[0]
+	if want := []MaterialGroup{{"", 0, 3}}; !reflect.DeepEqual(object.Groups, want) {
+		t.Fatalf("groups %v, want %v", object.Groups, want)
+	}
+	vertices, indices := object.Group(0)
+	if len(vertices) != len(object.CombinedVertex) || len(indices) != len(object.Indices) {
+		t.Errorf("the only group has %d vertices and %d indices, want all %d and %d", len(vertices), len(indices), len(object.CombinedVertex), len(object.Indices))
+	}
+}
+
+func TestParseObjTriangulatesPolygons(t *testing.T) {
+	objects, err := ParseObj([]byte("v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nv -1 1 0\nvn 0 0 1\nf 1//1 2//1 3//1 4//1 5//1\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint32{0, 1, 2, 0, 2, 3, 0, 3, 4}; !reflect.DeepEqual(objects[0].Indices, want) {
+		t.Errorf("pentagon indices %v, want the fan %v", objects[0].Indices, want)
+	}
+}
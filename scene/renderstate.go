@@ -0,0 +1,21 @@
+package scene
+
+import "physics/gfx"
+
+// RenderState is the fixed function state a material draws with. The zero
+// value is what objects have always been drawn with: no culling, depth test
+// CompareLess with depth writes, filled polygons.
+type RenderState struct {
+	Cull gfx.CullMode
+	// DepthFunc is the depth comparison, gfx.CompareLess when zero.
+	// gfx.CompareAlways turns the depth test off.
+	DepthFunc gfx.CompareFunc
+	// DepthReadOnly keeps the depth test but stops writing depth. Transparent
+	// blend modes imply it.
+	DepthReadOnly bool
+	// PolygonOffsetFactor and PolygonOffsetUnits push depth away from the
+	// camera, e.g. for decals, and are disabled when both are zero
+	PolygonOffsetFactor float32
+	PolygonOffsetUnits  float32
+	Wireframe           bool
+}
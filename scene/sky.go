@@ -0,0 +1,48 @@
+package scene
+
+import "github.com/go-gl/mathgl/mgl32"
+
+type SkyKind int
+
+const (
+	// SkyCubemap samples a cube map texture
+	SkyCubemap SkyKind = iota
+	// SkyEquirectangular samples a 2D texture wrapped around the view, longitude
+	// along its width and latitude along its height
+	SkyEquirectangular
+	// SkyGradient blends between ground, horizon and zenith colors with a sun
+	// and its haze, no texture needed
+	SkyGradient
+)
+
+// Sky is how a Scene's background is shaded, without the texture textured
+// kinds sample
+type Sky struct {
+	Kind SkyKind
+	// Intensity scales the sky's color, above 1 for skies that should bloom
+	Intensity float32
+
+	// Colors of the gradient sky, linear
+	ZenithColor  mgl32.Vec3
+	HorizonColor mgl32.Vec3
+	GroundColor  mgl32.Vec3
+	// SunDirection points towards the sun. A zero SunColor leaves it out.
+	SunDirection mgl32.Vec3
+	SunColor     mgl32.Vec3
+	// SunSize is the sun disc's angular radius in radians
+	SunSize float32
+}
+
+// GradientSky is a daylight sky with the sun high in the south-west
+func GradientSky() Sky {
+	return Sky{
+		Kind:         SkyGradient,
+		Intensity:    1,
+		ZenithColor:  mgl32.Vec3{0.12, 0.3, 0.7},
+		HorizonColor: mgl32.Vec3{0.65, 0.75, 0.85},
+		GroundColor:  mgl32.Vec3{0.2, 0.18, 0.16},
+		SunDirection: mgl32.Vec3{-0.4, 0.6, -0.7}.Normalize(),
+		SunColor:     mgl32.Vec3{20, 18, 15},
+		SunSize:      0.01,
+	}
+}
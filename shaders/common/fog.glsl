@@ -0,0 +1,37 @@
+// Distance and height fog, the same for every shader that includes it after
+// common/frame.glsl. Modes match FogMode in fog.go.
+
+#define FOG_NONE 0
+#define FOG_LINEAR 1
+#define FOG_EXPONENTIAL 2
+#define FOG_HEIGHT 3
+
+// FogFactor returns how much of the fog color covers a surface at worldPos
+// seen from viewPos, from 0 to 1
+float FogFactor(vec3 worldPos)
+{
+    vec3 ray = worldPos - viewPos;
+    float distance = length(ray);
+
+    if (fog.mode == FOG_LINEAR) {
+        return clamp((distance - fog.start) / max(fog.end - fog.start, 0.0001), 0.0, 1.0);
+    }
+    if (fog.mode == FOG_EXPONENTIAL) {
+        return 1.0 - exp(-fog.density * distance);
+    }
+    if (fog.mode == FOG_HEIGHT) {
+        // Density falls off exponentially above fog.height. Integrate it
+        // along the ray from the eye to the surface.
+        float falloff = max(fog.heightFalloff, 0.0001);
+        float eyeDensity = fog.density * exp(-falloff * (viewPos.y - fog.height));
+        float rise = falloff * ray.y;
+        float integral = abs(rise) > 0.0001 ? (1.0 - exp(-rise)) / rise : 1.0;
+        return clamp(1.0 - exp(-eyeDensity * distance * integral), 0.0, 1.0);
+    }
+    return 0.0;
+}
+
+vec3 ApplyFog(vec3 color, vec3 worldPos)
+{
+    return mix(color, fog.color, FogFactor(worldPos));
+}
@@ -0,0 +1,39 @@
+// Uniform blocks shared by every shader program. FrameData is bound once per
+// view, ObjectData once per draw. The Go side mirrors these in frameuniforms.go.
+
+#ifndef MAX_LIGHTS
+#define MAX_LIGHTS 8
+#endif
+
+struct PointLight {
+    vec3 position;
+    vec3 color;
+    float range;
+    int shadowLayer;
+};
+
+// Fog settings of the scene, see common/fog.glsl and fog.go
+struct Fog {
+    vec3 color;
+    int mode;
+    float start;
+    float end;
+    float density;
+    float height;
+    float heightFalloff;
+};
+
+layout (std140) uniform FrameData {
+    mat4 view;
+    mat4 projection;
+    vec3 viewPos;
+    float time;
+    int lightCount;
+    PointLight lights[MAX_LIGHTS];
+    Fog fog;
+};
+
+layout (std140) uniform ObjectData {
+    mat4 model;
+    mat3 normalMatrix;
+};
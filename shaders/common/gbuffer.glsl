@@ -0,0 +1,37 @@
+// G-buffer outputs of the deferred geometry pass. Material shaders include this
+// and call one of the Write functions instead of shading when compiled with
+// DEFERRED; the Go side lays out the attachments in deferredrenderer.go.
+//
+// gMaterial's alpha holds the surface's shading model, so deferred/light.frag
+// shades it as the material's own forward shader would:
+//   Phong          albedo = diffuse, gMaterial.rgb = specular, gNormal.a = shininess
+//   Cook-Torrance  albedo, gMaterial.rg = metallic and roughness
+
+const float ShadingPhong = 0.0;
+const float ShadingCookTorrance = 1.0;
+
+#ifdef DEFERRED
+layout (location = 0) out vec4 gAlbedo;
+layout (location = 1) out vec4 gNormal;
+layout (location = 2) out vec4 gMaterial;
+layout (location = 3) out vec4 gLight;
+
+// WritePhongGBuffer stores a surface of default.frag. emission is added to the
+// light buffer as is, for ambient and self-lit surfaces.
+void WritePhongGBuffer(vec3 diffuse, vec3 specular, float shininess, vec3 normal, vec3 emission)
+{
+    gAlbedo = vec4(diffuse, 1.0);
+    gNormal = vec4(normalize(normal), shininess);
+    gMaterial = vec4(specular, ShadingPhong);
+    gLight = vec4(emission, 1.0);
+}
+
+// WriteCookTorranceGBuffer stores a surface of pbr.frag
+void WriteCookTorranceGBuffer(vec3 albedo, float metallic, float roughness, vec3 normal, vec3 emission)
+{
+    gAlbedo = vec4(albedo, 1.0);
+    gNormal = vec4(normalize(normal), 0.0);
+    gMaterial = vec4(metallic, roughness, 0.0, ShadingCookTorrance);
+    gLight = vec4(emission, 1.0);
+}
+#endif
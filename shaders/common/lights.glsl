@@ -0,0 +1,55 @@
+// Point lights and omnidirectional shadows shared by the lit shaders.
+
+#include "common/frame.glsl"
+
+uniform samplerCubeArray pointShadowMaps;
+uniform float shadowBias;
+uniform float shadowFilterRadius;
+uniform int shadowFilterSamples;
+
+const vec3 shadowSampleOffsets[20] = vec3[](
+    vec3( 1,  1,  1), vec3( 1, -1,  1), vec3(-1, -1,  1), vec3(-1,  1,  1),
+    vec3( 1,  1, -1), vec3( 1, -1, -1), vec3(-1, -1, -1), vec3(-1,  1, -1),
+    vec3( 1,  1,  0), vec3( 1, -1,  0), vec3(-1, -1,  0), vec3(-1,  1,  0),
+    vec3( 1,  0,  1), vec3(-1,  0,  1), vec3( 1,  0, -1), vec3(-1,  0, -1),
+    vec3( 0,  1,  1), vec3( 0, -1,  1), vec3( 0, -1, -1), vec3( 0,  1, -1)
+);
+
+// PointShadow returns how much of the light the surface at fragPos, facing
+// normal, is shadowed from
+float PointShadow(PointLight light, vec3 fragPos, vec3 normal)
+{
+    if (light.shadowLayer < 0) {
+        return 0.0;
+    }
+
+    vec3 fragToLight = fragPos - light.position;
+    float currentDepth = length(fragToLight);
+    float viewDistance = length(viewPos - fragPos);
+    float diskRadius = (1.0 + viewDistance / light.range) * shadowFilterRadius * light.range;
+    int samples = clamp(shadowFilterSamples, 1, 20);
+
+    // Samples across the disk land on parts of a surface tilted towards the
+    // light that are nearer to it, by up to the disk's radius times the tilt's
+    // tangent. Without that in the bias, lit surfaces shadow themselves in
+    // bands.
+    float cosTilt = clamp(dot(normal, -fragToLight / currentDepth), 0.1, 1.0);
+    float bias = shadowBias + diskRadius * sqrt(1.0 - cosTilt * cosTilt) / cosTilt;
+
+    // Percentage-closer filtering over a fixed set of offset directions
+    float shadow = 0.0;
+    for (int i = 0; i < samples; ++i) {
+        vec3 dir = fragToLight + shadowSampleOffsets[i] * diskRadius;
+        float closestDepth = texture(pointShadowMaps, vec4(dir, float(light.shadowLayer))).r * light.range;
+        if (currentDepth - bias > closestDepth) {
+            shadow += 1.0;
+        }
+    }
+    return shadow / float(samples);
+}
+
+float PointAttenuation(PointLight light, float distance)
+{
+    float attenuation = clamp(1.0 - distance / light.range, 0.0, 1.0);
+    return attenuation * attenuation;
+}
@@ -0,0 +1,14 @@
+// The Phong material of default.frag, also read by the depth prepass to
+// discard cutout fragments
+
+layout (std140) uniform PhongMaterial {
+    vec3 ambient;
+    vec3 diffuse;
+    vec3 specular;
+    float shininess;
+    float opacity;
+    float alphaCutoff;
+    bool premultiplied;
+} material;
+
+uniform sampler2D materialTexture;
@@ -0,0 +1,10 @@
+// Screen space ambient occlusion computed before the main pass. Materials
+// multiply their ambient term with AmbientOcclusion(); without SSAO the
+// sampler holds a white texture.
+
+uniform sampler2D ambientOcclusionMap;
+
+float AmbientOcclusion()
+{
+    return texture(ambientOcclusionMap, gl_FragCoord.xy / vec2(textureSize(ambientOcclusionMap, 0))).r;
+}
@@ -1,39 +1,70 @@
 #version 410 core
 
-struct Material {
-    vec3 ambient;
-    vec3 diffuse;
-    vec3 specular;
-    float shininess;
-    sampler2D texture;
-};
-
-uniform Material material;
+#include "common/lights.glsl"
+#include "common/gbuffer.glsl"
+#include "common/ssao.glsl"
+#include "common/fog.glsl"
+#include "common/phongmaterial.glsl"
 
 in vec2 TexCoord;
 in vec3 Normal;
 in vec3 FragPos;
+in vec4 Tint;
 
+#ifndef DEFERRED
 out vec4 FragColor;
+#endif
 
-void main()
+vec3 Shade(vec3 lightDir, vec3 lightColor, vec3 norm, vec3 viewDir, vec3 texColor)
 {
-    // Ambient
-    vec3 ambient = material.ambient * texture(material.texture, TexCoord).rgb;
-
-    // Diffuse
-    vec3 norm = normalize(Normal);
-    vec3 lightDir = normalize(vec3(0.0, 0.0, 1.0)); // For simplicity, we set light direction as a constant
     float diff = max(dot(norm, lightDir), 0.0);
-    vec3 diffuse = material.diffuse * diff * texture(material.texture, TexCoord).rgb;
+    vec3 diffuse = material.diffuse * diff * texColor;
 
-    // Specular
-    vec3 viewDir = normalize(-FragPos);
     vec3 reflectDir = reflect(-lightDir, norm);
     float spec = pow(max(dot(viewDir, reflectDir), 0.0), material.shininess);
-    vec3 specular = material.specular * spec * texture(material.texture, TexCoord).rgb;
+    vec3 specular = material.specular * spec * texColor;
+
+    return (diffuse + specular) * lightColor;
+}
+
+void main()
+{
+    vec4 texSample = texture(materialTexture, TexCoord) * Tint;
+    vec3 texColor = texSample.rgb;
+    float alpha = texSample.a * material.opacity;
+    if (alpha < material.alphaCutoff) {
+        discard;
+    }
+
+    vec3 norm = normalize(Normal);
+
+#ifdef DEFERRED
+    WritePhongGBuffer(material.diffuse * texColor, material.specular * texColor, material.shininess, norm, material.ambient * texColor);
+#else
+    vec3 viewDir = normalize(viewPos - FragPos);
+
+    // Ambient
+    vec3 result = material.ambient * texColor * AmbientOcclusion();
+
+    if (lightCount == 0) {
+        // No scene lights, fall back to a constant directional light
+        result += Shade(normalize(vec3(0.0, 0.0, 1.0)), vec3(1.0), norm, viewDir, texColor);
+    }
+
+    for (int i = 0; i < lightCount && i < MAX_LIGHTS; ++i) {
+        vec3 toLight = lights[i].position - FragPos;
+        float distance = length(toLight);
+        float attenuation = PointAttenuation(lights[i], distance);
+        float shadow = PointShadow(lights[i], FragPos, norm);
+        result += (1.0 - shadow) * attenuation * Shade(toLight / distance, lights[i].color, norm, viewDir, texColor);
+    }
+
+    result = ApplyFog(result, FragPos);
+
+    if (material.premultiplied) {
+        result *= material.opacity;
+    }
 
-    // Combine components
-    vec3 result = ambient + diffuse + specular;
-    FragColor = vec4(result, 1.0);
+    FragColor = vec4(result, alpha);
+#endif
 }
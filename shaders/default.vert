@@ -1,21 +1,36 @@
 #version 410 core
 
+#include "common/frame.glsl"
+
 layout (location = 0) in vec3 aPos;
 layout (location = 1) in vec2 aTexCoord;
 layout (location = 2) in vec3 aNormal;
 
-uniform mat4 model;
-uniform mat4 view;
-uniform mat4 projection;
+#ifdef INSTANCED
+// Per-instance attributes, the matrix takes locations 3 to 6
+layout (location = 3) in mat4 aInstanceModel;
+layout (location = 7) in vec4 aInstanceColor;
+#endif
 
 out vec2 TexCoord;
 out vec3 Normal;
 out vec3 FragPos;
+out vec4 Tint;
 
 void main()
 {
-    gl_Position = projection * view * model * vec4(aPos, 1.0);
+#ifdef INSTANCED
+    mat4 objectModel = aInstanceModel;
+    mat3 objectNormalMatrix = transpose(inverse(mat3(aInstanceModel)));
+    Tint = aInstanceColor;
+#else
+    mat4 objectModel = model;
+    mat3 objectNormalMatrix = normalMatrix;
+    Tint = vec4(1.0);
+#endif
+
+    gl_Position = projection * view * objectModel * vec4(aPos, 1.0);
     TexCoord = aTexCoord;
-    FragPos = vec3(model * vec4(aPos, 1.0));
-    Normal = mat3(transpose(inverse(model))) * aNormal;
+    FragPos = vec3(objectModel * vec4(aPos, 1.0));
+    Normal = objectNormalMatrix * aNormal;
 }
@@ -0,0 +1,26 @@
+#version 410 core
+
+#include "common/frame.glsl"
+#include "common/fog.glsl"
+
+// Fogs the lit G-buffer surfaces, blended over the light buffer with the fog
+// factor as alpha
+
+uniform sampler2D gDepth;
+uniform mat4 inverseViewProjection;
+
+out vec4 FragColor;
+
+void main()
+{
+    ivec2 texel = ivec2(gl_FragCoord.xy);
+    float depth = texelFetch(gDepth, texel, 0).r;
+    if (depth == 1.0) {
+        // The sky is fogged when it is drawn
+        discard;
+    }
+
+    vec2 uv = gl_FragCoord.xy / vec2(textureSize(gDepth, 0));
+    vec4 world = inverseViewProjection * vec4(vec3(uv, depth) * 2.0 - 1.0, 1.0);
+    FragColor = vec4(fog.color, FogFactor(world.xyz / world.w));
+}
@@ -0,0 +1,75 @@
+#version 410 core
+
+#include "common/lights.glsl"
+#include "common/brdf.glsl"
+
+// Lights the surfaces in the G-buffer covered by one light volume, adding the
+// result to the light buffer
+
+uniform sampler2D gAlbedo;
+uniform sampler2D gNormal;
+uniform sampler2D gMaterial;
+uniform sampler2D gDepth;
+
+uniform mat4 inverseViewProjection;
+
+uniform vec3 lightColor;
+#ifndef DIRECTIONAL
+uniform vec3 lightPosition;
+uniform float lightRange;
+uniform int lightShadowLayer;
+#endif
+
+out vec4 FragColor;
+
+// ShadePhong is default.frag's Shade
+vec3 ShadePhong(vec3 N, vec3 V, vec3 L, vec3 diffuse, vec3 specular, float shininess)
+{
+    float diff = max(dot(N, L), 0.0);
+    float spec = pow(max(dot(V, reflect(-L, N)), 0.0), shininess);
+    return diffuse * diff + specular * spec;
+}
+
+void main()
+{
+    ivec2 texel = ivec2(gl_FragCoord.xy);
+    float depth = texelFetch(gDepth, texel, 0).r;
+    if (depth == 1.0) {
+        // Background, nothing was drawn here
+        discard;
+    }
+
+    vec2 uv = gl_FragCoord.xy / vec2(textureSize(gDepth, 0));
+    vec4 world = inverseViewProjection * vec4(vec3(uv, depth) * 2.0 - 1.0, 1.0);
+    vec3 fragPos = world.xyz / world.w;
+
+    vec3 albedo = texelFetch(gAlbedo, texel, 0).rgb;
+    vec4 normal = texelFetch(gNormal, texel, 0);
+    vec3 N = normalize(normal.xyz);
+    vec4 surface = texelFetch(gMaterial, texel, 0);
+    bool cookTorrance = surface.a > 0.5;
+    vec3 V = normalize(viewPos - fragPos);
+
+#ifdef DIRECTIONAL
+    // The constant light of each forward shader when the scene has none
+    vec3 L = cookTorrance ? normalize(vec3(-1.0, 0.5, -1.0)) : vec3(0.0, 0.0, 1.0);
+    vec3 radiance = lightColor;
+#else
+    PointLight light = PointLight(lightPosition, lightColor, lightRange, lightShadowLayer);
+    vec3 toLight = lightPosition - fragPos;
+    float distance = length(toLight);
+    if (distance >= lightRange) {
+        discard;
+    }
+    vec3 L = toLight / distance;
+    vec3 radiance = lightColor * PointAttenuation(light, distance) * (1.0 - PointShadow(light, fragPos, N));
+#endif
+
+    vec3 color;
+    if (cookTorrance) {
+        color = CookTorrance(N, V, L, albedo, surface.r, surface.g);
+    } else {
+        color = ShadePhong(N, V, L, albedo, surface.rgb, normal.a);
+    }
+    FragColor = vec4(color * radiance, 1.0);
+}
@@ -0,0 +1,23 @@
+#version 410 core
+
+#include "common/frame.glsl"
+
+#ifdef DIRECTIONAL
+// A single triangle covering the screen, generated from the vertex index
+void main()
+{
+    vec2 corner = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+    gl_Position = vec4(corner * 2.0 - 1.0, 0.0, 1.0);
+}
+#else
+// A sphere around the light, large enough to contain its range
+layout (location = 0) in vec3 aPos;
+
+uniform vec3 lightPosition;
+uniform float volumeRadius;
+
+void main()
+{
+    gl_Position = projection * view * vec4(lightPosition + aPos * volumeRadius, 1.0);
+}
+#endif
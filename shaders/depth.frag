@@ -0,0 +1,20 @@
+#version 410 core
+
+#ifdef CUTOUT
+// Cutout materials discard what default.frag discards, so they don't occlude
+// through their holes
+#include "common/phongmaterial.glsl"
+
+in vec2 TexCoord;
+in vec4 Tint;
+#endif
+
+void main()
+{
+#ifdef CUTOUT
+    float alpha = texture(materialTexture, TexCoord).a * Tint.a * material.opacity;
+    if (alpha < material.alphaCutoff) {
+        discard;
+    }
+#endif
+}
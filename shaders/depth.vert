@@ -0,0 +1,38 @@
+#version 410 core
+
+// Depth only pass, drawn before the main pass for screen space effects that
+// need the scene's depth up front
+
+#include "common/frame.glsl"
+
+layout (location = 0) in vec3 aPos;
+#ifdef CUTOUT
+layout (location = 1) in vec2 aTexCoord;
+#endif
+#ifdef INSTANCED
+layout (location = 3) in mat4 aInstanceModel;
+layout (location = 7) in vec4 aInstanceColor;
+#endif
+
+#ifdef CUTOUT
+out vec2 TexCoord;
+out vec4 Tint;
+#endif
+
+void main()
+{
+#ifdef INSTANCED
+    gl_Position = projection * view * aInstanceModel * vec4(aPos, 1.0);
+#else
+    gl_Position = projection * view * model * vec4(aPos, 1.0);
+#endif
+
+#ifdef CUTOUT
+    TexCoord = aTexCoord;
+#ifdef INSTANCED
+    Tint = aInstanceColor;
+#else
+    Tint = vec4(1.0);
+#endif
+#endif
+}
@@ -1,75 +1,108 @@
 #version 410 core
 
+#include "common/lights.glsl"
+#include "common/brdf.glsl"
+#include "common/ssao.glsl"
+#include "common/fog.glsl"
+#include "common/gbuffer.glsl"
+
 in vec3 fragNormal;
 in vec3 fragPos;
 in vec2 fragTexCoord;
+// fragTint is the instance color of the INSTANCED variant, white otherwise
+in vec4 fragTint;
 
+#ifndef DEFERRED
 out vec4 fragColor;
+#endif
 
-uniform vec3 camPos;
-uniform vec3 albedo;
-uniform float metallic;
-uniform float roughness;
+layout (std140) uniform PBRMaterialData {
+    vec3 albedo;
+    float metallic;
+    float roughness;
+};
 
+#ifdef HAS_ALBEDO_MAP
 uniform sampler2D albedoMap;
+#endif
+#ifdef HAS_NORMAL_MAP
 uniform sampler2D normalMap;
+#endif
+#ifdef HAS_METALLIC_MAP
 uniform sampler2D metallicMap;
+#endif
+#ifdef HAS_ROUGHNESS_MAP
 uniform sampler2D roughnessMap;
+#endif
+#ifdef HAS_AO_MAP
 uniform sampler2D aoMap;
+#endif
 
-const float PI = 3.14159265359;
+#ifdef HAS_NORMAL_MAP
+// Perturb the normal with a cotangent frame built from screen space
+// derivatives, so meshes do not need a tangent attribute.
+vec3 PerturbNormal(vec3 N, vec3 p, vec2 uv) {
+    vec3 dp1 = dFdx(p);
+    vec3 dp2 = dFdy(p);
+    vec2 duv1 = dFdx(uv);
+    vec2 duv2 = dFdy(uv);
 
-vec3 FresnelSchlick(float cosTheta, vec3 F0) {
-    return F0 + (1.0 - F0) * pow(1.0 - cosTheta, 5.0);
-}
+    vec3 dp2perp = cross(dp2, N);
+    vec3 dp1perp = cross(N, dp1);
+    vec3 T = dp2perp * duv1.x + dp1perp * duv2.x;
+    vec3 B = dp2perp * duv1.y + dp1perp * duv2.y;
+    float invmax = inversesqrt(max(dot(T, T), dot(B, B)));
+    mat3 TBN = mat3(T * invmax, B * invmax, N);
 
-float DistributionGGX(vec3 N, vec3 H, float roughness) {
-    float a = roughness * roughness;
-    float a2 = a * a;
-    float NdotH = max(dot(N, H), 0.0);
-    float NdotH2 = NdotH * NdotH;
-    float d = (NdotH2 * (a2 - 1.0) + 1.0);
-    return a2 / (PI * d * d);
+    vec3 mapped = texture(normalMap, uv).xyz * 2.0 - 1.0;
+    return normalize(TBN * mapped);
 }
+#endif
 
-float GeometrySchlickGGX(float NdotV, float roughness) {
-    float r = (roughness + 1.0);
-    float k = (r*r) / 8.0;
+void main() {
+    vec3 N = normalize(fragNormal);
+    vec3 V = normalize(viewPos - fragPos);
 
-    float vis = NdotV / (NdotV * (1.0 - k) + k);
-    return vis;
-}
+    vec3 baseColor = albedo * fragTint.rgb;
+    float metal = metallic;
+    float rough = roughness;
+    float ao = 1.0;
+#ifdef HAS_ALBEDO_MAP
+    baseColor *= texture(albedoMap, fragTexCoord).rgb;
+#endif
+#ifdef HAS_METALLIC_MAP
+    metal *= texture(metallicMap, fragTexCoord).r;
+#endif
+#ifdef HAS_ROUGHNESS_MAP
+    rough *= texture(roughnessMap, fragTexCoord).r;
+#endif
+#ifdef HAS_AO_MAP
+    ao = texture(aoMap, fragTexCoord).r;
+#endif
+#ifdef HAS_NORMAL_MAP
+    N = PerturbNormal(N, fragPos, fragTexCoord);
+#endif
 
-float GeometrySmith(vec3 N, vec3 V, vec3 L, float roughness) {
-    float NdotV = max(dot(N, V), 0.0);
-    float NdotL = max(dot(N, L), 0.0);
-    float ggx2 = GeometrySchlickGGX(NdotV, roughness);
-    float ggx1 = GeometrySchlickGGX(NdotL, roughness);
-    return ggx1 * ggx2;
-}
+#ifdef DEFERRED
+    // SSAO and fog are applied to the G-buffer by the deferred renderer
+    WriteCookTorranceGBuffer(baseColor, metal, rough, N, vec3(0.03) * baseColor * ao);
+#else
+    vec3 Lo = vec3(0.0);
+    if (lightCount == 0) {
+        // No scene lights, fall back to a constant directional light
+        Lo += CookTorrance(N, V, normalize(vec3(-1.0, 0.5, -1.0)), baseColor, metal, rough);
+    }
 
-vec3 CookTorranceBRDF(vec3 N, vec3 V, vec3 L, vec3 albedo, float metallic, float roughness) {
-    vec3 H = normalize(V + L);
-    vec3 F0 = vec3(0.04);
-    F0 = mix(F0, albedo, metallic);
-    vec3 F = FresnelSchlick(max(dot(H, V), 0.0), F0);
-    float D = DistributionGGX(N, H, roughness);
-    float G = GeometrySmith(N, V, L, roughness);
-    return (F * D * G) / (4.0 * max(dot(N, L), 0.0));
-}
+    for (int i = 0; i < lightCount && i < MAX_LIGHTS; ++i) {
+        vec3 toLight = lights[i].position - fragPos;
+        float distance = length(toLight);
+        float attenuation = PointAttenuation(lights[i], distance);
+        float shadow = PointShadow(lights[i], fragPos, N);
+        vec3 radiance = lights[i].color * attenuation * (1.0 - shadow);
+        Lo += CookTorrance(N, V, toLight / distance, baseColor, metal, rough) * radiance;
+    }
 
-void main() {
-    vec3 N = normalize(fragNormal);
-    vec3 V = normalize(camPos - fragPos);
-    vec3 albedoTex = texture(albedoMap, fragTexCoord).rgb;
-    vec3 albedo = albedo * albedoTex;
-    float metallicTex = texture(metallicMap, fragTexCoord).r;
-    float metallic = metallic * metallicTex;
-    float roughnessTex = texture(roughnessMap, fragTexCoord).r;
-    float roughness = roughness * roughnessTex;
-    vec3 L = normalize(vec3(-1.0, 0.5, -1.0));
-    vec3 diffuse = albedo * (1.0 - metallic);
-    vec3 specular = CookTorranceBRDF(N, V, L, albedo, metallic, roughness);
-    vec3 ambient = vec3(0.03) * albedo;
-    fragColor = vec4(diffuse + specular + ambient, 1.0);
+    vec3 ambient = vec3(0.03) * baseColor * ao * AmbientOcclusion();
+    fragColor = vec4(ApplyFog(Lo + ambient, fragPos), 1.0);
+#endif
 }
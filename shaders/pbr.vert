@@ -1,21 +1,36 @@
 #version 410
 
-in vec3 position;
-in vec3 normal;
-in vec2 texCoord;
+#include "common/frame.glsl"
 
-uniform mat4 model;
-uniform mat4 view;
-uniform mat4 projection;
+layout (location = 0) in vec3 position;
+layout (location = 1) in vec2 texCoord;
+layout (location = 2) in vec3 normal;
+
+#ifdef INSTANCED
+// Per-instance attributes, the matrix takes locations 3 to 6
+layout (location = 3) in mat4 aInstanceModel;
+layout (location = 7) in vec4 aInstanceColor;
+#endif
 
 out vec3 fragPos;
 out vec3 fragNormal;
 out vec2 fragTexCoord;
+out vec4 fragTint;
 
 void main()
 {
-    gl_Position = projection * view * model * vec4(position, 1.0);
-    fragPos = vec3(model * vec4(position, 1.0));
-    fragNormal = mat3(transpose(inverse(model))) * normal;
+#ifdef INSTANCED
+    mat4 objectModel = aInstanceModel;
+    mat3 objectNormalMatrix = transpose(inverse(mat3(aInstanceModel)));
+    fragTint = aInstanceColor;
+#else
+    mat4 objectModel = model;
+    mat3 objectNormalMatrix = normalMatrix;
+    fragTint = vec4(1.0);
+#endif
+
+    gl_Position = projection * view * objectModel * vec4(position, 1.0);
+    fragPos = vec3(objectModel * vec4(position, 1.0));
+    fragNormal = objectNormalMatrix * normal;
     fragTexCoord = texCoord;
 }
@@ -0,0 +1,68 @@
+#version 410 core
+
+// The passes of Bloom, one per define: PREFILTER keeps what is brighter than
+// the threshold while halving the image, DOWNSAMPLE halves it further,
+// UPSAMPLE blurs a level into the next larger one and COMBINE adds the result
+// to the scene.
+
+uniform sampler2D sourceTexture;
+uniform vec2 texelSize;
+
+#ifdef PREFILTER
+uniform float threshold;
+uniform float knee;
+#endif
+#ifdef COMBINE
+uniform sampler2D bloomTexture;
+uniform float intensity;
+#endif
+
+in vec2 TexCoord;
+
+out vec4 FragColor;
+
+// Box filter over 4x4 texels using four bilinear taps
+vec3 Downsample(vec2 uv)
+{
+    vec4 offset = texelSize.xyxy * vec4(-1.0, -1.0, 1.0, 1.0);
+    return 0.25 * (texture(sourceTexture, uv + offset.xy).rgb +
+                   texture(sourceTexture, uv + offset.zy).rgb +
+                   texture(sourceTexture, uv + offset.xw).rgb +
+                   texture(sourceTexture, uv + offset.zw).rgb);
+}
+
+// 3x3 tent filter
+vec3 Upsample(vec2 uv)
+{
+    vec4 offset = texelSize.xyxy * vec4(1.0, 1.0, -1.0, 0.0);
+    vec3 sum = texture(sourceTexture, uv - offset.xy).rgb;
+    sum += texture(sourceTexture, uv - offset.wy).rgb * 2.0;
+    sum += texture(sourceTexture, uv - offset.zy).rgb;
+    sum += texture(sourceTexture, uv + offset.zw).rgb * 2.0;
+    sum += texture(sourceTexture, uv).rgb * 4.0;
+    sum += texture(sourceTexture, uv + offset.xw).rgb * 2.0;
+    sum += texture(sourceTexture, uv + offset.zy).rgb;
+    sum += texture(sourceTexture, uv + offset.wy).rgb * 2.0;
+    sum += texture(sourceTexture, uv + offset.xy).rgb;
+    return sum / 16.0;
+}
+
+void main()
+{
+#if defined(PREFILTER)
+    vec3 color = Downsample(TexCoord);
+    // Soft threshold, a quadratic ramp of width knee around the threshold
+    float brightness = max(color.r, max(color.g, color.b));
+    float soft = clamp(brightness - threshold + knee, 0.0, 2.0 * knee);
+    soft = soft * soft / (4.0 * knee + 0.0001);
+    float contribution = max(soft, brightness - threshold) / max(brightness, 0.0001);
+    FragColor = vec4(color * contribution, 1.0);
+#elif defined(DOWNSAMPLE)
+    FragColor = vec4(Downsample(TexCoord), 1.0);
+#elif defined(UPSAMPLE)
+    FragColor = vec4(Upsample(TexCoord), 1.0);
+#else
+    vec3 color = texture(sourceTexture, TexCoord).rgb;
+    FragColor = vec4(color + texture(bloomTexture, TexCoord).rgb * intensity, 1.0);
+#endif
+}
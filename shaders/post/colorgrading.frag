@@ -0,0 +1,34 @@
+#version 410 core
+
+#include "common/color.glsl"
+
+// Color grading through a lookup table laid out as a horizontal strip of
+// lutSize slices of lutSize x lutSize texels, red along x and green along y
+// within a slice, blue selecting the slice. The table maps sRGB encoded colors.
+
+uniform sampler2D sourceTexture;
+uniform sampler2D lut;
+uniform float lutSize;
+uniform float intensity;
+
+in vec2 TexCoord;
+
+out vec4 FragColor;
+
+vec3 SampleLUT(vec3 c)
+{
+    float slice = c.b * (lutSize - 1.0);
+    float slice0 = floor(slice);
+    float slice1 = min(slice0 + 1.0, lutSize - 1.0);
+    vec2 uv = (c.rg * (lutSize - 1.0) + 0.5) / vec2(lutSize * lutSize, lutSize);
+    vec3 color0 = texture(lut, uv + vec2(slice0 / lutSize, 0.0)).rgb;
+    vec3 color1 = texture(lut, uv + vec2(slice1 / lutSize, 0.0)).rgb;
+    return mix(color0, color1, slice - slice0);
+}
+
+void main()
+{
+    vec3 color = clamp(texture(sourceTexture, TexCoord).rgb, 0.0, 1.0);
+    vec3 graded = SRGBToLinear(SampleLUT(LinearToSRGB(color)));
+    FragColor = vec4(mix(color, graded, intensity), 1.0);
+}
@@ -0,0 +1,13 @@
+#version 410 core
+
+// A single triangle covering the screen, generated from the vertex index. Draw
+// it with DrawFullscreen.
+
+out vec2 TexCoord;
+
+void main()
+{
+    vec2 corner = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+    TexCoord = corner;
+    gl_Position = vec4(corner * 2.0 - 1.0, 0.0, 1.0);
+}
@@ -0,0 +1,47 @@
+#version 410 core
+
+#include "common/color.glsl"
+
+// Fast approximate anti-aliasing: blurs along the edge direction found from
+// the luma of the four diagonal neighbours
+
+uniform sampler2D sourceTexture;
+uniform vec2 texelSize;
+uniform float spanMax;
+
+in vec2 TexCoord;
+
+out vec4 FragColor;
+
+const float reduceMul = 1.0 / 8.0;
+const float reduceMin = 1.0 / 128.0;
+
+// Luma works on roughly perceptual values, the input is linear
+float Luma(vec3 c)
+{
+    return sqrt(Luminance(c));
+}
+
+void main()
+{
+    vec3 rgbM = texture(sourceTexture, TexCoord).rgb;
+    float lumaNW = Luma(texture(sourceTexture, TexCoord + vec2(-1.0, -1.0) * texelSize).rgb);
+    float lumaNE = Luma(texture(sourceTexture, TexCoord + vec2(1.0, -1.0) * texelSize).rgb);
+    float lumaSW = Luma(texture(sourceTexture, TexCoord + vec2(-1.0, 1.0) * texelSize).rgb);
+    float lumaSE = Luma(texture(sourceTexture, TexCoord + vec2(1.0, 1.0) * texelSize).rgb);
+    float lumaM = Luma(rgbM);
+    float lumaMin = min(lumaM, min(min(lumaNW, lumaNE), min(lumaSW, lumaSE)));
+    float lumaMax = max(lumaM, max(max(lumaNW, lumaNE), max(lumaSW, lumaSE)));
+
+    vec2 dir = vec2(-((lumaNW + lumaNE) - (lumaSW + lumaSE)), (lumaNW + lumaSW) - (lumaNE + lumaSE));
+    float dirReduce = max((lumaNW + lumaNE + lumaSW + lumaSE) * 0.25 * reduceMul, reduceMin);
+    float rcpDirMin = 1.0 / (min(abs(dir.x), abs(dir.y)) + dirReduce);
+    dir = clamp(dir * rcpDirMin, vec2(-spanMax), vec2(spanMax)) * texelSize;
+
+    vec3 rgbA = 0.5 * (texture(sourceTexture, TexCoord + dir * (1.0 / 3.0 - 0.5)).rgb +
+                       texture(sourceTexture, TexCoord + dir * (2.0 / 3.0 - 0.5)).rgb);
+    vec3 rgbB = rgbA * 0.5 + 0.25 * (texture(sourceTexture, TexCoord - dir * 0.5).rgb +
+                                     texture(sourceTexture, TexCoord + dir * 0.5).rgb);
+    float lumaB = Luma(rgbB);
+    FragColor = vec4((lumaB < lumaMin || lumaB > lumaMax) ? rgbA : rgbB, 1.0);
+}
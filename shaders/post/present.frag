@@ -0,0 +1,14 @@
+#version 410 core
+
+// Copies the processed image to the window, which encodes it to sRGB
+
+uniform sampler2D sourceTexture;
+
+in vec2 TexCoord;
+
+out vec4 FragColor;
+
+void main()
+{
+    FragColor = vec4(texture(sourceTexture, TexCoord).rgb, 1.0);
+}
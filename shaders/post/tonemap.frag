@@ -0,0 +1,29 @@
+#version 410 core
+
+uniform sampler2D sourceTexture;
+uniform float exposure;
+// toneMapper selects the curve, matching the ToneMapOperator constants
+uniform int toneMapper;
+
+in vec2 TexCoord;
+
+out vec4 FragColor;
+
+// Krzysztof Narkowicz's fit of the ACES filmic curve
+vec3 ACESFilm(vec3 x)
+{
+    return clamp((x * (2.51 * x + 0.03)) / (x * (2.43 * x + 0.59) + 0.14), 0.0, 1.0);
+}
+
+void main()
+{
+    vec3 color = texture(sourceTexture, TexCoord).rgb * exposure;
+    if (toneMapper == 0) {
+        color = ACESFilm(color);
+    } else if (toneMapper == 1) {
+        color = color / (1.0 + color);
+    } else {
+        color = clamp(color, 0.0, 1.0);
+    }
+    FragColor = vec4(color, 1.0);
+}
@@ -0,0 +1,19 @@
+#version 410 core
+
+uniform sampler2D sourceTexture;
+uniform float intensity;
+uniform float radius;
+uniform float softness;
+
+in vec2 TexCoord;
+
+out vec4 FragColor;
+
+void main()
+{
+    vec3 color = texture(sourceTexture, TexCoord).rgb;
+    // 0 at the center, 1 in the corners
+    float distance = length(TexCoord - 0.5) * sqrt(2.0);
+    float vignette = smoothstep(radius, radius - softness, distance);
+    FragColor = vec4(color * mix(1.0, vignette, intensity), 1.0);
+}
@@ -0,0 +1,12 @@
+#version 410 core
+
+in vec3 FragPos;
+
+uniform vec3 lightPos;
+uniform float farPlane;
+
+void main()
+{
+    // Store linear distance to the light so every cube face shares one depth scale
+    gl_FragDepth = length(FragPos - lightPos) / farPlane;
+}
@@ -0,0 +1,23 @@
+#version 410 core
+
+layout (location = 0) in vec3 aPos;
+
+#ifdef INSTANCED
+layout (location = 3) in mat4 aInstanceModel;
+#else
+uniform mat4 model;
+#endif
+uniform mat4 lightViewProjection;
+
+out vec3 FragPos;
+
+void main()
+{
+#ifdef INSTANCED
+    vec4 worldPos = aInstanceModel * vec4(aPos, 1.0);
+#else
+    vec4 worldPos = model * vec4(aPos, 1.0);
+#endif
+    FragPos = worldPos.xyz;
+    gl_Position = lightViewProjection * worldPos;
+}
@@ -0,0 +1,61 @@
+#version 410 core
+
+// Scene background, one of CUBEMAP, EQUIRECTANGULAR or GRADIENT
+
+#include "common/frame.glsl"
+#include "common/fog.glsl"
+
+#define PI 3.14159265359
+
+uniform float intensity;
+
+#if defined(CUBEMAP)
+uniform samplerCube skyTexture;
+#elif defined(EQUIRECTANGULAR)
+uniform sampler2D skyTexture;
+#else
+uniform vec3 zenithColor;
+uniform vec3 horizonColor;
+uniform vec3 groundColor;
+uniform vec3 sunDirection;
+uniform vec3 sunColor;
+uniform float sunSize;
+#endif
+
+in vec3 Direction;
+
+out vec4 FragColor;
+
+vec3 Sky(vec3 direction)
+{
+#if defined(CUBEMAP)
+    return texture(skyTexture, direction).rgb;
+#elif defined(EQUIRECTANGULAR)
+    vec2 uv = vec2(atan(direction.z, direction.x) / (2.0 * PI) + 0.5, 0.5 - asin(clamp(direction.y, -1.0, 1.0)) / PI);
+    // The longitude wraps around within a pixel at the seam, which would pick
+    // the smallest mip there
+    return textureLod(skyTexture, uv, 0.0).rgb;
+#else
+    float height = direction.y;
+    vec3 color = height > 0.0
+        ? mix(horizonColor, zenithColor, pow(height, 0.5))
+        : mix(horizonColor, groundColor, pow(-height, 0.3));
+
+    // Haze brightening the sky around the sun, then the disc itself
+    float sunAngle = acos(clamp(dot(direction, sunDirection), -1.0, 1.0));
+    color += sunColor * 0.02 * exp(-sunAngle * 4.0) * step(0.0, height);
+    color += sunColor * (1.0 - smoothstep(sunSize * 0.8, sunSize, sunAngle));
+    return color;
+#endif
+}
+
+void main()
+{
+    vec3 direction = normalize(Direction);
+
+    // Fog the sky as if it stood at the far plane, so distant geometry fades
+    // into it seamlessly
+    float far = projection[3][2] / (projection[2][2] + 1.0);
+    vec3 color = Sky(direction) * intensity;
+    FragColor = vec4(ApplyFog(color, viewPos + direction * far), 1.0);
+}
@@ -0,0 +1,19 @@
+#version 410 core
+
+// A triangle covering the screen at the far plane, as in post/fullscreen.vert,
+// passing on the view direction of each corner
+
+#include "common/frame.glsl"
+
+out vec3 Direction;
+
+void main()
+{
+    vec2 corner = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+    vec4 position = vec4(corner * 2.0 - 1.0, 1.0, 1.0);
+
+    // Unproject without the camera's translation, the sky is infinitely far
+    vec4 direction = inverse(projection * mat4(mat3(view))) * position;
+    Direction = direction.xyz / direction.w;
+    gl_Position = position;
+}
@@ -0,0 +1,15 @@
+#version 410 core
+
+// Outputs the occlusion as a color for multiplicative blending, darkening the
+// ambient light already in the deferred light buffer
+
+uniform sampler2D sourceTexture;
+
+in vec2 TexCoord;
+
+out vec4 FragColor;
+
+void main()
+{
+    FragColor = vec4(vec3(texture(sourceTexture, TexCoord).r), 1.0);
+}
@@ -0,0 +1,22 @@
+#version 410 core
+
+// Averages the 4x4 block the SSAO noise tiles over, removing its pattern
+
+uniform sampler2D sourceTexture;
+uniform vec2 texelSize;
+
+in vec2 TexCoord;
+
+out vec4 FragColor;
+
+void main()
+{
+    float result = 0.0;
+    for (int x = -2; x < 2; ++x) {
+        for (int y = -2; y < 2; ++y) {
+            result += texture(sourceTexture, TexCoord + vec2(x, y) * texelSize).r;
+        }
+    }
+    result /= 16.0;
+    FragColor = vec4(result, result, result, 1.0);
+}
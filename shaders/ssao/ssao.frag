@@ -0,0 +1,82 @@
+#version 410 core
+
+// Hemisphere SSAO in view space. Normals come from the G-buffer when compiled
+// with NORMAL_TEXTURE and are reconstructed from depth otherwise.
+
+#define MAX_SAMPLES 64
+
+uniform sampler2D depthTexture;
+uniform sampler2D noiseTexture;
+#ifdef NORMAL_TEXTURE
+// World space normals, brought into view space with viewMatrix
+uniform sampler2D normalTexture;
+uniform mat4 viewMatrix;
+#endif
+
+uniform mat4 projection;
+uniform mat4 inverseProjection;
+uniform vec3 kernel[MAX_SAMPLES];
+uniform int sampleCount;
+uniform float radius;
+uniform float bias;
+uniform float power;
+
+in vec2 TexCoord;
+
+out vec4 FragColor;
+
+vec3 ViewPosition(vec2 uv)
+{
+    float depth = texture(depthTexture, uv).r;
+    vec4 position = inverseProjection * vec4(vec3(uv, depth) * 2.0 - 1.0, 1.0);
+    return position.xyz / position.w;
+}
+
+void main()
+{
+    if (texture(depthTexture, TexCoord).r == 1.0) {
+        // Nothing drawn here, the sky isn't occluded
+        FragColor = vec4(1.0);
+        return;
+    }
+    vec3 position = ViewPosition(TexCoord);
+
+#ifdef NORMAL_TEXTURE
+    vec3 normal = normalize(mat3(viewMatrix) * texture(normalTexture, TexCoord).xyz);
+#else
+    // Take the smaller difference along each axis so the normal doesn't bend
+    // across depth discontinuities
+    vec2 texel = 1.0 / vec2(textureSize(depthTexture, 0));
+    vec3 right = ViewPosition(TexCoord + vec2(texel.x, 0.0)) - position;
+    vec3 left = position - ViewPosition(TexCoord - vec2(texel.x, 0.0));
+    vec3 up = ViewPosition(TexCoord + vec2(0.0, texel.y)) - position;
+    vec3 down = position - ViewPosition(TexCoord - vec2(0.0, texel.y));
+    vec3 dx = abs(right.z) < abs(left.z) ? right : left;
+    vec3 dy = abs(up.z) < abs(down.z) ? up : down;
+    vec3 normal = normalize(cross(dx, dy));
+#endif
+
+    // Rotate the kernel by the tiled noise, the blur pass removes the pattern
+    vec2 noiseScale = vec2(textureSize(depthTexture, 0)) / vec2(textureSize(noiseTexture, 0));
+    vec3 random = vec3(texture(noiseTexture, TexCoord * noiseScale).xy, 0.0);
+    vec3 tangent = normalize(random - normal * dot(random, normal));
+    vec3 bitangent = cross(normal, tangent);
+    mat3 TBN = mat3(tangent, bitangent, normal);
+
+    int samples = clamp(sampleCount, 1, MAX_SAMPLES);
+    float occlusion = 0.0;
+    for (int i = 0; i < samples; ++i) {
+        vec3 samplePosition = position + TBN * kernel[i] * radius;
+        vec4 offset = projection * vec4(samplePosition, 1.0);
+        vec2 uv = offset.xy / offset.w * 0.5 + 0.5;
+        float sampleDepth = ViewPosition(uv).z;
+
+        // Fade out occluders far outside the radius, such as the background
+        // behind an object's silhouette
+        float rangeCheck = smoothstep(0.0, 1.0, radius / abs(position.z - sampleDepth));
+        occlusion += (sampleDepth >= samplePosition.z + bias ? 1.0 : 0.0) * rangeCheck;
+    }
+
+    float ao = pow(1.0 - occlusion / float(samples), power);
+    FragColor = vec4(ao, ao, ao, 1.0);
+}
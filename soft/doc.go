@@ -0,0 +1,6 @@
+// Package soft is a renderer drawing engine scenes on the CPU into an
+// image.RGBA, for machines without a GPU. The drawing is done by package
+// soft/raster, which builds without cgo; this package hands it the engine's
+// scenes and textures. Package engine links OpenGL through cgo, so Renderer is
+// only built with cgo enabled and the package is empty without it.
+package soft
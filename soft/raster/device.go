@@ -0,0 +1,469 @@
+package raster
+
+import (
+	"fmt"
+	"image"
+	"physics/gfx"
+	"reflect"
+	"unsafe"
+)
+
+// Device is a gfx.Device drawing on the CPU with the Renderer's rasterizer, so
+// code written against gfx runs without a GPU or cgo. It can't compile GLSL:
+// programs are Go code registered with RegisterProgram. Only level 0 of
+// textures is stored and sampled, multisampling and anisotropy are ignored,
+// compressed uploads are dropped, only triangles are drawn and wireframe draws
+// are filled. The window is a linear RGBA8 color buffer with a depth buffer.
+type Device struct {
+	next uint32
+
+	buffers        map[uint32][]byte
+	uniformBuffers map[uint32]uint32
+	vertexArrays   map[uint32]gfx.VertexLayout
+	registry       map[string]*Program
+	programs       map[uint32]*linkedProgram
+	textures       map[uint32]*texture
+	units          map[int]uint32
+	renderbuffers  map[uint32]*surface
+	framebuffers   map[uint32]*framebuffer
+	window         *framebuffer
+
+	program     uint32
+	framebuffer uint32
+	viewport    image.Rectangle
+	pipeline    gfx.PipelineState
+	encodeSRGB  bool
+}
+
+var _ gfx.Device = (*Device)(nil)
+
+func NewDevice(width, height int) *Device {
+	window := &framebuffer{
+		colors: []attachment{{surface: newSurface(gfx.TexelFormat{Format: gfx.TextureFormatRGBA8}, width, height, gfx.SamplerState{})}},
+		depth:  &attachment{surface: newSurface(gfx.TexelFormat{Format: gfx.TextureFormatDepth24}, width, height, gfx.SamplerState{})},
+	}
+	return &Device{
+		buffers:        make(map[uint32][]byte),
+		uniformBuffers: make(map[uint32]uint32),
+		vertexArrays:   make(map[uint32]gfx.VertexLayout),
+		registry:       make(map[string]*Program),
+		programs:       make(map[uint32]*linkedProgram),
+		textures:       make(map[uint32]*texture),
+		units:          make(map[int]uint32),
+		renderbuffers:  make(map[uint32]*surface),
+		framebuffers:   make(map[uint32]*framebuffer),
+		window:         window,
+		viewport:       image.Rect(0, 0, width, height),
+		pipeline:       gfx.DefaultPipelineState(),
+	}
+}
+
+// add returns a new handle after store has kept the object under it. Handles
+// are unique across kinds, 0 is never used.
+func (d *Device) add(store func(handle uint32)) uint32 {
+	d.next++
+	store(d.next)
+	return d.next
+}
+
+// Window returns the window's color buffer top row first, like
+// engine.CaptureFrame
+func (d *Device) Window() *image.RGBA {
+	s := d.window.colors[0].surface
+	img := image.NewRGBA(image.Rect(0, 0, s.width, s.height))
+	for y := 0; y < s.height; y++ {
+		for x := 0; x < s.width; x++ {
+			c := s.rgba8((s.height-1-y)*s.width + x)
+			copy(img.Pix[y*img.Stride+x*4:], c[:])
+		}
+	}
+	return img
+}
+
+// bytesOf copies up to size bytes of data, a slice or a pointer, into a
+// buffer of size bytes
+func bytesOf(data interface{}, size int) []byte {
+	buffer := make([]byte, size)
+	if data == nil {
+		return buffer
+	}
+	v := reflect.ValueOf(data)
+	var length int
+	switch v.Kind() {
+	case reflect.Slice:
+		length = v.Len() * int(v.Type().Elem().Size())
+	case reflect.Ptr:
+		length = int(v.Type().Elem().Size())
+	default:
+		return buffer
+	}
+	if length == 0 || v.Pointer() == 0 {
+		return buffer
+	}
+	copy(buffer, unsafe.Slice((*byte)(unsafe.Pointer(v.Pointer())), length))
+	return buffer
+}
+
+func (d *Device) CreateBuffer(kind gfx.BufferKind, size int, data interface{}, usage gfx.BufferUsage) uint32 {
+	return d.add(func(handle uint32) { d.buffers[handle] = bytesOf(data, size) })
+}
+
+func (d *Device) WriteBuffer(kind gfx.BufferKind, buffer uint32, offset, size int, data interface{}) {
+	contents := d.buffers[buffer]
+	if offset < 0 || offset >= len(contents) {
+		return
+	}
+	copy(contents[offset:], bytesOf(data, size))
+}
+
+func (d *Device) ReallocateBuffer(kind gfx.BufferKind, buffer uint32, size int, data interface{}, usage gfx.BufferUsage) {
+	if _, ok := d.buffers[buffer]; ok {
+		d.buffers[buffer] = bytesOf(data, size)
+	}
+}
+
+func (d *Device) DeleteBuffer(buffer uint32) {
+	delete(d.buffers, buffer)
+}
+
+func (d *Device) BindUniformBuffer(binding, buffer uint32) {
+	d.uniformBuffers[binding] = buffer
+}
+
+func (d *Device) CreateVertexArray(layout gfx.VertexLayout) uint32 {
+	layout.Attributes = append([]gfx.VertexAttribute(nil), layout.Attributes...)
+	return d.add(func(handle uint32) { d.vertexArrays[handle] = layout })
+}
+
+func (d *Device) DeleteVertexArray(vertexArray uint32) {
+	delete(d.vertexArrays, vertexArray)
+}
+
+// texture holds the images of a Device texture by level and layer. Cube map
+// faces are layers, six per cube of a cube map array.
+type texture struct {
+	sampler gfx.SamplerState
+	levels  [][]*surface
+}
+
+// image returns a layer of a level, nil when it was never uploaded
+func (t *texture) image(level, layer int) *surface {
+	if t == nil || level >= len(t.levels) || layer < 0 || layer >= len(t.levels[level]) {
+		return nil
+	}
+	return t.levels[level][layer]
+}
+
+// allocate replaces a layer of a level with an image of format
+func (t *texture) allocate(level, layer int, format gfx.TexelFormat, width, height int32) *surface {
+	if t == nil || level < 0 || level >= len(t.levels) || layer < 0 {
+		return nil
+	}
+	for len(t.levels[level]) <= layer {
+		t.levels[level] = append(t.levels[level], nil)
+	}
+	s := newSurface(format, int(width), int(height), t.sampler)
+	t.levels[level][layer] = s
+	return s
+}
+
+// faceLayer is the layer an upload to target goes to
+func faceLayer(target gfx.TextureTarget) int {
+	if target.IsCubeMapFace() {
+		return int(target - gfx.TextureCubeMapPositiveX)
+	}
+	return 0
+}
+
+func (d *Device) CreateTexture(target gfx.TextureTarget, levels int32) uint32 {
+	if levels < 1 {
+		levels = 1
+	}
+	t := &texture{levels: make([][]*surface, levels)}
+	return d.add(func(handle uint32) { d.textures[handle] = t })
+}
+
+func (d *Device) SetSampler(texture uint32, target gfx.TextureTarget, sampler gfx.SamplerState) {
+	t, ok := d.textures[texture]
+	if !ok {
+		return
+	}
+	t.sampler = sampler
+	for _, level := range t.levels {
+		for _, s := range level {
+			if s != nil {
+				s.sampler = sampler
+			}
+		}
+	}
+}
+
+func (d *Device) TexImage2D(texture uint32, target gfx.TextureTarget, level int32, format gfx.TexelFormat, width, height int32, pixels interface{}) {
+	if s := d.textures[texture].allocate(int(level), faceLayer(target), format, width, height); s != nil {
+		s.upload(pixels)
+	}
+}
+
+func (d *Device) TexImage3D(texture uint32, target gfx.TextureTarget, level int32, format gfx.TexelFormat, width, height, depth int32, pixels interface{}) {
+	t, ok := d.textures[texture]
+	if !ok {
+		return
+	}
+	size := int(width * height * int32(channels(format.Format)))
+	for layer := 0; layer < int(depth); layer++ {
+		s := t.allocate(int(level), layer, format, width, height)
+		if s == nil {
+			return
+		}
+		s.upload(layerPixels(pixels, layer, size))
+	}
+}
+
+// layerPixels returns the pixels of one layer of a 3D upload, size components
+// long, nil when pixels doesn't hold it
+func layerPixels(pixels interface{}, layer, size int) interface{} {
+	v := reflect.ValueOf(pixels)
+	if pixels == nil || v.Kind() != reflect.Slice || v.Len() < (layer+1)*size {
+		return nil
+	}
+	return v.Slice(layer*size, (layer+1)*size).Interface()
+}
+
+func (d *Device) TexLayer(texture uint32, target gfx.TextureTarget, level, layer int32, format gfx.TexelFormat, width, height int32, pixels interface{}) {
+	if s := d.textures[texture].image(int(level), int(layer)); s != nil && s.width == int(width) && s.height == int(height) {
+		s.upload(pixels)
+	}
+}
+
+// CompressedTexImage2D allocates the level without decoding it, it samples as
+// transparent black. FormatSupported reports compressed formats unsupported,
+// so the engine uploads them decoded.
+func (d *Device) CompressedTexImage2D(texture uint32, target gfx.TextureTarget, level int32, format gfx.TexelFormat, width, height int32, data []byte) {
+	d.textures[texture].allocate(int(level), faceLayer(target), format, width, height)
+}
+
+// GenerateMipmaps does nothing, only level 0 is sampled
+func (d *Device) GenerateMipmaps(texture uint32, target gfx.TextureTarget) {}
+
+func (d *Device) BindTexture(unit int, target gfx.TextureTarget, texture uint32) {
+	d.units[unit] = texture
+}
+
+func (d *Device) DeleteTexture(texture uint32) {
+	delete(d.textures, texture)
+}
+
+func (d *Device) MaxAnisotropy() float32 {
+	return 0
+}
+
+func (d *Device) FormatSupported(format gfx.TextureFormat) bool {
+	return !format.IsCompressed()
+}
+
+// CreateRenderbuffer ignores samples, every renderbuffer has one sample
+func (d *Device) CreateRenderbuffer(format gfx.TexelFormat, width, height, samples int32) uint32 {
+	s := newSurface(format, int(width), int(height), gfx.SamplerState{})
+	return d.add(func(handle uint32) { d.renderbuffers[handle] = s })
+}
+
+func (d *Device) DeleteRenderbuffer(renderbuffer uint32) {
+	delete(d.renderbuffers, renderbuffer)
+}
+
+func (d *Device) MaxSamples() int32 {
+	return 1
+}
+
+// attachment is an image a framebuffer draws to. Texture images are looked up
+// at each use, so uploads replacing them after they were attached are seen.
+type attachment struct {
+	texture uint32
+	layer   int
+	// surface is set for renderbuffers and the window
+	surface *surface
+}
+
+// framebuffer holds the attachments of a Device framebuffer
+type framebuffer struct {
+	colors []attachment
+	depth  *attachment
+}
+
+func (d *Device) resolve(a *attachment) *surface {
+	if a == nil {
+		return nil
+	}
+	if a.surface != nil {
+		return a.surface
+	}
+	return d.textures[a.texture].image(0, a.layer)
+}
+
+// color returns color attachment n of a framebuffer, nil when there is none
+func (d *Device) color(f *framebuffer, n int) *surface {
+	if f == nil || n < 0 || n >= len(f.colors) {
+		return nil
+	}
+	return d.resolve(&f.colors[n])
+}
+
+func (d *Device) framebufferOf(handle uint32) *framebuffer {
+	if handle == 0 {
+		return d.window
+	}
+	return d.framebuffers[handle]
+}
+
+// CreateFramebuffer fails when an attachment doesn't exist or the images
+// differ in size, the cases GL reports incomplete
+func (d *Device) CreateFramebuffer(attachments []gfx.FramebufferAttachment) (uint32, error) {
+	f := &framebuffer{}
+	width, height := -1, -1
+	for _, a := range attachments {
+		target := attachment{texture: a.Texture}
+		if a.Renderbuffer != 0 {
+			target = attachment{surface: d.renderbuffers[a.Renderbuffer]}
+		}
+		s := d.resolve(&target)
+		if s == nil {
+			return 0, fmt.Errorf("framebuffer incomplete: attachment %d has no image", a.Attachment)
+		}
+		if width >= 0 && (s.width != width || s.height != height) {
+			return 0, fmt.Errorf("framebuffer incomplete: attachment %d is %dx%d, not %dx%d", a.Attachment, s.width, s.height, width, height)
+		}
+		width, height = s.width, s.height
+
+		if a.Attachment.IsColor() {
+			n := int(a.Attachment - gfx.AttachmentColor0)
+			for len(f.colors) <= n {
+				f.colors = append(f.colors, attachment{})
+			}
+			f.colors[n] = target
+		} else {
+			f.depth = &target
+		}
+	}
+	return d.add(func(handle uint32) { d.framebuffers[handle] = f }), nil
+}
+
+func (d *Device) AttachLayer(framebuffer uint32, a gfx.Attachment, texture uint32, layer int32) {
+	f, ok := d.framebuffers[framebuffer]
+	if !ok {
+		return
+	}
+	target := attachment{texture: texture, layer: int(layer)}
+	if !a.IsColor() {
+		f.depth = &target
+		return
+	}
+	n := int(a - gfx.AttachmentColor0)
+	for len(f.colors) <= n {
+		f.colors = append(f.colors, attachment{})
+	}
+	f.colors[n] = target
+}
+
+func (d *Device) BindFramebuffer(framebuffer uint32) {
+	d.framebuffer = framebuffer
+}
+
+func (d *Device) BlitFramebuffer(from, to uint32, width, height int32, mask gfx.BufferMask, n int) {
+	src, dst := d.framebufferOf(from), d.framebufferOf(to)
+	if src == nil || dst == nil {
+		return
+	}
+	if mask&gfx.ColorBuffer != 0 {
+		if n < 0 {
+			// The current read buffer is attachment 0, written to every
+			// draw buffer
+			for i := range dst.colors {
+				d.copySurface(d.color(src, 0), d.color(dst, i), int(width), int(height))
+			}
+		} else {
+			d.copySurface(d.color(src, n), d.color(dst, n), int(width), int(height))
+		}
+	}
+	if mask&gfx.DepthBuffer != 0 {
+		d.copySurface(d.resolve(src.depth), d.resolve(dst.depth), int(width), int(height))
+	}
+}
+
+// copySurface copies the bottom left width by height texels of src to dst
+func (d *Device) copySurface(src, dst *surface, width, height int) {
+	if src == nil || dst == nil {
+		return
+	}
+	for y := 0; y < height && y < src.height && y < dst.height; y++ {
+		for x := 0; x < width && x < src.width && x < dst.width; x++ {
+			dst.store(y*dst.width+x, src.texels[y*src.width+x], true)
+		}
+	}
+}
+
+func (d *Device) DeleteFramebuffer(framebuffer uint32) {
+	if d.framebuffer == framebuffer {
+		d.framebuffer = 0
+	}
+	delete(d.framebuffers, framebuffer)
+}
+
+func (d *Device) ReadPixels(framebuffer uint32, n int, width, height int32, dst []byte) {
+	if framebuffer == 0 {
+		// The window's back buffer is its only color buffer
+		n = 0
+	}
+	s := d.color(d.framebufferOf(framebuffer), n)
+	if s == nil {
+		return
+	}
+	for y := 0; y < int(height) && y < s.height; y++ {
+		for x := 0; x < int(width) && x < s.width; x++ {
+			i := (y*int(width) + x) * 4
+			if i+4 > len(dst) {
+				return
+			}
+			c := s.rgba8(y*s.width + x)
+			copy(dst[i:], c[:])
+		}
+	}
+}
+
+// SetFramebufferSRGB applies to sRGB attachments, the window is linear
+func (d *Device) SetFramebufferSRGB(enabled bool) {
+	d.encodeSRGB = enabled
+}
+
+func (d *Device) Viewport(x, y, width, height int32) {
+	d.viewport = image.Rect(int(x), int(y), int(x+width), int(y+height))
+}
+
+// Clear fills the bound framebuffer's buffers in mask with transparent black
+// and the far plane. Like GL, depth is only cleared while depth writes are
+// enabled.
+func (d *Device) Clear(mask gfx.BufferMask) {
+	f := d.framebufferOf(d.framebuffer)
+	if f == nil {
+		return
+	}
+	if mask&gfx.ColorBuffer != 0 {
+		for i := range f.colors {
+			if s := d.color(f, i); s != nil {
+				s.clear()
+			}
+		}
+	}
+	if mask&gfx.DepthBuffer != 0 && d.pipeline.DepthWrite {
+		if s := d.resolve(f.depth); s != nil {
+			s.clear()
+		}
+	}
+}
+
+func (d *Device) SetPipeline(state gfx.PipelineState) {
+	d.pipeline = state
+}
+
+func (d *Device) Pipeline() gfx.PipelineState {
+	return d.pipeline
+}
@@ -0,0 +1,337 @@
+package raster
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"physics/gfx"
+	"testing"
+)
+
+var (
+	red         = [4]uint8{255, 0, 0, 255}
+	green       = [4]uint8{0, 255, 0, 255}
+	blue        = [4]uint8{0, 0, 255, 255}
+	transparent = [4]uint8{}
+)
+
+// colorProgram draws its positions, attribute 0 offset by attribute 1, in the
+// uniform color
+func colorProgram() *Program {
+	return &Program{
+		Uniforms: []gfx.ShaderUniform{{Name: "color", Type: gfx.TypeVec4, Size: 1, Location: 0, BlockIndex: -1}},
+		Attributes: []gfx.ShaderAttribute{
+			{Name: "aPos", Type: gfx.TypeVec3, Size: 1, Location: 0},
+			{Name: "aOffset", Type: gfx.TypeVec2, Size: 1, Location: 1},
+		},
+		Outputs: []string{"FragColor"},
+		Bind: func(u Uniforms) Shaders {
+			color := u.Vec4("color")
+			return Shaders{
+				Vertex: func(attributes []mgl32.Vec4, varyings []float32) mgl32.Vec4 {
+					return attributes[0].Add(mgl32.Vec4{attributes[1][0], attributes[1][1], 0, 0})
+				},
+				Fragment: func(varyings []float32, outputs []mgl32.Vec4) bool {
+					outputs[0] = color
+					return true
+				},
+			}
+		},
+	}
+}
+
+// newColorDevice returns a device of size by size pixels using colorProgram
+func newColorDevice(t *testing.T, size int) *Device {
+	d := NewDevice(size, size)
+	d.RegisterProgram("color", colorProgram())
+	program, err := d.CreateProgram("// soft: color\nvoid main() {}", "void main() {}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.UseProgram(program)
+	return d
+}
+
+// quad creates a vertex array of two triangles covering x0 to x1 and y0 to y1
+// in clip space at depth z
+func quad(d *Device, x0, y0, x1, y1, z float32) uint32 {
+	vertices := []float32{x0, y0, z, x1, y0, z, x1, y1, z, x0, y1, z}
+	indices := []uint32{0, 1, 2, 0, 2, 3}
+	vertexBuffer := d.CreateBuffer(gfx.BufferVertices, len(vertices)*4, vertices, gfx.UsageStatic)
+	indexBuffer := d.CreateBuffer(gfx.BufferIndices, len(indices)*4, indices, gfx.UsageStatic)
+	return d.CreateVertexArray(gfx.VertexLayout{
+		Attributes:  []gfx.VertexAttribute{{Location: 0, Buffer: vertexBuffer, Components: 3}},
+		IndexBuffer: indexBuffer,
+	})
+}
+
+func drawQuad(d *Device, vertexArray uint32, color mgl32.Vec4) {
+	d.SetUniform(0, color)
+	d.Draw(gfx.DrawCall{VertexArray: vertexArray, Mode: gfx.PrimitiveTriangles, Count: 6, Indexed: true})
+}
+
+// readPixels returns the pixels of a framebuffer's first color attachment,
+// bottom row first
+func readPixels(d *Device, framebuffer uint32, size int) [][4]uint8 {
+	data := make([]byte, size*size*4)
+	d.ReadPixels(framebuffer, 0, int32(size), int32(size), data)
+	pixels := make([][4]uint8, size*size)
+	for i := range pixels {
+		copy(pixels[i][:], data[i*4:])
+	}
+	return pixels
+}
+
+func TestDeviceCreateProgram(t *testing.T) {
+	d := NewDevice(4, 4)
+	d.RegisterProgram("color", colorProgram())
+
+	var shaderErr *gfx.ShaderError
+	if _, err := d.CreateProgram("void main() {}", "void main() {}"); !errors.As(err, &shaderErr) {
+		t.Errorf("program without a marker: got error %v, want a *gfx.ShaderError", err)
+	}
+	if _, err := d.CreateProgram("// soft: missing", ""); !errors.As(err, &shaderErr) {
+		t.Errorf("unregistered program: got error %v, want a *gfx.ShaderError", err)
+	}
+
+	program, err := d.CreateProgram("#version 410 core\n", "// soft: color\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reflection := d.ReflectProgram(program)
+	if location, ok := reflection.Locations["color"]; !ok || location != 0 {
+		t.Errorf("color at location %d, %v, want 0", location, ok)
+	}
+	if len(reflection.Attributes) != 2 {
+		t.Errorf("got %d attributes, want 2", len(reflection.Attributes))
+	}
+	if location := d.FragDataLocation(program, "FragColor"); location != 0 {
+		t.Errorf("FragColor at %d, want 0", location)
+	}
+	if location := d.FragDataLocation(program, "Missing"); location != -1 {
+		t.Errorf("missing output at %d, want -1", location)
+	}
+}
+
+// TestDeviceOrientation draws the left and bottom halves of the window and
+// checks that rows are read bottom first like GL's, and that Window flips them
+func TestDeviceOrientation(t *testing.T) {
+	const size = 4
+	d := newColorDevice(t, size)
+	drawQuad(d, quad(d, -1, -1, 0, 1, 0), mgl32.Vec4{1, 0, 0, 1})
+	drawQuad(d, quad(d, 0, -1, 1, 0, 0), mgl32.Vec4{0, 1, 0, 1})
+
+	pixels := readPixels(d, 0, size)
+	window := d.Window()
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			want := transparent
+			switch {
+			case x < size/2:
+				want = red
+			case y < size/2:
+				want = green
+			}
+			if got := pixels[y*size+x]; got != want {
+				t.Errorf("pixel (%d, %d) from the bottom is %v, want %v", x, y, got, want)
+			}
+			var got [4]uint8
+			copy(got[:], window.Pix[window.PixOffset(x, size-1-y):])
+			if got != want {
+				t.Errorf("window pixel (%d, %d) from the top is %v, want %v", x, size-1-y, got, want)
+			}
+		}
+	}
+}
+
+func TestDeviceViewport(t *testing.T) {
+	const size = 4
+	d := newColorDevice(t, size)
+	d.Viewport(2, 0, 2, 2)
+	drawQuad(d, quad(d, -1, -1, 1, 1, 0), mgl32.Vec4{0, 0, 1, 1})
+
+	pixels := readPixels(d, 0, size)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			want := transparent
+			if x >= 2 && y < 2 {
+				want = blue
+			}
+			if got := pixels[y*size+x]; got != want {
+				t.Errorf("pixel (%d, %d) is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+// TestDeviceCulling checks that counter-clockwise triangles face the camera,
+// as in GL
+func TestDeviceCulling(t *testing.T) {
+	const size = 4
+	for _, test := range []struct {
+		cull gfx.CullMode
+		want [4]uint8
+	}{
+		{gfx.CullNone, red},
+		{gfx.CullBack, red},
+		{gfx.CullFront, transparent},
+	} {
+		d := newColorDevice(t, size)
+		state := gfx.DefaultPipelineState()
+		state.Cull = test.cull
+		d.SetPipeline(state)
+		drawQuad(d, quad(d, -1, -1, 1, 1, 0), mgl32.Vec4{1, 0, 0, 1})
+		if got := readPixels(d, 0, size)[0]; got != test.want {
+			t.Errorf("cull mode %d: pixel is %v, want %v", test.cull, got, test.want)
+		}
+	}
+}
+
+func TestDeviceFramebuffer(t *testing.T) {
+	const size = 4
+	d := newColorDevice(t, size)
+	color := d.CreateTexture(gfx.Texture2D, 1)
+	d.TexImage2D(color, gfx.Texture2D, 0, gfx.TexelFormat{Format: gfx.TextureFormatRGBA8}, size, size, nil)
+	depth := d.CreateRenderbuffer(gfx.TexelFormat{Format: gfx.TextureFormatDepth24}, size, size, 1)
+	framebuffer, err := d.CreateFramebuffer([]gfx.FramebufferAttachment{
+		{Attachment: gfx.ColorAttachment(0), Texture: color},
+		{Attachment: gfx.AttachmentDepth, Renderbuffer: depth},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.BindFramebuffer(framebuffer)
+	d.Clear(gfx.ColorBuffer | gfx.DepthBuffer)
+
+	full := func(z float32) uint32 { return quad(d, -1, -1, 1, 1, z) }
+	drawQuad(d, full(0.5), mgl32.Vec4{0, 0, 1, 1})
+	drawQuad(d, full(-0.5), mgl32.Vec4{1, 0, 0, 1})
+	// Behind what is drawn, the depth test rejects it
+	drawQuad(d, full(0), mgl32.Vec4{0, 1, 0, 1})
+	for i, got := range readPixels(d, framebuffer, size) {
+		if got != red {
+			t.Fatalf("depth tested pixel %d is %v, want %v", i, got, red)
+		}
+	}
+
+	state := gfx.DefaultPipelineState()
+	state.DepthTest = false
+	state.Blend = gfx.AdditiveBlend()
+	d.SetPipeline(state)
+	drawQuad(d, full(0), mgl32.Vec4{0, 0.5, 0, 1})
+	want := [4]uint8{255, 128, 0, 255}
+	for i, got := range readPixels(d, framebuffer, size) {
+		if got != want {
+			t.Fatalf("blended pixel %d is %v, want %v", i, got, want)
+		}
+	}
+
+	// The window wasn't drawn to
+	for i, got := range readPixels(d, 0, size) {
+		if got != transparent {
+			t.Fatalf("window pixel %d is %v, want it cleared", i, got)
+		}
+	}
+}
+
+func TestDeviceIncompleteFramebuffer(t *testing.T) {
+	d := NewDevice(4, 4)
+	small := d.CreateRenderbuffer(gfx.TexelFormat{Format: gfx.TextureFormatRGBA8}, 2, 2, 1)
+	large := d.CreateRenderbuffer(gfx.TexelFormat{Format: gfx.TextureFormatDepth24}, 4, 4, 1)
+	if _, err := d.CreateFramebuffer([]gfx.FramebufferAttachment{
+		{Attachment: gfx.ColorAttachment(0), Renderbuffer: small},
+		{Attachment: gfx.AttachmentDepth, Renderbuffer: large},
+	}); err == nil {
+		t.Error("attachments of different sizes made a complete framebuffer")
+	}
+	if _, err := d.CreateFramebuffer([]gfx.FramebufferAttachment{{Attachment: gfx.ColorAttachment(0), Texture: 99}}); err == nil {
+		t.Error("a missing texture made a complete framebuffer")
+	}
+}
+
+func TestDeviceInstancing(t *testing.T) {
+	const size = 4
+	d := newColorDevice(t, size)
+	vertices := []float32{-1, -1, 0, 0, -1, 0, 0, 1, 0, -1, 1, 0}
+	offsets := []float32{0, 0, 1, 0}
+	vertexBuffer := d.CreateBuffer(gfx.BufferVertices, len(vertices)*4, vertices, gfx.UsageStatic)
+	offsetBuffer := d.CreateBuffer(gfx.BufferVertices, len(offsets)*4, offsets, gfx.UsageStatic)
+	vertexArray := d.CreateVertexArray(gfx.VertexLayout{Attributes: []gfx.VertexAttribute{
+		{Location: 0, Buffer: vertexBuffer, Components: 3},
+		{Location: 1, Buffer: offsetBuffer, Components: 2, Divisor: 1},
+	}})
+	d.SetUniform(0, mgl32.Vec4{0, 1, 0, 1})
+	d.Draw(gfx.DrawCall{VertexArray: vertexArray, Mode: gfx.PrimitiveTriangleFan, Count: 4, Instances: 2})
+
+	for i, got := range readPixels(d, 0, size) {
+		if got != green {
+			t.Fatalf("pixel %d is %v, want both instances to cover it", i, got)
+		}
+	}
+}
+
+// TestDeviceTextureAndBlock samples a texture through a sampler uniform and
+// reads a tint from a uniform block
+func TestDeviceTextureAndBlock(t *testing.T) {
+	const size = 2
+	d := NewDevice(size, size)
+	d.RegisterProgram("textured", &Program{
+		Uniforms: []gfx.ShaderUniform{
+			{Name: "tex", Type: gfx.TypeSampler2D, Size: 1, Location: 0, BlockIndex: -1},
+			{Name: "tint", Type: gfx.TypeVec4, Size: 1, Location: -1, BlockIndex: 0},
+		},
+		UniformBlocks: []gfx.ShaderUniformBlock{{Name: "Tint", Index: 0, DataSize: 16}},
+		Outputs:       []string{"FragColor"},
+		Varyings:      2,
+		Bind: func(u Uniforms) Shaders {
+			tex := u.Texture("tex")
+			var tint mgl32.Vec4
+			if block := u.Block("Tint"); len(block) >= 16 {
+				for i := range tint {
+					tint[i] = math.Float32frombits(binary.LittleEndian.Uint32(block[i*4:]))
+				}
+			}
+			return Shaders{
+				Vertex: func(attributes []mgl32.Vec4, varyings []float32) mgl32.Vec4 {
+					varyings[0] = (attributes[0][0] + 1) / 2
+					varyings[1] = (attributes[0][1] + 1) / 2
+					return attributes[0]
+				},
+				Fragment: func(varyings []float32, outputs []mgl32.Vec4) bool {
+					c := tex.Sample(mgl32.Vec2{varyings[0], varyings[1]})
+					outputs[0] = mgl32.Vec4{c[0] * tint[0], c[1] * tint[1], c[2] * tint[2], c[3] * tint[3]}
+					return true
+				},
+			}
+		},
+	})
+	program, err := d.CreateProgram("// soft: textured", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.UseProgram(program)
+
+	// Row 0 of the texture is sampled at t=0, the bottom of the window
+	texels := []uint8{
+		255, 0, 0, 255, 0, 255, 0, 255,
+		0, 0, 255, 255, 255, 255, 255, 255,
+	}
+	tex := d.CreateTexture(gfx.Texture2D, 1)
+	d.TexImage2D(tex, gfx.Texture2D, 0, gfx.TexelFormat{Format: gfx.TextureFormatRGBA8}, size, size, texels)
+	d.SetSampler(tex, gfx.Texture2D, gfx.SamplerState{MinFilter: gfx.FilterNearest, MagFilter: gfx.FilterNearest})
+	d.BindTexture(3, gfx.Texture2D, tex)
+	d.SetUniform(0, int32(3))
+
+	tint := []float32{1, 1, 1, 1}
+	d.BindUniformBlock(program, 0, 2)
+	d.BindUniformBuffer(2, d.CreateBuffer(gfx.BufferUniforms, 16, tint, gfx.UsageDynamic))
+
+	d.Draw(gfx.DrawCall{VertexArray: quad(d, -1, -1, 1, 1, 0), Mode: gfx.PrimitiveTriangles, Count: 6, Indexed: true})
+	want := [][4]uint8{red, green, blue, {255, 255, 255, 255}}
+	for i, got := range readPixels(d, 0, size) {
+		if got != want[i] {
+			t.Errorf("pixel %d is %v, want %v", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package raster
+
+import (
+	"encoding/binary"
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"math"
+	"physics/gfx"
+)
+
+// shadedVertex is a vertex after a Program's vertex shader
+type shadedVertex struct {
+	clip     mgl32.Vec4
+	varyings []float32
+}
+
+func (v *shadedVertex) lerp(to *shadedVertex, t float32) shadedVertex {
+	varyings := make([]float32, len(v.varyings))
+	for i := range varyings {
+		varyings[i] = v.varyings[i] + (to.varyings[i]-v.varyings[i])*t
+	}
+	return shadedVertex{clip: lerp4(v.clip, to.clip, t), varyings: varyings}
+}
+
+// drawTarget is the images a draw writes to, all width by height
+type drawTarget struct {
+	colors        []*surface
+	depth         *surface
+	width, height int
+}
+
+func (d *Device) drawTarget(f *framebuffer) *drawTarget {
+	t := &drawTarget{depth: d.resolve(f.depth)}
+	for i := range f.colors {
+		t.colors = append(t.colors, d.color(f, i))
+	}
+	for _, s := range append(t.colors, t.depth) {
+		if s != nil {
+			t.width, t.height = s.width, s.height
+			break
+		}
+	}
+	return t
+}
+
+// Draw runs the current program over the vertex array and rasterizes its
+// triangles into the bound framebuffer
+func (d *Device) Draw(call gfx.DrawCall) {
+	p, ok := d.programs[d.program]
+	if !ok || p.Bind == nil {
+		return
+	}
+	layout, ok := d.vertexArrays[call.VertexArray]
+	if !ok {
+		return
+	}
+	f := d.framebufferOf(d.framebuffer)
+	if f == nil {
+		return
+	}
+	target := d.drawTarget(f)
+	if target.width == 0 || target.height == 0 {
+		return
+	}
+	shaders := p.Bind(Uniforms{device: d, program: p})
+
+	indices := d.indices(call, layout)
+	// Attributes the program reads but the layout doesn't feed keep their
+	// defaults
+	locations := 1
+	for _, a := range layout.Attributes {
+		if int(a.Location) >= locations {
+			locations = int(a.Location) + 1
+		}
+	}
+	for _, a := range p.Attributes {
+		if int(a.Location)+int(a.Size) > locations {
+			locations = int(a.Location) + int(a.Size)
+		}
+	}
+	attributes := make([]mgl32.Vec4, locations)
+	instances := int(call.Instances)
+	if instances < 1 {
+		instances = 1
+	}
+
+	for instance := 0; instance < instances; instance++ {
+		shaded := make(map[uint32]*shadedVertex)
+		shade := func(index uint32) *shadedVertex {
+			if v, ok := shaded[index]; ok {
+				return v
+			}
+			d.fetch(layout, int(index), instance, attributes)
+			v := &shadedVertex{varyings: make([]float32, p.Varyings)}
+			v.clip = shaders.Vertex(attributes, v.varyings)
+			shaded[index] = v
+			return v
+		}
+		triangles(call.Mode, len(indices), func(i0, i1, i2 int) {
+			d.triangle(target, p, shaders, shade(indices[i0]), shade(indices[i1]), shade(indices[i2]))
+		})
+	}
+}
+
+// indices returns the vertices a draw reads, in order
+func (d *Device) indices(call gfx.DrawCall, layout gfx.VertexLayout) []uint32 {
+	if !call.Indexed {
+		indices := make([]uint32, call.Count)
+		for i := range indices {
+			indices[i] = uint32(i)
+		}
+		return indices
+	}
+	data := d.buffers[layout.IndexBuffer]
+	count := int(call.Count)
+	if count > len(data)/4 {
+		count = len(data) / 4
+	}
+	indices := make([]uint32, count)
+	for i := range indices {
+		indices[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	return indices
+}
+
+// fetch reads the attributes of a vertex of an instance, indexed by location.
+// Components a layout doesn't provide default to 0, 0, 0, 1 as in GL.
+func (d *Device) fetch(layout gfx.VertexLayout, vertex, instance int, attributes []mgl32.Vec4) {
+	for i := range attributes {
+		attributes[i] = mgl32.Vec4{0, 0, 0, 1}
+	}
+	for _, a := range layout.Attributes {
+		data := d.buffers[a.Buffer]
+		index := vertex
+		if a.Divisor != 0 {
+			index = instance / int(a.Divisor)
+		}
+		stride := int(a.Stride)
+		if stride == 0 {
+			stride = int(a.Components) * 4
+		}
+		offset := a.Offset + index*stride
+		for c := 0; c < int(a.Components) && c < 4; c++ {
+			at := offset + c*4
+			if at < 0 || at+4 > len(data) {
+				break
+			}
+			attributes[a.Location][c] = math.Float32frombits(binary.LittleEndian.Uint32(data[at:]))
+		}
+	}
+}
+
+// triangles calls emit with the indices of each triangle mode assembles from
+// count vertices, wound as GL winds them. Points and lines assemble none.
+func triangles(mode gfx.PrimitiveMode, count int, emit func(i0, i1, i2 int)) {
+	switch mode {
+	case gfx.PrimitiveTriangles:
+		for i := 0; i+2 < count; i += 3 {
+			emit(i, i+1, i+2)
+		}
+	case gfx.PrimitiveTriangleStrip:
+		for i := 2; i < count; i++ {
+			if i%2 == 0 {
+				emit(i-2, i-1, i)
+			} else {
+				emit(i-1, i-2, i)
+			}
+		}
+	case gfx.PrimitiveTriangleFan:
+		for i := 2; i < count; i++ {
+			emit(0, i-1, i)
+		}
+	}
+}
+
+// clipShaded cuts a triangle against the near plane like clipNear
+func clipShaded(in []shadedVertex, out []shadedVertex) []shadedVertex {
+	for i := range in {
+		current, next := &in[i], &in[(i+1)%len(in)]
+		dc := current.clip[2] + current.clip[3]
+		dn := next.clip[2] + next.clip[3]
+		if dc >= 0 {
+			out = append(out, *current)
+		}
+		if (dc >= 0) != (dn >= 0) {
+			out = append(out, current.lerp(next, dc/(dc-dn)))
+		}
+	}
+	return out
+}
+
+// toScreen maps a vertex into the viewport, with y pointing down from the
+// top of the target as the rasterizer expects
+func (d *Device) toScreen(t *drawTarget, v *shadedVertex) screenVertex {
+	invW := 1 / v.clip[3]
+	return screenVertex{
+		x:    float32(d.viewport.Min.X) + (v.clip[0]*invW+1)*0.5*float32(d.viewport.Dx()),
+		y:    float32(t.height) - (float32(d.viewport.Min.Y) + (v.clip[1]*invW+1)*0.5*float32(d.viewport.Dy())),
+		z:    v.clip[2]*invW*0.5 + 0.5,
+		invW: invW,
+	}
+}
+
+// triangle clips, rasterizes and shades a triangle into target
+func (d *Device) triangle(target *drawTarget, p *linkedProgram, shaders Shaders, v0, v1, v2 *shadedVertex) {
+	var polygon, clipped [4]shadedVertex
+	polygon[0], polygon[1], polygon[2] = *v0, *v1, *v2
+	vertices := clipShaded(polygon[:3], clipped[:0])
+
+	// The viewport, in the rasterizer's y down pixels
+	bounds := image.Rect(d.viewport.Min.X, target.height-d.viewport.Max.Y, d.viewport.Max.X, target.height-d.viewport.Min.Y).
+		Intersect(image.Rect(0, 0, target.width, target.height))
+	state := d.pipeline
+	varyings := make([]float32, p.Varyings)
+	outputs := make([]mgl32.Vec4, len(p.Outputs))
+
+	for j := 1; j+1 < len(vertices); j++ {
+		a, b, c := &vertices[0], &vertices[j], &vertices[j+1]
+		sa, sb, sc := d.toScreen(target, a), d.toScreen(target, b), d.toScreen(target, c)
+		offset := polygonOffset(state, &sa, &sb, &sc)
+
+		scan(bounds, state.Cull, &sa, &sb, &sc, func(x, y int, z float32, weights [3]float32) {
+			z += offset
+			if state.DepthClamp {
+				z = clamp32(z, 0, 1)
+			} else if z < 0 || z > 1 {
+				return
+			}
+			i := (target.height-1-y)*target.width + x
+			depthTested := state.DepthTest && target.depth != nil
+			if depthTested && !depthTest(state.DepthFunc, z, target.depth.texels[i][0]) {
+				return
+			}
+
+			for k := range varyings {
+				varyings[k] = a.varyings[k]*weights[0] + b.varyings[k]*weights[1] + c.varyings[k]*weights[2]
+			}
+			for k := range outputs {
+				outputs[k] = mgl32.Vec4{}
+			}
+			if !shaders.Fragment(varyings, outputs) {
+				return
+			}
+
+			for k, color := range target.colors {
+				if color == nil || k >= len(outputs) {
+					continue
+				}
+				src := outputs[k]
+				if state.Blend.Enabled {
+					src = blendState(state.Blend, src, color.load(i, d.encodeSRGB))
+				}
+				color.store(i, src, d.encodeSRGB)
+			}
+			if depthTested && state.DepthWrite {
+				target.depth.texels[i][0] = z
+			}
+		})
+	}
+}
+
+// polygonOffset is the depth a pipeline's polygon offset adds to a triangle,
+// for a 24-bit depth buffer
+func polygonOffset(state gfx.PipelineState, a, b, c *screenVertex) float32 {
+	if state.PolygonOffsetFactor == 0 && state.PolygonOffsetUnits == 0 {
+		return 0
+	}
+	var slope float32
+	if det := (b.x-a.x)*(c.y-a.y) - (c.x-a.x)*(b.y-a.y); det != 0 {
+		dzdx := ((b.z-a.z)*(c.y-a.y) - (c.z-a.z)*(b.y-a.y)) / det
+		dzdy := ((c.z-a.z)*(b.x-a.x) - (b.z-a.z)*(c.x-a.x)) / det
+		slope = float32(math.Max(math.Abs(float64(dzdx)), math.Abs(float64(dzdy))))
+	}
+	return state.PolygonOffsetFactor*slope + state.PolygonOffsetUnits/(1<<24)
+}
+
+// blendState combines a fragment with the stored color by the blend
+// equation's factors
+func blendState(state gfx.BlendState, src, dst mgl32.Vec4) mgl32.Vec4 {
+	srcColor, dstColor := blendFactor(state.SrcColor, src, dst), blendFactor(state.DstColor, src, dst)
+	srcAlpha, dstAlpha := blendFactor(state.SrcAlpha, src, dst)[3], blendFactor(state.DstAlpha, src, dst)[3]
+	return mgl32.Vec4{
+		src[0]*srcColor[0] + dst[0]*dstColor[0],
+		src[1]*srcColor[1] + dst[1]*dstColor[1],
+		src[2]*srcColor[2] + dst[2]*dstColor[2],
+		src[3]*srcAlpha + dst[3]*dstAlpha,
+	}
+}
+
+func blendFactor(factor gfx.BlendFactor, src, dst mgl32.Vec4) mgl32.Vec4 {
+	one := mgl32.Vec4{1, 1, 1, 1}
+	switch factor {
+	case gfx.FactorOne:
+		return one
+	case gfx.FactorSrcColor:
+		return src
+	case gfx.FactorOneMinusSrcColor:
+		return one.Sub(src)
+	case gfx.FactorDstColor:
+		return dst
+	case gfx.FactorOneMinusDstColor:
+		return one.Sub(dst)
+	case gfx.FactorSrcAlpha:
+		return one.Mul(src[3])
+	case gfx.FactorOneMinusSrcAlpha:
+		return one.Mul(1 - src[3])
+	case gfx.FactorDstAlpha:
+		return one.Mul(dst[3])
+	case gfx.FactorOneMinusDstAlpha:
+		return one.Mul(1 - dst[3])
+	}
+	return mgl32.Vec4{}
+}
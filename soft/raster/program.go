@@ -0,0 +1,261 @@
+package raster
+
+import (
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"physics/gfx"
+	"reflect"
+	"regexp"
+)
+
+// Program is a shader program written in Go, which a Device runs in place of
+// the GLSL it can't compile. Its fields are what ReflectProgram reports.
+type Program struct {
+	// Uniforms outside a block need a Location, array elements take the
+	// locations following it
+	Uniforms      []gfx.ShaderUniform
+	UniformBlocks []gfx.ShaderUniformBlock
+	Attributes    []gfx.ShaderAttribute
+	// Outputs are the fragment outputs, drawn to the color attachment of the
+	// same index
+	Outputs []string
+	// Varyings is the number of floats the vertex shader hands to the
+	// fragment shader, interpolated across each triangle
+	Varyings int
+	// Bind returns the shaders of a draw made with u, once per draw
+	Bind func(u Uniforms) Shaders
+}
+
+// Shaders are the two stages of a Program, bound to a draw's uniforms
+type Shaders struct {
+	// Vertex returns the clip space position of the vertex whose attributes
+	// are indexed by location, writing its varyings
+	Vertex func(attributes []mgl32.Vec4, varyings []float32) mgl32.Vec4
+	// Fragment writes the outputs of a fragment with interpolated varyings,
+	// false when it is discarded
+	Fragment func(varyings []float32, outputs []mgl32.Vec4) bool
+}
+
+// programMarker names the Program a GLSL source stands for, e.g.
+// "// soft: phong"
+var programMarker = regexp.MustCompile(`//\s*soft:\s*(\S+)`)
+
+// linkedProgram is a Program created on the device, with its uniform values
+// and block bindings
+type linkedProgram struct {
+	*Program
+	values    map[int32]interface{}
+	locations map[string]int32
+	bindings  []uint32
+}
+
+// RegisterProgram makes CreateProgram build p for sources marked with
+// "// soft: <name>"
+func (d *Device) RegisterProgram(name string, p *Program) {
+	d.registry[name] = p
+}
+
+// CreateProgram links the registered Program named by a marker in either
+// source. Sources without one fail like a link error, since the device can't
+// run GLSL.
+func (d *Device) CreateProgram(vertexSource, fragmentSource string) (uint32, error) {
+	var name string
+	for _, source := range []string{vertexSource, fragmentSource} {
+		if match := programMarker.FindStringSubmatch(source); match != nil {
+			name = match[1]
+			break
+		}
+	}
+	if name == "" {
+		return 0, &gfx.ShaderError{Stage: "link", Log: "no \"// soft: <name>\" marker, the software device runs only registered Go programs"}
+	}
+	p, ok := d.registry[name]
+	if !ok {
+		return 0, &gfx.ShaderError{Stage: "link", Log: fmt.Sprintf("no program registered as %q", name)}
+	}
+
+	linked := &linkedProgram{
+		Program:   p,
+		values:    make(map[int32]interface{}),
+		locations: make(map[string]int32),
+		bindings:  make([]uint32, len(p.UniformBlocks)),
+	}
+	for _, uniform := range p.Uniforms {
+		if uniform.BlockIndex >= 0 {
+			continue
+		}
+		linked.locations[uniform.Name] = uniform.Location
+		if uniform.Size > 1 {
+			for element := int32(0); element < uniform.Size; element++ {
+				linked.locations[fmt.Sprintf("%s[%d]", uniform.Name, element)] = uniform.Location + element
+			}
+		}
+	}
+	for i, block := range p.UniformBlocks {
+		linked.bindings[i] = uint32(block.Binding)
+	}
+	return d.add(func(handle uint32) { d.programs[handle] = linked }), nil
+}
+
+func (d *Device) DeleteProgram(program uint32) {
+	if d.program == program {
+		d.program = 0
+	}
+	delete(d.programs, program)
+}
+
+func (d *Device) ReflectProgram(program uint32) gfx.ProgramReflection {
+	p, ok := d.programs[program]
+	if !ok {
+		return gfx.ProgramReflection{Locations: make(map[string]int32)}
+	}
+	r := gfx.ProgramReflection{
+		Uniforms:   append([]gfx.ShaderUniform(nil), p.Uniforms...),
+		Attributes: append([]gfx.ShaderAttribute(nil), p.Attributes...),
+		Locations:  make(map[string]int32, len(p.locations)),
+	}
+	for name, location := range p.locations {
+		r.Locations[name] = location
+	}
+	for i, block := range p.UniformBlocks {
+		block.Binding = int32(p.bindings[i])
+		r.UniformBlocks = append(r.UniformBlocks, block)
+	}
+	return r
+}
+
+func (d *Device) BindUniformBlock(program, blockIndex, binding uint32) {
+	if p, ok := d.programs[program]; ok && int(blockIndex) < len(p.bindings) {
+		p.bindings[blockIndex] = binding
+	}
+}
+
+func (d *Device) FragDataLocation(program uint32, name string) int32 {
+	if p, ok := d.programs[program]; ok {
+		for i, output := range p.Outputs {
+			if output == name {
+				return int32(i)
+			}
+		}
+	}
+	return -1
+}
+
+func (d *Device) UseProgram(program uint32) {
+	d.program = program
+}
+
+// SetUniform stores value for the current program. Slices set the array
+// elements from location on.
+func (d *Device) SetUniform(location int32, value interface{}) {
+	p, ok := d.programs[d.program]
+	if !ok || location < 0 {
+		return
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			p.values[location+int32(i)] = v.Index(i).Interface()
+		}
+		return
+	}
+	p.values[location] = value
+}
+
+// Uniforms are what a draw's shaders read: the current program's uniform
+// values, its uniform blocks and the textures its samplers select. Values
+// that were never set, or set with another type, read as zero.
+type Uniforms struct {
+	device  *Device
+	program *linkedProgram
+}
+
+// Value returns the value of a uniform, or of an array element such as
+// "lights[1]", nil when it was never set
+func (u Uniforms) Value(name string) interface{} {
+	location, ok := u.program.locations[name]
+	if !ok {
+		return nil
+	}
+	return u.program.values[location]
+}
+
+func (u Uniforms) Int(name string) int32 {
+	v, _ := u.Value(name).(int32)
+	return v
+}
+
+func (u Uniforms) Float(name string) float32 {
+	v, _ := u.Value(name).(float32)
+	return v
+}
+
+func (u Uniforms) Vec2(name string) mgl32.Vec2 {
+	v, _ := u.Value(name).(mgl32.Vec2)
+	return v
+}
+
+func (u Uniforms) Vec3(name string) mgl32.Vec3 {
+	v, _ := u.Value(name).(mgl32.Vec3)
+	return v
+}
+
+func (u Uniforms) Vec4(name string) mgl32.Vec4 {
+	v, _ := u.Value(name).(mgl32.Vec4)
+	return v
+}
+
+func (u Uniforms) Mat3(name string) mgl32.Mat3 {
+	v, _ := u.Value(name).(mgl32.Mat3)
+	return v
+}
+
+func (u Uniforms) Mat4(name string) mgl32.Mat4 {
+	v, _ := u.Value(name).(mgl32.Mat4)
+	return v
+}
+
+// Block returns the contents of the buffer bound to a uniform block, laid
+// out as the block's uniforms' offsets say, nil when none is bound
+func (u Uniforms) Block(name string) []byte {
+	for i, block := range u.program.UniformBlocks {
+		if block.Name == name {
+			return u.device.buffers[u.device.uniformBuffers[u.program.bindings[i]]]
+		}
+	}
+	return nil
+}
+
+// texture returns the texture on the unit a sampler uniform selects
+func (u Uniforms) texture(name string) *texture {
+	return u.device.textures[u.device.units[int(u.Int(name))]]
+}
+
+// Texture returns the first layer of the texture a sampler selects, nil when
+// none is bound; nil textures sample as white
+func (u Uniforms) Texture(name string) *Texture {
+	return u.Layer(name, 0)
+}
+
+// Layer returns a layer of the texture array a sampler selects
+func (u Uniforms) Layer(name string, layer int) *Texture {
+	if s := u.texture(name).image(0, layer); s != nil {
+		return &s.Texture
+	}
+	return nil
+}
+
+// Cubemap returns a cube of the cube map, or cube map array, a sampler
+// selects
+func (u Uniforms) Cubemap(name string, cube int) *Cubemap {
+	t := u.texture(name)
+	if t == nil {
+		return nil
+	}
+	c := &Cubemap{}
+	for face := range c {
+		if s := t.image(0, cube*6+face); s != nil {
+			c[face] = &s.Texture
+		}
+	}
+	return c
+}
@@ -0,0 +1,271 @@
+package raster
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"math"
+	"physics/gfx"
+	"physics/scene"
+)
+
+// vertex is a transformed mesh vertex with the attributes the shading needs
+type vertex struct {
+	clip     mgl32.Vec4
+	position mgl32.Vec3
+	normal   mgl32.Vec3
+	texCoord mgl32.Vec2
+}
+
+func (v vertex) lerp(to vertex, t float32) vertex {
+	return vertex{
+		clip:     lerp4(v.clip, to.clip, t),
+		position: lerp3(v.position, to.position, t),
+		normal:   lerp3(v.normal, to.normal, t),
+		texCoord: v.texCoord.Add(to.texCoord.Sub(v.texCoord).Mul(t)),
+	}
+}
+
+// screenVertex is a vertex after the perspective divide, in pixels with y
+// pointing down
+type screenVertex struct {
+	x, y, z float32
+	// invW weights the attributes for perspective correct interpolation
+	invW float32
+	*vertex
+}
+
+// pipeline is the fixed function state of a draw
+type pipeline struct {
+	cull      gfx.CullMode
+	depthFunc gfx.CompareFunc
+	// depthWrite is off for read-only and transparent materials
+	depthWrite bool
+	blend      scene.BlendMode
+	tint       mgl32.Vec4
+	shade      func(f *fragment) (mgl32.Vec4, bool)
+}
+
+// drawGeometry transforms d's geometry by model and rasterizes its triangles
+func (r *Renderer) drawGeometry(d *Draw, model mgl32.Mat4, tint mgl32.Vec4, shade func(f *fragment) (mgl32.Vec4, bool)) {
+	mesh := d.Geometry
+	state := d.Material.RenderState
+	p := pipeline{
+		cull:       state.Cull,
+		depthFunc:  state.DepthFunc,
+		depthWrite: !state.DepthReadOnly && !d.Material.BlendMode.IsTransparent(),
+		blend:      d.Material.BlendMode,
+		tint:       tint,
+		shade:      shade,
+	}
+
+	viewProjection := r.projection.Mul4(r.view)
+	normalMatrix := model.Mat3().Inv().Transpose()
+	count := len(mesh.Vertices) / 3
+	r.vertices = r.vertices[:0]
+	for i := 0; i < count; i++ {
+		position := model.Mul4x1(mgl32.Vec4{mesh.Vertices[i*3], mesh.Vertices[i*3+1], mesh.Vertices[i*3+2], 1}).Vec3()
+		v := vertex{
+			clip:     viewProjection.Mul4x1(position.Vec4(1)),
+			position: position,
+		}
+		if len(mesh.Normals) >= i*3+3 {
+			v.normal = normalMatrix.Mul3x1(mgl32.Vec3{mesh.Normals[i*3], mesh.Normals[i*3+1], mesh.Normals[i*3+2]})
+		}
+		if len(mesh.TexCoords) >= i*2+2 {
+			v.texCoord = mgl32.Vec2{mesh.TexCoords[i*2], mesh.TexCoords[i*2+1]}
+		}
+		r.vertices = append(r.vertices, v)
+	}
+
+	var polygon, clipped [4]vertex
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		a, b, c := mesh.Indices[i], mesh.Indices[i+1], mesh.Indices[i+2]
+		if int(a) >= count || int(b) >= count || int(c) >= count {
+			continue
+		}
+		polygon[0], polygon[1], polygon[2] = r.vertices[a], r.vertices[b], r.vertices[c]
+		n := clipNear(polygon[:3], clipped[:0])
+		for j := 1; j+1 < n; j++ {
+			r.rasterize(&p, &clipped[0], &clipped[j], &clipped[j+1])
+		}
+	}
+}
+
+// clipNear cuts a triangle against the near plane, z >= -w in clip space,
+// leaving up to four vertices in out. Everything past it then has w > 0, the
+// other planes are handled by the rasterizer's bounds.
+func clipNear(in []vertex, out []vertex) int {
+	for i := range in {
+		current, next := in[i], in[(i+1)%len(in)]
+		dc := current.clip[2] + current.clip[3]
+		dn := next.clip[2] + next.clip[3]
+		if dc >= 0 {
+			out = append(out, current)
+		}
+		if (dc >= 0) != (dn >= 0) {
+			out = append(out, current.lerp(next, dc/(dc-dn)))
+		}
+	}
+	return len(out)
+}
+
+func (r *Renderer) toScreen(v *vertex) screenVertex {
+	invW := 1 / v.clip[3]
+	return screenVertex{
+		x:      (v.clip[0]*invW + 1) * 0.5 * float32(r.Width),
+		y:      (1 - v.clip[1]*invW) * 0.5 * float32(r.Height),
+		z:      v.clip[2]*invW*0.5 + 0.5,
+		invW:   invW,
+		vertex: v,
+	}
+}
+
+// rasterize shades the pixels a triangle covers into the color and depth
+// buffers
+func (r *Renderer) rasterize(p *pipeline, v0, v1, v2 *vertex) {
+	a, b, c := r.toScreen(v0), r.toScreen(v1), r.toScreen(v2)
+	var f fragment
+	scan(image.Rect(0, 0, r.Width, r.Height), p.cull, &a, &b, &c, func(x, y int, z float32, weights [3]float32) {
+		if z < 0 || z > 1 {
+			return
+		}
+		i := y*r.Width + x
+		if !depthTest(p.depthFunc, z, r.depth[i]) {
+			return
+		}
+
+		p0, p1, p2 := weights[0], weights[1], weights[2]
+		f.position = a.position.Mul(p0).Add(b.position.Mul(p1)).Add(c.position.Mul(p2))
+		f.normal = a.normal.Mul(p0).Add(b.normal.Mul(p1)).Add(c.normal.Mul(p2))
+		f.texCoord = a.texCoord.Mul(p0).Add(b.texCoord.Mul(p1)).Add(c.texCoord.Mul(p2))
+		f.tint = p.tint
+
+		color, ok := p.shade(&f)
+		if !ok {
+			return
+		}
+		r.color[i] = blend(p.blend, color, r.color[i])
+		if p.depthWrite {
+			r.depth[i] = z
+		}
+	})
+}
+
+// scan calls visit for each pixel of bounds whose center a triangle covers,
+// following GL's top-left rule so triangles sharing an edge don't both draw
+// it. Triangles culled by cull are skipped. visit gets the triangle's depth
+// at the pixel and the perspective correct weights of a, b and c.
+func scan(bounds image.Rectangle, cull gfx.CullMode, a, b, c *screenVertex, visit func(x, y int, z float32, weights [3]float32)) {
+	// Counter-clockwise triangles face the camera, as in GL. With y pointing
+	// down they have a negative area.
+	area := edge(a, b, c.x, c.y)
+	if area == 0 || bounds.Empty() {
+		return
+	}
+	front := area < 0
+	if (cull == gfx.CullBack && !front) || (cull == gfx.CullFront && front) {
+		return
+	}
+	swapped := area < 0
+	if swapped {
+		b, c = c, b
+		area = -area
+	}
+
+	minX := clampInt(int(math.Floor(float64(min3(a.x, b.x, c.x)))), bounds.Min.X, bounds.Max.X-1)
+	maxX := clampInt(int(math.Ceil(float64(max3(a.x, b.x, c.x)))), bounds.Min.X, bounds.Max.X-1)
+	minY := clampInt(int(math.Floor(float64(min3(a.y, b.y, c.y)))), bounds.Min.Y, bounds.Max.Y-1)
+	maxY := clampInt(int(math.Ceil(float64(max3(a.y, b.y, c.y)))), bounds.Min.Y, bounds.Max.Y-1)
+	topLeft0, topLeft1, topLeft2 := isTopLeft(b, c), isTopLeft(c, a), isTopLeft(a, b)
+
+	for y := minY; y <= maxY; y++ {
+		py := float32(y) + 0.5
+		for x := minX; x <= maxX; x++ {
+			px := float32(x) + 0.5
+			w0, w1, w2 := edge(b, c, px, py), edge(c, a, px, py), edge(a, b, px, py)
+			if !covers(w0, topLeft0) || !covers(w1, topLeft1) || !covers(w2, topLeft2) {
+				continue
+			}
+			l0, l1, l2 := w0/area, w1/area, w2/area
+
+			// Depth is affine in screen space, the attributes are not
+			z := l0*a.z + l1*b.z + l2*c.z
+			p0, p1, p2 := l0*a.invW, l1*b.invW, l2*c.invW
+			scale := 1 / (p0 + p1 + p2)
+			p0, p1, p2 = p0*scale, p1*scale, p2*scale
+			if swapped {
+				p1, p2 = p2, p1
+			}
+			visit(x, y, z, [3]float32{p0, p1, p2})
+		}
+	}
+}
+
+// edge is twice the signed area of the triangle a, b, (x, y), positive when
+// the point is right of a->b on screen
+func edge(a, b *screenVertex, x, y float32) float32 {
+	return (b.x-a.x)*(y-a.y) - (b.y-a.y)*(x-a.x)
+}
+
+// isTopLeft reports whether a->b is a top or left edge of a triangle wound
+// clockwise on screen
+func isTopLeft(a, b *screenVertex) bool {
+	return (a.y == b.y && b.x > a.x) || b.y < a.y
+}
+
+func covers(w float32, topLeft bool) bool {
+	return w > 0 || (w == 0 && topLeft)
+}
+
+func depthTest(depthFunc gfx.CompareFunc, z, stored float32) bool {
+	switch depthFunc {
+	case gfx.CompareNever:
+		return false
+	case gfx.CompareEqual:
+		return z == stored
+	case gfx.CompareLessEqual:
+		return z <= stored
+	case gfx.CompareGreater:
+		return z > stored
+	case gfx.CompareNotEqual:
+		return z != stored
+	case gfx.CompareGreaterEqual:
+		return z >= stored
+	case gfx.CompareAlways:
+		return true
+	}
+	return z < stored
+}
+
+// blend combines a fragment with the color buffer like the blend functions
+// the GL renderers set for each mode
+func blend(mode scene.BlendMode, src, dst mgl32.Vec4) mgl32.Vec4 {
+	alpha := src[3]
+	switch mode {
+	case scene.BlendAlpha:
+		return src.Vec3().Mul(alpha).Add(dst.Vec3().Mul(1 - alpha)).Vec4(alpha + dst[3]*(1-alpha))
+	case scene.BlendAdditive:
+		return dst.Add(src.Mul(alpha))
+	case scene.BlendPremultiplied:
+		return src.Add(dst.Mul(1 - alpha))
+	}
+	return src
+}
+
+func min3(a, b, c float32) float32 {
+	return float32(math.Min(float64(a), math.Min(float64(b), float64(c))))
+}
+
+func max3(a, b, c float32) float32 {
+	return float32(math.Max(float64(a), math.Max(float64(b), float64(c))))
+}
+
+func clampInt(x, low, high int) int {
+	if x < low {
+		return low
+	}
+	if x > high {
+		return high
+	}
+	return x
+}
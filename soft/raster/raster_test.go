@@ -0,0 +1,183 @@
+package raster
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"physics/gfx"
+	"physics/scene"
+	"testing"
+)
+
+// newTestRenderer returns a renderer with its depth buffer cleared to the far
+// plane, as Render does
+func newTestRenderer(width, height int) *Renderer {
+	r := NewRenderer(width, height)
+	for i := range r.depth {
+		r.depth[i] = 1
+	}
+	return r
+}
+
+// clipVertex is a vertex at clip space x, y, z, w
+func clipVertex(x, y, z, w float32) vertex {
+	return vertex{clip: mgl32.Vec4{x, y, z, w}}
+}
+
+func TestClipNear(t *testing.T) {
+	inside := []vertex{clipVertex(-1, -1, 0, 1), clipVertex(1, -1, 0, 1), clipVertex(0, 1, 0, 1)}
+	var out [4]vertex
+	if n := clipNear(inside, out[:0]); n != 3 {
+		t.Fatalf("triangle in front of the near plane: got %d vertices, want 3", n)
+	}
+	for i := range inside {
+		if out[i].clip != inside[i].clip {
+			t.Errorf("vertex %d moved from %v to %v", i, inside[i].clip, out[i].clip)
+		}
+	}
+
+	behind := []vertex{clipVertex(-1, -1, -2, 1), clipVertex(1, -1, -3, 1), clipVertex(0, 1, -2, 1)}
+	if n := clipNear(behind, out[:0]); n != 0 {
+		t.Errorf("triangle behind the near plane: got %d vertices, want 0", n)
+	}
+
+	// One vertex behind the plane turns the triangle into a quad whose new
+	// vertices lie on the plane
+	crossing := []vertex{clipVertex(0, 0, -3, 1), clipVertex(1, 0, 1, 1), clipVertex(0, 1, 1, 1)}
+	n := clipNear(crossing, out[:0])
+	if n != 4 {
+		t.Fatalf("triangle crossing the near plane: got %d vertices, want 4", n)
+	}
+	for i, v := range out[:n] {
+		if d := v.clip[2] + v.clip[3]; d < -1e-6 {
+			t.Errorf("vertex %d %v is behind the near plane", i, v.clip)
+		}
+	}
+	if want := (mgl32.Vec4{0.5, 0, -1, 1}); !out[0].clip.ApproxEqual(want) {
+		t.Errorf("first intersection %v, want %v", out[0].clip, want)
+	}
+}
+
+// TestTopLeftRule draws two triangles splitting a square along a diagonal
+// through pixel centers, every pixel has to be drawn exactly once
+func TestTopLeftRule(t *testing.T) {
+	const size = 8
+	r := newTestRenderer(size, size)
+	p := pipeline{
+		depthFunc: gfx.CompareAlways,
+		blend:     scene.BlendAdditive,
+		shade: func(f *fragment) (mgl32.Vec4, bool) {
+			return mgl32.Vec4{1, 1, 1, 1}, true
+		},
+	}
+	a, b, c, d := clipVertex(-1, -1, 0, 1), clipVertex(1, -1, 0, 1), clipVertex(1, 1, 0, 1), clipVertex(-1, 1, 0, 1)
+	r.rasterize(&p, &a, &b, &c)
+	r.rasterize(&p, &a, &c, &d)
+
+	for i, color := range r.color {
+		if color[0] != 1 {
+			t.Errorf("pixel (%d, %d) drawn %v times, want once", i%size, i/size, color[0])
+		}
+	}
+}
+
+func TestDepthTest(t *testing.T) {
+	for _, test := range []struct {
+		depthFunc              gfx.CompareFunc
+		nearer, equal, further bool
+	}{
+		{gfx.CompareLess, true, false, false},
+		{gfx.CompareNever, false, false, false},
+		{gfx.CompareEqual, false, true, false},
+		{gfx.CompareLessEqual, true, true, false},
+		{gfx.CompareGreater, false, false, true},
+		{gfx.CompareNotEqual, true, false, true},
+		{gfx.CompareGreaterEqual, false, true, true},
+		{gfx.CompareAlways, true, true, true},
+	} {
+		const stored = 0.5
+		if got := depthTest(test.depthFunc, 0.25, stored); got != test.nearer {
+			t.Errorf("func %d, nearer fragment: got %v, want %v", test.depthFunc, got, test.nearer)
+		}
+		if got := depthTest(test.depthFunc, stored, stored); got != test.equal {
+			t.Errorf("func %d, equal fragment: got %v, want %v", test.depthFunc, got, test.equal)
+		}
+		if got := depthTest(test.depthFunc, 0.75, stored); got != test.further {
+			t.Errorf("func %d, further fragment: got %v, want %v", test.depthFunc, got, test.further)
+		}
+	}
+}
+
+func TestBlend(t *testing.T) {
+	src := mgl32.Vec4{1, 0.5, 0, 0.25}
+	dst := mgl32.Vec4{0, 0, 1, 1}
+	for _, test := range []struct {
+		mode scene.BlendMode
+		want mgl32.Vec4
+	}{
+		{scene.BlendOpaque, src},
+		{scene.BlendCutout, src},
+		{scene.BlendAlpha, mgl32.Vec4{0.25, 0.125, 0.75, 1}},
+		{scene.BlendAdditive, mgl32.Vec4{0.25, 0.125, 1, 1.0625}},
+		{scene.BlendPremultiplied, mgl32.Vec4{1, 0.5, 0.75, 1}},
+	} {
+		if got := blend(test.mode, src, dst); !got.ApproxEqual(test.want) {
+			t.Errorf("mode %d: got %v, want %v", test.mode, got, test.want)
+		}
+	}
+}
+
+// TestPerspectiveInterpolation checks that attributes are interpolated
+// linearly in clip space rather than on screen. Each vertex carries its clip
+// space x, y and w as its position, so a correctly interpolated fragment
+// projects back onto the center of its own pixel.
+func TestPerspectiveInterpolation(t *testing.T) {
+	const size = 16
+	r := newTestRenderer(size, size)
+	withPosition := func(x, y, z, w float32) vertex {
+		v := clipVertex(x, y, z, w)
+		v.position = mgl32.Vec3{x, y, w}
+		return v
+	}
+	// The far edge has w four times that of the near vertex
+	a, b, c := withPosition(-1, -1, 0, 1), withPosition(4, -4, 0, 4), withPosition(-4, 4, 0, 4)
+
+	fragments := 0
+	p := pipeline{
+		depthFunc: gfx.CompareAlways,
+		shade: func(f *fragment) (mgl32.Vec4, bool) {
+			fragments++
+			x := (f.position[0]/f.position[2] + 1) * 0.5 * size
+			y := (1 - f.position[1]/f.position[2]) * 0.5 * size
+			// Pixel centers lie at .5
+			dx := x - float32(math.Floor(float64(x))) - 0.5
+			dy := y - float32(math.Floor(float64(y))) - 0.5
+			if math.Abs(float64(dx)) > 1e-3 || math.Abs(float64(dy)) > 1e-3 {
+				t.Errorf("fragment projects to (%v, %v), off its pixel center", x, y)
+			}
+			return mgl32.Vec4{}, true
+		},
+	}
+	r.rasterize(&p, &a, &b, &c)
+	if fragments == 0 {
+		t.Fatal("triangle covered no pixels")
+	}
+}
+
+// TestShadePhongOpacity checks that an Opacity of 0 draws fully transparent
+// rather than falling back to opaque
+func TestShadePhongOpacity(t *testing.T) {
+	r := newTestRenderer(1, 1)
+	f := fragment{position: mgl32.Vec3{0, 0, -1}, normal: mgl32.Vec3{0, 0, 1}, tint: mgl32.Vec4{1, 1, 1, 1}}
+	for _, opacity := range []float32{0, 0.5, 1} {
+		m := scene.DefaultPhong()
+		m.BlendMode = scene.BlendAlpha
+		m.Opacity = opacity
+		color, ok := r.shadePhong(&m, nil, &f)
+		if !ok {
+			t.Fatalf("opacity %v: fragment discarded", opacity)
+		}
+		if color[3] != opacity {
+			t.Errorf("opacity %v: alpha %v", opacity, color[3])
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Package raster draws scenes on the CPU into an image.RGBA. It shades like
+// the forward renderer's Phong and PBR shaders, without shadows, ambient
+// occlusion, wireframes or polygon offset, and builds without cgo so it runs
+// with no OpenGL at all. Package soft adapts it to engine.Renderer. Device
+// draws with the same rasterizer behind gfx.Device, running shaders written in
+// Go.
+package raster
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"physics/scene"
+	"sort"
+)
+
+// Renderer draws every view into Image
+type Renderer struct {
+	Width, Height int
+	// Image holds the last view drawn, sRGB encoded and opaque like
+	// engine.CaptureFrame's
+	Image *image.RGBA
+
+	// color is linear and unclamped until the view is resolved into Image
+	color []mgl32.Vec4
+	depth []float32
+
+	opaque      []Draw
+	transparent []Draw
+	vertices    []vertex
+
+	lights []scene.PointLight
+	fog    scene.Fog
+	sky    *Sky
+
+	eye        mgl32.Vec3
+	view       mgl32.Mat4
+	projection mgl32.Mat4
+}
+
+// Frame is what every view of a frame shares
+type Frame struct {
+	// Lights beyond scene.MaxLights are ignored, as by the GL renderers
+	Lights []scene.PointLight
+	Fog    scene.Fog
+	// Sky is drawn behind the opaque draws, nil for a black background
+	Sky *Sky
+}
+
+// Sky is a scene.Sky with the image its kind samples
+type Sky struct {
+	scene.Sky
+	// Texture is sampled by scene.SkyEquirectangular skies
+	Texture *Texture
+	// Cubemap is sampled by scene.SkyCubemap skies
+	Cubemap *Cubemap
+}
+
+// Draw is a mesh drawn with a material, once or once per instance
+type Draw struct {
+	Geometry *scene.Geometry
+	Model    mgl32.Mat4
+	// Instances, when not nil, draw the geometry with each of their model
+	// matrices instead of Model
+	Instances []Instance
+
+	// Material is the surface's Phong shading and its blend and render state
+	Material *scene.Phong
+	// Texture multiplies Material's colors, white when nil
+	Texture *Texture
+	// PBR, when set, shades the surface instead of Material's Phong terms
+	PBR *scene.PBR
+}
+
+// Instance is one copy of an instanced Draw
+type Instance struct {
+	Model mgl32.Mat4
+	// Color multiplies the material's texture color
+	Color mgl32.Vec4
+}
+
+func NewRenderer(width, height int) *Renderer {
+	return &Renderer{
+		Width:  width,
+		Height: height,
+		Image:  image.NewRGBA(image.Rect(0, 0, width, height)),
+		color:  make([]mgl32.Vec4, width*height),
+		depth:  make([]float32, width*height),
+	}
+}
+
+func (r *Renderer) BeginFrame(frame Frame) {
+	r.lights = frame.Lights
+	r.fog = frame.Fog
+	r.sky = frame.Sky
+}
+
+// Submit queues d for the next Render, ignoring draws without geometry or
+// material
+func (r *Renderer) Submit(d Draw) {
+	if d.Geometry == nil || d.Material == nil {
+		return
+	}
+	if d.Material.BlendMode.IsTransparent() {
+		r.transparent = append(r.transparent, d)
+	} else {
+		r.opaque = append(r.opaque, d)
+	}
+}
+
+// Render draws the submitted draws seen from eye into Image, opaque ones
+// first and transparent ones back to front, and empties the queue
+func (r *Renderer) Render(eye mgl32.Vec3, view, projection mgl32.Mat4) {
+	r.eye = eye
+	r.view = view
+	r.projection = projection
+
+	for i := range r.color {
+		r.color[i] = mgl32.Vec4{}
+		r.depth[i] = 1
+	}
+
+	for i := range r.opaque {
+		r.draw(&r.opaque[i])
+	}
+	if r.sky != nil {
+		r.drawSky()
+	}
+
+	sort.SliceStable(r.transparent, func(i, j int) bool {
+		return r.distance(&r.transparent[i]) > r.distance(&r.transparent[j])
+	})
+	for i := range r.transparent {
+		r.draw(&r.transparent[i])
+	}
+
+	r.resolve()
+	r.opaque = r.opaque[:0]
+	r.transparent = r.transparent[:0]
+}
+
+func (r *Renderer) distance(d *Draw) float32 {
+	return d.Model.Col(3).Vec3().Sub(r.eye).LenSqr()
+}
+
+// shader returns the shading of d's fragments
+func (r *Renderer) shader(d *Draw) func(f *fragment) (mgl32.Vec4, bool) {
+	if d.PBR != nil {
+		material := d.PBR
+		return func(f *fragment) (mgl32.Vec4, bool) {
+			return r.shadePBR(material, f), true
+		}
+	}
+	material, texture := d.Material, d.Texture
+	return func(f *fragment) (mgl32.Vec4, bool) {
+		return r.shadePhong(material, texture, f)
+	}
+}
+
+// draw rasterizes d, once per instance for instanced draws
+func (r *Renderer) draw(d *Draw) {
+	shade := r.shader(d)
+	if d.Instances == nil {
+		r.drawGeometry(d, d.Model, mgl32.Vec4{1, 1, 1, 1}, shade)
+		return
+	}
+	for _, instance := range d.Instances {
+		r.drawGeometry(d, instance.Model, instance.Color, shade)
+	}
+}
+
+// drawSky shades the pixels no geometry covered, as the GL renderers' sky pass
+// does at the far plane
+func (r *Renderer) drawSky() {
+	// Unproject without the camera's translation, the sky is infinitely far
+	inverse := r.projection.Mul4(r.view.Mat3().Mat4()).Inv()
+	for y := 0; y < r.Height; y++ {
+		ndcY := 1 - (float32(y)+0.5)/float32(r.Height)*2
+		for x := 0; x < r.Width; x++ {
+			i := y*r.Width + x
+			if r.depth[i] < 1 {
+				continue
+			}
+			ndcX := (float32(x)+0.5)/float32(r.Width)*2 - 1
+			direction := inverse.Mul4x1(mgl32.Vec4{ndcX, ndcY, 1, 1})
+			r.color[i] = r.shadeSky(direction.Vec3().Mul(1 / direction[3]).Normalize()).Vec4(1)
+		}
+	}
+}
+
+// resolve encodes the linear color buffer into Image
+func (r *Renderer) resolve() {
+	for i, c := range r.color {
+		r.Image.Pix[i*4] = encode(linearToSRGB(clamp32(c[0], 0, 1)))
+		r.Image.Pix[i*4+1] = encode(linearToSRGB(clamp32(c[1], 0, 1)))
+		r.Image.Pix[i*4+2] = encode(linearToSRGB(clamp32(c[2], 0, 1)))
+		r.Image.Pix[i*4+3] = 255
+	}
+}
+
+func encode(c float32) uint8 {
+	return uint8(c*255 + 0.5)
+}
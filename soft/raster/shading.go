@@ -0,0 +1,244 @@
+package raster
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"physics/scene"
+)
+
+// The shading below follows the GLSL of the same names in shaders/ and
+// shaders/common, without shadows or ambient occlusion.
+
+// fragment is what the rasterizer interpolates for a pixel
+type fragment struct {
+	position mgl32.Vec3
+	normal   mgl32.Vec3
+	texCoord mgl32.Vec2
+	tint     mgl32.Vec4
+}
+
+// shadePhong is default.frag. ok is false when the fragment is discarded.
+func (r *Renderer) shadePhong(m *scene.Phong, tex *Texture, f *fragment) (result mgl32.Vec4, ok bool) {
+	texSample := tex.Sample(f.texCoord)
+	texSample = mgl32.Vec4{texSample[0] * f.tint[0], texSample[1] * f.tint[1], texSample[2] * f.tint[2], texSample[3] * f.tint[3]}
+	texColor := texSample.Vec3()
+	alpha := texSample[3] * m.Opacity
+	if m.BlendMode == scene.BlendCutout && alpha < m.AlphaCutoff {
+		return mgl32.Vec4{}, false
+	}
+
+	norm := f.normal.Normalize()
+	viewDir := r.eye.Sub(f.position).Normalize()
+
+	color := mul3(m.Ambient, texColor)
+	if len(r.lights) == 0 {
+		color = color.Add(phong(m, mgl32.Vec3{0, 0, 1}, mgl32.Vec3{1, 1, 1}, norm, viewDir, texColor))
+	}
+	for i := range r.lights {
+		if i == scene.MaxLights {
+			break
+		}
+		light := &r.lights[i]
+		toLight := light.Position.Sub(f.position)
+		distance := toLight.Len()
+		attenuation := pointAttenuation(light, distance)
+		color = color.Add(phong(m, toLight.Mul(1/distance), light.Color, norm, viewDir, texColor).Mul(attenuation))
+	}
+
+	color = r.applyFog(color, f.position)
+	if m.BlendMode == scene.BlendPremultiplied {
+		color = color.Mul(m.Opacity)
+	}
+	return color.Vec4(alpha), true
+}
+
+func phong(m *scene.Phong, lightDir, lightColor, norm, viewDir, texColor mgl32.Vec3) mgl32.Vec3 {
+	diff := max32(norm.Dot(lightDir), 0)
+	diffuse := mul3(m.Diffuse, texColor).Mul(diff)
+
+	reflectDir := glslReflect(lightDir.Mul(-1), norm)
+	spec := pow32(max32(viewDir.Dot(reflectDir), 0), m.Shininess)
+	specular := mul3(m.Specular, texColor).Mul(spec)
+
+	return mul3(diffuse.Add(specular), lightColor)
+}
+
+// shadePBR is pbr.frag
+func (r *Renderer) shadePBR(m *scene.PBR, f *fragment) mgl32.Vec4 {
+	n := f.normal.Normalize()
+	v := r.eye.Sub(f.position).Normalize()
+
+	var lo mgl32.Vec3
+	if len(r.lights) == 0 {
+		lo = lo.Add(cookTorrance(n, v, mgl32.Vec3{-1, 0.5, -1}.Normalize(), m.AlbedoColor, m.Metallic, m.Roughness))
+	}
+	for i := range r.lights {
+		if i == scene.MaxLights {
+			break
+		}
+		light := &r.lights[i]
+		toLight := light.Position.Sub(f.position)
+		distance := toLight.Len()
+		radiance := light.Color.Mul(pointAttenuation(light, distance))
+		lo = lo.Add(mul3(cookTorrance(n, v, toLight.Mul(1/distance), m.AlbedoColor, m.Metallic, m.Roughness), radiance))
+	}
+
+	ambient := m.AlbedoColor.Mul(0.03)
+	return r.applyFog(lo.Add(ambient), f.position).Vec4(1)
+}
+
+// cookTorrance is CookTorrance in brdf.glsl
+func cookTorrance(n, v, l, albedo mgl32.Vec3, metallic, roughness float32) mgl32.Vec3 {
+	h := v.Add(l).Normalize()
+	f0 := lerp3(mgl32.Vec3{0.04, 0.04, 0.04}, albedo, metallic)
+	f := fresnelSchlick(max32(h.Dot(v), 0), f0)
+	d := distributionGGX(n, h, roughness)
+	g := geometrySmith(n, v, l, roughness)
+
+	nDotL := max32(n.Dot(l), 0)
+	specular := f.Mul(d * g / (4*max32(n.Dot(v), 0)*nDotL + 0.0001))
+	kD := mgl32.Vec3{1, 1, 1}.Sub(f).Mul(1 - metallic)
+	return mul3(kD, albedo).Mul(1 / math.Pi).Add(specular).Mul(nDotL)
+}
+
+func fresnelSchlick(cosTheta float32, f0 mgl32.Vec3) mgl32.Vec3 {
+	return f0.Add(mgl32.Vec3{1, 1, 1}.Sub(f0).Mul(pow32(1-cosTheta, 5)))
+}
+
+func distributionGGX(n, h mgl32.Vec3, roughness float32) float32 {
+	a := roughness * roughness
+	a2 := a * a
+	nDotH := max32(n.Dot(h), 0)
+	d := nDotH*nDotH*(a2-1) + 1
+	return a2 / (math.Pi * d * d)
+}
+
+func geometrySchlickGGX(nDotV, roughness float32) float32 {
+	r := roughness + 1
+	k := r * r / 8
+	return nDotV / (nDotV*(1-k) + k)
+}
+
+func geometrySmith(n, v, l mgl32.Vec3, roughness float32) float32 {
+	return geometrySchlickGGX(max32(n.Dot(v), 0), roughness) * geometrySchlickGGX(max32(n.Dot(l), 0), roughness)
+}
+
+// pointAttenuation is PointAttenuation in lights.glsl
+func pointAttenuation(light *scene.PointLight, distance float32) float32 {
+	attenuation := clamp32(1-distance/light.EffectiveRange(), 0, 1)
+	return attenuation * attenuation
+}
+
+// applyFog is ApplyFog in fog.glsl
+func (r *Renderer) applyFog(color, position mgl32.Vec3) mgl32.Vec3 {
+	return lerp3(color, r.fog.Color, r.fogFactor(position))
+}
+
+func (r *Renderer) fogFactor(position mgl32.Vec3) float32 {
+	fog := &r.fog
+	ray := position.Sub(r.eye)
+	distance := ray.Len()
+
+	switch fog.Mode {
+	case scene.FogLinear:
+		return clamp32((distance-fog.Start)/max32(fog.End-fog.Start, 0.0001), 0, 1)
+	case scene.FogExponential:
+		return 1 - exp32(-fog.Density*distance)
+	case scene.FogHeight:
+		falloff := max32(fog.HeightFalloff, 0.0001)
+		eyeDensity := fog.Density * exp32(-falloff*(r.eye[1]-fog.Height))
+		rise := falloff * ray[1]
+		integral := float32(1)
+		if abs(rise) > 0.0001 {
+			integral = (1 - exp32(-rise)) / rise
+		}
+		return clamp32(1-exp32(-eyeDensity*distance*integral), 0, 1)
+	}
+	return 0
+}
+
+// shadeSky is sky.frag, for a normalized view direction
+func (r *Renderer) shadeSky(direction mgl32.Vec3) mgl32.Vec3 {
+	sky := r.sky
+	var color mgl32.Vec3
+	switch sky.Kind {
+	case scene.SkyCubemap:
+		color = sky.Cubemap.Sample(direction).Vec3()
+	case scene.SkyEquirectangular:
+		uv := mgl32.Vec2{
+			float32(math.Atan2(float64(direction[2]), float64(direction[0])))/(2*math.Pi) + 0.5,
+			0.5 - float32(math.Asin(float64(clamp32(direction[1], -1, 1))))/math.Pi,
+		}
+		color = sky.Texture.Sample(uv).Vec3()
+	default:
+		height := direction[1]
+		if height > 0 {
+			color = lerp3(sky.HorizonColor, sky.ZenithColor, pow32(height, 0.5))
+		} else {
+			color = lerp3(sky.HorizonColor, sky.GroundColor, pow32(-height, 0.3))
+		}
+
+		sunAngle := float32(math.Acos(float64(clamp32(direction.Dot(sky.SunDirection), -1, 1))))
+		if height >= 0 {
+			color = color.Add(sky.SunColor.Mul(0.02 * exp32(-sunAngle*4)))
+		}
+		color = color.Add(sky.SunColor.Mul(1 - smoothstep(sky.SunSize*0.8, sky.SunSize, sunAngle)))
+	}
+
+	far := r.projection[14] / (r.projection[10] + 1)
+	return r.applyFog(color.Mul(sky.Intensity), r.eye.Add(direction.Mul(far)))
+}
+
+func mul3(a, b mgl32.Vec3) mgl32.Vec3 {
+	return mgl32.Vec3{a[0] * b[0], a[1] * b[1], a[2] * b[2]}
+}
+
+func lerp3(a, b mgl32.Vec3, t float32) mgl32.Vec3 {
+	return a.Add(b.Sub(a).Mul(t))
+}
+
+func lerp4(a, b mgl32.Vec4, t float32) mgl32.Vec4 {
+	return a.Add(b.Sub(a).Mul(t))
+}
+
+// glslReflect is GLSL's reflect
+func glslReflect(i, n mgl32.Vec3) mgl32.Vec3 {
+	return i.Sub(n.Mul(2 * n.Dot(i)))
+}
+
+func smoothstep(edge0, edge1, x float32) float32 {
+	t := clamp32((x-edge0)/(edge1-edge0), 0, 1)
+	return t * t * (3 - 2*t)
+}
+
+func clamp32(x, low, high float32) float32 {
+	if x < low {
+		return low
+	}
+	if x > high {
+		return high
+	}
+	return x
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func abs(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func pow32(x, y float32) float32 {
+	return float32(math.Pow(float64(x), float64(y)))
+}
+
+func exp32(x float32) float32 {
+	return float32(math.Exp(float64(x)))
+}
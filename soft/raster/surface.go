@@ -0,0 +1,146 @@
+package raster
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"physics/gfx"
+)
+
+// surface is an image of a Device texture, renderbuffer or window. Its rows
+// are stored bottom first like GL's, colors linear and depth in the red
+// channel.
+type surface struct {
+	Texture
+	format gfx.TexelFormat
+}
+
+func newSurface(format gfx.TexelFormat, width, height int, sampler gfx.SamplerState) *surface {
+	s := &surface{
+		Texture: Texture{width: width, height: height, texels: make([]mgl32.Vec4, width*height), sampler: sampler},
+		format:  format,
+	}
+	s.clear()
+	return s
+}
+
+// clear fills the surface with GL's default clear values, transparent black
+// and the far plane
+func (s *surface) clear() {
+	value := mgl32.Vec4{}
+	if s.format.Format.IsDepth() {
+		value = mgl32.Vec4{1, 0, 0, 1}
+	}
+	for i := range s.texels {
+		s.texels[i] = value
+	}
+}
+
+// channels is the number of components of each texel of format
+func channels(format gfx.TextureFormat) int {
+	switch format {
+	case gfx.TextureFormatRGBA8, gfx.TextureFormatRGBA16, gfx.TextureFormatRGBA16F, gfx.TextureFormatRGBA32F:
+		return 4
+	case gfx.TextureFormatRGB8, gfx.TextureFormatRGB16F, gfx.TextureFormatRGB32F:
+		return 3
+	case gfx.TextureFormatRG8, gfx.TextureFormatRG16F:
+		return 2
+	}
+	return 1
+}
+
+// normalized reports whether format stores values clamped to [0, 1]
+func normalized(format gfx.TextureFormat) bool {
+	switch format {
+	case gfx.TextureFormatRGBA8, gfx.TextureFormatRGB8, gfx.TextureFormatRG8, gfx.TextureFormatR8,
+		gfx.TextureFormatRGBA16, gfx.TextureFormatR16:
+		return true
+	}
+	return format.IsDepth()
+}
+
+// upload fills the surface from pixels laid out as gfx.TexelFormat describes,
+// leaving it unchanged when pixels is nil
+func (s *surface) upload(pixels interface{}) {
+	n := channels(s.format.Format)
+	var component func(i int) float32
+	switch p := pixels.(type) {
+	case []uint8:
+		if len(p) < len(s.texels)*n {
+			return
+		}
+		component = func(i int) float32 { return float32(p[i]) / 0xff }
+	case []uint16:
+		if len(p) < len(s.texels)*n {
+			return
+		}
+		component = func(i int) float32 { return float32(p[i]) / 0xffff }
+	case []float32:
+		if len(p) < len(s.texels)*n {
+			return
+		}
+		component = func(i int) float32 { return p[i] }
+	default:
+		return
+	}
+
+	srgb := s.isSRGB()
+	for i := range s.texels {
+		texel := mgl32.Vec4{0, 0, 0, 1}
+		for c := 0; c < n; c++ {
+			texel[c] = component(i*n + c)
+			if srgb && c < 3 {
+				texel[c] = srgbToLinear(texel[c])
+			}
+		}
+		s.texels[i] = texel
+	}
+}
+
+// isSRGB reports whether the surface stores sRGB encoded colors
+func (s *surface) isSRGB() bool {
+	return s.format.ColorSpace == gfx.ColorSpaceSRGB && s.format.Format.HasSRGB()
+}
+
+// load returns the color at i as blending sees it: linear, unless the surface
+// is sRGB and encoding is off, when GL blends the encoded values
+func (s *surface) load(i int, encode bool) mgl32.Vec4 {
+	c := s.texels[i]
+	if s.isSRGB() && !encode {
+		c = mgl32.Vec4{linearToSRGB(c[0]), linearToSRGB(c[1]), linearToSRGB(c[2]), c[3]}
+	}
+	return c
+}
+
+// store writes a color produced with load's encoding at i, dropping the
+// channels the format lacks and clamping normalized formats
+func (s *surface) store(i int, c mgl32.Vec4, encode bool) {
+	n := channels(s.format.Format)
+	for j := n; j < 3; j++ {
+		c[j] = 0
+	}
+	if n < 4 {
+		c[3] = 1
+	}
+	if normalized(s.format.Format) {
+		for j := range c {
+			c[j] = clamp32(c[j], 0, 1)
+		}
+	}
+	if s.isSRGB() && !encode {
+		c = mgl32.Vec4{srgbToLinear(c[0]), srgbToLinear(c[1]), srgbToLinear(c[2]), c[3]}
+	}
+	s.texels[i] = c
+}
+
+// rgba8 returns texel i as GL reads it back into RGBA8
+func (s *surface) rgba8(i int) [4]uint8 {
+	c := s.texels[i]
+	if s.isSRGB() {
+		c = mgl32.Vec4{linearToSRGB(c[0]), linearToSRGB(c[1]), linearToSRGB(c[2]), c[3]}
+	}
+	return [4]uint8{
+		encode(clamp32(c[0], 0, 1)),
+		encode(clamp32(c[1], 0, 1)),
+		encode(clamp32(c[2], 0, 1)),
+		encode(clamp32(c[3], 0, 1)),
+	}
+}
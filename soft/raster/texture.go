@@ -0,0 +1,168 @@
+package raster
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"image/color"
+	"math"
+	"physics/gfx"
+)
+
+// TextureOptions say how an image is turned into a Texture, like the
+// engine's options of the same name
+type TextureOptions struct {
+	Sampler    gfx.SamplerState
+	ColorSpace gfx.ColorSpace
+	// FlipY stores images bottom row first, matching OpenGL's texture origin
+	FlipY bool
+}
+
+// Texture is an image converted to linear RGBA, with row 0 at t=0 like a GL
+// texture
+type Texture struct {
+	width, height int
+	texels        []mgl32.Vec4
+	sampler       gfx.SamplerState
+}
+
+func NewTexture(img image.Image, opts TextureOptions) *Texture {
+	bounds := img.Bounds()
+	t := &Texture{
+		width:   bounds.Dx(),
+		height:  bounds.Dy(),
+		texels:  make([]mgl32.Vec4, bounds.Dx()*bounds.Dy()),
+		sampler: opts.Sampler,
+	}
+	for y := 0; y < t.height; y++ {
+		row := y
+		if opts.FlipY {
+			row = t.height - 1 - y
+		}
+		for x := 0; x < t.width; x++ {
+			c := color.NRGBA64Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA64)
+			texel := mgl32.Vec4{float32(c.R) / 0xffff, float32(c.G) / 0xffff, float32(c.B) / 0xffff, float32(c.A) / 0xffff}
+			if opts.ColorSpace == gfx.ColorSpaceSRGB {
+				texel = mgl32.Vec4{srgbToLinear(texel[0]), srgbToLinear(texel[1]), srgbToLinear(texel[2]), texel[3]}
+			}
+			t.texels[row*t.width+x] = texel
+		}
+	}
+	return t
+}
+
+// Sample filters the texture at uv like a GL sampler without mipmaps. A nil
+// texture samples as white, like the engine's untextured materials.
+func (t *Texture) Sample(uv mgl32.Vec2) mgl32.Vec4 {
+	if t == nil {
+		return mgl32.Vec4{1, 1, 1, 1}
+	}
+	x := uv[0]*float32(t.width) - 0.5
+	y := uv[1]*float32(t.height) - 0.5
+	if t.sampler.MagFilter == gfx.FilterNearest {
+		return t.texel(int(math.Floor(float64(x+0.5))), int(math.Floor(float64(y+0.5))))
+	}
+
+	x0, y0 := float32(math.Floor(float64(x))), float32(math.Floor(float64(y)))
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+	top := lerp4(t.texel(ix, iy), t.texel(ix+1, iy), fx)
+	bottom := lerp4(t.texel(ix, iy+1), t.texel(ix+1, iy+1), fx)
+	return lerp4(top, bottom, fy)
+}
+
+func (t *Texture) texel(x, y int) mgl32.Vec4 {
+	x = wrap(x, t.width, t.sampler.WrapS)
+	y = wrap(y, t.height, t.sampler.WrapT)
+	return t.texels[y*t.width+x]
+}
+
+// wrap maps a texel coordinate into [0, size) with a wrap mode, repeating
+// unless the mode clamps
+func wrap(i, size int, mode gfx.WrapMode) int {
+	switch mode {
+	case gfx.WrapClampToEdge, gfx.WrapClampToBorder:
+		if i < 0 {
+			return 0
+		}
+		if i >= size {
+			return size - 1
+		}
+		return i
+	case gfx.WrapMirroredRepeat:
+		period := 2 * size
+		i = ((i % period) + period) % period
+		if i >= size {
+			return period - 1 - i
+		}
+		return i
+	}
+	return ((i % size) + size) % size
+}
+
+// Cubemap holds the six faces of a cube map texture in GL's order: +X, -X,
+// +Y, -Y, +Z, -Z
+type Cubemap [6]*Texture
+
+// NewCubemap converts the faces of a cube map, in the order of
+// engine.NewCubemap
+func NewCubemap(faces [6]image.Image, opts TextureOptions) *Cubemap {
+	cube := &Cubemap{}
+	for i, face := range faces {
+		cube[i] = NewTexture(face, opts)
+	}
+	return cube
+}
+
+// Sample looks up direction the way GL selects and addresses cube map faces. A
+// nil cube map samples as white.
+func (c *Cubemap) Sample(direction mgl32.Vec3) mgl32.Vec4 {
+	if c == nil {
+		return mgl32.Vec4{1, 1, 1, 1}
+	}
+	x, y, z := direction[0], direction[1], direction[2]
+	ax, ay, az := abs(x), abs(y), abs(z)
+
+	var face int
+	var sc, tc, ma float32
+	switch {
+	case ax >= ay && ax >= az:
+		ma = ax
+		if x > 0 {
+			face, sc, tc = 0, -z, -y
+		} else {
+			face, sc, tc = 1, z, -y
+		}
+	case ay >= az:
+		ma = ay
+		if y > 0 {
+			face, sc, tc = 2, x, z
+		} else {
+			face, sc, tc = 3, x, -z
+		}
+	default:
+		ma = az
+		if z > 0 {
+			face, sc, tc = 4, x, -y
+		} else {
+			face, sc, tc = 5, -x, -y
+		}
+	}
+	if c[face] == nil || ma == 0 {
+		return mgl32.Vec4{1, 1, 1, 1}
+	}
+	return c[face].Sample(mgl32.Vec2{(sc/ma + 1) / 2, (tc/ma + 1) / 2})
+}
+
+func srgbToLinear(c float32) float32 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return float32(math.Pow(float64((c+0.055)/1.055), 2.4))
+}
+
+func linearToSRGB(c float32) float32 {
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*float32(math.Pow(float64(c), 1/2.4)) - 0.055
+}
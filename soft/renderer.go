@@ -0,0 +1,158 @@
+//go:build cgo
+
+package soft
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"physics/engine"
+	"physics/pbr"
+	"physics/scene"
+	"physics/soft/raster"
+)
+
+// Renderer draws every view into Image. Textures only exist in video memory,
+// so the images of those it samples are registered with SetTexture or
+// SetCubemap; others sample as white.
+type Renderer struct {
+	*raster.Renderer
+
+	textures map[*engine.Texture]*raster.Texture
+	cubemaps map[*engine.Texture]*raster.Cubemap
+
+	lights []scene.PointLight
+}
+
+var _ engine.Renderer = (*Renderer)(nil)
+
+func NewRenderer(width, height int) *Renderer {
+	return &Renderer{
+		Renderer: raster.NewRenderer(width, height),
+		textures: make(map[*engine.Texture]*raster.Texture),
+		cubemaps: make(map[*engine.Texture]*raster.Cubemap),
+	}
+}
+
+func rasterOptions(opts engine.TextureOptions) raster.TextureOptions {
+	return raster.TextureOptions{Sampler: opts.Sampler, ColorSpace: opts.ColorSpace, FlipY: opts.FlipY}
+}
+
+// SetTexture gives the renderer the image of tex, loaded with opts
+func (r *Renderer) SetTexture(tex *engine.Texture, img image.Image, opts engine.TextureOptions) {
+	r.textures[tex] = raster.NewTexture(img, rasterOptions(opts))
+}
+
+// SetCubemap gives the renderer the faces of a cube map, in the order of
+// engine.NewCubemap
+func (r *Renderer) SetCubemap(tex *engine.Texture, faces [6]image.Image, opts engine.TextureOptions) {
+	r.cubemaps[tex] = raster.NewCubemap(faces, rasterOptions(opts))
+}
+
+// NewTexture returns a texture only this renderer can sample, for materials
+// and skies of scenes drawn without OpenGL
+func (r *Renderer) NewTexture(img image.Image, opts engine.TextureOptions) *engine.Texture {
+	bounds := img.Bounds()
+	tex := &engine.Texture{
+		Width:      int32(bounds.Dx()),
+		Height:     int32(bounds.Dy()),
+		Layers:     1,
+		Levels:     1,
+		ColorSpace: opts.ColorSpace,
+		Sampler:    opts.Sampler,
+	}
+	r.SetTexture(tex, img, opts)
+	return tex
+}
+
+// NewCamera creates a camera with the image's aspect ratio, which unlike
+// engine.NewCamera needs no window
+func (r *Renderer) NewCamera(position, target, up mgl32.Vec3) *engine.Camera {
+	return &engine.Camera{
+		GameObject: &engine.GameObject{
+			Position: position,
+			Rotation: engine.QuatIdent,
+		},
+		Target:      target,
+		Up:          up,
+		Left:        up.Cross(target.Sub(position)).Normalize(),
+		AspectRatio: float32(r.Width) / float32(r.Height),
+		FovY:        45.0,
+	}
+}
+
+func (r *Renderer) BeginFrame(s *engine.Scene) {
+	r.lights = r.lights[:0]
+	for _, light := range s.Lights {
+		r.lights = append(r.lights, light.PointLight)
+	}
+	frame := raster.Frame{Lights: r.lights, Fog: s.Fog}
+	if s.Sky != nil {
+		frame.Sky = &raster.Sky{
+			Sky:     s.Sky.Sky,
+			Texture: r.textures[s.Sky.Texture],
+			Cubemap: r.cubemaps[s.Sky.Texture],
+		}
+	}
+	r.Renderer.BeginFrame(frame)
+}
+
+// Submit queues item, skipping items whose renderer only draws with OpenGL
+func (r *Renderer) Submit(item engine.DrawItem) {
+	if item.Mesh == nil || item.Material == nil {
+		return
+	}
+	d := raster.Draw{
+		Geometry: &item.Mesh.Geometry,
+		Model:    item.Model,
+		Material: &item.Material.Phong,
+		Texture:  r.textures[item.Material.Texture],
+	}
+	switch renderer := item.Renderer.(type) {
+	case nil:
+	case *pbr.MaterialRenderer:
+		d.PBR = &renderer.Material.PBR
+	default:
+		return
+	}
+	if item.Instances != nil {
+		d.Instances = make([]raster.Instance, len(item.Instances))
+		for i, instance := range item.Instances {
+			d.Instances[i] = raster.Instance{Model: instance.Model, Color: instance.Color}
+		}
+	}
+	r.Renderer.Submit(d)
+}
+
+func (r *Renderer) RenderView(camera *engine.Camera) error {
+	r.Render(camera.EyePosition(), camera.ViewMatrix(), camera.ProjectionMatrix())
+	return nil
+}
+
+func (r *Renderer) EndFrame() {}
+
+// SetTarget is ignored, framebuffers live in video memory. Views are always
+// drawn into Image.
+func (r *Renderer) SetTarget(target *engine.Framebuffer) {}
+
+// NewMaterial returns the values of engine.NewDefaultMaterial without its
+// shader, for scenes only this renderer draws
+func NewMaterial() engine.Material {
+	return engine.Material{Phong: scene.DefaultPhong()}
+}
+
+// LoadMeshes reads the objects of an OBJ file into meshes that are never
+// uploaded
+func LoadMeshes(path string) ([]*engine.Mesh, error) {
+	imported, err := engine.LdrParseObj(path)
+	if err != nil {
+		return nil, err
+	}
+	var meshes []*engine.Mesh
+	for _, object := range imported.Objects {
+		if object == nil || len(object.Indices) == 0 {
+			continue
+		}
+		meshes = append(meshes, engine.NewMeshData(object.CombinedVertex, object.Indices))
+	}
+	return meshes, nil
+}
@@ -0,0 +1,57 @@
+//go:build cgo
+
+package soft
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"image/color"
+	"physics/engine"
+	"physics/scene"
+	"testing"
+)
+
+// quad is a square facing -Z, two units across at the origin
+func quad() *engine.Mesh {
+	normal := mgl32.Vec3{0, 0, -1}
+	return engine.NewMeshData([]engine.CombinedVertex{
+		{Position: mgl32.Vec3{-1, -1, 0}, TexCoord: mgl32.Vec2{0, 0}, Normal: normal},
+		{Position: mgl32.Vec3{1, -1, 0}, TexCoord: mgl32.Vec2{1, 0}, Normal: normal},
+		{Position: mgl32.Vec3{1, 1, 0}, TexCoord: mgl32.Vec2{1, 1}, Normal: normal},
+		{Position: mgl32.Vec3{-1, 1, 0}, TexCoord: mgl32.Vec2{0, 1}, Normal: normal},
+	}, []uint32{0, 1, 2, 0, 2, 3})
+}
+
+// TestRendererDrawsScene draws a red textured quad under a gradient sky
+// through Scene.Render, the way the engine's renderers are driven
+func TestRendererDrawsScene(t *testing.T) {
+	const size = 32
+	r := NewRenderer(size, size)
+
+	red := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	red.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	material := NewMaterial()
+	material.Texture = r.NewTexture(red, engine.ColorTextureOptions())
+
+	s := &engine.Scene{Sky: &engine.Skybox{Sky: scene.GradientSky()}}
+	s.AddObject(&engine.GameObject{Rotation: engine.QuatIdent, Scale: 1, Mesh: quad(), Material: material})
+	s.AddLight(engine.NewPointLight(mgl32.Vec3{0, 0, -4}, mgl32.Vec3{1, 1, 1}, 20))
+	camera := r.NewCamera(mgl32.Vec3{0, 0, -5}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
+
+	r.BeginFrame(s)
+	if err := s.Render(r, camera); err != nil {
+		t.Fatal(err)
+	}
+	r.EndFrame()
+
+	center := r.Image.RGBAAt(size/2, size/2)
+	if center.R < 128 || center.G > 32 || center.B > 32 {
+		t.Errorf("quad drawn %v, want lit red", center)
+	}
+	// The quad covers less than half of the 45 degree view, the sky shows
+	// around it
+	corner := r.Image.RGBAAt(0, 0)
+	if corner.B <= corner.R {
+		t.Errorf("corner drawn %v, want the blue sky", corner)
+	}
+}